@@ -1,19 +1,27 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
-	"github.com/Joey-Boivin/sdisk/internal/models"
 	"gopkg.in/yaml.v3"
 )
 
+// version is reported to the server when connecting. Release builds set it
+// with -ldflags "-X main.version=...".
+var version = "dev"
+
 type ClientConfig struct {
-	Host       string `yaml:"host"`
-	Port       uint   `yaml:"port"`
-	FolderName string `yaml:"folderName"`
-	Token      string `yaml:"token"`
+	Host             string `yaml:"host"`
+	Port             uint   `yaml:"port"`
+	SocketPath       string `yaml:"socketPath"`
+	FolderName       string `yaml:"folderName"`
+	DeviceCredential string `yaml:"deviceCredential"`
+	LogLevel         string `yaml:"logLevel"`
+	LogFormat        string `yaml:"logFormat"`
 }
 
 func main() {
@@ -22,7 +30,7 @@ func main() {
 
 	file, err := os.Open(path)
 	if err != nil {
-		log.Fatalf("Error opening file: %v", err)
+		fatal("could not open configuration", "path", path, "error", err)
 	}
 
 	defer file.Close()
@@ -30,16 +38,53 @@ func main() {
 	var conf ClientConfig
 	decoder := yaml.NewDecoder(file)
 	if err := decoder.Decode(&conf); err != nil {
-		log.Fatalf("Error decoding yaml file: %v", err)
+		fatal("could not decode configuration", "path", path, "error", err)
 	}
 
-	userID, err := models.FromString(conf.Token)
-
+	logger, err := infrastructure.NewLogger(os.Stderr, conf.LogLevel, conf.LogFormat)
 	if err != nil {
-		panic(err)
+		fatal("invalid logging configuration", "error", err)
+	}
+	slog.SetDefault(logger)
+
+	if conf.DeviceCredential == "" {
+		fatal("missing device credential, register this device with POST /users/{id}/devices", "path", path)
+	}
+
+	clientConfig := infrastructure.NewDefaultTCPClientConfig(conf.DeviceCredential, conf.Host, conf.Port, conf.FolderName)
+	clientConfig.SetVersion(version)
+	if conf.SocketPath != "" {
+		clientConfig.SetUnixSocket(conf.SocketPath)
 	}
 
-	clientConfig := infrastructure.NewDefaultTCPClientConfig(userID, conf.Host, conf.Port, conf.FolderName)
 	client := infrastructure.NewTCPClient(clientConfig)
-	client.Run()
+
+	for {
+		err := client.Run()
+
+		var disconnected *infrastructure.ErrDisconnectedByPeer
+		if !errors.As(err, &disconnected) || !shouldReconnect(disconnected) {
+			fatal("disconnected from server", "error", err)
+		}
+
+		slog.Warn("disconnected from server, reconnecting", "retryAfter", disconnected.RetryAfter, "error", err)
+		time.Sleep(disconnected.RetryAfter)
+	}
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func shouldReconnect(err *infrastructure.ErrDisconnectedByPeer) bool {
+	switch err.Reason {
+	case infrastructure.DisconnectReasonServerBusy,
+		infrastructure.DisconnectReasonTooManyConnections,
+		infrastructure.DisconnectReasonIdleTimeout,
+		infrastructure.DisconnectReasonSessionExpired:
+		return true
+	}
+
+	return false
 }
@@ -1,24 +1,71 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/Joey-Boivin/sdisk/internal/application"
 	"github.com/Joey-Boivin/sdisk/internal/handlers"
 	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
 	"gopkg.in/yaml.v3"
 )
 
+// ADMIN_PASSWORD_FILE_NAME is the file under SDISK_ROOT the generated password
+// of the admin is written to, so it never goes through the logs.
+const ADMIN_PASSWORD_FILE_NAME = ".sdisk-admin-password"
+
 type ServerConfig struct {
-	Host         string `yaml:"apiHost"`
-	Port         uint   `yaml:"apiPort"`
-	DiskSize     uint   `yaml:"diskSizeMiB"`
-	RealTimeHost string `yaml:"realTimeHost"`
-	RealTimePort uint   `yaml:"realTimePort"`
-	RootFolder   string `yaml:"serverRootFolder"`
+	Host                  string       `yaml:"apiHost"`
+	Port                  uint         `yaml:"apiPort"`
+	DiskSize              uint         `yaml:"diskSizeMiB"`
+	RealTimeHost          string       `yaml:"realTimeHost"`
+	RealTimePort          uint         `yaml:"realTimePort"`
+	RealTimeSocket        string       `yaml:"realTimeSocket"`
+	RealTimeSocketMode    string       `yaml:"realTimeSocketMode"`
+	RootFolder            string       `yaml:"serverRootFolder"`
+	MaxConnections        uint         `yaml:"maxConnections"`
+	MaxConnectionsPerUser uint         `yaml:"maxConnectionsPerUser"`
+	IdleTimeout           int          `yaml:"idleTimeoutMinutes"`
+	MaxSessionAge         int          `yaml:"maxSessionAgeMinutes"`
+	UploadLimit           uint64       `yaml:"uploadLimitKiBps"`
+	DownloadLimit         uint64       `yaml:"downloadLimitKiBps"`
+	UserUploadLimit       uint64       `yaml:"userUploadLimitKiBps"`
+	UserDownloadLimit     uint64       `yaml:"userDownloadLimitKiBps"`
+	AdminEmail            string       `yaml:"adminEmail"`
+	AccessTokenLifetime   uint         `yaml:"accessTokenLifetimeMinutes"`
+	SessionLifetime       uint         `yaml:"sessionLifetimeMinutes"`
+	DeletionGracePeriod   uint         `yaml:"accountDeletionGraceMinutes"`
+	PasswordMinLength     int          `yaml:"passwordMinLength"`
+	PasswordMemoryKiB     uint32       `yaml:"passwordHashMemoryKiB"`
+	PasswordIterations    uint32       `yaml:"passwordHashIterations"`
+	PasswordParallelism   uint8        `yaml:"passwordHashParallelism"`
+	Mailer                string       `yaml:"mailer"`
+	MailFrom              string       `yaml:"mailFrom"`
+	SMTPHost              string       `yaml:"smtpHost"`
+	SMTPPort              uint         `yaml:"smtpPort"`
+	SMTPUsername          string       `yaml:"smtpUsername"`
+	SMTPRequireTLS        *bool        `yaml:"smtpRequireTLS"`
+	LogLevel              string       `yaml:"logLevel"`
+	LogFormat             string       `yaml:"logFormat"`
+	MaxConcurrentHooks    int          `yaml:"maxConcurrentHooks"`
+	Hooks                 []HookConfig `yaml:"hooks"`
+}
+
+type HookConfig struct {
+	Name               string   `yaml:"name"`
+	Event              string   `yaml:"event"`
+	Path               string   `yaml:"path"`
+	Command            []string `yaml:"command"`
+	Timeout            uint     `yaml:"timeoutSeconds"`
+	InheritEnvironment bool     `yaml:"inheritEnvironment"`
 }
 
 func main() {
@@ -27,7 +74,7 @@ func main() {
 
 	file, err := os.Open(path)
 	if err != nil {
-		log.Fatalf("Error opening file: %v", err)
+		fatal("could not open configuration", "path", path, "error", err)
 	}
 
 	defer file.Close()
@@ -35,29 +82,287 @@ func main() {
 	var conf ServerConfig
 	decoder := yaml.NewDecoder(file)
 	if err := decoder.Decode(&conf); err != nil {
-		log.Fatalf("Error decoding yaml file: %v", err)
+		fatal("could not decode configuration", "path", path, "error", err)
 	}
 
-	log.Printf("Server starting on %s:%d", conf.Host, conf.Port)
+	logger, err := infrastructure.NewLogger(os.Stderr, conf.LogLevel, conf.LogFormat)
+	if err != nil {
+		fatal("invalid logging configuration", "error", err)
+	}
+	slog.SetDefault(logger)
+
+	slog.Info("server starting", "api", fmt.Sprintf("%s:%d", conf.Host, conf.Port), "realTime", fmt.Sprintf("%s:%d", conf.RealTimeHost, conf.RealTimePort))
 
 	tcpserverconfig := infrastructure.NewDefaultTCPServerConfig(conf.RealTimeHost, conf.RealTimePort)
+	tcpserverconfig.SetConnectionLimits(conf.MaxConnections, conf.MaxConnectionsPerUser)
+	tcpserverconfig.SetSessionLimits(time.Duration(conf.IdleTimeout)*time.Minute, time.Duration(conf.MaxSessionAge)*time.Minute)
+	tcpserverconfig.SetBandwidthLimits(models.BandwidthLimits{
+		Upload:       conf.UploadLimit * 1024,
+		Download:     conf.DownloadLimit * 1024,
+		UserUpload:   conf.UserUploadLimit * 1024,
+		UserDownload: conf.UserDownloadLimit * 1024,
+	})
+	if conf.RealTimeSocket != "" {
+		mode, err := parseSocketMode(conf.RealTimeSocketMode)
+		if err != nil {
+			fatal("invalid realTimeSocketMode", "mode", conf.RealTimeSocketMode, "error", err)
+		}
+
+		tcpserverconfig.SetUnixSocket(conf.RealTimeSocket, mode)
+	}
+
+	journal := infrastructure.NewFileJournal(filepath.Join(os.Getenv("SDISK_ROOT"), infrastructure.JOURNAL_DIRECTORY_NAME))
+	tcpserverconfig.SetChangeJournal(journal)
+
+	metrics := infrastructure.NewMetrics()
+	tcpserverconfig.SetMetrics(metrics)
+
+	eventBus := infrastructure.NewEventBus()
+	tcpserverconfig.SetEventPublisher(eventBus)
+
+	webhooksPath := filepath.Join(os.Getenv("SDISK_ROOT"), infrastructure.WEBHOOKS_DIRECTORY_NAME)
+	webhookRepository, err := infrastructure.NewFileWebhookRepository(filepath.Join(webhooksPath, infrastructure.WEBHOOKS_FILE_NAME))
+	if err != nil {
+		fatal("could not load webhooks", "error", err)
+	}
+
+	webhookDispatcher := infrastructure.NewWebhookDispatcher(infrastructure.NewDefaultWebhookDispatcherConfig(webhookRepository, webhooksPath))
+	eventBus.Forward(webhookDispatcher)
+	go webhookDispatcher.Run()
+
+	hookRunner, err := infrastructure.NewExecHookRunner(toExecHooks(conf.Hooks), os.Getenv("SDISK_ROOT"), conf.MaxConcurrentHooks)
+	if err != nil {
+		fatal("could not load hooks", "error", err)
+	}
+
+	eventBus.Forward(hookRunner)
+	go hookRunner.Run()
+
 	s := infrastructure.NewTCPServer(tcpserverconfig)
-	go s.Run()
+
+	hasherConfig := infrastructure.NewDefaultArgon2idConfig()
+	hasherConfig.SetParameters(conf.PasswordMemoryKiB, conf.PasswordIterations, conf.PasswordParallelism)
+	hasher := infrastructure.NewArgon2idHasher(hasherConfig)
+	passwordPolicy := models.NewDefaultPasswordPolicy()
+	passwordPolicy.SetLengths(conf.PasswordMinLength, 0)
+
+	mailer, err := newMailer(conf)
+	if err != nil {
+		fatal("invalid mailer configuration", "mailer", conf.Mailer, "error", err)
+	}
 
 	userRepository := infrastructure.NewRamRepository()
-	registerService := application.NewRegisterService(userRepository)
+	tokenRepository := infrastructure.NewRamTokenRepository()
+	verificationService := application.NewEmailVerificationService(userRepository, tokenRepository, mailer)
+	registerService := application.NewRegisterService(userRepository, hasher, passwordPolicy, eventBus, verificationService)
+	authenticationService := application.NewAuthenticationService(userRepository, hasher)
+	sessionService := application.NewSessionService(userRepository, authenticationService, infrastructure.NewRamSessionRepository(), s, time.Duration(conf.AccessTokenLifetime)*time.Minute, time.Duration(conf.SessionLifetime)*time.Minute)
+	passwordResetService := application.NewPasswordResetService(userRepository, tokenRepository, mailer, hasher, passwordPolicy, sessionService)
+	deviceService := application.NewDeviceService(userRepository, infrastructure.NewRamDeviceRepository(), s)
+	updateUserService := application.NewUpdateUserService(userRepository, tokenRepository, hasher, passwordPolicy, sessionService, verificationService)
+	accountDeletionService := application.NewAccountDeletionService(userRepository, tokenRepository, sessionService, deviceService, s, eventBus, time.Duration(conf.DeletionGracePeriod)*time.Minute)
+	s.SetTokenVerifier(sessionService)
+	s.SetDeviceAuthenticator(deviceService)
+	go func() {
+		err := s.Run()
+		fatal("could not start real-time server", "address", fmt.Sprintf("%s:%d", conf.RealTimeHost, conf.RealTimePort), "error", err)
+	}()
+	go purgeDeletedAccounts(accountDeletionService)
+	roleService := application.NewRoleService(userRepository)
+	bootstrapService := application.NewBootstrapService(userRepository, hasher, passwordPolicy)
+	generatedPassword, err := bootstrapService.BootstrapAdmin(conf.AdminEmail, os.Getenv("SDISK_ADMIN_PASSWORD"))
+	var noAdmin *application.ErrNoAdmin
+	if errors.As(err, &noAdmin) {
+		slog.Warn("there is no admin, set adminEmail to create one", "error", err)
+	} else if err != nil {
+		fatal("could not bootstrap the admin account", "email", conf.AdminEmail, "error", err)
+	}
+	if generatedPassword != "" {
+		passwordPath, err := writeAdminPassword(generatedPassword)
+		if err != nil {
+			fatal("could not save the generated admin password", "email", conf.AdminEmail, "error", err)
+		}
+
+		slog.Warn("created the admin account with a generated password, change it after signing in and delete the file", "email", conf.AdminEmail, "path", passwordPath)
+	}
+
 	fetchUserService := application.NewFetchUserService(userRepository)
 	createDiskService := application.NewCreateDiskService(userRepository, uint64(conf.DiskSize), s)
+	disconnectUserService := application.NewDisconnectUserService(userRepository, s)
+	bandwidthService := application.NewBandwidthService(s)
+	realTimeService := application.NewRealTimeService(s)
+	changesService := application.NewChangesService(userRepository, journal)
+	eventsService := application.NewEventsService(userRepository, eventBus)
+	webhookService := application.NewWebhookService(userRepository, webhookRepository, webhookDispatcher)
 	userResource := handlers.NewUserHandler(registerService, fetchUserService, createDiskService)
+	connectionResource := handlers.NewConnectionHandler(disconnectUserService)
+	bandwidthResource := handlers.NewBandwidthHandler(bandwidthService)
+	realTimeResource := handlers.NewRealTimeHandler(realTimeService)
+	changesResource := handlers.NewChangesHandler(changesService)
+	eventsResource := handlers.NewEventsHandler(eventsService)
+	webhookResource := handlers.NewWebhookHandler(webhookService)
+	loginThrottle := application.NewLoginThrottle(application.DEFAULT_LOGIN_MAX_FAILURES, application.DEFAULT_LOGIN_FAILURE_WINDOW_MS*time.Millisecond)
+	sessionResource := handlers.NewSessionHandler(sessionService, loginThrottle)
+	verificationResource := handlers.NewVerificationHandler(verificationService)
+	resetThrottle := application.NewLoginThrottle(application.DEFAULT_RESET_MAX_REQUESTS, application.DEFAULT_RESET_REQUEST_WINDOW_MS*time.Millisecond)
+	passwordResetResource := handlers.NewPasswordResetHandler(passwordResetService, resetThrottle)
+	deviceResource := handlers.NewDeviceHandler(deviceService)
+	accountResource := handlers.NewAccountHandler(updateUserService, accountDeletionService)
+	roleResource := handlers.NewRoleHandler(roleService)
 	pingResource := handlers.NewPingHandler()
+	metricsResource := handlers.NewMetricsHandler(metrics)
+	authenticator := handlers.NewAuthenticator(sessionService)
 
 	router := http.NewServeMux()
-	router.HandleFunc(handlers.PingEndpoint, pingResource.Ping)
-	router.HandleFunc(handlers.CreateUserEndpoint, userResource.CreateUserResource)
-	router.HandleFunc(handlers.GetUserEndpoint, userResource.GetUserResource)
-	router.HandleFunc(handlers.CreateDiskEndpoint, userResource.CreateDiskResource)
+	handle := func(pattern string, handler http.HandlerFunc) {
+		router.HandleFunc(pattern, handlers.Instrument(metrics, pattern, handler))
+	}
+
+	handle(handlers.PingEndpoint, pingResource.Ping)
+	handle(handlers.CreateUserEndpoint, userResource.CreateUserResource)
+	handle(handlers.CreateSessionEndpoint, sessionResource.CreateSessionResource)
+	handle(handlers.RefreshSessionEndpoint, sessionResource.RefreshSessionResource)
+	handle(handlers.VerifyEmailEndpoint, verificationResource.VerifyEmailResource)
+	handle(handlers.RequestPasswordResetEndpoint, passwordResetResource.RequestPasswordResetResource)
+	handle(handlers.ResetPasswordEndpoint, passwordResetResource.ResetPasswordResource)
+	handle(handlers.SendVerificationEndpoint, authenticator.RequireOwner(verificationResource.SendVerificationResource))
+	handle(handlers.ListSessionsEndpoint, authenticator.RequireOwner(sessionResource.ListSessionsResource))
+	handle(handlers.RevokeSessionsEndpoint, authenticator.RequireOwner(sessionResource.RevokeSessionsResource))
+	handle(handlers.RevokeSessionEndpoint, authenticator.RequireOwner(sessionResource.RevokeSessionResource))
+	handle(handlers.RegisterDeviceEndpoint, authenticator.RequireOwner(deviceResource.RegisterDeviceResource))
+	handle(handlers.ListDevicesEndpoint, authenticator.RequireOwner(deviceResource.ListDevicesResource))
+	handle(handlers.RevokeDeviceEndpoint, authenticator.RequireOwner(deviceResource.RevokeDeviceResource))
+	handle(handlers.GetUserEndpoint, authenticator.RequireOwner(userResource.GetUserResource))
+	handle(handlers.UpdateUserEndpoint, authenticator.RequireOwner(accountResource.UpdateUserResource))
+	handle(handlers.DeleteUserEndpoint, authenticator.RequireOwner(accountResource.DeleteUserResource))
+	handle(handlers.CreateDiskEndpoint, authenticator.RequireOwner(userResource.CreateDiskResource))
+	handle(handlers.DisconnectUserEndpoint, authenticator.RequireOwner(connectionResource.DisconnectUserResource))
+	handle(handlers.GetChangesEndpoint, authenticator.RequireOwner(changesResource.GetChangesResource))
+	handle(handlers.GetEventsEndpoint, authenticator.RequireOwner(eventsResource.GetEventsResource))
+	handle(handlers.ListUsersEndpoint, authenticator.RequireRole(models.RoleAdmin, roleResource.ListUsersResource))
+	handle(handlers.SetRoleEndpoint, authenticator.RequireRole(models.RoleAdmin, roleResource.SetRoleResource))
+	handle(handlers.RestoreUserEndpoint, authenticator.RequireRole(models.RoleAdmin, accountResource.RestoreUserResource))
+	handle(handlers.GetBandwidthEndpoint, authenticator.RequireRole(models.RoleAdmin, bandwidthResource.GetBandwidthResource))
+	handle(handlers.SetBandwidthEndpoint, authenticator.RequireRole(models.RoleAdmin, bandwidthResource.SetBandwidthResource))
+	handle(handlers.CreateWebhookEndpoint, authenticator.RequireRole(models.RoleAdmin, webhookResource.CreateWebhookResource))
+	handle(handlers.ListWebhooksEndpoint, authenticator.RequireRole(models.RoleAdmin, webhookResource.ListWebhooksResource))
+	handle(handlers.GetWebhookEndpoint, authenticator.RequireRole(models.RoleAdmin, webhookResource.GetWebhookResource))
+	handle(handlers.DeleteWebhookEndpoint, authenticator.RequireRole(models.RoleAdmin, webhookResource.DeleteWebhookResource))
+	handle(handlers.ListWebhookDeliveriesEndpoint, authenticator.RequireRole(models.RoleAdmin, webhookResource.ListWebhookDeliveriesResource))
+	handle(handlers.MetricsEndpoint, authenticator.RequireRole(models.RoleAdmin, metricsResource.MetricsResource))
+	handle(handlers.RealTimeEndpoint, realTimeResource.RealTimeResource)
 
 	if err := http.ListenAndServe(fmt.Sprintf("%s:%d", conf.Host, conf.Port), router); err != nil {
-		log.Fatalf("Error trying to start server on %s:%d. %v", conf.Host, conf.Port, err)
+		fatal("could not start API server", "address", fmt.Sprintf("%s:%d", conf.Host, conf.Port), "error", err)
+	}
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// writeAdminPassword writes password to a file only the server user may read
+// and returns its path.
+func writeAdminPassword(password string) (string, error) {
+	path := filepath.Join(os.Getenv("SDISK_ROOT"), ADMIN_PASSWORD_FILE_NAME)
+	err := os.MkdirAll(filepath.Dir(path), 0777)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = file.WriteString(password + "\n")
+	closeErr := file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return path, closeErr
+}
+
+// purgeDeletedAccounts purges the accounts whose grace period is over, until
+// the process exits.
+func purgeDeletedAccounts(accountDeletionService *application.AccountDeletionService) {
+	ticker := time.NewTicker(application.DEFAULT_ACCOUNT_PURGE_INTERVAL_MS * time.Millisecond)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		purged, err := accountDeletionService.PurgeExpired(now)
+		if err != nil {
+			slog.Error("could not purge deleted accounts", "error", err)
+		}
+
+		if purged > 0 {
+			slog.Info("purged deleted accounts", "count", purged)
+		}
+	}
+}
+
+// newMailer returns the mailer named in the configuration. Mail goes to the
+// outbox under the server root unless an SMTP relay is configured.
+func newMailer(conf ServerConfig) (ports.Mailer, error) {
+	switch conf.Mailer {
+	case "", "outbox":
+		return infrastructure.NewOutboxMailer(filepath.Join(os.Getenv("SDISK_ROOT"), infrastructure.OUTBOX_DIRECTORY_NAME), conf.MailFrom), nil
+
+	case "smtp":
+		if conf.SMTPHost == "" {
+			return nil, errors.New("smtpHost is required")
+		}
+
+		config := infrastructure.NewDefaultSMTPMailerConfig(conf.SMTPHost, conf.SMTPPort, conf.MailFrom)
+		if conf.SMTPUsername != "" {
+			config.SetCredentials(conf.SMTPUsername, os.Getenv("SDISK_SMTP_PASSWORD"))
+		}
+
+		if conf.SMTPRequireTLS != nil {
+			config.SetRequireTLS(*conf.SMTPRequireTLS)
+		}
+
+		return infrastructure.NewSMTPMailer(config), nil
+
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", conf.Mailer)
+	}
+}
+
+func toExecHooks(hooks []HookConfig) []infrastructure.ExecHook {
+	execHooks := make([]infrastructure.ExecHook, 0, len(hooks))
+	for i, hook := range hooks {
+		name := hook.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		execHooks = append(execHooks, infrastructure.ExecHook{
+			Name:               name,
+			Event:              models.EventType(hook.Event),
+			PathPattern:        hook.Path,
+			Command:            hook.Command,
+			Timeout:            time.Duration(hook.Timeout) * time.Second,
+			InheritEnvironment: hook.InheritEnvironment,
+		})
 	}
+
+	return execHooks
+}
+
+// parseSocketMode reads an octal permission such as "0660". Only the owner and
+// group of the server may use the socket when no mode is given.
+func parseSocketMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0660, nil
+	}
+
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	return os.FileMode(parsed).Perm(), nil
 }
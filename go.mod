@@ -3,7 +3,14 @@ module github.com/Joey-Boivin/sdisk
 go 1.22.5
 
 require (
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/smallnest/ringbuffer v0.0.0-20241129171057-356c688ba81d // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/google/uuid v1.6.0
+	github.com/smallnest/ringbuffer v0.0.0-20241129171057-356c688ba81d
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/net v0.35.0
+
+require (
+	golang.org/x/crypto v0.33.0
+	golang.org/x/sys v0.30.0 // indirect
 )
@@ -0,0 +1,178 @@
+package application
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+const (
+	DEFAULT_ACCOUNT_DELETION_GRACE_PERIOD_MS = 7 * 24 * 60 * 60 * 1000
+	DEFAULT_ACCOUNT_PURGE_INTERVAL_MS        = 10 * 60 * 1000
+)
+
+type AccountDeletionService struct {
+	userRepository  ports.UserRepository
+	tokenRepository ports.OneTimeTokenRepository
+	sessionService  *SessionService
+	deviceService   *DeviceService
+	realTimeServer  ports.RealTimeServer
+	eventStream     ports.EventStream
+	gracePeriod     time.Duration
+}
+
+// NewAccountDeletionService returns a service deleting accounts, which are
+// purged once they stayed deleted for gracePeriod. A zero grace period keeps
+// the default.
+func NewAccountDeletionService(userRepository ports.UserRepository, tokenRepository ports.OneTimeTokenRepository, sessionService *SessionService, deviceService *DeviceService, realTimeServer ports.RealTimeServer, eventStream ports.EventStream, gracePeriod time.Duration) *AccountDeletionService {
+	if gracePeriod <= 0 {
+		gracePeriod = DEFAULT_ACCOUNT_DELETION_GRACE_PERIOD_MS * time.Millisecond
+	}
+
+	return &AccountDeletionService{
+		userRepository:  userRepository,
+		tokenRepository: tokenRepository,
+		sessionService:  sessionService,
+		deviceService:   deviceService,
+		realTimeServer:  realTimeServer,
+		eventStream:     eventStream,
+		gracePeriod:     gracePeriod,
+	}
+}
+
+// DeleteAccount deletes the account of a user right away: their sessions,
+// devices and mailed tokens are revoked and their real-time connections are
+// dropped. Their data is kept until the account is purged, and the email
+// address cannot be registered again until then.
+func (a *AccountDeletionService) DeleteAccount(id string) (*models.User, error) {
+	userID, err := models.FromString(id)
+	if err != nil {
+		return nil, err
+	}
+
+	user := a.userRepository.GetByID(userID)
+	if user == nil || user.IsDeleted() {
+		return nil, &ErrUserDoesNotExist{}
+	}
+
+	if user.IsAdmin() && countAdmins(a.userRepository) == 1 {
+		return nil, &ErrLastAdmin{}
+	}
+
+	user.MarkDeleted(time.Now().UTC())
+	err = a.userRepository.SaveUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.revokeCredentials(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, a.realTimeServer.DisconnectUser(user)
+}
+
+// RestoreAccount cancels the deletion of an account that was not purged yet.
+// The user signs in again and registers their devices again.
+func (a *AccountDeletionService) RestoreAccount(id string) (*models.User, error) {
+	userID, err := models.FromString(id)
+	if err != nil {
+		return nil, err
+	}
+
+	user := a.userRepository.GetByID(userID)
+	if user == nil {
+		return nil, &ErrUserDoesNotExist{}
+	}
+
+	if !user.IsDeleted() {
+		return nil, &ErrAccountNotDeleted{}
+	}
+
+	user.Restore()
+	err = a.userRepository.SaveUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// PurgeAt returns when a deleted account is purged.
+func (a *AccountDeletionService) PurgeAt(user *models.User) time.Time {
+	return user.GetDeletedAt().Add(a.gracePeriod)
+}
+
+// PurgeExpired purges the accounts deleted for longer than the grace period
+// at now, and returns how many were. Their disk, journal, tokens and events are
+// removed along with them.
+func (a *AccountDeletionService) PurgeExpired(now time.Time) (int, error) {
+	purged := 0
+	var errs []error
+
+	for _, user := range a.userRepository.ListUsers() {
+		if !user.IsDeleted() || now.Before(a.PurgeAt(user)) {
+			continue
+		}
+
+		err := a.purge(user)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		purged++
+	}
+
+	return purged, errors.Join(errs...)
+}
+
+func (a *AccountDeletionService) purge(user *models.User) error {
+	err := a.revokeCredentials(user)
+	if err != nil {
+		return err
+	}
+
+	err = a.realTimeServer.DeleteDisk(user)
+	if err != nil {
+		return err
+	}
+
+	a.userRepository.DeleteUser(user.GetID())
+	a.eventStream.Forget(user.GetID())
+	return nil
+}
+
+func (a *AccountDeletionService) revokeCredentials(user *models.User) error {
+	userID := user.GetID()
+	id := userID.ToString()
+
+	for _, purpose := range []models.TokenPurpose{models.TokenPurposeVerifyEmail, models.TokenPurposeResetPassword} {
+		err := a.tokenRepository.DeleteTokens(userID, purpose)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := a.sessionService.RevokeAll(id)
+	if err != nil {
+		return err
+	}
+
+	devices, err := a.deviceService.ListDevices(id)
+	if err != nil {
+		return err
+	}
+
+	for _, device := range devices {
+		err := a.deviceService.RevokeDevice(id, device.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
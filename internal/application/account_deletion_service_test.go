@@ -0,0 +1,190 @@
+package application_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestAccountDeletionService(t *testing.T) {
+	anyUserPassword := "correct horse"
+	anyGracePeriod := 24 * time.Hour
+	newUserRepo := func(users ...*models.User) *mocks.UserRepositoryMock {
+		return &mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+			for _, user := range users {
+				if user.GetID() == id {
+					return user
+				}
+			}
+			return nil
+		}, FnGetUserByEmail: func(email string) *models.User {
+			for _, user := range users {
+				if user.GetEmail() == email {
+					return user
+				}
+			}
+			return nil
+		}, FnListUsers: func() []*models.User {
+			return users
+		}}
+	}
+	newService := func(userRepo *mocks.UserRepositoryMock, deviceRepo *mocks.DeviceRepositoryMock, tokenRepo *mocks.OneTimeTokenRepositoryMock, serverSpy *mocks.ServerMock) (*application.AccountDeletionService, *application.SessionService) {
+		authenticationService := application.NewAuthenticationService(userRepo, &mocks.PasswordHasherMock{})
+		sessionService := application.NewSessionService(userRepo, authenticationService, newSessionStore(), serverSpy, time.Minute, time.Hour)
+		deviceService := application.NewDeviceService(userRepo, deviceRepo, serverSpy)
+		return application.NewAccountDeletionService(userRepo, tokenRepo, sessionService, deviceService, serverSpy, &mocks.EventStreamMock{}, anyGracePeriod), sessionService
+	}
+
+	t.Run("MarkUserDeletedAndRevokeSessions", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		userRepoSpy := newUserRepo(user)
+		serverSpy := mocks.ServerMock{}
+		deletionService, sessionService := newService(userRepoSpy, &mocks.DeviceRepositoryMock{}, newTokenStore(), &serverSpy)
+		tokens, _ := sessionService.Login(user.GetEmail(), anyUserPassword)
+
+		deleted, err := deletionService.DeleteAccount(idOf(user))
+		_, _, authErr := sessionService.Authenticate(tokens.AccessToken)
+
+		assertNoError(t, err)
+		assertTrue(t, deleted.IsDeleted())
+		assertTrue(t, userRepoSpy.SaveUserCalled)
+		assertTrue(t, serverSpy.DropSessionCalled)
+		assertTrue(t, serverSpy.DisconnectUserCalled)
+		assertFalse(t, serverSpy.DeleteDiskCalled)
+		assertInvalidToken(t, authErr)
+	})
+
+	t.Run("RevokeDevicesOfDeletedUser", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		device := models.Device{ID: "laptop", UserID: user.GetID()}
+		deviceRepoSpy := mocks.DeviceRepositoryMock{FnListDevices: func(userID models.UserID) []*models.Device {
+			return []*models.Device{&device}
+		}, FnGetDevice: func(id string) *models.Device {
+			return &device
+		}}
+		serverSpy := mocks.ServerMock{}
+		deletionService, _ := newService(newUserRepo(user), &deviceRepoSpy, newTokenStore(), &serverSpy)
+
+		_, err := deletionService.DeleteAccount(idOf(user))
+
+		assertNoError(t, err)
+		assertStringEquals(t, device.ID, deviceRepoSpy.DeleteDeviceCalledWith)
+		assertStringEquals(t, device.ID, serverSpy.DropDeviceCalledWith)
+	})
+
+	t.Run("RejectLoginOfDeletedUser", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		deletionService, sessionService := newService(newUserRepo(user), &mocks.DeviceRepositoryMock{}, newTokenStore(), &mocks.ServerMock{})
+		_, _ = deletionService.DeleteAccount(idOf(user))
+
+		_, err := sessionService.Login(user.GetEmail(), anyUserPassword)
+
+		assertInvalidCredentials(t, err)
+	})
+
+	t.Run("ReturnErrUserDoesNotExistForDeletedUser", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		user.MarkDeleted(time.Now())
+		deletionService, _ := newService(newUserRepo(user), &mocks.DeviceRepositoryMock{}, newTokenStore(), &mocks.ServerMock{})
+
+		_, err := deletionService.DeleteAccount(idOf(user))
+
+		if _, ok := err.(*application.ErrUserDoesNotExist); !ok {
+			t.Fatalf("Expected ErrUserDoesNotExist, got %v", err)
+		}
+	})
+
+	t.Run("ReturnErrLastAdminWhenDeletingLastAdmin", func(t *testing.T) {
+		admin := models.NewUser("admin@test.com", "hash")
+		admin.SetRole(models.RoleAdmin)
+		deletionService, _ := newService(newUserRepo(admin), &mocks.DeviceRepositoryMock{}, newTokenStore(), &mocks.ServerMock{})
+
+		_, err := deletionService.DeleteAccount(idOf(admin))
+
+		if _, ok := err.(*application.ErrLastAdmin); !ok {
+			t.Fatalf("Expected ErrLastAdmin, got %v", err)
+		}
+		assertFalse(t, admin.IsDeleted())
+	})
+
+	t.Run("RestoreDeletedUser", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		deletionService, sessionService := newService(newUserRepo(user), &mocks.DeviceRepositoryMock{}, newTokenStore(), &mocks.ServerMock{})
+		_, _ = deletionService.DeleteAccount(idOf(user))
+
+		_, err := deletionService.RestoreAccount(idOf(user))
+		_, loginErr := sessionService.Login(user.GetEmail(), anyUserPassword)
+
+		assertNoError(t, err)
+		assertFalse(t, user.IsDeleted())
+		assertNoError(t, loginErr)
+	})
+
+	t.Run("ReturnErrAccountNotDeletedWhenRestoringActiveUser", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hash")
+		deletionService, _ := newService(newUserRepo(user), &mocks.DeviceRepositoryMock{}, newTokenStore(), &mocks.ServerMock{})
+
+		_, err := deletionService.RestoreAccount(idOf(user))
+
+		if _, ok := err.(*application.ErrAccountNotDeleted); !ok {
+			t.Fatalf("Expected ErrAccountNotDeleted, got %v", err)
+		}
+	})
+
+	t.Run("PurgeAccountsAfterGracePeriod", func(t *testing.T) {
+		deletedAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+		expired := models.NewUser("expired@test.com", "hash")
+		expired.MarkDeleted(deletedAt)
+		recent := models.NewUser("recent@test.com", "hash")
+		recent.MarkDeleted(deletedAt.Add(time.Hour))
+		active := models.NewUser("active@test.com", "hash")
+		userRepoSpy := newUserRepo(expired, recent, active)
+		tokenRepoSpy := newTokenStore()
+		serverSpy := mocks.ServerMock{}
+		eventStreamSpy := mocks.EventStreamMock{}
+		sessionService := application.NewSessionService(userRepoSpy, application.NewAuthenticationService(userRepoSpy, &mocks.PasswordHasherMock{}), newSessionStore(), &serverSpy, time.Minute, time.Hour)
+		deviceService := application.NewDeviceService(userRepoSpy, &mocks.DeviceRepositoryMock{}, &serverSpy)
+		deletionService := application.NewAccountDeletionService(userRepoSpy, tokenRepoSpy, sessionService, deviceService, &serverSpy, &eventStreamSpy, anyGracePeriod)
+
+		purged, err := deletionService.PurgeExpired(deletedAt.Add(anyGracePeriod))
+
+		assertNoError(t, err)
+		assertTrue(t, purged == 1)
+		assertTrue(t, serverSpy.DeleteDiskCalledWith == expired)
+		assertTrue(t, userRepoSpy.DeleteUserCalledWith == expired.GetID())
+		assertTrue(t, tokenRepoSpy.DeleteTokensCalled)
+		assertTrue(t, eventStreamSpy.ForgetCalledWith == expired.GetID())
+	})
+
+	t.Run("KeepUserWhenDiskCannotBeDeleted", func(t *testing.T) {
+		deletedAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+		user := models.NewUser("john_doe@test.com", "hash")
+		user.MarkDeleted(deletedAt)
+		userRepoSpy := newUserRepo(user)
+		serverSpy := mocks.ServerMock{FnDeleteDisk: func(u *models.User) error {
+			return errors.New("read-only file system")
+		}}
+		deletionService, _ := newService(userRepoSpy, &mocks.DeviceRepositoryMock{}, newTokenStore(), &serverSpy)
+
+		purged, err := deletionService.PurgeExpired(deletedAt.Add(anyGracePeriod))
+
+		assertError(t, err)
+		assertTrue(t, purged == 0)
+		assertFalse(t, userRepoSpy.DeleteUserCalled)
+	})
+
+	t.Run("PurgeAtEndOfGracePeriod", func(t *testing.T) {
+		deletedAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+		user := models.NewUser("john_doe@test.com", "hash")
+		user.MarkDeleted(deletedAt)
+		deletionService, _ := newService(newUserRepo(user), &mocks.DeviceRepositoryMock{}, newTokenStore(), &mocks.ServerMock{})
+
+		purgeAt := deletionService.PurgeAt(user)
+
+		assertTrue(t, purgeAt.Equal(deletedAt.Add(anyGracePeriod)))
+	})
+}
@@ -0,0 +1,53 @@
+package application
+
+import (
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+type AuthenticationService struct {
+	userRepository ports.UserRepository
+	hasher         ports.PasswordHasher
+}
+
+func NewAuthenticationService(userRepository ports.UserRepository, hasher ports.PasswordHasher) *AuthenticationService {
+	return &AuthenticationService{
+		userRepository: userRepository,
+		hasher:         hasher,
+	}
+}
+
+// Authenticate returns the user signing in with email and password. The
+// password hash is replaced when it was made with outdated parameters. An
+// unknown email takes as long to reject as a wrong password.
+func (authenticationService *AuthenticationService) Authenticate(email string, password string) (*models.User, error) {
+	user := authenticationService.userRepository.GetByEmail(models.NormalizeEmail(email))
+
+	if user == nil || user.IsDeleted() {
+		_, err := authenticationService.hasher.Hash(password)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, &ErrInvalidCredentials{}
+	}
+
+	ok, needsRehash, err := authenticationService.hasher.Verify(password, user.GetPasswordHash())
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, &ErrInvalidCredentials{}
+	}
+
+	if needsRehash {
+		passwordHash, err := authenticationService.hasher.Hash(password)
+		if err == nil {
+			user.SetPasswordHash(passwordHash)
+			_ = authenticationService.userRepository.SaveUser(user)
+		}
+	}
+
+	return user, nil
+}
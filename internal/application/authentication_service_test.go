@@ -0,0 +1,97 @@
+package application_test
+
+import (
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestAuthenticationService(t *testing.T) {
+	userInRepoEmail := "john_doe@test.com"
+	anyUserPassword := "correct horse"
+
+	newUserRepo := func(user *models.User) *mocks.UserRepositoryMock {
+		return &mocks.UserRepositoryMock{FnGetUserByEmail: func(email string) *models.User {
+			if user != nil && email == user.GetEmail() {
+				return user
+			}
+			return nil
+		}}
+	}
+
+	t.Run("ReturnUserIfPasswordMatches", func(t *testing.T) {
+		userInRepo := models.NewUser(userInRepoEmail, "hashed:"+anyUserPassword)
+		authenticationService := application.NewAuthenticationService(newUserRepo(userInRepo), &mocks.PasswordHasherMock{})
+
+		user, err := authenticationService.Authenticate(userInRepoEmail, anyUserPassword)
+
+		assertNoError(t, err)
+		assertTrue(t, user == userInRepo)
+	})
+
+	t.Run("IgnoreCaseAndSpacesAroundEmail", func(t *testing.T) {
+		userInRepo := models.NewUser(userInRepoEmail, "hashed:"+anyUserPassword)
+		authenticationService := application.NewAuthenticationService(newUserRepo(userInRepo), &mocks.PasswordHasherMock{})
+
+		user, err := authenticationService.Authenticate(" John_Doe@Test.com ", anyUserPassword)
+
+		assertNoError(t, err)
+		assertTrue(t, user == userInRepo)
+	})
+
+	t.Run("ReturnErrInvalidCredentialsIfPasswordDoesNotMatch", func(t *testing.T) {
+		userInRepo := models.NewUser(userInRepoEmail, "hashed:"+anyUserPassword)
+		authenticationService := application.NewAuthenticationService(newUserRepo(userInRepo), &mocks.PasswordHasherMock{})
+
+		_, err := authenticationService.Authenticate(userInRepoEmail, "wrong password")
+
+		assertInvalidCredentials(t, err)
+	})
+
+	t.Run("ReturnErrInvalidCredentialsIfUserDoesNotExist", func(t *testing.T) {
+		hasherSpy := mocks.PasswordHasherMock{}
+		authenticationService := application.NewAuthenticationService(newUserRepo(nil), &hasherSpy)
+
+		_, err := authenticationService.Authenticate(userInRepoEmail, anyUserPassword)
+
+		assertInvalidCredentials(t, err)
+		assertTrue(t, hasherSpy.HashCalled)
+	})
+
+	t.Run("RehashPasswordMadeWithOutdatedParameters", func(t *testing.T) {
+		userInRepo := models.NewUser(userInRepoEmail, "outdated")
+		userRepoSpy := newUserRepo(userInRepo)
+		hasherStub := mocks.PasswordHasherMock{FnVerify: func(password string, encodedHash string) (bool, bool, error) {
+			return true, true, nil
+		}}
+		authenticationService := application.NewAuthenticationService(userRepoSpy, &hasherStub)
+
+		_, err := authenticationService.Authenticate(userInRepoEmail, anyUserPassword)
+
+		assertNoError(t, err)
+		assertStringEquals(t, "hashed:"+anyUserPassword, userInRepo.GetPasswordHash())
+		assertTrue(t, userRepoSpy.SaveUserCalled)
+	})
+
+	t.Run("KeepPasswordHashMadeWithCurrentParameters", func(t *testing.T) {
+		userInRepo := models.NewUser(userInRepoEmail, "hashed:"+anyUserPassword)
+		userRepoSpy := newUserRepo(userInRepo)
+		hasherSpy := mocks.PasswordHasherMock{}
+		authenticationService := application.NewAuthenticationService(userRepoSpy, &hasherSpy)
+
+		_, _ = authenticationService.Authenticate(userInRepoEmail, anyUserPassword)
+
+		assertFalse(t, hasherSpy.HashCalled)
+		assertFalse(t, userRepoSpy.SaveUserCalled)
+	})
+}
+
+func assertInvalidCredentials(t *testing.T, err error) {
+	t.Helper()
+
+	if _, ok := err.(*application.ErrInvalidCredentials); !ok {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+package application
+
+import (
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+type BandwidthService struct {
+	realTimeServer ports.RealTimeServer
+}
+
+func NewBandwidthService(server ports.RealTimeServer) *BandwidthService {
+	return &BandwidthService{
+		realTimeServer: server,
+	}
+}
+
+func (b *BandwidthService) GetStats() models.BandwidthStats {
+	return b.realTimeServer.GetBandwidthStats()
+}
+
+func (b *BandwidthService) SetLimits(limits models.BandwidthLimits) error {
+	return b.realTimeServer.SetBandwidthLimits(limits)
+}
@@ -0,0 +1,35 @@
+package application_test
+
+import (
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestBandwidthService(t *testing.T) {
+	anyLimits := models.BandwidthLimits{Upload: 1024, Download: 2048, UserUpload: 512, UserDownload: 256}
+
+	t.Run("ServerReceivesNewLimits", func(t *testing.T) {
+		serverSpy := mocks.ServerMock{}
+		service := application.NewBandwidthService(&serverSpy)
+
+		err := service.SetLimits(anyLimits)
+
+		assertNoError(t, err)
+		assertTrue(t, serverSpy.SetBandwidthLimitsCalledWith == anyLimits)
+	})
+
+	t.Run("ReturnStatsOfServer", func(t *testing.T) {
+		serverMock := mocks.ServerMock{FnGetBandwidthStats: func() models.BandwidthStats {
+			return models.BandwidthStats{Limits: anyLimits}
+		}}
+		service := application.NewBandwidthService(&serverMock)
+
+		stats := service.GetStats()
+
+		assertTrue(t, serverMock.GetBandwidthStatsCalled)
+		assertTrue(t, stats.Limits == anyLimits)
+	})
+}
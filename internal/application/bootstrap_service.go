@@ -0,0 +1,73 @@
+package application
+
+import (
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+type BootstrapService struct {
+	userRepository ports.UserRepository
+	hasher         ports.PasswordHasher
+	passwordPolicy *models.PasswordPolicy
+}
+
+func NewBootstrapService(userRepository ports.UserRepository, hasher ports.PasswordHasher, passwordPolicy *models.PasswordPolicy) *BootstrapService {
+	return &BootstrapService{
+		userRepository: userRepository,
+		hasher:         hasher,
+		passwordPolicy: passwordPolicy,
+	}
+}
+
+// BootstrapAdmin makes sure the server has an admin. When there is none, the
+// user with email becomes one, or is created with password. A password is
+// generated and returned when none is given, so it can be shown once. The
+// address of a created admin is trusted, as it comes from the operator.
+func (b *BootstrapService) BootstrapAdmin(email string, password string) (string, error) {
+	for _, user := range b.userRepository.ListUsers() {
+		if user.IsAdmin() {
+			return "", nil
+		}
+	}
+
+	email = models.NormalizeEmail(email)
+	if email == "" {
+		return "", &ErrNoAdmin{}
+	}
+
+	user := b.userRepository.GetByEmail(email)
+	if user != nil {
+		user.SetRole(models.RoleAdmin)
+		return "", b.userRepository.SaveUser(user)
+	}
+
+	generatedPassword := ""
+	if password == "" {
+		var err error
+		generatedPassword, err = generateToken()
+		if err != nil {
+			return "", err
+		}
+		password = generatedPassword
+	}
+
+	err := b.passwordPolicy.Check(email, password)
+	if err != nil {
+		return "", err
+	}
+
+	passwordHash, err := b.hasher.Hash(password)
+	if err != nil {
+		return "", err
+	}
+
+	user = models.NewUser(email, passwordHash)
+	user.SetRole(models.RoleAdmin)
+	user.SetEmailVerified(true)
+	err = b.userRepository.SaveUser(user)
+	if err != nil {
+		return "", err
+	}
+
+	return generatedPassword, nil
+}
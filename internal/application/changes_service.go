@@ -0,0 +1,53 @@
+package application
+
+import (
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+type ChangesService struct {
+	userRepository ports.UserRepository
+	journal        ports.ChangeJournal
+}
+
+type ChangesPage struct {
+	Changes []models.Change
+	Cursor  uint64
+	More    bool
+}
+
+func NewChangesService(userRepository ports.UserRepository, journal ports.ChangeJournal) *ChangesService {
+	return &ChangesService{
+		userRepository: userRepository,
+		journal:        journal,
+	}
+}
+
+// GetChanges lists at most limit changes made to the disk of the user after
+// since. Cursor is the one to resume from to get the next changes.
+func (c *ChangesService) GetChanges(id string, since uint64, limit int) (ChangesPage, error) {
+	userID, err := models.FromString(id)
+
+	if err != nil {
+		return ChangesPage{}, err
+	}
+
+	user := c.userRepository.GetByID(userID)
+	if user == nil {
+		return ChangesPage{}, &ErrUserDoesNotExist{}
+	}
+
+	changes, cursor, err := c.journal.Since(userID, since)
+	if err != nil {
+		return ChangesPage{}, err
+	}
+
+	page := ChangesPage{Changes: changes, Cursor: cursor}
+	if limit > 0 && len(changes) > limit {
+		page.Changes = changes[:limit]
+		page.Cursor = changes[limit-1].Seq
+		page.More = true
+	}
+
+	return page, nil
+}
@@ -0,0 +1,68 @@
+package application_test
+
+import (
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestGetChanges(t *testing.T) {
+	userInRepository := models.NewUser("John_doe@test.com", "12345")
+	idOfUserInRepository := userInRepository.GetID()
+
+	repoWithoutUserMock := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+		return nil
+	}}
+
+	repoWithUserMock := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+		return userInRepository
+	}}
+
+	journalWithChangesMock := mocks.ChangeJournalMock{FnSince: func(userID models.UserID, cursor uint64) ([]models.Change, uint64, error) {
+		return []models.Change{{Seq: 2}, {Seq: 3}, {Seq: 4}}, 4, nil
+	}}
+
+	t.Run("ReturnErrUserDoesNotExist", func(t *testing.T) {
+		journalSpy := mocks.ChangeJournalMock{}
+		service := application.NewChangesService(&repoWithoutUserMock, &journalSpy)
+
+		_, err := service.GetChanges(idOfUserInRepository.ToString(), 0, 10)
+
+		if _, ok := err.(*application.ErrUserDoesNotExist); !ok {
+			t.Fatalf("Expected ErrUserDoesNotExist, got %v", err)
+		}
+		assertFalse(t, journalSpy.SinceCalled)
+	})
+
+	t.Run("ListChangesSinceCursor", func(t *testing.T) {
+		journalSpy := mocks.ChangeJournalMock{}
+		service := application.NewChangesService(&repoWithUserMock, &journalSpy)
+
+		_, err := service.GetChanges(idOfUserInRepository.ToString(), 7, 10)
+
+		assertNoError(t, err)
+		assertEquals(t, journalSpy.SinceCalledWith, 7)
+	})
+
+	t.Run("ReturnWholePageWithLastCursor", func(t *testing.T) {
+		service := application.NewChangesService(&repoWithUserMock, &journalWithChangesMock)
+
+		page, _ := service.GetChanges(idOfUserInRepository.ToString(), 1, 10)
+
+		assertEquals(t, uint64(len(page.Changes)), 3)
+		assertEquals(t, page.Cursor, 4)
+		assertFalse(t, page.More)
+	})
+
+	t.Run("ResumeFromLastChangeOfTruncatedPage", func(t *testing.T) {
+		service := application.NewChangesService(&repoWithUserMock, &journalWithChangesMock)
+
+		page, _ := service.GetChanges(idOfUserInRepository.ToString(), 1, 2)
+
+		assertEquals(t, uint64(len(page.Changes)), 2)
+		assertEquals(t, page.Cursor, 3)
+		assertTrue(t, page.More)
+	})
+}
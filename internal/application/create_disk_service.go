@@ -19,6 +19,7 @@ func NewCreateDiskService(userRepository ports.UserRepository, sizeInMib uint64,
 	}
 }
 
+// CreateDisk gives a disk to a user whose email address is verified.
 func (c *CreateDiskService) CreateDisk(id string) error {
 	userID, err := models.FromString(id)
 
@@ -27,15 +28,24 @@ func (c *CreateDiskService) CreateDisk(id string) error {
 	}
 
 	u := c.userRepository.GetByID(userID)
-	if u == nil {
+	if u == nil || u.IsDeleted() {
 		return &ErrUserDoesNotExist{}
 	}
 
+	if !u.IsEmailVerified() {
+		return &ErrEmailNotVerified{}
+	}
+
 	d := models.NewDisk(c.sizeInMiB)
 	err = u.AddDisk(d)
 	if err != nil {
 		return err
 	}
 
+	err = c.userRepository.SaveUser(u)
+	if err != nil {
+		return err
+	}
+
 	return c.realTimeServer.PrepareDisk(d, u)
 }
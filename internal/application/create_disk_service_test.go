@@ -15,6 +15,7 @@ func TestCreateDisk(t *testing.T) {
 	userInRepoEmail := "John_doe@test.com"
 	anyUserPassword := "12345"
 	userInRepository := models.NewUser(userInRepoEmail, anyUserPassword)
+	userInRepository.SetEmailVerified(true)
 	idOfUserInRepository := userInRepository.GetID()
 
 	repoWithoutUserMock := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
@@ -63,6 +64,7 @@ func TestCreateDisk(t *testing.T) {
 
 	t.Run("ReturnServerFailureError", func(t *testing.T) {
 		userInRepository = models.NewUser(userInRepoEmail, anyUserPassword)
+		userInRepository.SetEmailVerified(true)
 		service := application.NewCreateDiskService(&repoWithUserMock, uint64(anySizeInMiB), &serverMockThatFails)
 
 		err := service.CreateDisk(idOfUserInRepository.ToString())
@@ -72,12 +74,26 @@ func TestCreateDisk(t *testing.T) {
 
 	t.Run("ServerPreparesDisk", func(t *testing.T) {
 		userInRepository = models.NewUser(userInRepoEmail, anyUserPassword)
+		userInRepository.SetEmailVerified(true)
 		service := application.NewCreateDiskService(&repoWithUserMock, uint64(anySizeInMiB), &serverMockDummy)
 
 		_ = service.CreateDisk(idOfUserInRepository.ToString())
 
 		assertTrue(t, serverMockDummy.PrepareDiskCalled)
 	})
+
+	t.Run("ReturnErrEmailNotVerified", func(t *testing.T) {
+		userInRepository = models.NewUser(userInRepoEmail, anyUserPassword)
+		serverSpy := mocks.ServerMock{}
+		service := application.NewCreateDiskService(&repoWithUserMock, uint64(anySizeInMiB), &serverSpy)
+
+		err := service.CreateDisk(idOfUserInRepository.ToString())
+
+		if _, ok := err.(*application.ErrEmailNotVerified); !ok {
+			t.Fatalf("Expected ErrEmailNotVerified, got %v", err)
+		}
+		assertFalse(t, serverSpy.PrepareDiskCalled)
+	})
 }
 
 func assertEquals(t *testing.T, got uint64, want uint64) {
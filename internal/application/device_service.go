@@ -0,0 +1,146 @@
+package application
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+	"github.com/google/uuid"
+)
+
+const (
+	DEVICE_NAME_MAX_LENGTH    = 64
+	DEVICE_VERSION_MAX_LENGTH = 64
+)
+
+type DeviceService struct {
+	userRepository   ports.UserRepository
+	deviceRepository ports.DeviceRepository
+	realTimeServer   ports.RealTimeServer
+}
+
+func NewDeviceService(userRepository ports.UserRepository, deviceRepository ports.DeviceRepository, realTimeServer ports.RealTimeServer) *DeviceService {
+	return &DeviceService{
+		userRepository:   userRepository,
+		deviceRepository: deviceRepository,
+		realTimeServer:   realTimeServer,
+	}
+}
+
+// RegisterDevice registers a device named name for a user and returns the
+// credential it connects with. The credential cannot be read again.
+func (d *DeviceService) RegisterDevice(id string, name string) (string, *models.Device, error) {
+	userID, err := models.FromString(id)
+	if err != nil {
+		return "", nil, err
+	}
+
+	user := d.userRepository.GetByID(userID)
+	if user == nil || user.IsDeleted() {
+		return "", nil, &ErrUserDoesNotExist{}
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > DEVICE_NAME_MAX_LENGTH {
+		return "", nil, &ErrInvalidDevice{Reason: "name must have between 1 and 64 characters"}
+	}
+
+	credential, err := generateToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	device := models.Device{
+		ID:             uuid.NewString(),
+		UserID:         userID,
+		Name:           name,
+		CredentialHash: hashToken(credential),
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	err = d.deviceRepository.SaveDevice(&device)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return credential, &device, nil
+}
+
+// ListDevices returns the devices of a user, oldest first.
+func (d *DeviceService) ListDevices(id string) ([]*models.Device, error) {
+	userID, err := models.FromString(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.userRepository.GetByID(userID) == nil {
+		return nil, &ErrUserDoesNotExist{}
+	}
+
+	return d.deviceRepository.ListDevices(userID), nil
+}
+
+// RevokeDevice forgets a device of a user. Its credential stops working and
+// its real-time connections are dropped.
+func (d *DeviceService) RevokeDevice(id string, deviceID string) error {
+	userID, err := models.FromString(id)
+	if err != nil {
+		return err
+	}
+
+	device := d.deviceRepository.GetDevice(deviceID)
+	if device == nil || device.UserID != userID {
+		return &ErrDeviceDoesNotExist{ID: deviceID}
+	}
+
+	err = d.deviceRepository.DeleteDevice(deviceID)
+	if err != nil {
+		return err
+	}
+
+	return d.realTimeServer.DropDevice(deviceID)
+}
+
+// AuthenticateDevice returns the device whose credential is credential, after
+// recording that it was seen running version.
+func (d *DeviceService) AuthenticateDevice(credential string, version string) (*models.Device, error) {
+	if credential == "" {
+		return nil, &ErrInvalidDeviceCredential{}
+	}
+
+	device := d.deviceRepository.GetDeviceByCredentialHash(hashToken(credential))
+	if device == nil {
+		return nil, &ErrInvalidDeviceCredential{}
+	}
+
+	user := d.userRepository.GetByID(device.UserID)
+	if user == nil || user.IsDeleted() {
+		return nil, &ErrInvalidDeviceCredential{}
+	}
+
+	if utf8.RuneCountInString(version) > DEVICE_VERSION_MAX_LENGTH {
+		version = string([]rune(version)[:DEVICE_VERSION_MAX_LENGTH])
+	}
+
+	device.Version = version
+	device.LastSeenAt = time.Now().UTC()
+	err := d.deviceRepository.SaveDevice(device)
+	if err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+// DeviceSeen records that a device was seen now, as when it disconnects.
+func (d *DeviceService) DeviceSeen(deviceID string) error {
+	device := d.deviceRepository.GetDevice(deviceID)
+	if device == nil {
+		return &ErrDeviceDoesNotExist{ID: deviceID}
+	}
+
+	device.LastSeenAt = time.Now().UTC()
+	return d.deviceRepository.SaveDevice(device)
+}
@@ -0,0 +1,118 @@
+package application_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestDeviceService(t *testing.T) {
+	userInRepo := models.NewUser("John_doe@test.com", "hashed:correct horse")
+	userInRepoMock := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+		if id == userInRepo.GetID() {
+			return userInRepo
+		}
+		return nil
+	}}
+	anyDeviceName := "laptop"
+
+	t.Run("SaveDeviceWithHashOfCredential", func(t *testing.T) {
+		deviceRepoSpy := mocks.DeviceRepositoryMock{}
+		deviceService := application.NewDeviceService(&userInRepoMock, &deviceRepoSpy, &mocks.ServerMock{})
+
+		credential, device, err := deviceService.RegisterDevice(idOf(userInRepo), anyDeviceName)
+
+		assertNoError(t, err)
+		assertTrue(t, deviceRepoSpy.SaveDeviceCalled)
+		assertTrue(t, device.UserID == userInRepo.GetID())
+		assertStringEquals(t, anyDeviceName, device.Name)
+		assertFalse(t, credential == "")
+		assertFalse(t, device.CredentialHash == credential)
+	})
+
+	t.Run("ReturnErrInvalidDeviceForBadName", func(t *testing.T) {
+		for _, name := range []string{"", "   ", strings.Repeat("a", application.DEVICE_NAME_MAX_LENGTH+1)} {
+			deviceService := application.NewDeviceService(&userInRepoMock, &mocks.DeviceRepositoryMock{}, &mocks.ServerMock{})
+
+			_, _, err := deviceService.RegisterDevice(idOf(userInRepo), name)
+
+			if _, ok := err.(*application.ErrInvalidDevice); !ok {
+				t.Fatalf("Expected ErrInvalidDevice for %q, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("ReturnErrUserDoesNotExistForUnknownUser", func(t *testing.T) {
+		deviceService := application.NewDeviceService(&userInRepoMock, &mocks.DeviceRepositoryMock{}, &mocks.ServerMock{})
+		unknownUserID := models.NewUserID()
+
+		_, _, err := deviceService.RegisterDevice(unknownUserID.ToString(), anyDeviceName)
+
+		if _, ok := err.(*application.ErrUserDoesNotExist); !ok {
+			t.Fatalf("Expected ErrUserDoesNotExist, got %v", err)
+		}
+	})
+
+	t.Run("AuthenticateDeviceAndRecordVersion", func(t *testing.T) {
+		deviceRepo := mocks.DeviceRepositoryMock{}
+		deviceService := application.NewDeviceService(&userInRepoMock, &deviceRepo, &mocks.ServerMock{})
+		credential, device, _ := deviceService.RegisterDevice(idOf(userInRepo), anyDeviceName)
+		deviceRepo.FnGetDeviceByCredentialHash = func(credentialHash string) *models.Device {
+			if credentialHash == device.CredentialHash {
+				found := *device
+				return &found
+			}
+			return nil
+		}
+
+		authenticated, err := deviceService.AuthenticateDevice(credential, "1.2.3")
+
+		assertNoError(t, err)
+		assertTrue(t, authenticated.ID == device.ID)
+		assertStringEquals(t, "1.2.3", deviceRepo.SaveDeviceCalledWith.Version)
+		assertFalse(t, deviceRepo.SaveDeviceCalledWith.LastSeenAt.IsZero())
+	})
+
+	t.Run("ReturnErrInvalidDeviceCredentialForUnknownCredential", func(t *testing.T) {
+		deviceService := application.NewDeviceService(&userInRepoMock, &mocks.DeviceRepositoryMock{}, &mocks.ServerMock{})
+
+		_, err := deviceService.AuthenticateDevice("unknown", "1.2.3")
+
+		if _, ok := err.(*application.ErrInvalidDeviceCredential); !ok {
+			t.Fatalf("Expected ErrInvalidDeviceCredential, got %v", err)
+		}
+	})
+
+	t.Run("RevokeDeviceAndDropItsConnections", func(t *testing.T) {
+		device := models.Device{ID: "device", UserID: userInRepo.GetID()}
+		deviceRepoSpy := mocks.DeviceRepositoryMock{FnGetDevice: func(id string) *models.Device {
+			return &device
+		}}
+		serverSpy := mocks.ServerMock{}
+		deviceService := application.NewDeviceService(&userInRepoMock, &deviceRepoSpy, &serverSpy)
+
+		err := deviceService.RevokeDevice(idOf(userInRepo), device.ID)
+
+		assertNoError(t, err)
+		assertStringEquals(t, device.ID, deviceRepoSpy.DeleteDeviceCalledWith)
+		assertStringEquals(t, device.ID, serverSpy.DropDeviceCalledWith)
+	})
+
+	t.Run("ReturnErrDeviceDoesNotExistForDeviceOfOtherUser", func(t *testing.T) {
+		device := models.Device{ID: "device", UserID: models.NewUserID()}
+		deviceRepoSpy := mocks.DeviceRepositoryMock{FnGetDevice: func(id string) *models.Device {
+			return &device
+		}}
+		deviceService := application.NewDeviceService(&userInRepoMock, &deviceRepoSpy, &mocks.ServerMock{})
+
+		err := deviceService.RevokeDevice(idOf(userInRepo), device.ID)
+
+		if _, ok := err.(*application.ErrDeviceDoesNotExist); !ok {
+			t.Fatalf("Expected ErrDeviceDoesNotExist, got %v", err)
+		}
+		assertFalse(t, deviceRepoSpy.DeleteDeviceCalled)
+	})
+}
@@ -0,0 +1,33 @@
+package application
+
+import (
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+type DisconnectUserService struct {
+	userRepository ports.UserRepository
+	realTimeServer ports.RealTimeServer
+}
+
+func NewDisconnectUserService(userRepository ports.UserRepository, server ports.RealTimeServer) *DisconnectUserService {
+	return &DisconnectUserService{
+		userRepository: userRepository,
+		realTimeServer: server,
+	}
+}
+
+func (d *DisconnectUserService) DisconnectUser(id string) error {
+	userID, err := models.FromString(id)
+
+	if err != nil {
+		return err
+	}
+
+	u := d.userRepository.GetByID(userID)
+	if u == nil {
+		return &ErrUserDoesNotExist{}
+	}
+
+	return d.realTimeServer.DisconnectUser(u)
+}
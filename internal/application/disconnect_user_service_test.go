@@ -0,0 +1,66 @@
+package application_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestDisconnectUser(t *testing.T) {
+	userInRepoEmail := "John_doe@test.com"
+	anyUserPassword := "12345"
+	userInRepository := models.NewUser(userInRepoEmail, anyUserPassword)
+	idOfUserInRepository := userInRepository.GetID()
+
+	repoWithoutUserMock := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+		return nil
+	}}
+
+	repoWithUserMock := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+		return userInRepository
+	}}
+
+	t.Run("ReturnErrorIfIDIsInvalid", func(t *testing.T) {
+		serverSpy := mocks.ServerMock{}
+		service := application.NewDisconnectUserService(&repoWithUserMock, &serverSpy)
+
+		err := service.DisconnectUser("not an id")
+
+		assertError(t, err)
+		assertFalse(t, serverSpy.DisconnectUserCalled)
+	})
+
+	t.Run("ReturnErrUserDoesNotExist", func(t *testing.T) {
+		serverSpy := mocks.ServerMock{}
+		service := application.NewDisconnectUserService(&repoWithoutUserMock, &serverSpy)
+
+		err := service.DisconnectUser(idOfUserInRepository.ToString())
+
+		assertError(t, err)
+		assertFalse(t, serverSpy.DisconnectUserCalled)
+	})
+
+	t.Run("ServerDisconnectsUser", func(t *testing.T) {
+		serverSpy := mocks.ServerMock{}
+		service := application.NewDisconnectUserService(&repoWithUserMock, &serverSpy)
+
+		err := service.DisconnectUser(idOfUserInRepository.ToString())
+
+		assertNoError(t, err)
+		assertTrue(t, serverSpy.DisconnectUserCalledWith == userInRepository)
+	})
+
+	t.Run("ReturnServerFailureError", func(t *testing.T) {
+		serverMockThatFails := mocks.ServerMock{FnDisconnectUser: func(u *models.User) error {
+			return errors.New("server failed to disconnect user")
+		}}
+		service := application.NewDisconnectUserService(&repoWithUserMock, &serverMockThatFails)
+
+		err := service.DisconnectUser(idOfUserInRepository.ToString())
+
+		assertError(t, err)
+	})
+}
@@ -0,0 +1,128 @@
+package application
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+const DEFAULT_VERIFICATION_TOKEN_LIFETIME_MS = 48 * 60 * 60 * 1000
+
+type EmailVerificationService struct {
+	userRepository  ports.UserRepository
+	tokenRepository ports.OneTimeTokenRepository
+	mailer          ports.Mailer
+	lifetime        time.Duration
+}
+
+func NewEmailVerificationService(userRepository ports.UserRepository, tokenRepository ports.OneTimeTokenRepository, mailer ports.Mailer) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepository:  userRepository,
+		tokenRepository: tokenRepository,
+		mailer:          mailer,
+		lifetime:        DEFAULT_VERIFICATION_TOKEN_LIFETIME_MS * time.Millisecond,
+	}
+}
+
+// SendVerification mails a user the token verifying their email address. The
+// tokens sent before stop working.
+func (v *EmailVerificationService) SendVerification(id string) error {
+	userID, err := models.FromString(id)
+	if err != nil {
+		return err
+	}
+
+	user := v.userRepository.GetByID(userID)
+	if user == nil || user.IsDeleted() {
+		return &ErrUserDoesNotExist{}
+	}
+
+	if user.IsEmailVerified() {
+		return &ErrEmailAlreadyVerified{}
+	}
+
+	return v.sendVerification(user)
+}
+
+func (v *EmailVerificationService) sendVerification(user *models.User) error {
+	token, expiresAt, err := issueOneTimeToken(v.tokenRepository, user.GetID(), models.TokenPurposeVerifyEmail, v.lifetime)
+	if err != nil {
+		return err
+	}
+
+	return v.mailer.Send(models.Mail{
+		To:      user.GetEmail(),
+		Subject: "Verify your sdisk email address",
+		Body: fmt.Sprintf("Send this token to POST /verifications to verify your email address:\n\n%s\n\nIt expires on %s.\n",
+			token, expiresAt.Format(time.RFC1123)),
+	})
+}
+
+// Verify marks the email address of the user the token was mailed to as
+// verified.
+func (v *EmailVerificationService) Verify(token string) error {
+	oneTimeToken, err := redeemOneTimeToken(v.tokenRepository, token, models.TokenPurposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+
+	user := v.userRepository.GetByID(oneTimeToken.UserID)
+	if user == nil || user.IsDeleted() {
+		return &ErrInvalidToken{}
+	}
+
+	user.SetEmailVerified(true)
+	return v.userRepository.SaveUser(user)
+}
+
+// issueOneTimeToken saves a new token for purpose, replacing the ones the user
+// was given for it before.
+func issueOneTimeToken(tokenRepository ports.OneTimeTokenRepository, userID models.UserID, purpose models.TokenPurpose, lifetime time.Duration) (string, time.Time, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	err = tokenRepository.DeleteTokens(userID, purpose)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now().UTC()
+	oneTimeToken := models.OneTimeToken{
+		Hash:      hashToken(token),
+		UserID:    userID,
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(lifetime),
+	}
+
+	err = tokenRepository.SaveToken(&oneTimeToken)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, oneTimeToken.ExpiresAt, nil
+}
+
+// redeemOneTimeToken returns the token issued for purpose and forgets every
+// token of its user for that purpose, so it cannot be used again.
+func redeemOneTimeToken(tokenRepository ports.OneTimeTokenRepository, token string, purpose models.TokenPurpose) (*models.OneTimeToken, error) {
+	if token == "" {
+		return nil, &ErrInvalidToken{}
+	}
+
+	oneTimeToken := tokenRepository.GetToken(hashToken(token))
+	if oneTimeToken == nil || oneTimeToken.Purpose != purpose || oneTimeToken.Expired(time.Now()) {
+		return nil, &ErrInvalidToken{}
+	}
+
+	err := tokenRepository.DeleteTokens(oneTimeToken.UserID, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	return oneTimeToken, nil
+}
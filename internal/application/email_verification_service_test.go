@@ -0,0 +1,141 @@
+package application_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestEmailVerificationService(t *testing.T) {
+	userInRepoEmail := "John_doe@test.com"
+	anyUserPassword := "correct horse"
+	userInRepo := models.NewUser(userInRepoEmail, anyUserPassword)
+	userInRepoMock := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+		return userInRepo
+	}}
+	repoWithoutUserMock := mocks.UserRepositoryMock{}
+
+	t.Run("MailTokenToUser", func(t *testing.T) {
+		userInRepo.SetEmailVerified(false)
+		tokenRepo := newTokenStore()
+		mailerSpy := mocks.MailerMock{}
+		verificationService := application.NewEmailVerificationService(&userInRepoMock, tokenRepo, &mailerSpy)
+
+		err := verificationService.SendVerification(idOf(userInRepo))
+
+		assertNoError(t, err)
+		assertStringEquals(t, userInRepoEmail, mailerSpy.SendCalledWith.To)
+		assertFalse(t, tokenRepo.SaveTokenCalledWith.Hash == tokenIn(mailerSpy.SendCalledWith))
+	})
+
+	t.Run("ReturnErrUserDoesNotExist", func(t *testing.T) {
+		verificationService := application.NewEmailVerificationService(&repoWithoutUserMock, newTokenStore(), &mocks.MailerMock{})
+
+		err := verificationService.SendVerification(idOf(userInRepo))
+
+		if _, ok := err.(*application.ErrUserDoesNotExist); !ok {
+			t.Fatalf("Expected ErrUserDoesNotExist, got %v", err)
+		}
+	})
+
+	t.Run("ReturnErrEmailAlreadyVerified", func(t *testing.T) {
+		userInRepo.SetEmailVerified(true)
+		mailerSpy := mocks.MailerMock{}
+		verificationService := application.NewEmailVerificationService(&userInRepoMock, newTokenStore(), &mailerSpy)
+
+		err := verificationService.SendVerification(idOf(userInRepo))
+
+		if _, ok := err.(*application.ErrEmailAlreadyVerified); !ok {
+			t.Fatalf("Expected ErrEmailAlreadyVerified, got %v", err)
+		}
+		assertFalse(t, mailerSpy.SendCalled)
+	})
+
+	t.Run("VerifyEmailWithMailedToken", func(t *testing.T) {
+		userInRepo.SetEmailVerified(false)
+		mailerSpy := mocks.MailerMock{}
+		verificationService := application.NewEmailVerificationService(&userInRepoMock, newTokenStore(), &mailerSpy)
+		_ = verificationService.SendVerification(idOf(userInRepo))
+
+		err := verificationService.Verify(tokenIn(mailerSpy.SendCalledWith))
+
+		assertNoError(t, err)
+		assertTrue(t, userInRepo.IsEmailVerified())
+	})
+
+	t.Run("ReturnErrInvalidTokenIfTokenIsUsedTwice", func(t *testing.T) {
+		userInRepo.SetEmailVerified(false)
+		mailerSpy := mocks.MailerMock{}
+		verificationService := application.NewEmailVerificationService(&userInRepoMock, newTokenStore(), &mailerSpy)
+		_ = verificationService.SendVerification(idOf(userInRepo))
+		token := tokenIn(mailerSpy.SendCalledWith)
+		_ = verificationService.Verify(token)
+
+		err := verificationService.Verify(token)
+
+		assertInvalidToken(t, err)
+	})
+
+	t.Run("ReturnErrInvalidTokenIfTokenWasReplaced", func(t *testing.T) {
+		userInRepo.SetEmailVerified(false)
+		mailerSpy := mocks.MailerMock{}
+		verificationService := application.NewEmailVerificationService(&userInRepoMock, newTokenStore(), &mailerSpy)
+		_ = verificationService.SendVerification(idOf(userInRepo))
+		firstToken := tokenIn(mailerSpy.SendCalledWith)
+		_ = verificationService.SendVerification(idOf(userInRepo))
+
+		err := verificationService.Verify(firstToken)
+
+		assertInvalidToken(t, err)
+		assertFalse(t, userInRepo.IsEmailVerified())
+	})
+
+	t.Run("ReturnErrInvalidTokenIfTokenIsUnknown", func(t *testing.T) {
+		verificationService := application.NewEmailVerificationService(&userInRepoMock, newTokenStore(), &mocks.MailerMock{})
+
+		err := verificationService.Verify("unknown")
+
+		assertInvalidToken(t, err)
+	})
+}
+
+// newTokenStore returns a one-time token repository mock keeping the tokens
+// it is given, so mailed tokens can be redeemed.
+func newTokenStore() *mocks.OneTimeTokenRepositoryMock {
+	tokens := make(map[string]models.OneTimeToken)
+
+	return &mocks.OneTimeTokenRepositoryMock{
+		FnSaveToken: func(t *models.OneTimeToken) error {
+			tokens[t.Hash] = *t
+			return nil
+		},
+		FnGetToken: func(hash string) *models.OneTimeToken {
+			t, ok := tokens[hash]
+			if !ok {
+				return nil
+			}
+			return &t
+		},
+		FnDeleteTokens: func(userID models.UserID, purpose models.TokenPurpose) error {
+			for hash, t := range tokens {
+				if t.UserID == userID && t.Purpose == purpose {
+					delete(tokens, hash)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// tokenIn returns the token of a mail, which has a paragraph of its own.
+func tokenIn(mail models.Mail) string {
+	paragraphs := strings.Split(mail.Body, "\n\n")
+	if len(paragraphs) < 2 {
+		return ""
+	}
+
+	return paragraphs[1]
+}
@@ -2,6 +2,8 @@ package application
 
 import (
 	"fmt"
+	"strings"
+	"time"
 )
 
 type ErrUserAlreadyExists struct {
@@ -19,3 +21,158 @@ type ErrUserDoesNotExist struct {
 func (e *ErrUserDoesNotExist) Error() string {
 	return fmt.Sprintf("user with email %s does not exist", e.Email)
 }
+
+type ErrWebhookDoesNotExist struct {
+	ID string
+}
+
+func (e *ErrWebhookDoesNotExist) Error() string {
+	return fmt.Sprintf("webhook %s does not exist", e.ID)
+}
+
+type ErrInvalidWebhook struct {
+	Reason string
+}
+
+func (e *ErrInvalidWebhook) Error() string {
+	return fmt.Sprintf("invalid webhook: %s", e.Reason)
+}
+
+type ErrInvalidCredentials struct {
+}
+
+func (e *ErrInvalidCredentials) Error() string {
+	return "invalid email or password"
+}
+
+// ErrTooManyAttempts means logins were refused for failing too often. They
+// are allowed again after RetryAfter.
+type ErrTooManyAttempts struct {
+	RetryAfter time.Duration
+}
+
+func (e *ErrTooManyAttempts) Error() string {
+	return fmt.Sprintf("too many failed logins, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+type ErrIncorrectPassword struct {
+}
+
+func (e *ErrIncorrectPassword) Error() string {
+	return "the current password is incorrect"
+}
+
+type ErrInvalidToken struct {
+}
+
+func (e *ErrInvalidToken) Error() string {
+	return "token is invalid or expired"
+}
+
+type ErrLastAdmin struct {
+}
+
+func (e *ErrLastAdmin) Error() string {
+	return "the last admin cannot lose the admin role"
+}
+
+type ErrAccountNotDeleted struct {
+}
+
+func (e *ErrAccountNotDeleted) Error() string {
+	return "the account is not waiting to be purged"
+}
+
+type ErrNoAdmin struct {
+}
+
+func (e *ErrNoAdmin) Error() string {
+	return "there is no admin and no email address to create one with"
+}
+
+type ErrSessionDoesNotExist struct {
+	ID string
+}
+
+func (e *ErrSessionDoesNotExist) Error() string {
+	return fmt.Sprintf("session %s does not exist", e.ID)
+}
+
+type ErrInvalidDevice struct {
+	Reason string
+}
+
+func (e *ErrInvalidDevice) Error() string {
+	return fmt.Sprintf("invalid device: %s", e.Reason)
+}
+
+type ErrDeviceDoesNotExist struct {
+	ID string
+}
+
+func (e *ErrDeviceDoesNotExist) Error() string {
+	return fmt.Sprintf("device %s does not exist", e.ID)
+}
+
+type ErrInvalidDeviceCredential struct {
+}
+
+func (e *ErrInvalidDeviceCredential) Error() string {
+	return "device credential is invalid or revoked"
+}
+
+type ErrEmailNotVerified struct {
+}
+
+func (e *ErrEmailNotVerified) Error() string {
+	return "the email address of the user must be verified first"
+}
+
+type ErrEmailAlreadyVerified struct {
+}
+
+func (e *ErrEmailAlreadyVerified) Error() string {
+	return "the email address of the user is already verified"
+}
+
+// ErrVerificationNotSent is returned once a user is registered but the mail
+// to verify their address could not be sent. It can be sent again later.
+type ErrVerificationNotSent struct {
+	Err error
+}
+
+func (e *ErrVerificationNotSent) Error() string {
+	return fmt.Sprintf("could not send the verification mail: %v", e.Err)
+}
+
+func (e *ErrVerificationNotSent) Unwrap() error {
+	return e.Err
+}
+
+// FieldError is the reason the value of a field was rejected.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+type ErrFieldRequired struct {
+}
+
+func (e *ErrFieldRequired) Error() string {
+	return "the field is required"
+}
+
+// ErrInvalidFields lists every rejected field of a request, so they can all be
+// fixed at once.
+type ErrInvalidFields struct {
+	Fields []FieldError
+}
+
+func (e *ErrInvalidFields) Error() string {
+	reasons := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		reasons = append(reasons, fmt.Sprintf("%s: %v", field.Field, field.Err))
+	}
+
+	return fmt.Sprintf("invalid fields: %s", strings.Join(reasons, "; "))
+}
@@ -0,0 +1,42 @@
+package application
+
+import (
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+type EventsService struct {
+	userRepository ports.UserRepository
+	eventStream    ports.EventStream
+}
+
+type EventSubscription struct {
+	Replay []models.Event
+	Events <-chan models.Event
+	Cancel func()
+}
+
+func NewEventsService(userRepository ports.UserRepository, eventStream ports.EventStream) *EventsService {
+	return &EventsService{
+		userRepository: userRepository,
+		eventStream:    eventStream,
+	}
+}
+
+// Subscribe follows the events of the user published after lastEventID. The
+// subscription has to be cancelled once the caller is done with it.
+func (e *EventsService) Subscribe(id string, lastEventID uint64) (EventSubscription, error) {
+	userID, err := models.FromString(id)
+
+	if err != nil {
+		return EventSubscription{}, err
+	}
+
+	user := e.userRepository.GetByID(userID)
+	if user == nil {
+		return EventSubscription{}, &ErrUserDoesNotExist{}
+	}
+
+	replay, events, cancel := e.eventStream.Subscribe(userID, lastEventID)
+	return EventSubscription{Replay: replay, Events: events, Cancel: cancel}, nil
+}
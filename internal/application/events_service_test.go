@@ -0,0 +1,43 @@
+package application_test
+
+import (
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestSubscribe(t *testing.T) {
+	userInRepository := models.NewUser("John_doe@test.com", "12345")
+	idOfUserInRepository := userInRepository.GetID()
+
+	repoWithoutUserMock := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+		return nil
+	}}
+
+	repoWithUserMock := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+		return userInRepository
+	}}
+
+	t.Run("ReturnErrUserDoesNotExist", func(t *testing.T) {
+		streamSpy := mocks.EventStreamMock{}
+		service := application.NewEventsService(&repoWithoutUserMock, &streamSpy)
+
+		_, err := service.Subscribe(idOfUserInRepository.ToString(), 0)
+
+		assertError(t, err)
+		assertFalse(t, streamSpy.SubscribeCalled)
+	})
+
+	t.Run("SubscribeAfterLastEventID", func(t *testing.T) {
+		streamSpy := mocks.EventStreamMock{}
+		service := application.NewEventsService(&repoWithUserMock, &streamSpy)
+
+		subscription, err := service.Subscribe(idOfUserInRepository.ToString(), 12)
+		defer subscription.Cancel()
+
+		assertNoError(t, err)
+		assertEquals(t, streamSpy.SubscribeCalledWith, 12)
+	})
+}
@@ -0,0 +1,111 @@
+package application
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+const (
+	DEFAULT_LOGIN_MAX_FAILURES      = 5
+	DEFAULT_LOGIN_FAILURE_WINDOW_MS = 15 * 60 * 1000
+)
+
+// LoginThrottle refuses the logins coming from an address, or for an email
+// address, that failed too often lately, so passwords cannot be guessed at the
+// pace the server hashes them. A successful login only forgets the failures of
+// the email address, so guessing from one address cannot be hidden between
+// logins to an account of the guesser.
+type LoginThrottle struct {
+	mutex       sync.Mutex
+	maxFailures int
+	window      time.Duration
+	failures    map[string][]time.Time
+	lastSweep   time.Time
+}
+
+func NewLoginThrottle(maxFailures int, window time.Duration) *LoginThrottle {
+	return &LoginThrottle{
+		maxFailures: maxFailures,
+		window:      window,
+		failures:    make(map[string][]time.Time),
+	}
+}
+
+// Allow returns an ErrTooManyAttempts when address or email failed to log in
+// maxFailures times within the window before now.
+func (l *LoginThrottle) Allow(address string, email string, now time.Time) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var retryAfter time.Duration
+	for _, key := range loginThrottleKeys(address, email) {
+		failures := l.recent(key, now)
+		if len(failures) >= l.maxFailures {
+			retryAfter = max(retryAfter, failures[len(failures)-l.maxFailures].Add(l.window).Sub(now))
+		}
+	}
+
+	if retryAfter > 0 {
+		return &ErrTooManyAttempts{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// Failed records that a login from address for email failed at now.
+func (l *LoginThrottle) Failed(address string, email string, now time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.sweep(now)
+	for _, key := range loginThrottleKeys(address, email) {
+		l.failures[key] = append(l.recent(key, now), now)
+	}
+}
+
+// Succeeded forgets the failed logins for email.
+func (l *LoginThrottle) Succeeded(email string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.failures, "email:"+models.NormalizeEmail(email))
+}
+
+// recent drops the failures of key older than the window and returns the
+// others.
+func (l *LoginThrottle) recent(key string, now time.Time) []time.Time {
+	failures := l.failures[key]
+	kept := failures[:0]
+	for _, failure := range failures {
+		if now.Sub(failure) < l.window {
+			kept = append(kept, failure)
+		}
+	}
+
+	if len(kept) == 0 {
+		delete(l.failures, key)
+		return nil
+	}
+
+	l.failures[key] = kept
+	return kept
+}
+
+// sweep forgets, at most once per window, the keys that did not fail within
+// the window, so addresses that gave up do not pile up.
+func (l *LoginThrottle) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+
+	l.lastSweep = now
+	for key := range l.failures {
+		l.recent(key, now)
+	}
+}
+
+func loginThrottleKeys(address string, email string) []string {
+	return []string{"address:" + address, "email:" + models.NormalizeEmail(email)}
+}
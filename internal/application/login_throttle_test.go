@@ -0,0 +1,75 @@
+package application_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	anyAddress := "192.0.2.1"
+	anyEmail := "john_doe@test.com"
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	newThrottleWithFailures := func(failures int) *application.LoginThrottle {
+		throttle := application.NewLoginThrottle(3, time.Minute)
+		for i := 0; i < failures; i++ {
+			throttle.Failed(anyAddress, anyEmail, now.Add(time.Duration(i)*time.Second))
+		}
+		return throttle
+	}
+
+	t.Run("AllowLoginsBelowMaxFailures", func(t *testing.T) {
+		throttle := newThrottleWithFailures(2)
+
+		err := throttle.Allow(anyAddress, anyEmail, now.Add(2*time.Second))
+
+		assertNoError(t, err)
+	})
+
+	t.Run("RefuseLoginsUntilOldestFailureLeavesWindow", func(t *testing.T) {
+		throttle := newThrottleWithFailures(3)
+
+		err := throttle.Allow(anyAddress, anyEmail, now.Add(30*time.Second))
+
+		tooManyAttempts, ok := err.(*application.ErrTooManyAttempts)
+		if !ok || tooManyAttempts.RetryAfter != 30*time.Second {
+			t.Fatalf("Expected ErrTooManyAttempts to retry after 30s, got %v", err)
+		}
+	})
+
+	t.Run("AllowLoginsAfterWindow", func(t *testing.T) {
+		throttle := newThrottleWithFailures(3)
+
+		err := throttle.Allow(anyAddress, anyEmail, now.Add(time.Minute))
+
+		assertNoError(t, err)
+	})
+
+	t.Run("RefuseOtherEmailsFromSameAddress", func(t *testing.T) {
+		throttle := newThrottleWithFailures(3)
+
+		err := throttle.Allow(anyAddress, "jane_doe@test.com", now.Add(3*time.Second))
+
+		assertError(t, err)
+	})
+
+	t.Run("RefuseSameEmailFromOtherAddresses", func(t *testing.T) {
+		throttle := newThrottleWithFailures(3)
+
+		err := throttle.Allow("198.51.100.7", " John_Doe@test.com", now.Add(3*time.Second))
+
+		assertError(t, err)
+	})
+
+	t.Run("ForgetFailuresOfEmailOnSuccess", func(t *testing.T) {
+		throttle := newThrottleWithFailures(3)
+
+		throttle.Succeeded(anyEmail)
+		emailErr := throttle.Allow("198.51.100.7", anyEmail, now.Add(3*time.Second))
+		addressErr := throttle.Allow(anyAddress, "jane_doe@test.com", now.Add(3*time.Second))
+
+		assertNoError(t, emailErr)
+		assertError(t, addressErr)
+	})
+}
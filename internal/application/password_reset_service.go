@@ -0,0 +1,99 @@
+package application
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+const (
+	DEFAULT_RESET_TOKEN_LIFETIME_MS = 60 * 60 * 1000
+	DEFAULT_RESET_MAX_REQUESTS      = 3
+	DEFAULT_RESET_REQUEST_WINDOW_MS = 15 * 60 * 1000
+)
+
+type PasswordResetService struct {
+	userRepository  ports.UserRepository
+	tokenRepository ports.OneTimeTokenRepository
+	mailer          ports.Mailer
+	hasher          ports.PasswordHasher
+	passwordPolicy  *models.PasswordPolicy
+	sessionService  *SessionService
+	lifetime        time.Duration
+}
+
+func NewPasswordResetService(userRepository ports.UserRepository, tokenRepository ports.OneTimeTokenRepository, mailer ports.Mailer, hasher ports.PasswordHasher, passwordPolicy *models.PasswordPolicy, sessionService *SessionService) *PasswordResetService {
+	return &PasswordResetService{
+		userRepository:  userRepository,
+		tokenRepository: tokenRepository,
+		mailer:          mailer,
+		hasher:          hasher,
+		passwordPolicy:  passwordPolicy,
+		sessionService:  sessionService,
+		lifetime:        DEFAULT_RESET_TOKEN_LIFETIME_MS * time.Millisecond,
+	}
+}
+
+// RequestReset mails the token to reset the password of the user signing in
+// with email. Nothing happens for an unknown email, so the caller cannot tell
+// which addresses have an account.
+func (p *PasswordResetService) RequestReset(email string) error {
+	user := p.userRepository.GetByEmail(models.NormalizeEmail(email))
+	if user == nil || user.IsDeleted() {
+		return nil
+	}
+
+	token, expiresAt, err := issueOneTimeToken(p.tokenRepository, user.GetID(), models.TokenPurposeResetPassword, p.lifetime)
+	if err != nil {
+		return err
+	}
+
+	return p.mailer.Send(models.Mail{
+		To:      user.GetEmail(),
+		Subject: "Reset your sdisk password",
+		Body: fmt.Sprintf("Send this token with your new password to POST /password-resets/confirm:\n\n%s\n\nIt expires on %s. Ignore this mail if you did not ask to reset your password.\n",
+			token, expiresAt.Format(time.RFC1123)),
+	})
+}
+
+// ResetPassword replaces the password of the user the token was mailed to and
+// closes all their sessions. Receiving the token proves the user owns their
+// address, which is verified as well.
+func (p *PasswordResetService) ResetPassword(token string, password string) error {
+	oneTimeToken := p.tokenRepository.GetToken(hashToken(token))
+	if oneTimeToken == nil || oneTimeToken.Purpose != models.TokenPurposeResetPassword {
+		return &ErrInvalidToken{}
+	}
+
+	user := p.userRepository.GetByID(oneTimeToken.UserID)
+	if user == nil || user.IsDeleted() {
+		return &ErrInvalidToken{}
+	}
+
+	err := p.passwordPolicy.Check(user.GetEmail(), password)
+	if err != nil {
+		return err
+	}
+
+	_, err = redeemOneTimeToken(p.tokenRepository, token, models.TokenPurposeResetPassword)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := p.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	user.SetPasswordHash(passwordHash)
+	user.SetEmailVerified(true)
+	err = p.userRepository.SaveUser(user)
+	if err != nil {
+		return err
+	}
+
+	userID := user.GetID()
+	return p.sessionService.RevokeAll(userID.ToString())
+}
@@ -0,0 +1,116 @@
+package application_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestPasswordResetService(t *testing.T) {
+	userInRepoEmail := "john_doe@test.com"
+	anyUserPassword := "correct horse"
+	newPassword := "battery staple"
+	passwordPolicy := models.NewDefaultPasswordPolicy()
+	userInRepo := models.NewUser(userInRepoEmail, "hashed:"+anyUserPassword)
+	userInRepoMock := mocks.UserRepositoryMock{FnGetUserByEmail: func(email string) *models.User {
+		if email != userInRepoEmail {
+			return nil
+		}
+		return userInRepo
+	}, FnGetUserByID: func(id models.UserID) *models.User {
+		return userInRepo
+	}}
+	hasherStub := mocks.PasswordHasherMock{}
+	authenticationService := application.NewAuthenticationService(&userInRepoMock, &hasherStub)
+	newPasswordResetService := func(mailer *mocks.MailerMock, sessionRepo *mocks.SessionRepositoryMock, serverSpy *mocks.ServerMock) *application.PasswordResetService {
+		sessionService := application.NewSessionService(&userInRepoMock, authenticationService, sessionRepo, serverSpy, time.Minute, time.Hour)
+		return application.NewPasswordResetService(&userInRepoMock, newTokenStore(), mailer, &hasherStub, passwordPolicy, sessionService)
+	}
+
+	t.Run("MailTokenToUser", func(t *testing.T) {
+		mailerSpy := mocks.MailerMock{}
+		passwordResetService := newPasswordResetService(&mailerSpy, newSessionStore(), &mocks.ServerMock{})
+
+		err := passwordResetService.RequestReset(userInRepoEmail)
+
+		assertNoError(t, err)
+		assertStringEquals(t, userInRepoEmail, mailerSpy.SendCalledWith.To)
+		assertFalse(t, tokenIn(mailerSpy.SendCalledWith) == "")
+	})
+
+	t.Run("DoNothingForUnknownEmail", func(t *testing.T) {
+		mailerSpy := mocks.MailerMock{}
+		passwordResetService := newPasswordResetService(&mailerSpy, newSessionStore(), &mocks.ServerMock{})
+
+		err := passwordResetService.RequestReset("unknown@test.com")
+
+		assertNoError(t, err)
+		assertFalse(t, mailerSpy.SendCalled)
+	})
+
+	t.Run("ReplacePasswordAndRevokeSessions", func(t *testing.T) {
+		userInRepo.SetPasswordHash("hashed:" + anyUserPassword)
+		userInRepo.SetEmailVerified(false)
+		mailerSpy := mocks.MailerMock{}
+		sessionRepo := newSessionStore()
+		serverSpy := mocks.ServerMock{}
+		passwordResetService := newPasswordResetService(&mailerSpy, sessionRepo, &serverSpy)
+		sessionService := application.NewSessionService(&userInRepoMock, authenticationService, sessionRepo, &serverSpy, time.Minute, time.Hour)
+		_, _ = sessionService.Login(userInRepoEmail, anyUserPassword)
+		_ = passwordResetService.RequestReset(userInRepoEmail)
+
+		err := passwordResetService.ResetPassword(tokenIn(mailerSpy.SendCalledWith), newPassword)
+
+		assertNoError(t, err)
+		assertStringEquals(t, "hashed:"+newPassword, userInRepo.GetPasswordHash())
+		assertTrue(t, userInRepo.IsEmailVerified())
+		assertTrue(t, len(serverSpy.DropSessionCalledWith) == 1)
+		sessions, _ := sessionService.ListSessions(idOf(userInRepo))
+		assertTrue(t, len(sessions) == 0)
+	})
+
+	t.Run("KeepTokenIfPasswordIsTooWeak", func(t *testing.T) {
+		userInRepo.SetPasswordHash("hashed:" + anyUserPassword)
+		mailerSpy := mocks.MailerMock{}
+		passwordResetService := newPasswordResetService(&mailerSpy, newSessionStore(), &mocks.ServerMock{})
+		_ = passwordResetService.RequestReset(userInRepoEmail)
+		token := tokenIn(mailerSpy.SendCalledWith)
+
+		err := passwordResetService.ResetPassword(token, "12345")
+
+		if _, ok := err.(*models.ErrWeakPassword); !ok {
+			t.Fatalf("Expected ErrWeakPassword, got %v", err)
+		}
+		assertStringEquals(t, "hashed:"+anyUserPassword, userInRepo.GetPasswordHash())
+		assertNoError(t, passwordResetService.ResetPassword(token, newPassword))
+	})
+
+	t.Run("ReturnErrInvalidTokenIfTokenIsUsedTwice", func(t *testing.T) {
+		mailerSpy := mocks.MailerMock{}
+		passwordResetService := newPasswordResetService(&mailerSpy, newSessionStore(), &mocks.ServerMock{})
+		_ = passwordResetService.RequestReset(userInRepoEmail)
+		token := tokenIn(mailerSpy.SendCalledWith)
+		_ = passwordResetService.ResetPassword(token, newPassword)
+
+		err := passwordResetService.ResetPassword(token, newPassword)
+
+		assertInvalidToken(t, err)
+	})
+
+	t.Run("ReturnErrInvalidTokenIfTokenIsForVerification", func(t *testing.T) {
+		userInRepo.SetEmailVerified(false)
+		mailerSpy := mocks.MailerMock{}
+		tokenRepo := newTokenStore()
+		verificationService := application.NewEmailVerificationService(&userInRepoMock, tokenRepo, &mailerSpy)
+		sessionService := application.NewSessionService(&userInRepoMock, authenticationService, newSessionStore(), &mocks.ServerMock{}, time.Minute, time.Hour)
+		passwordResetService := application.NewPasswordResetService(&userInRepoMock, tokenRepo, &mailerSpy, &hasherStub, passwordPolicy, sessionService)
+		_ = verificationService.SendVerification(idOf(userInRepo))
+
+		err := passwordResetService.ResetPassword(tokenIn(mailerSpy.SendCalledWith), newPassword)
+
+		assertInvalidToken(t, err)
+	})
+}
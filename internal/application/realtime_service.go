@@ -0,0 +1,22 @@
+package application
+
+import (
+	"net"
+
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+type RealTimeService struct {
+	realTimeServer ports.RealTimeServer
+}
+
+func NewRealTimeService(server ports.RealTimeServer) *RealTimeService {
+	return &RealTimeService{
+		realTimeServer: server,
+	}
+}
+
+// Serve synchronizes over conn until it is closed.
+func (r *RealTimeService) Serve(conn net.Conn) {
+	r.realTimeServer.ServeConn(conn)
+}
@@ -0,0 +1,22 @@
+package application_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+)
+
+func TestServe(t *testing.T) {
+	t.Run("ServerServesConnection", func(t *testing.T) {
+		serverSpy := mocks.ServerMock{}
+		service := application.NewRealTimeService(&serverSpy)
+		conn, peer := net.Pipe()
+		defer peer.Close()
+
+		service.Serve(conn)
+
+		assertTrue(t, serverSpy.ServeConnCalled)
+	})
+}
@@ -6,24 +6,77 @@ import (
 )
 
 type RegisterService struct {
-	userRepository ports.UserRepository
+	userRepository      ports.UserRepository
+	hasher              ports.PasswordHasher
+	passwordPolicy      *models.PasswordPolicy
+	events              ports.EventPublisher
+	verificationService *EmailVerificationService
 }
 
-func NewRegisterService(userRepository ports.UserRepository) *RegisterService {
+func NewRegisterService(userRepository ports.UserRepository, hasher ports.PasswordHasher, passwordPolicy *models.PasswordPolicy, events ports.EventPublisher, verificationService *EmailVerificationService) *RegisterService {
 	return &RegisterService{
-		userRepository: userRepository,
+		userRepository:      userRepository,
+		hasher:              hasher,
+		passwordPolicy:      passwordPolicy,
+		events:              events,
+		verificationService: verificationService,
 	}
 }
 
+// RegisterUser creates a user with the normalized email and mails them the
+// token verifying their address. Every rejected field is reported in an
+// ErrInvalidFields. The user is still registered when the mail cannot be
+// sent, which is reported with an ErrVerificationNotSent.
 func (registerService *RegisterService) RegisterUser(email string, password string) (models.UserID, error) {
+	email = models.NormalizeEmail(email)
+
+	var fields []FieldError
+	err := models.CheckEmail(email)
+	if err != nil {
+		fields = append(fields, FieldError{Field: "email", Err: err})
+	}
+
+	err = registerService.passwordPolicy.Check(email, password)
+	if err != nil {
+		fields = append(fields, FieldError{Field: "password", Err: err})
+	}
+
+	if len(fields) > 0 {
+		return models.UserID{}, &ErrInvalidFields{Fields: fields}
+	}
+
 	user := registerService.userRepository.GetByEmail(email)
 
 	if user != nil {
 		return user.GetID(), &ErrUserAlreadyExists{email}
 	}
 
-	user = models.NewUser(email, password)
-	registerService.userRepository.SaveUser(user)
+	passwordHash, err := registerService.hasher.Hash(password)
+	if err != nil {
+		return models.UserID{}, err
+	}
+
+	// Another registration may have taken the address while hashing, which
+	// the repository refuses atomically.
+	user = models.NewUser(email, passwordHash)
+	err = registerService.userRepository.SaveUser(user)
+	if _, ok := err.(*models.ErrEmailTaken); ok {
+		return models.UserID{}, &ErrUserAlreadyExists{email}
+	}
+
+	if err != nil {
+		return models.UserID{}, err
+	}
+	registerService.events.Publish(models.Event{
+		Type:   models.EventUserRegistered,
+		UserID: user.GetID(),
+		Email:  user.GetEmail(),
+	})
+
+	err = registerService.verificationService.sendVerification(user)
+	if err != nil {
+		return user.GetID(), &ErrVerificationNotSent{Err: err}
+	}
 
 	return user.GetID(), nil
 }
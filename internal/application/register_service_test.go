@@ -1,6 +1,7 @@
 package application_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/Joey-Boivin/sdisk/internal/application"
@@ -11,9 +12,12 @@ import (
 func TestRegisterService(t *testing.T) {
 	userInRepoEmail := "John_doe@test.com"
 	anyUserEmail := "EMAIL@TEST.com"
-	anyUserPassword := "12345"
+	anyUserPassword := "correct horse"
 
 	userRepoSpy := mocks.UserRepositoryMock{}
+	eventPublisherDummy := mocks.EventPublisherMock{}
+	hasherStub := mocks.PasswordHasherMock{}
+	passwordPolicy := models.NewDefaultPasswordPolicy()
 	userInRepo := models.NewUser(userInRepoEmail, anyUserPassword)
 
 	userInRepoMock := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
@@ -22,7 +26,11 @@ func TestRegisterService(t *testing.T) {
 		return userInRepo
 	}}
 
-	registerService := application.NewRegisterService(&userRepoSpy)
+	tokenRepositoryDummy := mocks.OneTimeTokenRepositoryMock{}
+	mailerSpy := mocks.MailerMock{}
+	verificationService := application.NewEmailVerificationService(&userRepoSpy, &tokenRepositoryDummy, &mailerSpy)
+
+	registerService := application.NewRegisterService(&userRepoSpy, &hasherStub, passwordPolicy, &eventPublisherDummy, verificationService)
 
 	t.Run("UseRegisterServiceToSaveUser", func(t *testing.T) {
 		_, err := registerService.RegisterUser(anyUserEmail, anyUserPassword)
@@ -35,19 +43,125 @@ func TestRegisterService(t *testing.T) {
 		_, err := registerService.RegisterUser(anyUserEmail, anyUserPassword)
 
 		emailUsed := userRepoSpy.SaveUserCalledWith.GetEmail()
-		passwordUsed := userRepoSpy.SaveUserCalledWith.GetPassword()
-		assertStringEquals(t, anyUserEmail, emailUsed)
-		assertStringEquals(t, anyUserPassword, passwordUsed)
+		assertStringEquals(t, "email@test.com", emailUsed)
 		assertNoError(t, err)
 	})
 
+	t.Run("SaveHashOfPassword", func(t *testing.T) {
+		_, err := registerService.RegisterUser(anyUserEmail, anyUserPassword)
+
+		assertNoError(t, err)
+		assertStringEquals(t, anyUserPassword, hasherStub.HashCalledWith)
+		assertStringEquals(t, "hashed:"+anyUserPassword, userRepoSpy.SaveUserCalledWith.GetPasswordHash())
+	})
+
+	t.Run("ReturnErrUserAlreadyExistsIfEmailIsTakenWhileHashing", func(t *testing.T) {
+		userRepoWithConcurrentRegistration := mocks.UserRepositoryMock{FnSaveUser: func(u *models.User) error {
+			return &models.ErrEmailTaken{Email: u.GetEmail()}
+		}}
+		registerService := application.NewRegisterService(&userRepoWithConcurrentRegistration, &hasherStub, passwordPolicy, &eventPublisherDummy, verificationService)
+
+		_, err := registerService.RegisterUser(anyUserEmail, anyUserPassword)
+
+		if _, ok := err.(*application.ErrUserAlreadyExists); !ok {
+			t.Fatalf("Expected ErrUserAlreadyExists, got %v", err)
+		}
+	})
+
+	t.Run("ReturnErrWeakPasswordIfPolicyRejectsPassword", func(t *testing.T) {
+		userRepoSpy := mocks.UserRepositoryMock{}
+		registerService := application.NewRegisterService(&userRepoSpy, &hasherStub, passwordPolicy, &eventPublisherDummy, verificationService)
+
+		_, err := registerService.RegisterUser(anyUserEmail, "12345")
+
+		assertInvalidField(t, err, "password")
+		assertFalse(t, userRepoSpy.SaveUserCalled)
+	})
+
+	t.Run("ReportEveryInvalidField", func(t *testing.T) {
+		registerService := application.NewRegisterService(&userRepoSpy, &hasherStub, passwordPolicy, &eventPublisherDummy, verificationService)
+
+		_, err := registerService.RegisterUser("", "")
+
+		assertInvalidField(t, err, "email")
+		assertInvalidField(t, err, "password")
+	})
+
+	t.Run("PublishUserRegistered", func(t *testing.T) {
+		eventPublisherSpy := mocks.EventPublisherMock{}
+		registerService := application.NewRegisterService(&userRepoSpy, &hasherStub, passwordPolicy, &eventPublisherSpy, verificationService)
+
+		id, _ := registerService.RegisterUser(anyUserEmail, anyUserPassword)
+
+		assertTrue(t, eventPublisherSpy.PublishCalled)
+		assertStringEquals(t, string(models.EventUserRegistered), string(eventPublisherSpy.PublishCalledWith.Type))
+		assertStringEquals(t, id.ToString(), eventPublisherSpy.PublishCalledWith.UserID.ToString())
+	})
+
 	t.Run("ReturnErrorIfUserAlreadyExists", func(t *testing.T) {
-		registerService = application.NewRegisterService(&userInRepoMock)
+		registerService = application.NewRegisterService(&userInRepoMock, &hasherStub, passwordPolicy, &eventPublisherDummy, verificationService)
 
 		_, err := registerService.RegisterUser(userInRepoEmail, anyUserPassword)
 
 		assertError(t, err)
 	})
+
+	t.Run("ReturnErrInvalidEmailIfEmailIsMalformed", func(t *testing.T) {
+		userRepoSpy := mocks.UserRepositoryMock{}
+		registerService := application.NewRegisterService(&userRepoSpy, &hasherStub, passwordPolicy, &eventPublisherDummy, verificationService)
+
+		_, err := registerService.RegisterUser("not an email", anyUserPassword)
+
+		assertInvalidField(t, err, "email")
+		assertFalse(t, userRepoSpy.SaveUserCalled)
+	})
+
+	t.Run("MailVerificationTokenToUser", func(t *testing.T) {
+		mailerSpy := mocks.MailerMock{}
+		tokenRepositorySpy := mocks.OneTimeTokenRepositoryMock{}
+		verificationService := application.NewEmailVerificationService(&userRepoSpy, &tokenRepositorySpy, &mailerSpy)
+		registerService := application.NewRegisterService(&userRepoSpy, &hasherStub, passwordPolicy, &eventPublisherDummy, verificationService)
+
+		_, err := registerService.RegisterUser(anyUserEmail, anyUserPassword)
+
+		assertNoError(t, err)
+		assertTrue(t, mailerSpy.SendCalled)
+		assertStringEquals(t, "email@test.com", mailerSpy.SendCalledWith.To)
+		assertStringEquals(t, string(models.TokenPurposeVerifyEmail), string(tokenRepositorySpy.SaveTokenCalledWith.Purpose))
+	})
+
+	t.Run("ReturnErrVerificationNotSentIfMailerFails", func(t *testing.T) {
+		userRepoSpy := mocks.UserRepositoryMock{}
+		mailerThatFails := mocks.MailerMock{FnSend: func(mail models.Mail) error {
+			return errors.New("relay unreachable")
+		}}
+		verificationService := application.NewEmailVerificationService(&userRepoSpy, &tokenRepositoryDummy, &mailerThatFails)
+		registerService := application.NewRegisterService(&userRepoSpy, &hasherStub, passwordPolicy, &eventPublisherDummy, verificationService)
+
+		_, err := registerService.RegisterUser(anyUserEmail, anyUserPassword)
+
+		if _, ok := err.(*application.ErrVerificationNotSent); !ok {
+			t.Fatalf("Expected ErrVerificationNotSent, got %v", err)
+		}
+		assertTrue(t, userRepoSpy.SaveUserCalled)
+	})
+}
+
+func assertInvalidField(t *testing.T, err error, field string) {
+	t.Helper()
+
+	invalidFields, ok := err.(*application.ErrInvalidFields)
+	if !ok {
+		t.Fatalf("Expected ErrInvalidFields, got %v", err)
+	}
+
+	for _, invalidField := range invalidFields.Fields {
+		if invalidField.Field == field {
+			return
+		}
+	}
+
+	t.Fatalf("Expected field %s to be invalid, got %v", field, err)
 }
 
 func assertTrue(t *testing.T, statement bool) {
@@ -58,6 +172,14 @@ func assertTrue(t *testing.T, statement bool) {
 	}
 }
 
+func assertFalse(t *testing.T, statement bool) {
+	t.Helper()
+
+	if statement {
+		t.Fatalf("Expected false, got true.")
+	}
+}
+
 func assertStringEquals(t *testing.T, want string, got string) {
 	t.Helper()
 
@@ -0,0 +1,63 @@
+package application
+
+import (
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+type RoleService struct {
+	userRepository ports.UserRepository
+}
+
+func NewRoleService(userRepository ports.UserRepository) *RoleService {
+	return &RoleService{
+		userRepository: userRepository,
+	}
+}
+
+func (r *RoleService) ListUsers() []*models.User {
+	return r.userRepository.ListUsers()
+}
+
+// SetRole gives role to the user id. The last admin cannot lose the role, so
+// the server can always be administered.
+func (r *RoleService) SetRole(id string, role string) (*models.User, error) {
+	userID, err := models.FromString(id)
+	if err != nil {
+		return nil, err
+	}
+
+	newRole, err := models.ParseRole(role)
+	if err != nil {
+		return nil, err
+	}
+
+	user := r.userRepository.GetByID(userID)
+	if user == nil {
+		return nil, &ErrUserDoesNotExist{}
+	}
+
+	if user.IsAdmin() && newRole != models.RoleAdmin && countAdmins(r.userRepository) == 1 {
+		return nil, &ErrLastAdmin{}
+	}
+
+	user.SetRole(newRole)
+	err = r.userRepository.SaveUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// countAdmins counts the admins whose account is not deleted.
+func countAdmins(userRepository ports.UserRepository) int {
+	count := 0
+	for _, user := range userRepository.ListUsers() {
+		if user.IsAdmin() && !user.IsDeleted() {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,161 @@
+package application_test
+
+import (
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestRoleService(t *testing.T) {
+	newUserRepo := func(users ...*models.User) *mocks.UserRepositoryMock {
+		return &mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+			for _, user := range users {
+				if user.GetID() == id {
+					return user
+				}
+			}
+			return nil
+		}, FnListUsers: func() []*models.User {
+			return users
+		}}
+	}
+
+	newAdmin := func(email string) *models.User {
+		admin := models.NewUser(email, "hash")
+		admin.SetRole(models.RoleAdmin)
+		return admin
+	}
+
+	t.Run("GiveRoleToUser", func(t *testing.T) {
+		user := models.NewUser("John_doe@test.com", "hash")
+		userRepoSpy := newUserRepo(user)
+		roleService := application.NewRoleService(userRepoSpy)
+
+		_, err := roleService.SetRole(idOf(user), string(models.RoleAdmin))
+
+		assertNoError(t, err)
+		assertTrue(t, user.IsAdmin())
+		assertTrue(t, userRepoSpy.SaveUserCalled)
+	})
+
+	t.Run("ReturnErrInvalidRoleForUnknownRole", func(t *testing.T) {
+		user := models.NewUser("John_doe@test.com", "hash")
+		roleService := application.NewRoleService(newUserRepo(user))
+
+		_, err := roleService.SetRole(idOf(user), "superuser")
+
+		if _, ok := err.(*models.ErrInvalidRole); !ok {
+			t.Fatalf("Expected ErrInvalidRole, got %v", err)
+		}
+	})
+
+	t.Run("ReturnErrUserDoesNotExistForUnknownUser", func(t *testing.T) {
+		roleService := application.NewRoleService(newUserRepo())
+		unknownID := models.NewUserID()
+
+		_, err := roleService.SetRole(unknownID.ToString(), string(models.RoleAdmin))
+
+		if _, ok := err.(*application.ErrUserDoesNotExist); !ok {
+			t.Fatalf("Expected ErrUserDoesNotExist, got %v", err)
+		}
+	})
+
+	t.Run("ReturnErrLastAdminWhenDemotingLastAdmin", func(t *testing.T) {
+		admin := newAdmin("admin@test.com")
+		roleService := application.NewRoleService(newUserRepo(admin, models.NewUser("John_doe@test.com", "hash")))
+
+		_, err := roleService.SetRole(idOf(admin), string(models.RoleUser))
+
+		if _, ok := err.(*application.ErrLastAdmin); !ok {
+			t.Fatalf("Expected ErrLastAdmin, got %v", err)
+		}
+		assertTrue(t, admin.IsAdmin())
+	})
+
+	t.Run("DemoteAdminWhenAnotherRemains", func(t *testing.T) {
+		admin := newAdmin("admin@test.com")
+		roleService := application.NewRoleService(newUserRepo(admin, newAdmin("other@test.com")))
+
+		_, err := roleService.SetRole(idOf(admin), string(models.RoleUser))
+
+		assertNoError(t, err)
+		assertFalse(t, admin.IsAdmin())
+	})
+}
+
+func TestBootstrapService(t *testing.T) {
+	anyAdminEmail := "admin@test.com"
+	anyAdminPassword := "correct horse"
+	policy := models.NewDefaultPasswordPolicy()
+
+	t.Run("CreateAdminWhenThereIsNone", func(t *testing.T) {
+		userRepoSpy := mocks.UserRepositoryMock{}
+		bootstrapService := application.NewBootstrapService(&userRepoSpy, &mocks.PasswordHasherMock{}, policy)
+
+		generated, err := bootstrapService.BootstrapAdmin(anyAdminEmail, anyAdminPassword)
+
+		assertNoError(t, err)
+		assertStringEquals(t, "", generated)
+		assertTrue(t, userRepoSpy.SaveUserCalled)
+		assertTrue(t, userRepoSpy.SaveUserCalledWith.IsAdmin())
+		assertStringEquals(t, "hashed:"+anyAdminPassword, userRepoSpy.SaveUserCalledWith.GetPasswordHash())
+	})
+
+	t.Run("GeneratePasswordWhenNoneIsGiven", func(t *testing.T) {
+		userRepoSpy := mocks.UserRepositoryMock{}
+		bootstrapService := application.NewBootstrapService(&userRepoSpy, &mocks.PasswordHasherMock{}, policy)
+
+		generated, err := bootstrapService.BootstrapAdmin(anyAdminEmail, "")
+
+		assertNoError(t, err)
+		assertFalse(t, generated == "")
+		assertStringEquals(t, "hashed:"+generated, userRepoSpy.SaveUserCalledWith.GetPasswordHash())
+	})
+
+	t.Run("PromoteExistingUser", func(t *testing.T) {
+		user := models.NewUser(anyAdminEmail, "hash")
+		userRepo := mocks.UserRepositoryMock{FnGetUserByEmail: func(email string) *models.User {
+			return user
+		}, FnListUsers: func() []*models.User {
+			return []*models.User{user}
+		}}
+		bootstrapService := application.NewBootstrapService(&userRepo, &mocks.PasswordHasherMock{}, policy)
+
+		_, err := bootstrapService.BootstrapAdmin(anyAdminEmail, "")
+
+		assertNoError(t, err)
+		assertTrue(t, user.IsAdmin())
+		assertStringEquals(t, "hash", user.GetPasswordHash())
+	})
+
+	t.Run("DoNothingWhenThereIsAnAdmin", func(t *testing.T) {
+		admin := models.NewUser("other@test.com", "hash")
+		admin.SetRole(models.RoleAdmin)
+		userRepoSpy := mocks.UserRepositoryMock{FnListUsers: func() []*models.User {
+			return []*models.User{admin}
+		}}
+		bootstrapService := application.NewBootstrapService(&userRepoSpy, &mocks.PasswordHasherMock{}, policy)
+
+		_, err := bootstrapService.BootstrapAdmin(anyAdminEmail, anyAdminPassword)
+
+		assertNoError(t, err)
+		assertFalse(t, userRepoSpy.SaveUserCalled)
+	})
+
+	t.Run("ReturnErrNoAdminWithoutEmail", func(t *testing.T) {
+		bootstrapService := application.NewBootstrapService(&mocks.UserRepositoryMock{}, &mocks.PasswordHasherMock{}, policy)
+
+		_, err := bootstrapService.BootstrapAdmin("", "")
+
+		if _, ok := err.(*application.ErrNoAdmin); !ok {
+			t.Fatalf("Expected ErrNoAdmin, got %v", err)
+		}
+	})
+}
+
+func idOf(user *models.User) string {
+	id := user.GetID()
+	return id.ToString()
+}
@@ -0,0 +1,237 @@
+package application
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+	"github.com/google/uuid"
+)
+
+const (
+	DEFAULT_ACCESS_TOKEN_LIFETIME_MS = 15 * 60 * 1000
+	DEFAULT_SESSION_LIFETIME_MS      = 30 * 24 * 60 * 60 * 1000
+	SESSION_TOKEN_SIZE_BYTES         = 32
+)
+
+// SessionTokens are handed to the client when a session is opened or
+// refreshed. They are never stored.
+type SessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+	Session      *models.Session
+}
+
+type SessionService struct {
+	userRepository        ports.UserRepository
+	authenticationService *AuthenticationService
+	sessionRepository     ports.SessionRepository
+	realTimeServer        ports.RealTimeServer
+	accessTokenLifetime   time.Duration
+	lifetime              time.Duration
+}
+
+// NewSessionService returns a service opening sessions lasting lifetime, whose
+// access tokens must be refreshed after accessTokenLifetime. A zero lifetime
+// keeps the default. Revoked sessions are dropped from realTimeServer.
+func NewSessionService(userRepository ports.UserRepository, authenticationService *AuthenticationService, sessionRepository ports.SessionRepository, realTimeServer ports.RealTimeServer, accessTokenLifetime time.Duration, lifetime time.Duration) *SessionService {
+	if accessTokenLifetime <= 0 {
+		accessTokenLifetime = DEFAULT_ACCESS_TOKEN_LIFETIME_MS * time.Millisecond
+	}
+
+	if lifetime <= 0 {
+		lifetime = DEFAULT_SESSION_LIFETIME_MS * time.Millisecond
+	}
+
+	return &SessionService{
+		userRepository:        userRepository,
+		authenticationService: authenticationService,
+		sessionRepository:     sessionRepository,
+		realTimeServer:        realTimeServer,
+		accessTokenLifetime:   accessTokenLifetime,
+		lifetime:              lifetime,
+	}
+}
+
+// Login opens a session for the user signing in with email and password.
+func (s *SessionService) Login(email string, password string) (*SessionTokens, error) {
+	user, err := s.authenticationService.Authenticate(email, password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	session := models.Session{
+		ID:        uuid.NewString(),
+		UserID:    user.GetID(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.lifetime),
+	}
+
+	return s.issueTokens(&session, now)
+}
+
+// Refresh trades a refresh token for new access and refresh tokens. Each
+// refresh token may only be used once: presenting one that was already
+// traded means it leaked, so the session is revoked.
+func (s *SessionService) Refresh(refreshToken string) (*SessionTokens, error) {
+	if refreshToken == "" {
+		return nil, &ErrInvalidToken{}
+	}
+
+	refreshTokenHash := hashToken(refreshToken)
+	session := s.sessionRepository.GetSessionByRefreshTokenHash(refreshTokenHash)
+	if session == nil {
+		return nil, &ErrInvalidToken{}
+	}
+
+	if session.RefreshTokenHash != refreshTokenHash {
+		err := s.revoke(session.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, &ErrInvalidToken{}
+	}
+
+	user := s.userRepository.GetByID(session.UserID)
+	if user == nil || user.IsDeleted() {
+		return nil, &ErrInvalidToken{}
+	}
+
+	session.PreviousRefreshTokenHash = refreshTokenHash
+	return s.issueTokens(session, time.Now().UTC())
+}
+
+// Authenticate returns the session whose access token is token and its user,
+// as it is now, so changes of role apply to the sessions already open.
+func (s *SessionService) Authenticate(token string) (*models.Session, *models.User, error) {
+	if token == "" {
+		return nil, nil, &ErrInvalidToken{}
+	}
+
+	session := s.sessionRepository.GetSessionByTokenHash(hashToken(token))
+	if session == nil || session.AccessExpired(time.Now()) {
+		return nil, nil, &ErrInvalidToken{}
+	}
+
+	user := s.userRepository.GetByID(session.UserID)
+	if user == nil || user.IsDeleted() {
+		return nil, nil, &ErrInvalidToken{}
+	}
+
+	return session, user, nil
+}
+
+// VerifyAccessToken is Authenticate for the real-time server, which only needs
+// the session.
+func (s *SessionService) VerifyAccessToken(token string) (*models.Session, error) {
+	session, _, err := s.Authenticate(token)
+	return session, err
+}
+
+// ListSessions returns the open sessions of a user, oldest first.
+func (s *SessionService) ListSessions(id string) ([]*models.Session, error) {
+	userID, err := models.FromString(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.userRepository.GetByID(userID) == nil {
+		return nil, &ErrUserDoesNotExist{}
+	}
+
+	return s.sessionRepository.ListSessions(userID), nil
+}
+
+// Revoke closes a session of a user right away. Its tokens stop working and
+// the real-time connections opened with them are dropped.
+func (s *SessionService) Revoke(id string, sessionID string) error {
+	userID, err := models.FromString(id)
+	if err != nil {
+		return err
+	}
+
+	session := s.sessionRepository.GetSession(sessionID)
+	if session == nil || session.UserID != userID {
+		return &ErrSessionDoesNotExist{ID: sessionID}
+	}
+
+	return s.revoke(sessionID)
+}
+
+// RevokeAll closes every session of a user.
+func (s *SessionService) RevokeAll(id string) error {
+	sessions, err := s.ListSessions(id)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		err := s.revoke(session.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SessionService) revoke(sessionID string) error {
+	err := s.sessionRepository.DeleteSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	return s.realTimeServer.DropSession(sessionID)
+}
+
+func (s *SessionService) issueTokens(session *models.Session, now time.Time) (*SessionTokens, error) {
+	accessToken, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session.TokenHash = hashToken(accessToken)
+	session.RefreshTokenHash = hashToken(refreshToken)
+	session.RefreshedAt = now
+	session.AccessExpiresAt = now.Add(s.accessTokenLifetime)
+	if session.AccessExpiresAt.After(session.ExpiresAt) {
+		session.AccessExpiresAt = session.ExpiresAt
+	}
+
+	err = s.sessionRepository.SaveSession(session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Session:      session,
+	}, nil
+}
+
+func generateToken() (string, error) {
+	token := make([]byte, SESSION_TOKEN_SIZE_BYTES)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
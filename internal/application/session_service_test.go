@@ -0,0 +1,254 @@
+package application_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestSessionService(t *testing.T) {
+	userInRepoEmail := "John_doe@test.com"
+	anyUserPassword := "correct horse"
+	anyAccessTokenLifetime := time.Minute
+	anyLifetime := time.Hour
+	userInRepo := models.NewUser(userInRepoEmail, "hashed:"+anyUserPassword)
+	userInRepoMock := mocks.UserRepositoryMock{FnGetUserByEmail: func(email string) *models.User {
+		return userInRepo
+	}, FnGetUserByID: func(id models.UserID) *models.User {
+		return userInRepo
+	}}
+	authenticationService := application.NewAuthenticationService(&userInRepoMock, &mocks.PasswordHasherMock{})
+	newSessionService := func(sessionRepo *mocks.SessionRepositoryMock, serverSpy *mocks.ServerMock) *application.SessionService {
+		return application.NewSessionService(&userInRepoMock, authenticationService, sessionRepo, serverSpy, anyAccessTokenLifetime, anyLifetime)
+	}
+
+	t.Run("SaveSessionOfUserOnLogin", func(t *testing.T) {
+		sessionRepoSpy := mocks.SessionRepositoryMock{}
+		sessionService := newSessionService(&sessionRepoSpy, &mocks.ServerMock{})
+
+		tokens, err := sessionService.Login(userInRepoEmail, anyUserPassword)
+
+		assertNoError(t, err)
+		assertTrue(t, sessionRepoSpy.SaveSessionCalled)
+		assertTrue(t, tokens.Session.UserID == userInRepo.GetID())
+		assertFalse(t, tokens.AccessToken == "" || tokens.RefreshToken == "")
+		assertFalse(t, tokens.Session.TokenHash == tokens.AccessToken)
+		assertFalse(t, tokens.Session.RefreshTokenHash == tokens.RefreshToken)
+	})
+
+	t.Run("ExpireAccessTokenBeforeSession", func(t *testing.T) {
+		sessionService := newSessionService(&mocks.SessionRepositoryMock{}, &mocks.ServerMock{})
+
+		tokens, _ := sessionService.Login(userInRepoEmail, anyUserPassword)
+
+		assertTrue(t, tokens.Session.AccessExpiresAt.Sub(tokens.Session.CreatedAt) == anyAccessTokenLifetime)
+		assertTrue(t, tokens.Session.ExpiresAt.Sub(tokens.Session.CreatedAt) == anyLifetime)
+	})
+
+	t.Run("ReturnErrInvalidCredentialsOnWrongPassword", func(t *testing.T) {
+		sessionRepoSpy := mocks.SessionRepositoryMock{}
+		sessionService := newSessionService(&sessionRepoSpy, &mocks.ServerMock{})
+
+		_, err := sessionService.Login(userInRepoEmail, "wrong password")
+
+		assertInvalidCredentials(t, err)
+		assertFalse(t, sessionRepoSpy.SaveSessionCalled)
+	})
+
+	t.Run("AuthenticateTokenOfSession", func(t *testing.T) {
+		sessionRepo := newSessionStore()
+		sessionService := newSessionService(sessionRepo, &mocks.ServerMock{})
+		tokens, _ := sessionService.Login(userInRepoEmail, anyUserPassword)
+
+		authenticated, user, err := sessionService.Authenticate(tokens.AccessToken)
+
+		assertNoError(t, err)
+		assertTrue(t, authenticated.ID == tokens.Session.ID)
+		assertTrue(t, user == userInRepo)
+	})
+
+	t.Run("ReturnErrInvalidTokenIfUserIsGone", func(t *testing.T) {
+		session := models.Session{UserID: userInRepo.GetID(), AccessExpiresAt: time.Now().Add(time.Hour), ExpiresAt: time.Now().Add(time.Hour)}
+		sessionRepo := mocks.SessionRepositoryMock{FnGetSessionByTokenHash: func(tokenHash string) *models.Session {
+			return &session
+		}}
+		sessionService := application.NewSessionService(&mocks.UserRepositoryMock{}, authenticationService, &sessionRepo, &mocks.ServerMock{}, anyAccessTokenLifetime, anyLifetime)
+
+		_, _, err := sessionService.Authenticate("any")
+
+		assertInvalidToken(t, err)
+	})
+
+	t.Run("ReturnErrInvalidTokenForUnknownToken", func(t *testing.T) {
+		sessionService := newSessionService(&mocks.SessionRepositoryMock{}, &mocks.ServerMock{})
+
+		_, _, err := sessionService.Authenticate("unknown")
+
+		assertInvalidToken(t, err)
+	})
+
+	t.Run("ReturnErrInvalidTokenForExpiredAccessToken", func(t *testing.T) {
+		expired := models.Session{UserID: userInRepo.GetID(), AccessExpiresAt: time.Now().Add(-time.Second), ExpiresAt: time.Now().Add(time.Hour)}
+		sessionRepo := mocks.SessionRepositoryMock{FnGetSessionByTokenHash: func(tokenHash string) *models.Session {
+			return &expired
+		}}
+		sessionService := newSessionService(&sessionRepo, &mocks.ServerMock{})
+
+		_, _, err := sessionService.Authenticate("any")
+
+		assertInvalidToken(t, err)
+	})
+
+	t.Run("RotateTokensOnRefresh", func(t *testing.T) {
+		sessionRepo := newSessionStore()
+		sessionService := newSessionService(sessionRepo, &mocks.ServerMock{})
+		tokens, _ := sessionService.Login(userInRepoEmail, anyUserPassword)
+
+		refreshed, err := sessionService.Refresh(tokens.RefreshToken)
+
+		assertNoError(t, err)
+		assertTrue(t, refreshed.Session.ID == tokens.Session.ID)
+		assertFalse(t, refreshed.AccessToken == tokens.AccessToken)
+		assertFalse(t, refreshed.RefreshToken == tokens.RefreshToken)
+		_, _, err = sessionService.Authenticate(tokens.AccessToken)
+		assertInvalidToken(t, err)
+		_, _, err = sessionService.Authenticate(refreshed.AccessToken)
+		assertNoError(t, err)
+	})
+
+	t.Run("RevokeSessionWhenRefreshTokenIsReused", func(t *testing.T) {
+		sessionRepo := newSessionStore()
+		serverSpy := mocks.ServerMock{}
+		sessionService := newSessionService(sessionRepo, &serverSpy)
+		tokens, _ := sessionService.Login(userInRepoEmail, anyUserPassword)
+		refreshed, _ := sessionService.Refresh(tokens.RefreshToken)
+
+		_, err := sessionService.Refresh(tokens.RefreshToken)
+
+		assertInvalidToken(t, err)
+		assertTrue(t, serverSpy.DropSessionCalled)
+		_, err = sessionService.Refresh(refreshed.RefreshToken)
+		assertInvalidToken(t, err)
+	})
+
+	t.Run("ReturnErrInvalidTokenForUnknownRefreshToken", func(t *testing.T) {
+		sessionService := newSessionService(&mocks.SessionRepositoryMock{}, &mocks.ServerMock{})
+
+		_, err := sessionService.Refresh("unknown")
+
+		assertInvalidToken(t, err)
+	})
+
+	t.Run("ListSessionsOfUser", func(t *testing.T) {
+		sessionRepo := newSessionStore()
+		sessionService := newSessionService(sessionRepo, &mocks.ServerMock{})
+		_, _ = sessionService.Login(userInRepoEmail, anyUserPassword)
+		_, _ = sessionService.Login(userInRepoEmail, anyUserPassword)
+
+		sessions, err := sessionService.ListSessions(idOf(userInRepo))
+
+		assertNoError(t, err)
+		assertTrue(t, len(sessions) == 2)
+	})
+
+	t.Run("RevokeSessionAndDropItsConnections", func(t *testing.T) {
+		sessionRepo := newSessionStore()
+		serverSpy := mocks.ServerMock{}
+		sessionService := newSessionService(sessionRepo, &serverSpy)
+		tokens, _ := sessionService.Login(userInRepoEmail, anyUserPassword)
+
+		err := sessionService.Revoke(idOf(userInRepo), tokens.Session.ID)
+
+		assertNoError(t, err)
+		assertTrue(t, serverSpy.DropSessionCalled)
+		assertStringEquals(t, tokens.Session.ID, serverSpy.DropSessionCalledWith[0])
+		_, _, err = sessionService.Authenticate(tokens.AccessToken)
+		assertInvalidToken(t, err)
+	})
+
+	t.Run("ReturnErrSessionDoesNotExistForSessionOfOtherUser", func(t *testing.T) {
+		sessionRepo := newSessionStore()
+		sessionService := newSessionService(sessionRepo, &mocks.ServerMock{})
+		tokens, _ := sessionService.Login(userInRepoEmail, anyUserPassword)
+		otherUserID := models.NewUserID()
+
+		err := sessionService.Revoke(otherUserID.ToString(), tokens.Session.ID)
+
+		if _, ok := err.(*application.ErrSessionDoesNotExist); !ok {
+			t.Fatalf("Expected ErrSessionDoesNotExist, got %v", err)
+		}
+	})
+
+	t.Run("RevokeAllSessionsOfUser", func(t *testing.T) {
+		sessionRepo := newSessionStore()
+		serverSpy := mocks.ServerMock{}
+		sessionService := newSessionService(sessionRepo, &serverSpy)
+		_, _ = sessionService.Login(userInRepoEmail, anyUserPassword)
+		_, _ = sessionService.Login(userInRepoEmail, anyUserPassword)
+
+		err := sessionService.RevokeAll(idOf(userInRepo))
+
+		assertNoError(t, err)
+		assertTrue(t, len(serverSpy.DropSessionCalledWith) == 2)
+		sessions, _ := sessionService.ListSessions(idOf(userInRepo))
+		assertTrue(t, len(sessions) == 0)
+	})
+}
+
+// newSessionStore returns a session repository mock keeping the sessions it
+// is given, so tokens can be used after they are issued.
+func newSessionStore() *mocks.SessionRepositoryMock {
+	sessions := make(map[string]models.Session)
+	find := func(match func(s models.Session) bool) *models.Session {
+		for _, s := range sessions {
+			if match(s) {
+				found := s
+				return &found
+			}
+		}
+		return nil
+	}
+
+	return &mocks.SessionRepositoryMock{
+		FnSaveSession: func(s *models.Session) error {
+			sessions[s.ID] = *s
+			return nil
+		},
+		FnGetSession: func(id string) *models.Session {
+			return find(func(s models.Session) bool { return s.ID == id })
+		},
+		FnGetSessionByTokenHash: func(tokenHash string) *models.Session {
+			return find(func(s models.Session) bool { return s.TokenHash == tokenHash })
+		},
+		FnGetSessionByRefreshTokenHash: func(refreshTokenHash string) *models.Session {
+			return find(func(s models.Session) bool {
+				return s.RefreshTokenHash == refreshTokenHash || s.PreviousRefreshTokenHash == refreshTokenHash
+			})
+		},
+		FnListSessions: func(userID models.UserID) []*models.Session {
+			list := make([]*models.Session, 0)
+			for _, s := range sessions {
+				if s.UserID == userID {
+					found := s
+					list = append(list, &found)
+				}
+			}
+			return list
+		},
+		FnDeleteSession: func(id string) error {
+			delete(sessions, id)
+			return nil
+		},
+	}
+}
+
+func assertInvalidToken(t *testing.T, err error) {
+	t.Helper()
+
+	if _, ok := err.(*application.ErrInvalidToken); !ok {
+		t.Fatalf("Expected ErrInvalidToken, got %v", err)
+	}
+}
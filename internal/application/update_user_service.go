@@ -0,0 +1,147 @@
+package application
+
+import (
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+// UserUpdate lists what changes about a user. A nil field is left as it is.
+// CurrentPassword is required to change the email address or the password.
+type UserUpdate struct {
+	Email           *string
+	Password        *string
+	CurrentPassword string
+}
+
+type UpdateUserService struct {
+	userRepository      ports.UserRepository
+	tokenRepository     ports.OneTimeTokenRepository
+	hasher              ports.PasswordHasher
+	passwordPolicy      *models.PasswordPolicy
+	sessionService      *SessionService
+	verificationService *EmailVerificationService
+}
+
+func NewUpdateUserService(userRepository ports.UserRepository, tokenRepository ports.OneTimeTokenRepository, hasher ports.PasswordHasher, passwordPolicy *models.PasswordPolicy, sessionService *SessionService, verificationService *EmailVerificationService) *UpdateUserService {
+	return &UpdateUserService{
+		userRepository:      userRepository,
+		tokenRepository:     tokenRepository,
+		hasher:              hasher,
+		passwordPolicy:      passwordPolicy,
+		sessionService:      sessionService,
+		verificationService: verificationService,
+	}
+}
+
+// UpdateUser changes the email address or the password of a user, who must
+// confirm either change with their current password. Every rejected field is
+// reported in an ErrInvalidFields. A new address is mailed a verification
+// token, and the tokens mailed to the previous one stop working. Changing the
+// password closes all the sessions of the user. The user is still updated when
+// the verification mail cannot be sent, which is reported with an
+// ErrVerificationNotSent.
+func (u *UpdateUserService) UpdateUser(id string, update UserUpdate) (*models.User, error) {
+	userID, err := models.FromString(id)
+	if err != nil {
+		return nil, err
+	}
+
+	user := u.userRepository.GetByID(userID)
+	if user == nil || user.IsDeleted() {
+		return nil, &ErrUserDoesNotExist{}
+	}
+
+	email := user.GetEmail()
+	if update.Email != nil {
+		email = models.NormalizeEmail(*update.Email)
+	}
+
+	var fields []FieldError
+	if update.Email != nil {
+		err := models.CheckEmail(email)
+		if err != nil {
+			fields = append(fields, FieldError{Field: "email", Err: err})
+		}
+	}
+
+	if update.Password != nil {
+		err := u.passwordPolicy.Check(email, *update.Password)
+		if err != nil {
+			fields = append(fields, FieldError{Field: "password", Err: err})
+		}
+	}
+
+	credentialsChange := update.Email != nil || update.Password != nil
+	if credentialsChange && update.CurrentPassword == "" {
+		fields = append(fields, FieldError{Field: "currentPassword", Err: &ErrFieldRequired{}})
+	}
+
+	if len(fields) > 0 {
+		return nil, &ErrInvalidFields{Fields: fields}
+	}
+
+	if credentialsChange {
+		ok, _, err := u.hasher.Verify(update.CurrentPassword, user.GetPasswordHash())
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, &ErrIncorrectPassword{}
+		}
+	}
+
+	emailChanged := email != user.GetEmail()
+	if emailChanged {
+		existing := u.userRepository.GetByEmail(email)
+		if existing != nil && existing.GetID() != userID {
+			return nil, &ErrUserAlreadyExists{email}
+		}
+	}
+
+	if update.Password != nil {
+		passwordHash, err := u.hasher.Hash(*update.Password)
+		if err != nil {
+			return nil, err
+		}
+
+		user.SetPasswordHash(passwordHash)
+	}
+
+	err = user.ChangeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.userRepository.SaveUser(user)
+	if _, ok := err.(*models.ErrEmailTaken); ok {
+		return nil, &ErrUserAlreadyExists{email}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Password != nil {
+		err := u.sessionService.RevokeAll(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !emailChanged {
+		return user, nil
+	}
+
+	err = u.tokenRepository.DeleteTokens(userID, models.TokenPurposeResetPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.verificationService.sendVerification(user)
+	if err != nil {
+		return user, &ErrVerificationNotSent{Err: err}
+	}
+
+	return user, nil
+}
@@ -0,0 +1,196 @@
+package application_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestUpdateUserService(t *testing.T) {
+	anyUserPassword := "correct horse"
+	newPassword := "battery staple"
+	newUserRepo := func(users ...*models.User) *mocks.UserRepositoryMock {
+		return &mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+			for _, user := range users {
+				if user.GetID() == id {
+					return user
+				}
+			}
+			return nil
+		}, FnGetUserByEmail: func(email string) *models.User {
+			for _, user := range users {
+				if user.GetEmail() == email {
+					return user
+				}
+			}
+			return nil
+		}}
+	}
+	newService := func(userRepo *mocks.UserRepositoryMock, tokenRepo *mocks.OneTimeTokenRepositoryMock, mailer *mocks.MailerMock, serverSpy *mocks.ServerMock) (*application.UpdateUserService, *application.SessionService) {
+		hasher := mocks.PasswordHasherMock{}
+		authenticationService := application.NewAuthenticationService(userRepo, &hasher)
+		sessionService := application.NewSessionService(userRepo, authenticationService, newSessionStore(), serverSpy, time.Minute, time.Hour)
+		verificationService := application.NewEmailVerificationService(userRepo, tokenRepo, mailer)
+		return application.NewUpdateUserService(userRepo, tokenRepo, &hasher, models.NewDefaultPasswordPolicy(), sessionService, verificationService), sessionService
+	}
+	stringOf := func(s string) *string {
+		return &s
+	}
+
+	t.Run("ChangeEmailAndMailVerificationToIt", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		user.SetEmailVerified(true)
+		userRepoSpy := newUserRepo(user)
+		mailerSpy := mocks.MailerMock{}
+		updateService, _ := newService(userRepoSpy, newTokenStore(), &mailerSpy, &mocks.ServerMock{})
+
+		updated, err := updateService.UpdateUser(idOf(user), application.UserUpdate{Email: stringOf(" Jane_Doe@Test.com "), CurrentPassword: anyUserPassword})
+
+		assertNoError(t, err)
+		assertStringEquals(t, "jane_doe@test.com", updated.GetEmail())
+		assertFalse(t, updated.IsEmailVerified())
+		assertTrue(t, userRepoSpy.SaveUserCalled)
+		assertStringEquals(t, "jane_doe@test.com", mailerSpy.SendCalledWith.To)
+	})
+
+	t.Run("StopResetTokensMailedToPreviousEmail", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		tokenRepoSpy := newTokenStore()
+		updateService, _ := newService(newUserRepo(user), tokenRepoSpy, &mocks.MailerMock{}, &mocks.ServerMock{})
+
+		_, err := updateService.UpdateUser(idOf(user), application.UserUpdate{Email: stringOf("jane_doe@test.com"), CurrentPassword: anyUserPassword})
+
+		assertNoError(t, err)
+		assertTrue(t, tokenRepoSpy.DeleteTokensCalled)
+	})
+
+	t.Run("KeepVerificationWhenEmailIsTheSame", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		user.SetEmailVerified(true)
+		mailerSpy := mocks.MailerMock{}
+		updateService, _ := newService(newUserRepo(user), newTokenStore(), &mailerSpy, &mocks.ServerMock{})
+
+		updated, err := updateService.UpdateUser(idOf(user), application.UserUpdate{Email: stringOf("JOHN_DOE@test.com"), CurrentPassword: anyUserPassword})
+
+		assertNoError(t, err)
+		assertTrue(t, updated.IsEmailVerified())
+		assertFalse(t, mailerSpy.SendCalled)
+	})
+
+	t.Run("ReturnErrUserAlreadyExistsForTakenEmail", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		other := models.NewUser("jane_doe@test.com", "hash")
+		updateService, _ := newService(newUserRepo(user, other), newTokenStore(), &mocks.MailerMock{}, &mocks.ServerMock{})
+
+		_, err := updateService.UpdateUser(idOf(user), application.UserUpdate{Email: stringOf("jane_doe@test.com"), CurrentPassword: anyUserPassword})
+
+		if _, ok := err.(*application.ErrUserAlreadyExists); !ok {
+			t.Fatalf("Expected ErrUserAlreadyExists, got %v", err)
+		}
+		assertStringEquals(t, "john_doe@test.com", user.GetEmail())
+	})
+
+	t.Run("ReturnErrUserAlreadyExistsIfEmailIsTakenWhileSaving", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		userRepo := newUserRepo(user)
+		userRepo.FnSaveUser = func(u *models.User) error {
+			return &models.ErrEmailTaken{Email: u.GetEmail()}
+		}
+		mailerSpy := mocks.MailerMock{}
+		updateService, _ := newService(userRepo, newTokenStore(), &mailerSpy, &mocks.ServerMock{})
+
+		_, err := updateService.UpdateUser(idOf(user), application.UserUpdate{Email: stringOf("jane_doe@test.com"), CurrentPassword: anyUserPassword})
+
+		if _, ok := err.(*application.ErrUserAlreadyExists); !ok {
+			t.Fatalf("Expected ErrUserAlreadyExists, got %v", err)
+		}
+		assertFalse(t, mailerSpy.SendCalled)
+	})
+
+	t.Run("ReturnErrInvalidFieldsForInvalidEmail", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		updateService, _ := newService(newUserRepo(user), newTokenStore(), &mocks.MailerMock{}, &mocks.ServerMock{})
+
+		_, err := updateService.UpdateUser(idOf(user), application.UserUpdate{Email: stringOf("not an email")})
+
+		assertInvalidField(t, err, "email")
+	})
+
+	t.Run("ReturnErrInvalidFieldsForEmailWithoutCurrentPassword", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		userRepoSpy := newUserRepo(user)
+		updateService, _ := newService(userRepoSpy, newTokenStore(), &mocks.MailerMock{}, &mocks.ServerMock{})
+
+		_, err := updateService.UpdateUser(idOf(user), application.UserUpdate{Email: stringOf("jane_doe@test.com")})
+
+		assertInvalidField(t, err, "currentPassword")
+		assertFalse(t, userRepoSpy.SaveUserCalled)
+	})
+
+	t.Run("ReturnErrIncorrectPasswordForEmailWithWrongCurrentPassword", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		userRepoSpy := newUserRepo(user)
+		updateService, _ := newService(userRepoSpy, newTokenStore(), &mocks.MailerMock{}, &mocks.ServerMock{})
+
+		_, err := updateService.UpdateUser(idOf(user), application.UserUpdate{Email: stringOf("jane_doe@test.com"), CurrentPassword: "wrong password"})
+
+		if _, ok := err.(*application.ErrIncorrectPassword); !ok {
+			t.Fatalf("Expected ErrIncorrectPassword, got %v", err)
+		}
+		assertFalse(t, userRepoSpy.SaveUserCalled)
+	})
+
+	t.Run("ChangePasswordAndRevokeSessions", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		serverSpy := mocks.ServerMock{}
+		updateService, sessionService := newService(newUserRepo(user), newTokenStore(), &mocks.MailerMock{}, &serverSpy)
+		tokens, _ := sessionService.Login(user.GetEmail(), anyUserPassword)
+
+		_, err := updateService.UpdateUser(idOf(user), application.UserUpdate{Password: stringOf(newPassword), CurrentPassword: anyUserPassword})
+		_, _, authErr := sessionService.Authenticate(tokens.AccessToken)
+		_, loginErr := sessionService.Login(user.GetEmail(), newPassword)
+
+		assertNoError(t, err)
+		assertTrue(t, serverSpy.DropSessionCalled)
+		assertInvalidToken(t, authErr)
+		assertNoError(t, loginErr)
+	})
+
+	t.Run("ReturnErrIncorrectPasswordForWrongCurrentPassword", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		userRepoSpy := newUserRepo(user)
+		updateService, _ := newService(userRepoSpy, newTokenStore(), &mocks.MailerMock{}, &mocks.ServerMock{})
+
+		_, err := updateService.UpdateUser(idOf(user), application.UserUpdate{Password: stringOf(newPassword), CurrentPassword: "wrong password"})
+
+		if _, ok := err.(*application.ErrIncorrectPassword); !ok {
+			t.Fatalf("Expected ErrIncorrectPassword, got %v", err)
+		}
+		assertFalse(t, userRepoSpy.SaveUserCalled)
+	})
+
+	t.Run("ReturnErrInvalidFieldsForWeakPasswordWithoutCurrentPassword", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		updateService, _ := newService(newUserRepo(user), newTokenStore(), &mocks.MailerMock{}, &mocks.ServerMock{})
+
+		_, err := updateService.UpdateUser(idOf(user), application.UserUpdate{Password: stringOf("short")})
+
+		assertInvalidField(t, err, "password")
+		assertInvalidField(t, err, "currentPassword")
+	})
+
+	t.Run("ReturnErrUserDoesNotExistForDeletedUser", func(t *testing.T) {
+		user := models.NewUser("john_doe@test.com", "hashed:"+anyUserPassword)
+		user.MarkDeleted(time.Now())
+		updateService, _ := newService(newUserRepo(user), newTokenStore(), &mocks.MailerMock{}, &mocks.ServerMock{})
+
+		_, err := updateService.UpdateUser(idOf(user), application.UserUpdate{Email: stringOf("jane_doe@test.com")})
+
+		if _, ok := err.(*application.ErrUserDoesNotExist); !ok {
+			t.Fatalf("Expected ErrUserDoesNotExist, got %v", err)
+		}
+	})
+}
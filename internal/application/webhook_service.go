@@ -0,0 +1,132 @@
+package application
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+	"github.com/google/uuid"
+)
+
+const WEBHOOK_SECRET_SIZE_BYTES = 32
+
+type WebhookService struct {
+	userRepository    ports.UserRepository
+	webhookRepository ports.WebhookRepository
+	deliveryLog       ports.WebhookDeliveryLog
+}
+
+func NewWebhookService(userRepository ports.UserRepository, webhookRepository ports.WebhookRepository, deliveryLog ports.WebhookDeliveryLog) *WebhookService {
+	return &WebhookService{
+		userRepository:    userRepository,
+		webhookRepository: webhookRepository,
+		deliveryLog:       deliveryLog,
+	}
+}
+
+// RegisterWebhook registers rawURL to be notified of events of the given types,
+// or of all of them when there are none. The webhook is global unless a user
+// id is given. A secret is generated when none is given.
+func (w *WebhookService) RegisterWebhook(rawURL string, secret string, userID string, events []string) (*models.Webhook, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, &ErrInvalidWebhook{Reason: "url must be an absolute http or https url"}
+	}
+
+	webhook := models.Webhook{
+		ID:        uuid.NewString(),
+		URL:       parsed.String(),
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if userID != "" {
+		id, err := models.FromString(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if w.userRepository.GetByID(id) == nil {
+			return nil, &ErrUserDoesNotExist{}
+		}
+
+		webhook.UserID = &id
+	}
+
+	for _, event := range events {
+		eventType, ok := webhookEventType(event)
+		if !ok {
+			return nil, &ErrInvalidWebhook{Reason: "unknown event " + event}
+		}
+
+		webhook.Events = append(webhook.Events, eventType)
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret, err = generateSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = w.webhookRepository.SaveWebhook(&webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (w *WebhookService) ListWebhooks() []*models.Webhook {
+	return w.webhookRepository.ListWebhooks()
+}
+
+func (w *WebhookService) GetWebhook(id string) (*models.Webhook, error) {
+	webhook := w.webhookRepository.GetWebhook(id)
+	if webhook == nil {
+		return nil, &ErrWebhookDoesNotExist{ID: id}
+	}
+
+	return webhook, nil
+}
+
+func (w *WebhookService) DeleteWebhook(id string) error {
+	if w.webhookRepository.GetWebhook(id) == nil {
+		return &ErrWebhookDoesNotExist{ID: id}
+	}
+
+	return w.webhookRepository.DeleteWebhook(id)
+}
+
+// Deliveries returns the last attempts at notifying the webhook, most recent
+// first.
+func (w *WebhookService) Deliveries(id string, limit int) ([]models.WebhookDelivery, error) {
+	if w.webhookRepository.GetWebhook(id) == nil {
+		return nil, &ErrWebhookDoesNotExist{ID: id}
+	}
+
+	return w.deliveryLog.Deliveries(id, limit)
+}
+
+func webhookEventType(event string) (models.EventType, bool) {
+	for _, eventType := range models.EventTypes {
+		if string(eventType) == event {
+			return eventType, true
+		}
+	}
+
+	return "", false
+}
+
+func generateSecret() (string, error) {
+	secret := make([]byte, WEBHOOK_SECRET_SIZE_BYTES)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,80 @@
+package application_test
+
+import (
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestRegisterWebhook(t *testing.T) {
+	userInRepository := models.NewUser("John_doe@test.com", "12345")
+	idOfUserInRepository := userInRepository.GetID()
+	anyURL := "https://example.com/hooks/sdisk"
+
+	repoWithoutUserMock := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+		return nil
+	}}
+
+	repoWithUserMock := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+		return userInRepository
+	}}
+
+	t.Run("RejectURLThatIsNotHTTP", func(t *testing.T) {
+		webhookRepoSpy := mocks.WebhookRepositoryMock{}
+		service := application.NewWebhookService(&repoWithUserMock, &webhookRepoSpy, &mocks.WebhookDeliveryLogMock{})
+
+		_, err := service.RegisterWebhook("file:///etc/passwd", "", "", nil)
+
+		assertError(t, err)
+		assertFalse(t, webhookRepoSpy.SaveWebhookCalled)
+	})
+
+	t.Run("RejectUnknownEvent", func(t *testing.T) {
+		webhookRepoSpy := mocks.WebhookRepositoryMock{}
+		service := application.NewWebhookService(&repoWithUserMock, &webhookRepoSpy, &mocks.WebhookDeliveryLogMock{})
+
+		_, err := service.RegisterWebhook(anyURL, "", "", []string{"file.renamed"})
+
+		assertError(t, err)
+		assertFalse(t, webhookRepoSpy.SaveWebhookCalled)
+	})
+
+	t.Run("ReturnErrUserDoesNotExist", func(t *testing.T) {
+		webhookRepoSpy := mocks.WebhookRepositoryMock{}
+		service := application.NewWebhookService(&repoWithoutUserMock, &webhookRepoSpy, &mocks.WebhookDeliveryLogMock{})
+
+		_, err := service.RegisterWebhook(anyURL, "", idOfUserInRepository.ToString(), nil)
+
+		if _, ok := err.(*application.ErrUserDoesNotExist); !ok {
+			t.Fatalf("Expected ErrUserDoesNotExist, got %v", err)
+		}
+	})
+
+	t.Run("SaveWebhookOfUserWithGeneratedSecret", func(t *testing.T) {
+		webhookRepoSpy := mocks.WebhookRepositoryMock{}
+		service := application.NewWebhookService(&repoWithUserMock, &webhookRepoSpy, &mocks.WebhookDeliveryLogMock{})
+
+		webhook, err := service.RegisterWebhook(anyURL, "", idOfUserInRepository.ToString(), []string{"file.updated"})
+
+		assertNoError(t, err)
+		assertTrue(t, webhookRepoSpy.SaveWebhookCalled)
+		assertTrue(t, webhook.Secret != "")
+		assertTrue(t, *webhook.UserID == idOfUserInRepository)
+		assertTrue(t, webhook.Matches(models.Event{Type: models.EventFileUpdated, UserID: idOfUserInRepository}))
+		assertFalse(t, webhook.Matches(models.Event{Type: models.EventFileDeleted, UserID: idOfUserInRepository}))
+	})
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	t.Run("ReturnErrWebhookDoesNotExist", func(t *testing.T) {
+		webhookRepoSpy := mocks.WebhookRepositoryMock{}
+		service := application.NewWebhookService(&mocks.UserRepositoryMock{}, &webhookRepoSpy, &mocks.WebhookDeliveryLogMock{})
+
+		err := service.DeleteWebhook("unknown")
+
+		assertError(t, err)
+		assertFalse(t, webhookRepoSpy.DeleteWebhookCalled)
+	})
+}
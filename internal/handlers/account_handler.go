@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+)
+
+const (
+	UpdateUserEndpoint  = "PATCH /users/{id}"
+	DeleteUserEndpoint  = "DELETE /users/{id}"
+	RestoreUserEndpoint = "POST /users/{id}/restore"
+)
+
+type AccountHandler struct {
+	updateUserService      *application.UpdateUserService
+	accountDeletionService *application.AccountDeletionService
+}
+
+// UpdateUserRequest changes the fields it has. CurrentPassword is required
+// with Email or Password.
+type UpdateUserRequest struct {
+	Email           *string `json:"email"`
+	Password        *string `json:"password"`
+	CurrentPassword string  `json:"currentPassword"`
+}
+
+// AccountDeletionResponse tells when a deleted account is purged. It can be
+// restored by an admin until then.
+type AccountDeletionResponse struct {
+	ID        string    `json:"id"`
+	DeletedAt time.Time `json:"deletedAt"`
+	PurgeAt   time.Time `json:"purgeAt"`
+}
+
+func NewAccountHandler(updateUserService *application.UpdateUserService, accountDeletionService *application.AccountDeletionService) *AccountHandler {
+	return &AccountHandler{
+		updateUserService:      updateUserService,
+		accountDeletionService: accountDeletionService,
+	}
+}
+
+func (h *AccountHandler) UpdateUserResource(writer http.ResponseWriter, req *http.Request) {
+	var updateRequest UpdateUserRequest
+
+	err := decodeJSON(writer, req, &updateRequest)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	if updateRequest.Email == nil && updateRequest.Password == nil {
+		writeError(writer, req, &ErrMalformedRequest{Reason: "the body must change the email or the password"})
+		return
+	}
+
+	user, err := h.updateUserService.UpdateUser(req.PathValue("id"), application.UserUpdate{
+		Email:           updateRequest.Email,
+		Password:        updateRequest.Password,
+		CurrentPassword: updateRequest.CurrentPassword,
+	})
+	if _, ok := err.(*application.ErrVerificationNotSent); ok {
+		slog.Warn("changed email without verification mail", "user", req.PathValue("id"), "error", err)
+	} else if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writeJSON(writer, http.StatusOK, toUserSummaryResponse(user))
+}
+
+func (h *AccountHandler) DeleteUserResource(writer http.ResponseWriter, req *http.Request) {
+	user, err := h.accountDeletionService.DeleteAccount(req.PathValue("id"))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	id := user.GetID()
+	writeJSON(writer, http.StatusAccepted, AccountDeletionResponse{
+		ID:        id.ToString(),
+		DeletedAt: user.GetDeletedAt(),
+		PurgeAt:   h.accountDeletionService.PurgeAt(user),
+	})
+}
+
+func (h *AccountHandler) RestoreUserResource(writer http.ResponseWriter, req *http.Request) {
+	user, err := h.accountDeletionService.RestoreAccount(req.PathValue("id"))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writeJSON(writer, http.StatusOK, toUserSummaryResponse(user))
+}
@@ -0,0 +1,180 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestAccountResources(t *testing.T) {
+	anyGracePeriod := 24 * time.Hour
+	newAccountHandler := func(userRepo *mocks.UserRepositoryMock) *handlers.AccountHandler {
+		authenticationService := application.NewAuthenticationService(userRepo, &mocks.PasswordHasherMock{})
+		sessionService := application.NewSessionService(userRepo, authenticationService, &mocks.SessionRepositoryMock{}, &serverDummy, time.Minute, time.Hour)
+		deviceService := application.NewDeviceService(userRepo, &mocks.DeviceRepositoryMock{}, &serverDummy)
+		updateUserService := application.NewUpdateUserService(userRepo, &tokenRepositoryDummy, &hasherDummy, models.NewDefaultPasswordPolicy(), sessionService, verificationService)
+		deletionService := application.NewAccountDeletionService(userRepo, &mocks.OneTimeTokenRepositoryMock{}, sessionService, deviceService, &serverDummy, &mocks.EventStreamMock{}, anyGracePeriod)
+		return handlers.NewAccountHandler(updateUserService, deletionService)
+	}
+	newRequest := func(method string, pattern string, id string) *http.Request {
+		req, _ := http.NewRequest(method, pattern, strings.NewReader(""))
+		req.SetPathValue("id", id)
+		return req
+	}
+	newPatchRequest := func(body string) *http.Request {
+		req := newJSONRequest(http.MethodPatch, handlers.UpdateUserEndpoint, strings.NewReader(body))
+		req.SetPathValue("id", idOfUserInRepository.ToString())
+		return req
+	}
+
+	t.Run("ChangeEmailReturnsOkAndUnverifiesIt", func(t *testing.T) {
+		setup()
+		userInRepository.SetPasswordHash("hashed:" + anyUserPassword)
+		userRepoWithFreeEmails := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+			return userInRepository
+		}}
+		accountHandler := newAccountHandler(&userRepoWithFreeEmails)
+		response := httptest.NewRecorder()
+
+		accountHandler.UpdateUserResource(response, newPatchRequest(`{"email": "Jane_Doe@test.com", "currentPassword": "`+anyUserPassword+`"}`))
+
+		assertStatus(t, response.Code, http.StatusOK)
+		var resp handlers.UserSummaryResponse
+		_ = json.Unmarshal(response.Body.Bytes(), &resp)
+		if resp.Email != "jane_doe@test.com" || resp.EmailVerified {
+			t.Fatalf("Expected the new unverified email, got %s", response.Body.String())
+		}
+	})
+
+	t.Run("ChangeEmailToTakenAddressReturnsConflict", func(t *testing.T) {
+		setup()
+		userInRepository.SetPasswordHash("hashed:" + anyUserPassword)
+		someoneElse := models.NewUser("someone_else@test.com", "hash")
+		userRepoWithTwoUsers := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+			return userInRepository
+		}, FnGetUserByEmail: func(email string) *models.User {
+			return someoneElse
+		}}
+		accountHandler := newAccountHandler(&userRepoWithTwoUsers)
+		response := httptest.NewRecorder()
+
+		accountHandler.UpdateUserResource(response, newPatchRequest(`{"email": "someone_else@test.com", "currentPassword": "`+anyUserPassword+`"}`))
+
+		assertProblem(t, response, http.StatusConflict, handlers.ProblemUserAlreadyExists)
+	})
+
+	t.Run("ChangeEmailWithoutCurrentPasswordReturnsBadRequest", func(t *testing.T) {
+		setup()
+		accountHandler := newAccountHandler(&userRepoWithUserMock)
+		response := httptest.NewRecorder()
+
+		accountHandler.UpdateUserResource(response, newPatchRequest(`{"email": "jane_doe@test.com"}`))
+
+		assertFieldErrors(t, response, "currentPassword")
+	})
+
+	t.Run("ChangePasswordWithoutCurrentPasswordReturnsBadRequest", func(t *testing.T) {
+		setup()
+		accountHandler := newAccountHandler(&userRepoWithUserMock)
+		response := httptest.NewRecorder()
+
+		accountHandler.UpdateUserResource(response, newPatchRequest(`{"password": "battery staple"}`))
+
+		assertFieldErrors(t, response, "currentPassword")
+	})
+
+	t.Run("ChangePasswordWithWrongCurrentPasswordReturnsForbidden", func(t *testing.T) {
+		setup()
+		accountHandler := newAccountHandler(&userRepoWithUserMock)
+		response := httptest.NewRecorder()
+
+		accountHandler.UpdateUserResource(response, newPatchRequest(`{"password": "battery staple", "currentPassword": "wrong password"}`))
+
+		assertProblem(t, response, http.StatusForbidden, handlers.ProblemIncorrectPassword)
+	})
+
+	t.Run("EmptyUpdateReturnsBadRequest", func(t *testing.T) {
+		setup()
+		accountHandler := newAccountHandler(&userRepoWithUserMock)
+		response := httptest.NewRecorder()
+
+		accountHandler.UpdateUserResource(response, newPatchRequest(`{}`))
+
+		assertProblem(t, response, http.StatusBadRequest, handlers.ProblemMalformedRequest)
+	})
+
+	t.Run("DeleteUserReturnsAcceptedWithPurgeTime", func(t *testing.T) {
+		setup()
+		accountHandler := newAccountHandler(&userRepoWithUserMock)
+		response := httptest.NewRecorder()
+
+		accountHandler.DeleteUserResource(response, newRequest(http.MethodDelete, handlers.DeleteUserEndpoint, idOfUserInRepository.ToString()))
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+		var resp handlers.AccountDeletionResponse
+		_ = json.Unmarshal(response.Body.Bytes(), &resp)
+		if !resp.PurgeAt.Equal(resp.DeletedAt.Add(anyGracePeriod)) || !userInRepository.IsDeleted() {
+			t.Fatalf("Expected the user to be deleted and purged after %s, got %+v", anyGracePeriod, resp)
+		}
+	})
+
+	t.Run("DeleteUnknownUserReturnsNotFound", func(t *testing.T) {
+		setup()
+		accountHandler := newAccountHandler(&userRepoEmptyMock)
+		response := httptest.NewRecorder()
+
+		accountHandler.DeleteUserResource(response, newRequest(http.MethodDelete, handlers.DeleteUserEndpoint, idOfUserInRepository.ToString()))
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("RestoreDeletedUserReturnsOk", func(t *testing.T) {
+		setup()
+		userInRepository.MarkDeleted(time.Now())
+		accountHandler := newAccountHandler(&userRepoWithUserMock)
+		response := httptest.NewRecorder()
+
+		accountHandler.RestoreUserResource(response, newRequest(http.MethodPost, handlers.RestoreUserEndpoint, idOfUserInRepository.ToString()))
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if userInRepository.IsDeleted() {
+			t.Fatalf("Expected the user to be restored")
+		}
+	})
+
+	t.Run("RestoreActiveUserReturnsConflict", func(t *testing.T) {
+		setup()
+		accountHandler := newAccountHandler(&userRepoWithUserMock)
+		response := httptest.NewRecorder()
+
+		accountHandler.RestoreUserResource(response, newRequest(http.MethodPost, handlers.RestoreUserEndpoint, idOfUserInRepository.ToString()))
+
+		assertProblem(t, response, http.StatusConflict, handlers.ProblemAccountNotDeleted)
+	})
+
+	t.Run("ListDeletedUserWithDeletionTime", func(t *testing.T) {
+		setup()
+		userInRepository.MarkDeleted(time.Now())
+		roleHandler := handlers.NewRoleHandler(application.NewRoleService(&mocks.UserRepositoryMock{FnListUsers: func() []*models.User {
+			return []*models.User{userInRepository}
+		}}))
+		response := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/users", nil)
+
+		roleHandler.ListUsersResource(response, req)
+
+		var resp []handlers.UserSummaryResponse
+		_ = json.Unmarshal(response.Body.Bytes(), &resp)
+		if len(resp) != 1 || resp[0].DeletedAt == nil {
+			t.Fatalf("Expected the deleted user to be listed with its deletion time, got %s", response.Body.String())
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+type sessionContextKey struct{}
+type userContextKey struct{}
+
+// Authenticator protects handlers with the bearer tokens handed out by
+// CreateSessionResource.
+type Authenticator struct {
+	sessionService *application.SessionService
+}
+
+func NewAuthenticator(sessionService *application.SessionService) *Authenticator {
+	return &Authenticator{
+		sessionService: sessionService,
+	}
+}
+
+// RequireSession answers 401 to requests without a valid bearer token. The
+// session and its user are available to next through SessionOf and UserOf.
+func (a *Authenticator) RequireSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) {
+		token, ok := bearerToken(req)
+		if !ok {
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(writer, req, &ErrMissingToken{})
+			return
+		}
+
+		session, user, err := a.sessionService.Authenticate(token)
+		if err != nil {
+			if _, ok := err.(*application.ErrInvalidToken); ok {
+				writer.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			}
+
+			writeError(writer, req, err)
+			return
+		}
+
+		ctx := context.WithValue(req.Context(), sessionContextKey{}, session)
+		ctx = context.WithValue(ctx, userContextKey{}, user)
+		next(writer, req.WithContext(ctx))
+	}
+}
+
+// RequireOwner is RequireSession, and answers 403 when the {id} of the route
+// is not the user of the session. Admins may act on every user.
+func (a *Authenticator) RequireOwner(next http.HandlerFunc) http.HandlerFunc {
+	return a.RequireSession(func(writer http.ResponseWriter, req *http.Request) {
+		if UserOf(req).IsAdmin() {
+			next(writer, req)
+			return
+		}
+
+		userID, err := models.FromString(req.PathValue("id"))
+		if err != nil || userID != SessionOf(req).UserID {
+			writeError(writer, req, &ErrNotOwner{})
+			return
+		}
+
+		next(writer, req)
+	})
+}
+
+// RequireRole is RequireSession, and answers 403 when the user of the session
+// does not have role.
+func (a *Authenticator) RequireRole(role models.Role, next http.HandlerFunc) http.HandlerFunc {
+	return a.RequireSession(func(writer http.ResponseWriter, req *http.Request) {
+		if UserOf(req).GetRole() != role {
+			writeError(writer, req, &ErrRoleRequired{Role: role})
+			return
+		}
+
+		next(writer, req)
+	})
+}
+
+// SessionOf returns the session of a request let through by the
+// Authenticator, or nil.
+func SessionOf(req *http.Request) *models.Session {
+	session, _ := req.Context().Value(sessionContextKey{}).(*models.Session)
+	return session
+}
+
+// UserOf returns the user of a request let through by the Authenticator, or
+// nil.
+func UserOf(req *http.Request) *models.User {
+	user, _ := req.Context().Value(userContextKey{}).(*models.User)
+	return user
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+const (
+	GetBandwidthEndpoint = "GET /bandwidth"
+	SetBandwidthEndpoint = "PUT /bandwidth"
+)
+
+type BandwidthHandler struct {
+	bandwidthService *application.BandwidthService
+}
+
+type BandwidthLimitsRequest struct {
+	Upload       uint64 `json:"uploadBytesPerSecond"`
+	Download     uint64 `json:"downloadBytesPerSecond"`
+	UserUpload   uint64 `json:"userUploadBytesPerSecond"`
+	UserDownload uint64 `json:"userDownloadBytesPerSecond"`
+}
+
+type TrafficStatsResponse struct {
+	Bytes          uint64  `json:"bytes"`
+	Throttled      uint64  `json:"throttled"`
+	ThrottledForMs float64 `json:"throttledForMs"`
+}
+
+type UserBandwidthStatsResponse struct {
+	Upload   TrafficStatsResponse `json:"upload"`
+	Download TrafficStatsResponse `json:"download"`
+}
+
+type BandwidthStatsResponse struct {
+	Limits   BandwidthLimitsRequest                `json:"limits"`
+	Upload   TrafficStatsResponse                  `json:"upload"`
+	Download TrafficStatsResponse                  `json:"download"`
+	Users    map[string]UserBandwidthStatsResponse `json:"users"`
+}
+
+func NewBandwidthHandler(bandwidthService *application.BandwidthService) *BandwidthHandler {
+	return &BandwidthHandler{
+		bandwidthService: bandwidthService,
+	}
+}
+
+func (h *BandwidthHandler) GetBandwidthResource(writer http.ResponseWriter, req *http.Request) {
+	stats := h.bandwidthService.GetStats()
+
+	resp := BandwidthStatsResponse{
+		Limits:   BandwidthLimitsRequest(stats.Limits),
+		Upload:   toTrafficStatsResponse(stats.Upload),
+		Download: toTrafficStatsResponse(stats.Download),
+		Users:    make(map[string]UserBandwidthStatsResponse, len(stats.Users)),
+	}
+
+	for userID, userStats := range stats.Users {
+		resp.Users[userID] = UserBandwidthStatsResponse{
+			Upload:   toTrafficStatsResponse(userStats.Upload),
+			Download: toTrafficStatsResponse(userStats.Download),
+		}
+	}
+
+	writeJSON(writer, http.StatusOK, resp)
+}
+
+func (h *BandwidthHandler) SetBandwidthResource(writer http.ResponseWriter, req *http.Request) {
+	var limitsRequest BandwidthLimitsRequest
+
+	err := decodeJSON(writer, req, &limitsRequest)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	err = h.bandwidthService.SetLimits(models.BandwidthLimits(limitsRequest))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func toTrafficStatsResponse(stats models.TrafficStats) TrafficStatsResponse {
+	return TrafficStatsResponse{
+		Bytes:          stats.Bytes,
+		Throttled:      stats.Throttled,
+		ThrottledForMs: float64(stats.ThrottledFor.Microseconds()) / 1000,
+	}
+}
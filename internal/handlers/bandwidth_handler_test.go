@@ -0,0 +1,76 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestGetBandwidthResource(t *testing.T) {
+	anyLimits := models.BandwidthLimits{Upload: 1024, Download: 2048}
+	serverMock := mocks.ServerMock{FnGetBandwidthStats: func() models.BandwidthStats {
+		return models.BandwidthStats{Limits: anyLimits}
+	}}
+	bandwidthHandler := handlers.NewBandwidthHandler(application.NewBandwidthService(&serverMock))
+
+	t.Run("ReturnHttpOk", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		getRequest, _ := http.NewRequest(http.MethodGet, handlers.GetBandwidthEndpoint, nil)
+
+		bandwidthHandler.GetBandwidthResource(response, getRequest)
+
+		assertStatus(t, response.Code, http.StatusOK)
+	})
+
+	t.Run("ReturnLimitsOfServer", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		getRequest, _ := http.NewRequest(http.MethodGet, handlers.GetBandwidthEndpoint, nil)
+
+		bandwidthHandler.GetBandwidthResource(response, getRequest)
+
+		var body handlers.BandwidthStatsResponse
+		_ = json.Unmarshal(response.Body.Bytes(), &body)
+		if body.Limits.Upload != anyLimits.Upload || body.Limits.Download != anyLimits.Download {
+			t.Fatalf("Expected limits %v, got %v", anyLimits, body.Limits)
+		}
+	})
+}
+
+func TestSetBandwidthResource(t *testing.T) {
+	t.Run("ReturnHttpBadRequestIfParseError", func(t *testing.T) {
+		serverSpy := mocks.ServerMock{}
+		bandwidthHandler := handlers.NewBandwidthHandler(application.NewBandwidthService(&serverSpy))
+		response := httptest.NewRecorder()
+		putRequest := newJSONRequest(http.MethodPut, handlers.SetBandwidthEndpoint, strings.NewReader("{"))
+
+		bandwidthHandler.SetBandwidthResource(response, putRequest)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+		if serverSpy.SetBandwidthLimitsCalled {
+			t.Fatalf("Limits should not have been changed")
+		}
+	})
+
+	t.Run("ServerReceivesLimitsAndReturnHttpNoContent", func(t *testing.T) {
+		serverSpy := mocks.ServerMock{}
+		bandwidthHandler := handlers.NewBandwidthHandler(application.NewBandwidthService(&serverSpy))
+		response := httptest.NewRecorder()
+		body := "{\"uploadBytesPerSecond\": 1024, \"userDownloadBytesPerSecond\": 512}"
+		putRequest := newJSONRequest(http.MethodPut, handlers.SetBandwidthEndpoint, strings.NewReader(body))
+
+		bandwidthHandler.SetBandwidthResource(response, putRequest)
+
+		assertStatus(t, response.Code, http.StatusNoContent)
+		want := models.BandwidthLimits{Upload: 1024, UserDownload: 512}
+		if serverSpy.SetBandwidthLimitsCalledWith != want {
+			t.Fatalf("Expected limits %v, got %v", want, serverSpy.SetBandwidthLimitsCalledWith)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+)
+
+const (
+	GetChangesEndpoint = "GET /users/{id}/changes"
+)
+
+const (
+	DEFAULT_CHANGES_PAGE_SIZE = 1000
+	MAX_CHANGES_PAGE_SIZE     = 10000
+)
+
+type ChangesHandler struct {
+	changesService *application.ChangesService
+}
+
+type ChangeResponse struct {
+	Seq    uint64    `json:"seq"`
+	Op     string    `json:"op"`
+	Path   string    `json:"path"`
+	Size   uint64    `json:"size,omitempty"`
+	Hash   string    `json:"hash,omitempty"`
+	Device string    `json:"device,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+type ChangesResponse struct {
+	Changes []ChangeResponse `json:"changes"`
+	Cursor  uint64           `json:"cursor"`
+	More    bool             `json:"more"`
+}
+
+func NewChangesHandler(changesService *application.ChangesService) *ChangesHandler {
+	return &ChangesHandler{
+		changesService: changesService,
+	}
+}
+
+// GetChangesResource lists the changes made after the cursor given by the
+// since query parameter. A cursor that cannot be resumed from is gone and the
+// client has to fetch the whole disk again.
+func (h *ChangesHandler) GetChangesResource(writer http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	since, err := parseUintQuery(req, "since", 0)
+	if err != nil {
+		writeError(writer, req, &ErrInvalidParameter{Name: "since", Reason: "must be a cursor"})
+		return
+	}
+
+	limit, err := parseUintQuery(req, "limit", DEFAULT_CHANGES_PAGE_SIZE)
+	if err != nil || limit == 0 || limit > MAX_CHANGES_PAGE_SIZE {
+		writeError(writer, req, &ErrInvalidParameter{Name: "limit", Reason: fmt.Sprintf("must be between 1 and %d", MAX_CHANGES_PAGE_SIZE)})
+		return
+	}
+
+	page, err := h.changesService.GetChanges(id, since, int(limit))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	resp := ChangesResponse{
+		Changes: make([]ChangeResponse, 0, len(page.Changes)),
+		Cursor:  page.Cursor,
+		More:    page.More,
+	}
+
+	for _, change := range page.Changes {
+		resp.Changes = append(resp.Changes, ChangeResponse{
+			Seq:    change.Seq,
+			Op:     string(change.Op),
+			Path:   change.Path,
+			Size:   change.Size,
+			Hash:   change.Hash,
+			Device: change.Device,
+			Time:   change.Time,
+		})
+	}
+
+	writeJSON(writer, http.StatusOK, resp)
+}
+
+func parseUintQuery(req *http.Request, name string, fallback uint64) (uint64, error) {
+	value := req.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	return strconv.ParseUint(value, 10, 64)
+}
@@ -0,0 +1,74 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestGetChangesResource(t *testing.T) {
+	journalWithChangesMock := mocks.ChangeJournalMock{FnSince: func(userID models.UserID, cursor uint64) ([]models.Change, uint64, error) {
+		return []models.Change{{Seq: 5, Op: models.ChangeUpdate, Path: "a.txt"}}, 5, nil
+	}}
+
+	journalWithExpiredCursorMock := mocks.ChangeJournalMock{FnSince: func(userID models.UserID, cursor uint64) ([]models.Change, uint64, error) {
+		return nil, 5, &models.ErrCursorExpired{Cursor: cursor}
+	}}
+
+	t.Run("IfUserDoesNotExistReturnHttpNotFound", func(t *testing.T) {
+		setup()
+		changesHandler := handlers.NewChangesHandler(application.NewChangesService(&userRepoEmptyMock, &journalWithChangesMock))
+		response := httptest.NewRecorder()
+
+		changesHandler.GetChangesResource(response, changesRequest("?since=4"))
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("IfCursorIsNotANumberReturnHttpBadRequest", func(t *testing.T) {
+		setup()
+		changesHandler := handlers.NewChangesHandler(application.NewChangesService(&userRepoWithUserMock, &journalWithChangesMock))
+		response := httptest.NewRecorder()
+
+		changesHandler.GetChangesResource(response, changesRequest("?since=yesterday"))
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("IfCursorExpiredReturnHttpGone", func(t *testing.T) {
+		setup()
+		changesHandler := handlers.NewChangesHandler(application.NewChangesService(&userRepoWithUserMock, &journalWithExpiredCursorMock))
+		response := httptest.NewRecorder()
+
+		changesHandler.GetChangesResource(response, changesRequest("?since=1"))
+
+		assertStatus(t, response.Code, http.StatusGone)
+	})
+
+	t.Run("ReturnChangesAndCursor", func(t *testing.T) {
+		setup()
+		changesHandler := handlers.NewChangesHandler(application.NewChangesService(&userRepoWithUserMock, &journalWithChangesMock))
+		response := httptest.NewRecorder()
+
+		changesHandler.GetChangesResource(response, changesRequest("?since=4"))
+
+		var body handlers.ChangesResponse
+		_ = json.Unmarshal(response.Body.Bytes(), &body)
+		assertStatus(t, response.Code, http.StatusOK)
+		if len(body.Changes) != 1 || body.Changes[0].Path != "a.txt" || body.Cursor != 5 {
+			t.Fatalf("Unexpected changes %+v", body)
+		}
+	})
+}
+
+func changesRequest(query string) *http.Request {
+	getRequest, _ := http.NewRequest(http.MethodGet, "/users/"+idOfUserInRepository.ToString()+"/changes"+query, nil)
+	getRequest.SetPathValue("id", idOfUserInRepository.ToString())
+	return getRequest
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+)
+
+const (
+	DisconnectUserEndpoint = "DELETE /users/{id}/connections"
+)
+
+type ConnectionHandler struct {
+	disconnectUserService *application.DisconnectUserService
+}
+
+func NewConnectionHandler(disconnectUserService *application.DisconnectUserService) *ConnectionHandler {
+	return &ConnectionHandler{
+		disconnectUserService: disconnectUserService,
+	}
+}
+
+func (h *ConnectionHandler) DisconnectUserResource(writer http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	err := h.disconnectUserService.DisconnectUser(id)
+
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,58 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestDisconnectUserResource(t *testing.T) {
+	t.Run("IfUserDoesNotExistReturnHttpNotFound", func(t *testing.T) {
+		setup()
+		service := application.NewDisconnectUserService(&userRepoEmptyMock, &serverDummy)
+		connectionHandler := handlers.NewConnectionHandler(service)
+		response := httptest.NewRecorder()
+		deleteRequest, _ := http.NewRequest(http.MethodDelete, handlers.DisconnectUserEndpoint, strings.NewReader(""))
+		deleteRequest.SetPathValue("id", idOfUserInRepository.ToString())
+
+		connectionHandler.DisconnectUserResource(response, deleteRequest)
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("IfUserIsDisconnectedReturnHttpNoContent", func(t *testing.T) {
+		setup()
+		service := application.NewDisconnectUserService(&userRepoWithUserMock, &serverDummy)
+		connectionHandler := handlers.NewConnectionHandler(service)
+		response := httptest.NewRecorder()
+		deleteRequest, _ := http.NewRequest(http.MethodDelete, handlers.DisconnectUserEndpoint, strings.NewReader(""))
+		deleteRequest.SetPathValue("id", idOfUserInRepository.ToString())
+
+		connectionHandler.DisconnectUserResource(response, deleteRequest)
+
+		assertStatus(t, response.Code, http.StatusNoContent)
+	})
+
+	t.Run("IfServerFailsReturnInternalError", func(t *testing.T) {
+		setup()
+		serverMockThatFails := mocks.ServerMock{FnDisconnectUser: func(u *models.User) error {
+			return errors.New("server failed to disconnect user")
+		}}
+		service := application.NewDisconnectUserService(&userRepoWithUserMock, &serverMockThatFails)
+		connectionHandler := handlers.NewConnectionHandler(service)
+		response := httptest.NewRecorder()
+		deleteRequest, _ := http.NewRequest(http.MethodDelete, handlers.DisconnectUserEndpoint, strings.NewReader(""))
+		deleteRequest.SetPathValue("id", idOfUserInRepository.ToString())
+
+		connectionHandler.DisconnectUserResource(response, deleteRequest)
+
+		assertStatus(t, response.Code, http.StatusInternalServerError)
+	})
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+const (
+	RegisterDeviceEndpoint = "POST /users/{id}/devices"
+	ListDevicesEndpoint    = "GET /users/{id}/devices"
+	RevokeDeviceEndpoint   = "DELETE /users/{id}/devices/{deviceId}"
+)
+
+type DeviceHandler struct {
+	deviceService *application.DeviceService
+}
+
+type DeviceRequest struct {
+	Name string `json:"name"`
+}
+
+type DeviceResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Version    string     `json:"version,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+}
+
+// RegisteredDeviceResponse is only sent when a device is registered, as its
+// credential cannot be read again.
+type RegisteredDeviceResponse struct {
+	DeviceResponse
+	Credential string `json:"credential"`
+}
+
+func NewDeviceHandler(deviceService *application.DeviceService) *DeviceHandler {
+	return &DeviceHandler{
+		deviceService: deviceService,
+	}
+}
+
+func (h *DeviceHandler) RegisterDeviceResource(writer http.ResponseWriter, req *http.Request) {
+	var deviceRequest DeviceRequest
+
+	err := decodeJSON(writer, req, &deviceRequest)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	credential, device, err := h.deviceService.RegisterDevice(req.PathValue("id"), deviceRequest.Name)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writer.Header().Set("Cache-Control", "no-store")
+	writeJSON(writer, http.StatusCreated, RegisteredDeviceResponse{
+		DeviceResponse: toDeviceResponse(device),
+		Credential:     credential,
+	})
+}
+
+func (h *DeviceHandler) ListDevicesResource(writer http.ResponseWriter, req *http.Request) {
+	devices, err := h.deviceService.ListDevices(req.PathValue("id"))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	resp := make([]DeviceResponse, 0, len(devices))
+	for _, device := range devices {
+		resp = append(resp, toDeviceResponse(device))
+	}
+
+	writeJSON(writer, http.StatusOK, resp)
+}
+
+func (h *DeviceHandler) RevokeDeviceResource(writer http.ResponseWriter, req *http.Request) {
+	err := h.deviceService.RevokeDevice(req.PathValue("id"), req.PathValue("deviceId"))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func toDeviceResponse(device *models.Device) DeviceResponse {
+	resp := DeviceResponse{
+		ID:        device.ID,
+		Name:      device.Name,
+		Version:   device.Version,
+		CreatedAt: device.CreatedAt,
+	}
+
+	if !device.LastSeenAt.IsZero() {
+		lastSeenAt := device.LastSeenAt
+		resp.LastSeenAt = &lastSeenAt
+	}
+
+	return resp
+}
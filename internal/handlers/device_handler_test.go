@@ -0,0 +1,91 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestDeviceResources(t *testing.T) {
+	user := models.NewUser("John_doe@test.com", "hashed:correct horse")
+	userRepo := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+		if id == user.GetID() {
+			return user
+		}
+		return nil
+	}}
+
+	serve := func(deviceRepo *mocks.DeviceRepositoryMock, method string, target string, body string) *httptest.ResponseRecorder {
+		deviceHandler := handlers.NewDeviceHandler(application.NewDeviceService(&userRepo, deviceRepo, &mocks.ServerMock{}))
+		router := http.NewServeMux()
+		router.HandleFunc(handlers.RegisterDeviceEndpoint, deviceHandler.RegisterDeviceResource)
+		router.HandleFunc(handlers.ListDevicesEndpoint, deviceHandler.ListDevicesResource)
+		router.HandleFunc(handlers.RevokeDeviceEndpoint, deviceHandler.RevokeDeviceResource)
+		response := httptest.NewRecorder()
+		request := newJSONRequest(method, target, strings.NewReader(body))
+
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("ReturnHttpCreatedWithCredential", func(t *testing.T) {
+		response := serve(&mocks.DeviceRepositoryMock{}, http.MethodPost, "/users/"+idOf(user)+"/devices", "{\"name\": \"laptop\"}")
+
+		assertStatus(t, response.Code, http.StatusCreated)
+		var body handlers.RegisteredDeviceResponse
+		_ = json.Unmarshal(response.Body.Bytes(), &body)
+		if body.Credential == "" || body.Name != "laptop" || body.ID == "" {
+			t.Errorf("Expected the device with its credential, got %+v", body)
+		}
+	})
+
+	t.Run("ReturnHttpBadRequestIfNameIsMissing", func(t *testing.T) {
+		response := serve(&mocks.DeviceRepositoryMock{}, http.MethodPost, "/users/"+idOf(user)+"/devices", "{}")
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("ReturnHttpNotFoundIfUserDoesNotExist", func(t *testing.T) {
+		unknownUserID := models.NewUserID()
+
+		response := serve(&mocks.DeviceRepositoryMock{}, http.MethodPost, "/users/"+unknownUserID.ToString()+"/devices", "{\"name\": \"laptop\"}")
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("ReturnHttpOkWithDevicesWithoutCredentials", func(t *testing.T) {
+		deviceRepo := mocks.DeviceRepositoryMock{FnListDevices: func(userID models.UserID) []*models.Device {
+			return []*models.Device{{ID: "device", Name: "laptop", CredentialHash: "hash", Version: "1.2.3"}}
+		}}
+
+		response := serve(&deviceRepo, http.MethodGet, "/users/"+idOf(user)+"/devices", "")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if strings.Contains(response.Body.String(), "hash") || !strings.Contains(response.Body.String(), "1.2.3") {
+			t.Errorf("Expected the devices without their credential, got %s", response.Body.String())
+		}
+	})
+
+	t.Run("ReturnHttpNoContentWhenDeviceIsRevoked", func(t *testing.T) {
+		deviceRepo := mocks.DeviceRepositoryMock{FnGetDevice: func(id string) *models.Device {
+			return &models.Device{ID: id, UserID: user.GetID()}
+		}}
+
+		response := serve(&deviceRepo, http.MethodDelete, "/users/"+idOf(user)+"/devices/device", "")
+
+		assertStatus(t, response.Code, http.StatusNoContent)
+	})
+
+	t.Run("ReturnHttpNotFoundWhenDeviceIsUnknown", func(t *testing.T) {
+		response := serve(&mocks.DeviceRepositoryMock{}, http.MethodDelete, "/users/"+idOf(user)+"/devices/unknown", "")
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+const (
+	GetEventsEndpoint = "GET /users/{id}/events"
+)
+
+const DEFAULT_EVENTS_KEEPALIVE_MS = 15 * 1000
+
+type EventsHandler struct {
+	eventsService *application.EventsService
+	keepAlive     time.Duration
+}
+
+type EventResponse struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Path      string    `json:"path,omitempty"`
+	Size      uint64    `json:"size,omitempty"`
+	Hash      string    `json:"hash,omitempty"`
+	UsedBytes uint64    `json:"usedBytes"`
+	DiskBytes uint64    `json:"diskBytes"`
+}
+
+func NewEventsHandler(eventsService *application.EventsService) *EventsHandler {
+	return &EventsHandler{
+		eventsService: eventsService,
+		keepAlive:     DEFAULT_EVENTS_KEEPALIVE_MS * time.Millisecond,
+	}
+}
+
+// GetEventsResource streams the events of a user as server-sent events until
+// the client goes away. A client resumes after the last event it received by
+// sending its id in the Last-Event-ID header.
+func (h *EventsHandler) GetEventsResource(writer http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		writeError(writer, req, errors.New("the response cannot be streamed"))
+		return
+	}
+
+	var lastEventID uint64
+	if header := req.Header.Get("Last-Event-ID"); header != "" {
+		parsed, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			writeError(writer, req, &ErrInvalidParameter{Name: "Last-Event-ID", Reason: "must be the id of an event"})
+			return
+		}
+		lastEventID = parsed
+	}
+
+	subscription, err := h.eventsService.Subscribe(id, lastEventID)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	defer subscription.Cancel()
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(http.StatusOK)
+
+	for _, event := range subscription.Replay {
+		if writeEvent(writer, event) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(h.keepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+
+		case <-keepAlive.C:
+			_, err := fmt.Fprint(writer, ": keep-alive\n\n")
+			if err != nil {
+				return
+			}
+
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return
+			}
+
+			if writeEvent(writer, event) != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+func writeEvent(writer http.ResponseWriter, event models.Event) error {
+	data, err := json.Marshal(EventResponse{
+		Type:      string(event.Type),
+		Time:      event.Time,
+		Path:      event.Path,
+		Size:      event.Size,
+		Hash:      event.Hash,
+		UsedBytes: event.UsedBytes,
+		DiskBytes: event.DiskBytes,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,67 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestGetEventsResource(t *testing.T) {
+	t.Run("IfUserDoesNotExistReturnHttpNotFound", func(t *testing.T) {
+		setup()
+		eventsHandler := handlers.NewEventsHandler(application.NewEventsService(&userRepoEmptyMock, &mocks.EventStreamMock{}))
+		response := httptest.NewRecorder()
+
+		eventsHandler.GetEventsResource(response, eventsRequest(""))
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("IfLastEventIDIsInvalidReturnHttpBadRequest", func(t *testing.T) {
+		setup()
+		eventsHandler := handlers.NewEventsHandler(application.NewEventsService(&userRepoWithUserMock, &mocks.EventStreamMock{}))
+		response := httptest.NewRecorder()
+
+		eventsHandler.GetEventsResource(response, eventsRequest("not an id"))
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("StreamEventsAfterLastEventID", func(t *testing.T) {
+		setup()
+		streamSpy := mocks.EventStreamMock{FnSubscribe: func(userID models.UserID, lastEventID uint64) ([]models.Event, <-chan models.Event, func()) {
+			events := make(chan models.Event, 1)
+			events <- models.Event{ID: 8, Type: models.EventFileDeleted, Path: "b.txt"}
+			close(events)
+			return []models.Event{{ID: 7, Type: models.EventFileUpdated, Path: "a.txt"}}, events, func() {}
+		}}
+		eventsHandler := handlers.NewEventsHandler(application.NewEventsService(&userRepoWithUserMock, &streamSpy))
+		response := httptest.NewRecorder()
+
+		eventsHandler.GetEventsResource(response, eventsRequest("6"))
+
+		body := response.Body.String()
+		assertStatus(t, response.Code, http.StatusOK)
+		if streamSpy.SubscribeCalledWith != 6 {
+			t.Fatalf("Expected to resume after event 6, got %d", streamSpy.SubscribeCalledWith)
+		}
+		if !strings.Contains(body, "id: 7\nevent: file.updated\n") || !strings.Contains(body, "id: 8\nevent: file.deleted\n") {
+			t.Fatalf("Unexpected event stream %q", body)
+		}
+	})
+}
+
+func eventsRequest(lastEventID string) *http.Request {
+	getRequest, _ := http.NewRequest(http.MethodGet, "/users/"+idOfUserInRepository.ToString()+"/events", nil)
+	getRequest.SetPathValue("id", idOfUserInRepository.ToString())
+	if lastEventID != "" {
+		getRequest.Header.Set("Last-Event-ID", lastEventID)
+	}
+	return getRequest
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+const (
+	MetricsEndpoint = "GET /metrics"
+)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type MetricsHandler struct {
+	metrics ports.Metrics
+}
+
+func NewMetricsHandler(metrics ports.Metrics) *MetricsHandler {
+	return &MetricsHandler{
+		metrics: metrics,
+	}
+}
+
+func (handler *MetricsHandler) MetricsResource(writer http.ResponseWriter, req *http.Request) {
+	writer.Header().Set("Content-Type", metricsContentType)
+	writer.WriteHeader(http.StatusOK)
+	_ = handler.metrics.WriteText(writer)
+}
+
+// Instrument records the status and duration of every request answered by
+// handler under route, the pattern it is registered with.
+func Instrument(metrics ports.Metrics, route string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+
+		handler(recorder, req)
+
+		metrics.ObserveHTTPRequest(route, recorder.status, time.Since(start))
+	}
+}
+
+// statusRecorder keeps the status written by a handler. It still lets
+// handlers flush and hijack the connection, for events and WebSockets.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	r.status = http.StatusSwitchingProtocols
+	r.wroteHeader = true
+	return hijacker.Hijack()
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
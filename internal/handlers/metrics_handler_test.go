@@ -0,0 +1,93 @@
+package handlers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+)
+
+func TestMetricsResource(t *testing.T) {
+	anyMetrics := "sdisk_sessions_active 2\n"
+	metricsMock := mocks.MetricsMock{FnWriteText: func(w io.Writer) error {
+		_, err := io.WriteString(w, anyMetrics)
+		return err
+	}}
+	metricsHandler := handlers.NewMetricsHandler(&metricsMock)
+
+	t.Run("ReturnHttpOk", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		getRequest, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+
+		metricsHandler.MetricsResource(response, getRequest)
+
+		assertStatus(t, response.Code, http.StatusOK)
+	})
+
+	t.Run("ReturnMetricsAsText", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		getRequest, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+
+		metricsHandler.MetricsResource(response, getRequest)
+
+		if !strings.HasPrefix(response.Header().Get("Content-Type"), "text/plain") {
+			t.Errorf("Expected a text/plain content type but got %s", response.Header().Get("Content-Type"))
+		}
+		assertEquals(t, response.Body.Bytes(), []byte(anyMetrics))
+	})
+}
+
+func TestInstrument(t *testing.T) {
+	t.Run("ObserveRouteAndStatusOfRequest", func(t *testing.T) {
+		metricsMock := mocks.MetricsMock{}
+		handler := handlers.Instrument(&metricsMock, handlers.GetUserEndpoint, func(writer http.ResponseWriter, req *http.Request) {
+			writer.WriteHeader(http.StatusNotFound)
+		})
+		response := httptest.NewRecorder()
+		getRequest, _ := http.NewRequest(http.MethodGet, "/users/abc", nil)
+
+		handler(response, getRequest)
+
+		if metricsMock.ObserveHTTPRequestRoute != handlers.GetUserEndpoint {
+			t.Errorf("Expected route %s but got %s", handlers.GetUserEndpoint, metricsMock.ObserveHTTPRequestRoute)
+		}
+		if metricsMock.ObserveHTTPRequestStatus != http.StatusNotFound {
+			t.Errorf("Expected status %d but got %d", http.StatusNotFound, metricsMock.ObserveHTTPRequestStatus)
+		}
+	})
+
+	t.Run("ObserveHttpOkWhenHandlerOnlyWrites", func(t *testing.T) {
+		metricsMock := mocks.MetricsMock{}
+		handler := handlers.Instrument(&metricsMock, handlers.PingEndpoint, func(writer http.ResponseWriter, req *http.Request) {
+			_, _ = writer.Write([]byte("pong"))
+		})
+		response := httptest.NewRecorder()
+		getRequest, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+
+		handler(response, getRequest)
+
+		if metricsMock.ObserveHTTPRequestStatus != http.StatusOK {
+			t.Errorf("Expected status %d but got %d", http.StatusOK, metricsMock.ObserveHTTPRequestStatus)
+		}
+	})
+
+	t.Run("LetHandlerFlush", func(t *testing.T) {
+		metricsMock := mocks.MetricsMock{}
+		flushed := false
+		handler := handlers.Instrument(&metricsMock, handlers.GetEventsEndpoint, func(writer http.ResponseWriter, req *http.Request) {
+			_, flushed = writer.(http.Flusher)
+		})
+		response := httptest.NewRecorder()
+		getRequest, _ := http.NewRequest(http.MethodGet, "/users/abc/events", nil)
+
+		handler(response, getRequest)
+
+		if !flushed {
+			t.Errorf("Expected the handler to be able to flush")
+		}
+	})
+}
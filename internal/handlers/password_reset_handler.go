@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+)
+
+const (
+	RequestPasswordResetEndpoint = "POST /password-resets"
+	ResetPasswordEndpoint        = "POST /password-resets/confirm"
+)
+
+type PasswordResetHandler struct {
+	passwordResetService *application.PasswordResetService
+	resetThrottle        *application.LoginThrottle
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func NewPasswordResetHandler(passwordResetService *application.PasswordResetService, resetThrottle *application.LoginThrottle) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		passwordResetService: passwordResetService,
+		resetThrottle:        resetThrottle,
+	}
+}
+
+// RequestPasswordResetResource answers 202 whether or not the email has an
+// account, and even when the mail could not be sent, which is only logged.
+// Every request counts against the address and the email it comes with, so
+// mailboxes cannot be flooded with resets.
+func (h *PasswordResetHandler) RequestPasswordResetResource(writer http.ResponseWriter, req *http.Request) {
+	var passwordResetRequest PasswordResetRequest
+
+	err := decodeJSON(writer, req, &passwordResetRequest)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	address := remoteHost(req)
+	err = h.resetThrottle.Allow(address, passwordResetRequest.Email, time.Now())
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	h.resetThrottle.Failed(address, passwordResetRequest.Email, time.Now())
+
+	err = h.passwordResetService.RequestReset(passwordResetRequest.Email)
+	if err != nil {
+		slog.Warn("could not send password reset mail", "error", err)
+	}
+
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+func (h *PasswordResetHandler) ResetPasswordResource(writer http.ResponseWriter, req *http.Request) {
+	var resetPasswordRequest ResetPasswordRequest
+
+	err := decodeJSON(writer, req, &resetPasswordRequest)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	err = h.passwordResetService.ResetPassword(resetPasswordRequest.Token, resetPasswordRequest.Password)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,138 @@
+package handlers_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestPasswordResetResources(t *testing.T) {
+	user := models.NewUser("john_doe@test.com", "hashed:correct horse")
+	userRepo := mocks.UserRepositoryMock{FnGetUserByEmail: func(email string) *models.User {
+		if email == user.GetEmail() {
+			return user
+		}
+		return nil
+	}, FnGetUserByID: func(id models.UserID) *models.User {
+		return user
+	}}
+	hasher := mocks.PasswordHasherMock{}
+	tokenRepository := infrastructure.NewRamTokenRepository()
+
+	serveWithThrottle := func(resetThrottle *application.LoginThrottle, mailer *mocks.MailerMock, method string, target string, body string) *httptest.ResponseRecorder {
+		sessionService := application.NewSessionService(&userRepo, application.NewAuthenticationService(&userRepo, &hasher), infrastructure.NewRamSessionRepository(), &mocks.ServerMock{}, time.Minute, time.Hour)
+		passwordResetService := application.NewPasswordResetService(&userRepo, tokenRepository, mailer, &hasher, models.NewDefaultPasswordPolicy(), sessionService)
+		passwordResetHandler := handlers.NewPasswordResetHandler(passwordResetService, resetThrottle)
+		router := http.NewServeMux()
+		router.HandleFunc(handlers.RequestPasswordResetEndpoint, passwordResetHandler.RequestPasswordResetResource)
+		router.HandleFunc(handlers.ResetPasswordEndpoint, passwordResetHandler.ResetPasswordResource)
+		response := httptest.NewRecorder()
+		request := newJSONRequest(method, target, strings.NewReader(body))
+
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	serve := func(mailer *mocks.MailerMock, method string, target string, body string) *httptest.ResponseRecorder {
+		return serveWithThrottle(application.NewLoginThrottle(application.DEFAULT_RESET_MAX_REQUESTS, time.Minute), mailer, method, target, body)
+	}
+
+	t.Run("ReturnHttpAcceptedWhenResetIsMailed", func(t *testing.T) {
+		mailerSpy := mocks.MailerMock{}
+
+		response := serve(&mailerSpy, http.MethodPost, "/password-resets", "{\"email\": \"john_doe@test.com\"}")
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+		if !mailerSpy.SendCalled {
+			t.Errorf("Expected the reset to be mailed")
+		}
+	})
+
+	t.Run("ReturnHttpAcceptedIfEmailIsUnknown", func(t *testing.T) {
+		mailerSpy := mocks.MailerMock{}
+
+		response := serve(&mailerSpy, http.MethodPost, "/password-resets", "{\"email\": \"unknown@test.com\"}")
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+		if mailerSpy.SendCalled {
+			t.Errorf("Expected no mail for an unknown email")
+		}
+	})
+
+	t.Run("ReturnHttpAcceptedIfMailerFails", func(t *testing.T) {
+		mailerThatFails := mocks.MailerMock{FnSend: func(mail models.Mail) error {
+			return errors.New("relay unreachable")
+		}}
+
+		response := serve(&mailerThatFails, http.MethodPost, "/password-resets", "{\"email\": \"john_doe@test.com\"}")
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+	})
+
+	t.Run("ReturnHttpTooManyRequestsAfterTooManyRequestsForAnEmail", func(t *testing.T) {
+		resetThrottle := application.NewLoginThrottle(application.DEFAULT_RESET_MAX_REQUESTS, time.Minute)
+		for range application.DEFAULT_RESET_MAX_REQUESTS {
+			_ = serveWithThrottle(resetThrottle, &mocks.MailerMock{}, http.MethodPost, "/password-resets", "{\"email\": \"unknown@test.com\"}")
+		}
+		mailerSpy := mocks.MailerMock{}
+
+		response := serveWithThrottle(resetThrottle, &mailerSpy, http.MethodPost, "/password-resets", "{\"email\": \"unknown@test.com\"}")
+
+		assertProblem(t, response, http.StatusTooManyRequests, handlers.ProblemTooManyAttempts)
+		if response.Header().Get("Retry-After") == "" {
+			t.Errorf("Expected a Retry-After header")
+		}
+	})
+
+	t.Run("ReturnHttpTooManyRequestsAfterTooManyRequestsFromAnAddress", func(t *testing.T) {
+		resetThrottle := application.NewLoginThrottle(application.DEFAULT_RESET_MAX_REQUESTS, time.Minute)
+		for i := range application.DEFAULT_RESET_MAX_REQUESTS {
+			_ = serveWithThrottle(resetThrottle, &mocks.MailerMock{}, http.MethodPost, "/password-resets", fmt.Sprintf("{\"email\": \"unknown%d@test.com\"}", i))
+		}
+		mailerSpy := mocks.MailerMock{}
+
+		response := serveWithThrottle(resetThrottle, &mailerSpy, http.MethodPost, "/password-resets", "{\"email\": \"john_doe@test.com\"}")
+
+		assertStatus(t, response.Code, http.StatusTooManyRequests)
+		if mailerSpy.SendCalled {
+			t.Errorf("Expected no mail once the address is throttled")
+		}
+	})
+
+	t.Run("ReturnHttpNoContentWhenPasswordIsReset", func(t *testing.T) {
+		mailerSpy := mocks.MailerMock{}
+		_ = serve(&mailerSpy, http.MethodPost, "/password-resets", "{\"email\": \"john_doe@test.com\"}")
+
+		response := serve(&mailerSpy, http.MethodPost, "/password-resets/confirm", "{\"token\": \""+mailedToken(mailerSpy.SendCalledWith)+"\", \"password\": \"battery staple\"}")
+
+		assertStatus(t, response.Code, http.StatusNoContent)
+		if user.GetPasswordHash() != "hashed:battery staple" {
+			t.Errorf("Expected the password to be replaced")
+		}
+	})
+
+	t.Run("ReturnHttpBadRequestIfPasswordIsTooWeak", func(t *testing.T) {
+		mailerSpy := mocks.MailerMock{}
+		_ = serve(&mailerSpy, http.MethodPost, "/password-resets", "{\"email\": \"john_doe@test.com\"}")
+
+		response := serve(&mailerSpy, http.MethodPost, "/password-resets/confirm", "{\"token\": \""+mailedToken(mailerSpy.SendCalledWith)+"\", \"password\": \"12345\"}")
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("ReturnHttpUnauthorizedIfTokenIsInvalid", func(t *testing.T) {
+		response := serve(&mocks.MailerMock{}, http.MethodPost, "/password-resets/confirm", "{\"token\": \"unknown\", \"password\": \"battery staple\"}")
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+	})
+}
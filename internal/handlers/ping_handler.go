@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 )
 
@@ -20,6 +20,6 @@ func NewPingHandler() *PingHandler {
 func (p *PingHandler) Ping(writer http.ResponseWriter, req *http.Request) {
 	_, err := writer.Write([]byte(response))
 	if err != nil {
-		log.Fatal("Error writing response in PingHandler")
+		slog.Error("could not write ping response", "error", err)
 	}
 }
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+const PROBLEM_CONTENT_TYPE = "application/problem+json"
+
+// PROBLEM_TYPE_PREFIX is followed by the code of a problem to make its type.
+const PROBLEM_TYPE_PREFIX = "urn:sdisk:problem:"
+
+// Codes of the problems answered by the API. They are stable, so clients can
+// rely on them rather than on the detail, which is meant for humans.
+const (
+	ProblemInternal             = "internal-error"
+	ProblemMalformedRequest     = "malformed-request"
+	ProblemUnsupportedMediaType = "unsupported-media-type"
+	ProblemRequestTooLarge      = "request-too-large"
+	ProblemInvalidFields        = "invalid-fields"
+	ProblemInvalidParameter     = "invalid-parameter"
+	ProblemWeakPassword         = "weak-password"
+	ProblemInvalidEmail         = "invalid-email"
+	ProblemMissingToken         = "missing-token"
+	ProblemInvalidToken         = "invalid-token"
+	ProblemInvalidCredentials   = "invalid-credentials"
+	ProblemTooManyAttempts      = "too-many-attempts"
+	ProblemIncorrectPassword    = "incorrect-password"
+	ProblemNotOwner             = "not-owner"
+	ProblemRoleRequired         = "role-required"
+	ProblemUserNotFound         = "user-not-found"
+	ProblemUserAlreadyExists    = "user-already-exists"
+	ProblemEmailNotVerified     = "email-not-verified"
+	ProblemEmailAlreadyVerified = "email-already-verified"
+	ProblemLastAdmin            = "last-admin"
+	ProblemAccountNotDeleted    = "account-not-deleted"
+	ProblemInvalidRole          = "invalid-role"
+	ProblemSessionNotFound      = "session-not-found"
+	ProblemDeviceNotFound       = "device-not-found"
+	ProblemInvalidDevice        = "invalid-device"
+	ProblemWebhookNotFound      = "webhook-not-found"
+	ProblemInvalidWebhook       = "invalid-webhook"
+	ProblemDiskAlreadyExists    = "disk-already-exists"
+	ProblemNoDisk               = "no-disk"
+	ProblemDiskQuotaExceeded    = "disk-quota-exceeded"
+	ProblemCursorExpired        = "cursor-expired"
+	ProblemTooManyConnections   = "too-many-connections"
+	ProblemServerBusy           = "server-busy"
+)
+
+// Problem is an RFC 7807 problem detail. Code is the stable code also found at
+// the end of Type, and Errors lists the rejected fields of the request.
+type Problem struct {
+	Type     string               `json:"type"`
+	Title    string               `json:"title"`
+	Status   int                  `json:"status"`
+	Detail   string               `json:"detail,omitempty"`
+	Instance string               `json:"instance,omitempty"`
+	Code     string               `json:"code"`
+	Errors   []FieldErrorResponse `json:"errors,omitempty"`
+
+	retryAfter time.Duration
+}
+
+type FieldErrorResponse struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type ErrMissingToken struct {
+}
+
+func (e *ErrMissingToken) Error() string {
+	return "missing bearer token"
+}
+
+type ErrNotOwner struct {
+}
+
+func (e *ErrNotOwner) Error() string {
+	return "the token does not belong to this user"
+}
+
+type ErrRoleRequired struct {
+	Role models.Role
+}
+
+func (e *ErrRoleRequired) Error() string {
+	return fmt.Sprintf("the %s role is required", e.Role)
+}
+
+// ErrInvalidParameter means the query parameter or header Name of a request
+// could not be used.
+type ErrInvalidParameter struct {
+	Name   string
+	Reason string
+}
+
+func (e *ErrInvalidParameter) Error() string {
+	return fmt.Sprintf("%s %s", e.Name, e.Reason)
+}
+
+// writeError answers req with the problem err stands for. Errors the mapping
+// does not know are logged and answered with a detail-less 500, so their
+// message cannot leak.
+func writeError(writer http.ResponseWriter, req *http.Request, err error) {
+	problem := problemOf(err)
+	problem.Instance = req.URL.Path
+
+	if problem.Status == http.StatusInternalServerError {
+		slog.Error("request failed", "method", req.Method, "path", req.URL.Path, "error", err)
+		problem.Detail = ""
+	}
+
+	data, marshalErr := json.Marshal(problem)
+	if marshalErr != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", PROBLEM_CONTENT_TYPE)
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	if problem.retryAfter > 0 {
+		writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(problem.retryAfter.Seconds()))))
+	}
+	writer.WriteHeader(problem.Status)
+	_, _ = writer.Write(data)
+}
+
+// problemOf maps the errors of every layer to their problem. It is the only
+// place deciding which status an error is answered with.
+func problemOf(err error) Problem {
+	switch err := err.(type) {
+	case *ErrMalformedRequest:
+		problem := newProblem(http.StatusBadRequest, ProblemMalformedRequest, "Malformed request", err)
+		if err.Field != "" {
+			problem.Errors = []FieldErrorResponse{{Field: err.Field, Message: err.Reason}}
+		}
+		return problem
+
+	case *ErrUnsupportedMediaType:
+		return newProblem(http.StatusUnsupportedMediaType, ProblemUnsupportedMediaType, "Unsupported media type", err)
+
+	case *ErrRequestTooLarge:
+		return newProblem(http.StatusRequestEntityTooLarge, ProblemRequestTooLarge, "Request too large", err)
+
+	case *application.ErrInvalidFields:
+		problem := newProblem(http.StatusBadRequest, ProblemInvalidFields, "Invalid fields", err)
+		problem.Detail = "some fields of the request are not valid"
+		for _, field := range err.Fields {
+			problem.Errors = append(problem.Errors, FieldErrorResponse{Field: field.Field, Message: field.Err.Error()})
+		}
+		return problem
+
+	case *ErrInvalidParameter:
+		problem := newProblem(http.StatusBadRequest, ProblemInvalidParameter, "Invalid parameter", err)
+		problem.Errors = []FieldErrorResponse{{Field: err.Name, Message: err.Reason}}
+		return problem
+
+	case *models.ErrWeakPassword:
+		return newProblem(http.StatusBadRequest, ProblemWeakPassword, "Weak password", err)
+
+	case *models.ErrInvalidEmail:
+		return newProblem(http.StatusBadRequest, ProblemInvalidEmail, "Invalid email", err)
+
+	case *ErrMissingToken:
+		return newProblem(http.StatusUnauthorized, ProblemMissingToken, "Missing token", err)
+
+	case *application.ErrInvalidToken:
+		return newProblem(http.StatusUnauthorized, ProblemInvalidToken, "Invalid token", err)
+
+	case *application.ErrInvalidCredentials:
+		return newProblem(http.StatusUnauthorized, ProblemInvalidCredentials, "Invalid credentials", err)
+
+	case *application.ErrTooManyAttempts:
+		problem := newProblem(http.StatusTooManyRequests, ProblemTooManyAttempts, "Too many attempts", err)
+		problem.retryAfter = err.RetryAfter
+		return problem
+
+	case *application.ErrIncorrectPassword:
+		return newProblem(http.StatusForbidden, ProblemIncorrectPassword, "Incorrect password", err)
+
+	case *ErrNotOwner:
+		return newProblem(http.StatusForbidden, ProblemNotOwner, "Not the owner", err)
+
+	case *ErrRoleRequired:
+		return newProblem(http.StatusForbidden, ProblemRoleRequired, "Role required", err)
+
+	case *models.ErrInvalidID, *application.ErrUserDoesNotExist:
+		return newProblem(http.StatusNotFound, ProblemUserNotFound, "User not found", err)
+
+	case *application.ErrUserAlreadyExists:
+		return newProblem(http.StatusConflict, ProblemUserAlreadyExists, "User already exists", err)
+
+	case *application.ErrEmailNotVerified:
+		return newProblem(http.StatusForbidden, ProblemEmailNotVerified, "Email not verified", err)
+
+	case *application.ErrEmailAlreadyVerified:
+		return newProblem(http.StatusConflict, ProblemEmailAlreadyVerified, "Email already verified", err)
+
+	case *application.ErrLastAdmin:
+		return newProblem(http.StatusConflict, ProblemLastAdmin, "Last admin", err)
+
+	case *application.ErrAccountNotDeleted:
+		return newProblem(http.StatusConflict, ProblemAccountNotDeleted, "Account not deleted", err)
+
+	case *models.ErrInvalidRole:
+		return newProblem(http.StatusBadRequest, ProblemInvalidRole, "Invalid role", err)
+
+	case *application.ErrSessionDoesNotExist:
+		return newProblem(http.StatusNotFound, ProblemSessionNotFound, "Session not found", err)
+
+	case *application.ErrDeviceDoesNotExist:
+		return newProblem(http.StatusNotFound, ProblemDeviceNotFound, "Device not found", err)
+
+	case *application.ErrInvalidDevice:
+		return newProblem(http.StatusBadRequest, ProblemInvalidDevice, "Invalid device", err)
+
+	case *application.ErrWebhookDoesNotExist:
+		return newProblem(http.StatusNotFound, ProblemWebhookNotFound, "Webhook not found", err)
+
+	case *application.ErrInvalidWebhook:
+		return newProblem(http.StatusBadRequest, ProblemInvalidWebhook, "Invalid webhook", err)
+
+	case *models.ErrUserAlreadyHasADisk:
+		return newProblem(http.StatusConflict, ProblemDiskAlreadyExists, "Disk already exists", err)
+
+	case *models.ErrUserHasNoDisk, *infrastructure.ErrUserHasNoDisk:
+		return newProblem(http.StatusConflict, ProblemNoDisk, "No disk", err)
+
+	case *models.ErrDiskQuotaExceeded:
+		return newProblem(http.StatusInsufficientStorage, ProblemDiskQuotaExceeded, "Disk quota exceeded", err)
+
+	case *models.ErrCursorExpired:
+		return newProblem(http.StatusGone, ProblemCursorExpired, "Cursor expired", err)
+
+	case *infrastructure.ErrMaximumClientsReached, *infrastructure.ErrMaximumUserConnectionsReached:
+		return newProblem(http.StatusServiceUnavailable, ProblemTooManyConnections, "Too many connections", err)
+
+	case *infrastructure.ErrHasherBusy:
+		return newProblem(http.StatusServiceUnavailable, ProblemServerBusy, "Server busy", err)
+
+	default:
+		return newProblem(http.StatusInternalServerError, ProblemInternal, "Internal error", err)
+	}
+}
+
+func newProblem(status int, code string, title string, err error) Problem {
+	return Problem{
+		Type:   PROBLEM_TYPE_PREFIX + code,
+		Title:  title,
+		Status: status,
+		Detail: err.Error(),
+		Code:   code,
+	}
+}
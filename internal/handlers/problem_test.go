@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestProblemResponses(t *testing.T) {
+	t.Run("AnswerConflictWithProblem", func(t *testing.T) {
+		setup()
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/users", strings.NewReader("{\"email\": \"john_doe@test.com\", \"password\": \"correct horse\"}"))
+
+		userHandler.CreateUserResource(response, postRequest)
+
+		problem := assertProblem(t, response, http.StatusConflict, handlers.ProblemUserAlreadyExists)
+		if problem.Instance != "/users" || problem.Detail == "" {
+			t.Errorf("Expected the instance and detail of the problem, got %+v", problem)
+		}
+	})
+
+	t.Run("HideDetailOfUnexpectedErrors", func(t *testing.T) {
+		setup()
+		serverThatFails := mocks.ServerMock{FnDisconnectUser: func(u *models.User) error {
+			return errors.New("secret internals")
+		}}
+		connectionHandler := handlers.NewConnectionHandler(application.NewDisconnectUserService(&userRepoWithUserMock, &serverThatFails))
+		response := httptest.NewRecorder()
+		deleteRequest, _ := http.NewRequest(http.MethodDelete, "/users/"+idOfUserInRepository.ToString()+"/connections", nil)
+		deleteRequest.SetPathValue("id", idOfUserInRepository.ToString())
+
+		connectionHandler.DisconnectUserResource(response, deleteRequest)
+
+		assertProblem(t, response, http.StatusInternalServerError, handlers.ProblemInternal)
+		if strings.Contains(response.Body.String(), "secret internals") {
+			t.Errorf("Expected the error to stay hidden, got %s", response.Body.String())
+		}
+	})
+
+	t.Run("AnswerMissingTokenWithProblem", func(t *testing.T) {
+		authenticator := handlers.NewAuthenticator(newTestSessionService(&mocks.SessionRepositoryMock{}))
+		response := httptest.NewRecorder()
+		getRequest, _ := http.NewRequest(http.MethodGet, "/users", nil)
+
+		authenticator.RequireSession(func(http.ResponseWriter, *http.Request) {})(response, getRequest)
+
+		assertProblem(t, response, http.StatusUnauthorized, handlers.ProblemMissingToken)
+		if response.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Expected a WWW-Authenticate header")
+		}
+	})
+
+	t.Run("ListRejectedFieldsOfProblem", func(t *testing.T) {
+		setup()
+		registerService = application.NewRegisterService(&userRepoEmptyMock, &hasherDummy, models.NewDefaultPasswordPolicy(), &eventPublisherDummy, verificationService)
+		userHandler = handlers.NewUserHandler(registerService, fetchUserService, createDiskService)
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/users", strings.NewReader("{\"email\": \"not an email\", \"password\": \"correct horse\"}"))
+
+		userHandler.CreateUserResource(response, postRequest)
+
+		problem := assertProblem(t, response, http.StatusBadRequest, handlers.ProblemInvalidFields)
+		if len(problem.Errors) != 1 || problem.Errors[0].Field != "email" {
+			t.Errorf("Expected the email to be rejected, got %+v", problem.Errors)
+		}
+	})
+}
+
+func assertProblem(t *testing.T, response *httptest.ResponseRecorder, status int, code string) handlers.Problem {
+	t.Helper()
+
+	assertStatus(t, response.Code, status)
+	if contentType := response.Header().Get("Content-Type"); contentType != handlers.PROBLEM_CONTENT_TYPE {
+		t.Fatalf("Expected a problem, got a body of type %q", contentType)
+	}
+
+	var problem handlers.Problem
+	err := json.Unmarshal(response.Body.Bytes(), &problem)
+	if err != nil {
+		t.Fatalf("Expected a problem, got %s", response.Body.String())
+	}
+
+	if problem.Status != status || problem.Code != code || problem.Type != handlers.PROBLEM_TYPE_PREFIX+code || problem.Title == "" {
+		t.Fatalf("Expected a %d problem with code %s, got %+v", status, code, problem)
+	}
+
+	return problem
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	RealTimeEndpoint = "GET /realtime"
+)
+
+type RealTimeHandler struct {
+	realTimeService *application.RealTimeService
+	server          websocket.Server
+}
+
+func NewRealTimeHandler(realTimeService *application.RealTimeService) *RealTimeHandler {
+	h := &RealTimeHandler{
+		realTimeService: realTimeService,
+	}
+
+	h.server = websocket.Server{
+		Handshake: checkOrigin,
+		Handler:   h.serve,
+	}
+
+	return h
+}
+
+// RealTimeResource upgrades the request to a WebSocket carrying the packets of
+// the real-time protocol in binary messages.
+func (h *RealTimeHandler) RealTimeResource(writer http.ResponseWriter, req *http.Request) {
+	h.server.ServeHTTP(writer, req)
+}
+
+func (h *RealTimeHandler) serve(ws *websocket.Conn) {
+	ws.PayloadType = websocket.BinaryFrame
+	h.realTimeService.Serve(&webSocketConn{Conn: ws})
+}
+
+// checkOrigin accepts clients that are not browsers, which send no origin, and
+// pages served by this server, so other sites cannot open a socket on behalf
+// of their visitors.
+func checkOrigin(config *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	var err error
+	config.Origin, err = websocket.Origin(config, req)
+	if err != nil {
+		return err
+	}
+
+	if config.Origin.Host != req.Host {
+		return fmt.Errorf("origin %s is not allowed", origin)
+	}
+
+	return nil
+}
+
+// webSocketConn ignores deadlines. A read timing out in the middle of a frame
+// would leave the frame half read, and the real-time server already closes
+// sessions that stay idle.
+type webSocketConn struct {
+	*websocket.Conn
+}
+
+func (c *webSocketConn) SetDeadline(time.Time) error {
+	return nil
+}
+
+func (c *webSocketConn) SetReadDeadline(time.Time) error {
+	return nil
+}
@@ -0,0 +1,48 @@
+package handlers_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"golang.org/x/net/websocket"
+)
+
+func TestRealTimeResource(t *testing.T) {
+	echoServerMock := mocks.ServerMock{FnServeConn: func(conn net.Conn) {
+		_, _ = io.Copy(conn, conn)
+	}}
+	realTimeHandler := handlers.NewRealTimeHandler(application.NewRealTimeService(&echoServerMock))
+	httpServer := httptest.NewServer(http.HandlerFunc(realTimeHandler.RealTimeResource))
+	defer httpServer.Close()
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http")
+
+	t.Run("CarryPacketsInBinaryMessages", func(t *testing.T) {
+		ws, err := websocket.Dial(url, "", httpServer.URL)
+		if err != nil {
+			t.Fatalf("Expected to connect but got %s", err.Error())
+		}
+		defer ws.Close()
+		packet := []byte{1, 2, 3}
+
+		_ = websocket.Message.Send(ws, packet)
+		var got []byte
+		_ = websocket.Message.Receive(ws, &got)
+
+		assertEquals(t, got, packet)
+	})
+
+	t.Run("RejectOtherOrigins", func(t *testing.T) {
+		_, err := websocket.Dial(url, "", "http://attacker.example")
+
+		if err == nil {
+			t.Fatalf("Expected connection from another origin to be rejected")
+		}
+	})
+}
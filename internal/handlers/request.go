@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const MAX_JSON_BODY_BYTES = 64 * 1024
+
+type ErrUnsupportedMediaType struct {
+	ContentType string
+}
+
+func (e *ErrUnsupportedMediaType) Error() string {
+	return fmt.Sprintf("expected a body of type application/json, got %q", e.ContentType)
+}
+
+type ErrRequestTooLarge struct {
+	Limit int64
+}
+
+func (e *ErrRequestTooLarge) Error() string {
+	return fmt.Sprintf("the body is larger than %d bytes", e.Limit)
+}
+
+// ErrMalformedRequest means the body is not the JSON object expected. Field is
+// set when the problem is the value of a single field.
+type ErrMalformedRequest struct {
+	Field  string
+	Reason string
+}
+
+func (e *ErrMalformedRequest) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("malformed body: %s", e.Reason)
+	}
+
+	return fmt.Sprintf("malformed body: %s: %s", e.Field, e.Reason)
+}
+
+// decodeJSON decodes the body of req into v, which must be a pointer to a
+// struct. The body must be a single application/json object of at most
+// MAX_JSON_BODY_BYTES without fields v does not have.
+func decodeJSON(writer http.ResponseWriter, req *http.Request, v any) error {
+	contentType := req.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "application/json" {
+		return &ErrUnsupportedMediaType{ContentType: contentType}
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(writer, req.Body, MAX_JSON_BODY_BYTES))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(v)
+	if err != nil {
+		return toDecodeError(err)
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return &ErrRequestTooLarge{Limit: maxBytesError.Limit}
+		}
+
+		return &ErrMalformedRequest{Reason: "the body must hold a single object"}
+	}
+
+	return nil
+}
+
+// writeJSON answers with status and resp encoded as JSON.
+func writeJSON(writer http.ResponseWriter, status int, resp any) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	_, _ = writer.Write(data)
+}
+
+func toDecodeError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &maxBytesError):
+		return &ErrRequestTooLarge{Limit: maxBytesError.Limit}
+
+	case errors.Is(err, io.EOF):
+		return &ErrMalformedRequest{Reason: "the body is empty"}
+
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &syntaxError):
+		return &ErrMalformedRequest{Reason: "the body is not valid JSON"}
+
+	case errors.As(err, &typeError):
+		if typeError.Field == "" {
+			return &ErrMalformedRequest{Reason: fmt.Sprintf("expected an object, got %s", typeError.Value)}
+		}
+
+		return &ErrMalformedRequest{Field: typeError.Field, Reason: fmt.Sprintf("expected %s, got %s", typeError.Type, typeError.Value)}
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), "\"")
+		return &ErrMalformedRequest{Field: field, Reason: "unknown field"}
+
+	default:
+		return &ErrMalformedRequest{Reason: err.Error()}
+	}
+}
+
+// remoteHost returns the address of the client of req, without its port.
+func remoteHost(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+const (
+	ListUsersEndpoint = "GET /users"
+	SetRoleEndpoint   = "PUT /users/{id}/role"
+)
+
+type RoleHandler struct {
+	roleService *application.RoleService
+}
+
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+
+type UserSummaryResponse struct {
+	ID            string     `json:"id"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"emailVerified"`
+	Role          string     `json:"role"`
+	DiskSpace     *uint64    `json:"diskSpaceInMiB,omitempty"`
+	DeletedAt     *time.Time `json:"deletedAt,omitempty"`
+}
+
+func NewRoleHandler(roleService *application.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roleService: roleService,
+	}
+}
+
+func (h *RoleHandler) ListUsersResource(writer http.ResponseWriter, req *http.Request) {
+	users := h.roleService.ListUsers()
+
+	resp := make([]UserSummaryResponse, 0, len(users))
+	for _, user := range users {
+		resp = append(resp, toUserSummaryResponse(user))
+	}
+
+	writeJSON(writer, http.StatusOK, resp)
+}
+
+func (h *RoleHandler) SetRoleResource(writer http.ResponseWriter, req *http.Request) {
+	var roleRequest RoleRequest
+
+	err := decodeJSON(writer, req, &roleRequest)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	user, err := h.roleService.SetRole(req.PathValue("id"), roleRequest.Role)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writeJSON(writer, http.StatusOK, toUserSummaryResponse(user))
+}
+
+func toUserSummaryResponse(user *models.User) UserSummaryResponse {
+	id := user.GetID()
+	resp := UserSummaryResponse{
+		ID:            id.ToString(),
+		Email:         user.GetEmail(),
+		EmailVerified: user.IsEmailVerified(),
+		Role:          string(user.GetRole()),
+	}
+
+	space, err := user.GetDiskSpaceLeft()
+	if err == nil {
+		resp.DiskSpace = &space
+	}
+
+	if user.IsDeleted() {
+		deletedAt := user.GetDeletedAt()
+		resp.DeletedAt = &deletedAt
+	}
+
+	return resp
+}
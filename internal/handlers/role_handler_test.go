@@ -0,0 +1,90 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestListUsersResource(t *testing.T) {
+	t.Run("ReturnEveryUserWithRole", func(t *testing.T) {
+		setup()
+		userRepo := mocks.UserRepositoryMock{FnListUsers: func() []*models.User {
+			return []*models.User{userInRepository}
+		}}
+		roleHandler := handlers.NewRoleHandler(application.NewRoleService(&userRepo))
+		response := httptest.NewRecorder()
+		getRequest, _ := http.NewRequest(http.MethodGet, "/users", nil)
+
+		roleHandler.ListUsersResource(response, getRequest)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		var body []handlers.UserSummaryResponse
+		_ = json.Unmarshal(response.Body.Bytes(), &body)
+		if len(body) != 1 || body[0].Email != userInRepoEmail || body[0].Role != string(models.RoleUser) {
+			t.Errorf("Expected the user in repository, got %+v", body)
+		}
+	})
+}
+
+func TestSetRoleResource(t *testing.T) {
+	serveSetRole := func(roleHandler *handlers.RoleHandler, id string, body string) *httptest.ResponseRecorder {
+		router := http.NewServeMux()
+		router.HandleFunc(handlers.SetRoleEndpoint, roleHandler.SetRoleResource)
+		response := httptest.NewRecorder()
+		putRequest := newJSONRequest(http.MethodPut, "/users/"+id+"/role", strings.NewReader(body))
+
+		router.ServeHTTP(response, putRequest)
+		return response
+	}
+
+	t.Run("ReturnHttpOkWhenRoleIsSet", func(t *testing.T) {
+		setup()
+		roleHandler := handlers.NewRoleHandler(application.NewRoleService(&userRepoWithUserMock))
+
+		response := serveSetRole(roleHandler, idOf(userInRepository), "{\"role\": \"admin\"}")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if !userInRepository.IsAdmin() {
+			t.Errorf("Expected the user to become admin")
+		}
+	})
+
+	t.Run("ReturnHttpBadRequestForUnknownRole", func(t *testing.T) {
+		setup()
+		roleHandler := handlers.NewRoleHandler(application.NewRoleService(&userRepoWithUserMock))
+
+		response := serveSetRole(roleHandler, idOf(userInRepository), "{\"role\": \"superuser\"}")
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("ReturnHttpNotFoundForUnknownUser", func(t *testing.T) {
+		setup()
+		roleHandler := handlers.NewRoleHandler(application.NewRoleService(&userRepoEmptyMock))
+
+		response := serveSetRole(roleHandler, idOf(userInRepository), "{\"role\": \"admin\"}")
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("ReturnHttpConflictWhenDemotingLastAdmin", func(t *testing.T) {
+		setup()
+		userInRepository.SetRole(models.RoleAdmin)
+		userRepoWithUserMock.FnListUsers = func() []*models.User {
+			return []*models.User{userInRepository}
+		}
+		roleHandler := handlers.NewRoleHandler(application.NewRoleService(&userRepoWithUserMock))
+
+		response := serveSetRole(roleHandler, idOf(userInRepository), "{\"role\": \"user\"}")
+
+		assertStatus(t, response.Code, http.StatusConflict)
+	})
+}
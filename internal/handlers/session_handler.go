@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+)
+
+const (
+	CreateSessionEndpoint  = "POST /sessions"
+	RefreshSessionEndpoint = "POST /sessions/refresh"
+	ListSessionsEndpoint   = "GET /users/{id}/sessions"
+	RevokeSessionsEndpoint = "DELETE /users/{id}/sessions"
+	RevokeSessionEndpoint  = "DELETE /users/{id}/sessions/{sessionId}"
+)
+
+type SessionHandler struct {
+	sessionService *application.SessionService
+	loginThrottle  *application.LoginThrottle
+}
+
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type SessionResponse struct {
+	AccessToken      string    `json:"accessToken"`
+	RefreshToken     string    `json:"refreshToken"`
+	TokenType        string    `json:"tokenType"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+	SessionID        string    `json:"sessionId"`
+	UserID           string    `json:"userId"`
+}
+
+type ActiveSessionResponse struct {
+	ID          string    `json:"id"`
+	CreatedAt   time.Time `json:"createdAt"`
+	RefreshedAt time.Time `json:"refreshedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	Current     bool      `json:"current"`
+}
+
+func NewSessionHandler(sessionService *application.SessionService, loginThrottle *application.LoginThrottle) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+		loginThrottle:  loginThrottle,
+	}
+}
+
+func (h *SessionHandler) CreateSessionResource(writer http.ResponseWriter, req *http.Request) {
+	var loginRequest LoginRequest
+
+	err := decodeJSON(writer, req, &loginRequest)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	address := remoteHost(req)
+	err = h.loginThrottle.Allow(address, loginRequest.Email, time.Now())
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	tokens, err := h.sessionService.Login(loginRequest.Email, loginRequest.Password)
+	if _, ok := err.(*application.ErrInvalidCredentials); ok {
+		h.loginThrottle.Failed(address, loginRequest.Email, time.Now())
+	}
+
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	h.loginThrottle.Succeeded(loginRequest.Email)
+	writeSessionTokens(writer, http.StatusCreated, tokens)
+}
+
+func (h *SessionHandler) RefreshSessionResource(writer http.ResponseWriter, req *http.Request) {
+	var refreshRequest RefreshRequest
+
+	err := decodeJSON(writer, req, &refreshRequest)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	tokens, err := h.sessionService.Refresh(refreshRequest.RefreshToken)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writeSessionTokens(writer, http.StatusOK, tokens)
+}
+
+func (h *SessionHandler) ListSessionsResource(writer http.ResponseWriter, req *http.Request) {
+	sessions, err := h.sessionService.ListSessions(req.PathValue("id"))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	current := SessionOf(req)
+	resp := make([]ActiveSessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		resp = append(resp, ActiveSessionResponse{
+			ID:          session.ID,
+			CreatedAt:   session.CreatedAt,
+			RefreshedAt: session.RefreshedAt,
+			ExpiresAt:   session.ExpiresAt,
+			Current:     current != nil && current.ID == session.ID,
+		})
+	}
+
+	writeJSON(writer, http.StatusOK, resp)
+}
+
+func (h *SessionHandler) RevokeSessionsResource(writer http.ResponseWriter, req *http.Request) {
+	err := h.sessionService.RevokeAll(req.PathValue("id"))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h *SessionHandler) RevokeSessionResource(writer http.ResponseWriter, req *http.Request) {
+	err := h.sessionService.Revoke(req.PathValue("id"), req.PathValue("sessionId"))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func writeSessionTokens(writer http.ResponseWriter, status int, tokens *application.SessionTokens) {
+	writer.Header().Set("Cache-Control", "no-store")
+	writeJSON(writer, status, SessionResponse{
+		AccessToken:      tokens.AccessToken,
+		RefreshToken:     tokens.RefreshToken,
+		TokenType:        "Bearer",
+		ExpiresAt:        tokens.Session.AccessExpiresAt,
+		RefreshExpiresAt: tokens.Session.ExpiresAt,
+		SessionID:        tokens.Session.ID,
+		UserID:           tokens.Session.UserID.ToString(),
+	})
+}
@@ -0,0 +1,352 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func newTestSessionService(sessionRepo *mocks.SessionRepositoryMock) *application.SessionService {
+	userInRepository = models.NewUser(userInRepoEmail, "hashed:"+anyUserPassword)
+	userRepo := mocks.UserRepositoryMock{FnGetUserByEmail: func(email string) *models.User {
+		if email == userInRepoEmail {
+			return userInRepository
+		}
+		return nil
+	}, FnGetUserByID: func(id models.UserID) *models.User {
+		if id == userInRepository.GetID() {
+			return userInRepository
+		}
+		return nil
+	}}
+	authenticationService := application.NewAuthenticationService(&userRepo, &mocks.PasswordHasherMock{})
+
+	return application.NewSessionService(&userRepo, authenticationService, sessionRepo, &mocks.ServerMock{}, time.Minute, time.Hour)
+}
+
+func newLoginThrottle() *application.LoginThrottle {
+	return application.NewLoginThrottle(application.DEFAULT_LOGIN_MAX_FAILURES, time.Minute)
+}
+
+func TestCreateSessionResource(t *testing.T) {
+	validLoginJson := "{\"email\": \"John_doe@test.com\", \"password\": \"correct horse\"}"
+
+	t.Run("ReturnHttpCreatedWithTokenIfCredentialsAreValid", func(t *testing.T) {
+		sessionHandler := handlers.NewSessionHandler(newTestSessionService(&mocks.SessionRepositoryMock{}), newLoginThrottle())
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/sessions", strings.NewReader(validLoginJson))
+
+		sessionHandler.CreateSessionResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusCreated)
+		var body handlers.SessionResponse
+		_ = json.Unmarshal(response.Body.Bytes(), &body)
+		if body.AccessToken == "" || body.RefreshToken == "" || body.TokenType != "Bearer" || body.UserID != idOf(userInRepository) {
+			t.Errorf("Expected bearer and refresh tokens for the user, got %+v", body)
+		}
+	})
+
+	t.Run("ReturnHttpUnauthorizedIfPasswordIsWrong", func(t *testing.T) {
+		sessionHandler := handlers.NewSessionHandler(newTestSessionService(&mocks.SessionRepositoryMock{}), newLoginThrottle())
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/sessions", strings.NewReader("{\"email\": \"John_doe@test.com\", \"password\": \"wrong password\"}"))
+
+		sessionHandler.CreateSessionResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("ReturnTooManyRequestsAfterRepeatedFailures", func(t *testing.T) {
+		sessionHandler := handlers.NewSessionHandler(newTestSessionService(&mocks.SessionRepositoryMock{}), application.NewLoginThrottle(2, time.Minute))
+		wrongLoginJson := "{\"email\": \"John_doe@test.com\", \"password\": \"wrong password\"}"
+		for i := 0; i < 2; i++ {
+			postRequest := newJSONRequest(http.MethodPost, "/sessions", strings.NewReader(wrongLoginJson))
+			sessionHandler.CreateSessionResource(httptest.NewRecorder(), postRequest)
+		}
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/sessions", strings.NewReader(validLoginJson))
+
+		sessionHandler.CreateSessionResource(response, postRequest)
+
+		assertProblem(t, response, http.StatusTooManyRequests, handlers.ProblemTooManyAttempts)
+		if response.Header().Get("Retry-After") != "60" {
+			t.Errorf("Expected to retry after 60 seconds, got %q", response.Header().Get("Retry-After"))
+		}
+	})
+
+	t.Run("ReturnServiceUnavailableIfHasherIsBusy", func(t *testing.T) {
+		userRepo := mocks.UserRepositoryMock{}
+		busyHasher := mocks.PasswordHasherMock{FnHash: func(password string) (string, error) {
+			return "", &infrastructure.ErrHasherBusy{}
+		}}
+		authenticationService := application.NewAuthenticationService(&userRepo, &busyHasher)
+		sessionService := application.NewSessionService(&userRepo, authenticationService, &mocks.SessionRepositoryMock{}, &mocks.ServerMock{}, time.Minute, time.Hour)
+		sessionHandler := handlers.NewSessionHandler(sessionService, newLoginThrottle())
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/sessions", strings.NewReader(validLoginJson))
+
+		sessionHandler.CreateSessionResource(response, postRequest)
+
+		assertProblem(t, response, http.StatusServiceUnavailable, handlers.ProblemServerBusy)
+	})
+
+	t.Run("ReturnHttpBadRequestIfParseError", func(t *testing.T) {
+		sessionHandler := handlers.NewSessionHandler(newTestSessionService(&mocks.SessionRepositoryMock{}), newLoginThrottle())
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/sessions", strings.NewReader("{"))
+
+		sessionHandler.CreateSessionResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("ReturnHttpBadRequestIfBodyHasUnknownFields", func(t *testing.T) {
+		sessionHandler := handlers.NewSessionHandler(newTestSessionService(&mocks.SessionRepositoryMock{}), newLoginThrottle())
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/sessions", strings.NewReader("{\"email\": \"John_doe@test.com\", \"password\": \"x\", \"admin\": true}"))
+
+		sessionHandler.CreateSessionResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("ReturnHttpUnsupportedMediaTypeIfBodyIsNotJSON", func(t *testing.T) {
+		sessionHandler := handlers.NewSessionHandler(newTestSessionService(&mocks.SessionRepositoryMock{}), newLoginThrottle())
+		response := httptest.NewRecorder()
+		postRequest, _ := http.NewRequest(http.MethodPost, "/sessions", strings.NewReader(validLoginJson))
+
+		sessionHandler.CreateSessionResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusUnsupportedMediaType)
+	})
+}
+
+func TestRefreshSessionResource(t *testing.T) {
+	t.Run("ReturnHttpOkWithNewTokensIfRefreshTokenIsValid", func(t *testing.T) {
+		sessionRepo := mocks.SessionRepositoryMock{}
+		sessionService := newTestSessionService(&sessionRepo)
+		tokens, _ := sessionService.Login(userInRepoEmail, anyUserPassword)
+		sessionRepo.FnGetSessionByRefreshTokenHash = func(refreshTokenHash string) *models.Session {
+			return tokens.Session
+		}
+		sessionHandler := handlers.NewSessionHandler(sessionService, newLoginThrottle())
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/sessions/refresh", strings.NewReader("{\"refreshToken\": \""+tokens.RefreshToken+"\"}"))
+
+		sessionHandler.RefreshSessionResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		var body handlers.SessionResponse
+		_ = json.Unmarshal(response.Body.Bytes(), &body)
+		if body.RefreshToken == "" || body.RefreshToken == tokens.RefreshToken || body.SessionID != tokens.Session.ID {
+			t.Errorf("Expected new tokens for the same session, got %+v", body)
+		}
+	})
+
+	t.Run("ReturnHttpUnauthorizedIfRefreshTokenIsUnknown", func(t *testing.T) {
+		sessionHandler := handlers.NewSessionHandler(newTestSessionService(&mocks.SessionRepositoryMock{}), newLoginThrottle())
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/sessions/refresh", strings.NewReader("{\"refreshToken\": \"unknown\"}"))
+
+		sessionHandler.RefreshSessionResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+	})
+}
+
+func TestSessionsResources(t *testing.T) {
+	serve := func(sessionHandler *handlers.SessionHandler, method string, target string) *httptest.ResponseRecorder {
+		router := http.NewServeMux()
+		router.HandleFunc(handlers.ListSessionsEndpoint, sessionHandler.ListSessionsResource)
+		router.HandleFunc(handlers.RevokeSessionsEndpoint, sessionHandler.RevokeSessionsResource)
+		router.HandleFunc(handlers.RevokeSessionEndpoint, sessionHandler.RevokeSessionResource)
+		response := httptest.NewRecorder()
+		request, _ := http.NewRequest(method, target, nil)
+
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("ReturnHttpOkWithSessionsOfUser", func(t *testing.T) {
+		anySession := models.Session{ID: "session"}
+		sessionRepo := mocks.SessionRepositoryMock{FnListSessions: func(userID models.UserID) []*models.Session {
+			return []*models.Session{&anySession}
+		}}
+		sessionHandler := handlers.NewSessionHandler(newTestSessionService(&sessionRepo), newLoginThrottle())
+
+		response := serve(sessionHandler, http.MethodGet, "/users/"+idOf(userInRepository)+"/sessions")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		var body []handlers.ActiveSessionResponse
+		_ = json.Unmarshal(response.Body.Bytes(), &body)
+		if len(body) != 1 || body[0].ID != anySession.ID {
+			t.Errorf("Expected the session of the user, got %+v", body)
+		}
+	})
+
+	t.Run("ReturnHttpNoContentWhenSessionIsRevoked", func(t *testing.T) {
+		sessionRepo := mocks.SessionRepositoryMock{}
+		sessionService := newTestSessionService(&sessionRepo)
+		sessionRepo.FnGetSession = func(id string) *models.Session {
+			return &models.Session{ID: id, UserID: userInRepository.GetID()}
+		}
+		sessionHandler := handlers.NewSessionHandler(sessionService, newLoginThrottle())
+
+		response := serve(sessionHandler, http.MethodDelete, "/users/"+idOf(userInRepository)+"/sessions/session")
+
+		assertStatus(t, response.Code, http.StatusNoContent)
+		if !sessionRepo.DeleteSessionCalled {
+			t.Errorf("Expected the session to be deleted")
+		}
+	})
+
+	t.Run("ReturnHttpNotFoundWhenSessionIsUnknown", func(t *testing.T) {
+		sessionHandler := handlers.NewSessionHandler(newTestSessionService(&mocks.SessionRepositoryMock{}), newLoginThrottle())
+
+		response := serve(sessionHandler, http.MethodDelete, "/users/"+idOf(userInRepository)+"/sessions/unknown")
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("ReturnHttpNoContentWhenAllSessionsAreRevoked", func(t *testing.T) {
+		sessionRepo := mocks.SessionRepositoryMock{FnListSessions: func(userID models.UserID) []*models.Session {
+			return []*models.Session{{ID: "first"}, {ID: "second"}}
+		}}
+		sessionHandler := handlers.NewSessionHandler(newTestSessionService(&sessionRepo), newLoginThrottle())
+
+		response := serve(sessionHandler, http.MethodDelete, "/users/"+idOf(userInRepository)+"/sessions")
+
+		assertStatus(t, response.Code, http.StatusNoContent)
+		if len(sessionRepo.DeleteSessionCalledWith) != 2 {
+			t.Errorf("Expected both sessions to be deleted, got %v", sessionRepo.DeleteSessionCalledWith)
+		}
+	})
+}
+
+func TestAuthenticator(t *testing.T) {
+	login := func(t *testing.T) (*handlers.Authenticator, string) {
+		t.Helper()
+
+		sessionRepo := mocks.SessionRepositoryMock{}
+		sessionService := newTestSessionService(&sessionRepo)
+		tokens, err := sessionService.Login(userInRepoEmail, anyUserPassword)
+		if err != nil {
+			t.Fatalf("Expected to log in, got %v", err)
+		}
+		sessionRepo.FnGetSessionByTokenHash = func(tokenHash string) *models.Session {
+			if tokenHash == tokens.Session.TokenHash {
+				return tokens.Session
+			}
+			return nil
+		}
+
+		return handlers.NewAuthenticator(sessionService), tokens.AccessToken
+	}
+
+	serveAs := func(handler http.HandlerFunc, userID string, authorization string) *httptest.ResponseRecorder {
+		router := http.NewServeMux()
+		router.HandleFunc(handlers.GetUserEndpoint, handler)
+		response := httptest.NewRecorder()
+		getRequest, _ := http.NewRequest(http.MethodGet, "/users/"+userID, nil)
+		if authorization != "" {
+			getRequest.Header.Set("Authorization", authorization)
+		}
+
+		router.ServeHTTP(response, getRequest)
+		return response
+	}
+
+	okHandler := func(writer http.ResponseWriter, req *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}
+
+	t.Run("ReturnHttpUnauthorizedWithoutToken", func(t *testing.T) {
+		authenticator, _ := login(t)
+
+		response := serveAs(authenticator.RequireOwner(okHandler), idOf(userInRepository), "")
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+		if response.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Expected a WWW-Authenticate challenge")
+		}
+	})
+
+	t.Run("ReturnHttpUnauthorizedWithUnknownToken", func(t *testing.T) {
+		authenticator, _ := login(t)
+
+		response := serveAs(authenticator.RequireOwner(okHandler), idOf(userInRepository), "Bearer unknown")
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("LetOwnerThrough", func(t *testing.T) {
+		authenticator, token := login(t)
+
+		response := serveAs(authenticator.RequireOwner(okHandler), idOf(userInRepository), "Bearer "+token)
+
+		assertStatus(t, response.Code, http.StatusOK)
+	})
+
+	t.Run("ReturnHttpForbiddenForOtherUser", func(t *testing.T) {
+		authenticator, token := login(t)
+		otherUserID := models.NewUserID()
+
+		response := serveAs(authenticator.RequireOwner(okHandler), otherUserID.ToString(), "Bearer "+token)
+
+		assertStatus(t, response.Code, http.StatusForbidden)
+	})
+
+	t.Run("LetAdminActOnOtherUser", func(t *testing.T) {
+		authenticator, token := login(t)
+		userInRepository.SetRole(models.RoleAdmin)
+		otherUserID := models.NewUserID()
+
+		response := serveAs(authenticator.RequireOwner(okHandler), otherUserID.ToString(), "Bearer "+token)
+
+		assertStatus(t, response.Code, http.StatusOK)
+	})
+
+	t.Run("ReturnHttpForbiddenWithoutRequiredRole", func(t *testing.T) {
+		authenticator, token := login(t)
+
+		response := serveAs(authenticator.RequireRole(models.RoleAdmin, okHandler), "any", "Bearer "+token)
+
+		assertStatus(t, response.Code, http.StatusForbidden)
+	})
+
+	t.Run("LetUserWithRequiredRoleThrough", func(t *testing.T) {
+		authenticator, token := login(t)
+		userInRepository.SetRole(models.RoleAdmin)
+
+		response := serveAs(authenticator.RequireRole(models.RoleAdmin, okHandler), "any", "Bearer "+token)
+
+		assertStatus(t, response.Code, http.StatusOK)
+	})
+
+	t.Run("GiveSessionToHandler", func(t *testing.T) {
+		authenticator, token := login(t)
+		var session *models.Session
+
+		serveAs(authenticator.RequireSession(func(writer http.ResponseWriter, req *http.Request) {
+			session = handlers.SessionOf(req)
+		}), "any", "Bearer "+token)
+
+		if session == nil || session.UserID != userInRepository.GetID() {
+			t.Errorf("Expected the session of the user, got %+v", session)
+		}
+	})
+}
+
+func idOf(user *models.User) string {
+	id := user.GetID()
+	return id.ToString()
+}
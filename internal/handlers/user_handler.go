@@ -1,13 +1,11 @@
 package handlers
 
 import (
-	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/Joey-Boivin/sdisk/internal/application"
-	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
-	"github.com/Joey-Boivin/sdisk/internal/models"
 )
 
 const (
@@ -23,13 +21,15 @@ type UserHandler struct {
 }
 
 type RegisterRequest struct {
-	Email    string
-	Password string
+	Email    string `json:"email"`
+	Password string `json:"password"`
 }
 
 type FetchUserResponse struct {
-	Email     string `json:"email"`
-	DiskSpace int    `json:"diskSpaceInMiB,omitempty"`
+	Email         string  `json:"email"`
+	EmailVerified bool    `json:"emailVerified"`
+	Role          string  `json:"role"`
+	DiskSpace     *uint64 `json:"diskSpaceInMiB,omitempty"`
 }
 
 func NewUserHandler(registerService *application.RegisterService, fetchUserService *application.FetchUserService, createDiskService *application.CreateDiskService) *UserHandler {
@@ -43,15 +43,17 @@ func NewUserHandler(registerService *application.RegisterService, fetchUserServi
 func (h *UserHandler) CreateUserResource(writer http.ResponseWriter, req *http.Request) {
 	var registerRequest RegisterRequest
 
-	err := json.NewDecoder(req.Body).Decode(&registerRequest)
+	err := decodeJSON(writer, req, &registerRequest)
 	if err != nil {
-		http.Error(writer, err.Error(), http.StatusBadRequest)
+		writeError(writer, req, err)
 		return
 	}
 
 	id, err := h.registerService.RegisterUser(registerRequest.Email, registerRequest.Password)
-	if err != nil {
-		writer.WriteHeader(http.StatusForbidden)
+	if _, ok := err.(*application.ErrVerificationNotSent); ok {
+		slog.Warn("registered user without verification mail", "user", id.ToString(), "error", err)
+	} else if err != nil {
+		writeError(writer, req, err)
 		return
 	}
 
@@ -63,7 +65,7 @@ func (h *UserHandler) GetUserResource(writer http.ResponseWriter, req *http.Requ
 	id := req.PathValue("id")
 	user, err := h.fetchUserService.FetchUser(id)
 	if err != nil {
-		writer.WriteHeader(http.StatusNotFound)
+		writeError(writer, req, err)
 		return
 	}
 
@@ -71,25 +73,12 @@ func (h *UserHandler) GetUserResource(writer http.ResponseWriter, req *http.Requ
 	var resp FetchUserResponse
 
 	if err != nil {
-		resp = FetchUserResponse{Email: user.GetEmail()}
+		resp = FetchUserResponse{Email: user.GetEmail(), EmailVerified: user.IsEmailVerified(), Role: string(user.GetRole())}
 	} else {
-		resp = FetchUserResponse{Email: user.GetEmail(), DiskSpace: int(space)}
-	}
-
-	data, err := json.Marshal(resp)
-
-	if err != nil {
-		writer.WriteHeader(http.StatusInternalServerError)
-		return
+		resp = FetchUserResponse{Email: user.GetEmail(), EmailVerified: user.IsEmailVerified(), Role: string(user.GetRole()), DiskSpace: &space}
 	}
 
-	_, err = writer.Write(data)
-	if err != nil {
-		writer.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	writer.Header().Set("Content-Type", "application/json")
+	writeJSON(writer, http.StatusOK, resp)
 }
 
 func (h *UserHandler) CreateDiskResource(writer http.ResponseWriter, req *http.Request) {
@@ -98,23 +87,8 @@ func (h *UserHandler) CreateDiskResource(writer http.ResponseWriter, req *http.R
 	err := h.createDiskService.CreateDisk(email)
 
 	if err != nil {
-		switch err.(type) {
-		default:
-			writer.WriteHeader(http.StatusInternalServerError)
-			return
-
-		case *models.ErrUserAlreadyHasADisk:
-			writer.WriteHeader(http.StatusForbidden)
-			return
-
-		case *application.ErrUserDoesNotExist:
-			writer.WriteHeader(http.StatusNotFound)
-			return
-
-		case *infrastructure.ErrUnknownPacket:
-			writer.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+		writeError(writer, req, err)
+		return
 	}
 
 	writer.WriteHeader(http.StatusCreated)
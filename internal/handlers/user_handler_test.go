@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -17,13 +18,14 @@ import (
 	"github.com/Joey-Boivin/sdisk/internal/models"
 )
 
-var userInRepoEmail = "John_doe@test.com"
-var anyUserPassword = "12345"
+var userInRepoEmail = "john_doe@test.com"
+var anyUserPassword = "correct horse"
 var anySizeInMiB = uint64(1024)
 
 var userInRepository = &models.User{}
 var idOfUserInRepository = models.UserID{}
 
+var verificationService = &application.EmailVerificationService{}
 var registerService = &application.RegisterService{}
 var fetchUserService = &application.FetchUserService{}
 var createDiskService = &application.CreateDiskService{}
@@ -34,9 +36,14 @@ var userRepoEmptyMock = mocks.UserRepositoryMock{}
 var userRepoWithUserMock = mocks.UserRepositoryMock{}
 var serverDummy = mocks.ServerMock{}
 var serverMockThatFails = mocks.ServerMock{}
+var eventPublisherDummy = mocks.EventPublisherMock{}
+var hasherDummy = mocks.PasswordHasherMock{}
+var tokenRepositoryDummy = mocks.OneTimeTokenRepositoryMock{}
+var mailerDummy = mocks.MailerMock{}
 
 func setup() {
 	userInRepository = models.NewUser(userInRepoEmail, anyUserPassword)
+	userInRepository.SetEmailVerified(true)
 	idOfUserInRepository = userInRepository.GetID()
 
 	userRepoEmptyMock = mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
@@ -55,21 +62,22 @@ func setup() {
 		return &infrastructure.ErrUnknownPacket{Opcode: uint8(anyOpcode)}
 	}}
 
-	registerService = application.NewRegisterService(&userRepoWithUserMock)
+	verificationService = application.NewEmailVerificationService(&userRepoWithUserMock, &tokenRepositoryDummy, &mailerDummy)
+	registerService = application.NewRegisterService(&userRepoWithUserMock, &hasherDummy, models.NewDefaultPasswordPolicy(), &eventPublisherDummy, verificationService)
 	fetchUserService = application.NewFetchUserService(&userRepoWithUserMock)
 	createDiskService = application.NewCreateDiskService(&userRepoWithUserMock, anySizeInMiB, &serverDummy)
 	userHandler = handlers.NewUserHandler(registerService, fetchUserService, createDiskService)
 }
 
 func TestCreateUser(t *testing.T) {
-	validUserJson := "{\"email\": \"EMAIL@TEST.com\", \"password\": \"12345\"}"
+	validUserJson := "{\"email\": \"EMAIL@TEST.com\", \"password\": \"correct horse\"}"
 
 	t.Run("ReturnHttpBadRequestIfParseError", func(t *testing.T) {
 		setup()
 		response := httptest.NewRecorder()
 		badRequest := "{"
 		reader := strings.NewReader(badRequest)
-		postRequest, _ := http.NewRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
+		postRequest := newJSONRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
 
 		userHandler.CreateUserResource(response, postRequest)
 
@@ -80,12 +88,12 @@ func TestCreateUser(t *testing.T) {
 
 	t.Run("DoNotSaveAUserIfParseError", func(t *testing.T) {
 		setup()
-		registerService = application.NewRegisterService(&userRepoEmptyMock)
+		registerService = application.NewRegisterService(&userRepoEmptyMock, &hasherDummy, models.NewDefaultPasswordPolicy(), &eventPublisherDummy, verificationService)
 		userHandler = handlers.NewUserHandler(registerService, fetchUserService, createDiskService)
 		response := httptest.NewRecorder()
 		badRequest := "{"
 		reader := strings.NewReader(badRequest)
-		postRequest, _ := http.NewRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
+		postRequest := newJSONRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
 
 		userHandler.CreateUserResource(response, postRequest)
 
@@ -94,11 +102,11 @@ func TestCreateUser(t *testing.T) {
 
 	t.Run("ReturnHttpCreatedIfNoErrors", func(t *testing.T) {
 		setup()
-		registerService = application.NewRegisterService(&userRepoEmptyMock)
+		registerService = application.NewRegisterService(&userRepoEmptyMock, &hasherDummy, models.NewDefaultPasswordPolicy(), &eventPublisherDummy, verificationService)
 		userHandler = handlers.NewUserHandler(registerService, fetchUserService, createDiskService)
 		response := httptest.NewRecorder()
 		reader := strings.NewReader(validUserJson)
-		postRequest, _ := http.NewRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
+		postRequest := newJSONRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
 
 		userHandler.CreateUserResource(response, postRequest)
 
@@ -109,28 +117,56 @@ func TestCreateUser(t *testing.T) {
 
 	t.Run("UserSavedIfNoErrors", func(t *testing.T) {
 		setup()
-		registerService = application.NewRegisterService(&userRepoEmptyMock)
+		registerService = application.NewRegisterService(&userRepoEmptyMock, &hasherDummy, models.NewDefaultPasswordPolicy(), &eventPublisherDummy, verificationService)
 		userHandler = handlers.NewUserHandler(registerService, fetchUserService, createDiskService)
 		response := httptest.NewRecorder()
 		reader := strings.NewReader(validUserJson)
-		postRequest, _ := http.NewRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
+		postRequest := newJSONRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
 
 		userHandler.CreateUserResource(response, postRequest)
 
 		assertSave(t, userRepoEmptyMock)
 	})
 
-	t.Run("ReturnHttpForbiddenIfUserAlreadyExists", func(t *testing.T) {
+	t.Run("ReturnHttpBadRequestIfPasswordIsTooWeak", func(t *testing.T) {
+		setup()
+		registerService = application.NewRegisterService(&userRepoEmptyMock, &hasherDummy, models.NewDefaultPasswordPolicy(), &eventPublisherDummy, verificationService)
+		userHandler = handlers.NewUserHandler(registerService, fetchUserService, createDiskService)
+		response := httptest.NewRecorder()
+		reader := strings.NewReader("{\"email\": \"EMAIL@TEST.com\", \"password\": \"12345\"}")
+		postRequest := newJSONRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
+
+		userHandler.CreateUserResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+		assertNoSave(t, userRepoEmptyMock)
+	})
+
+	t.Run("ReturnHttpBadRequestIfEmailIsInvalid", func(t *testing.T) {
+		setup()
+		registerService = application.NewRegisterService(&userRepoEmptyMock, &hasherDummy, models.NewDefaultPasswordPolicy(), &eventPublisherDummy, verificationService)
+		userHandler = handlers.NewUserHandler(registerService, fetchUserService, createDiskService)
+		response := httptest.NewRecorder()
+		reader := strings.NewReader("{\"email\": \"not an email\", \"password\": \"correct horse\"}")
+		postRequest := newJSONRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
+
+		userHandler.CreateUserResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+		assertNoSave(t, userRepoEmptyMock)
+	})
+
+	t.Run("ReturnHttpConflictIfUserAlreadyExists", func(t *testing.T) {
 		setup()
 		response := httptest.NewRecorder()
 
 		reader := strings.NewReader(validUserJson)
-		postRequest, _ := http.NewRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
+		postRequest := newJSONRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
 
 		userHandler.CreateUserResource(response, postRequest)
 
 		got := response.Code
-		want := http.StatusForbidden
+		want := http.StatusConflict
 		assertStatus(t, got, want)
 	})
 
@@ -138,12 +174,70 @@ func TestCreateUser(t *testing.T) {
 		setup()
 		response := httptest.NewRecorder()
 		reader := strings.NewReader(validUserJson)
-		postRequest, _ := http.NewRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
+		postRequest := newJSONRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
 
 		userHandler.CreateUserResource(response, postRequest)
 
 		assertNoSave(t, userRepoWithUserMock)
 	})
+
+	t.Run("ReturnHttpUnsupportedMediaTypeIfBodyIsNotJSON", func(t *testing.T) {
+		setup()
+		response := httptest.NewRecorder()
+		postRequest, _ := http.NewRequest(http.MethodPost, handlers.CreateUserEndpoint, strings.NewReader(validUserJson))
+		postRequest.Header.Set("Content-Type", "text/plain")
+
+		userHandler.CreateUserResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusUnsupportedMediaType)
+	})
+
+	t.Run("ReturnHttpRequestEntityTooLargeIfBodyIsTooLarge", func(t *testing.T) {
+		setup()
+		response := httptest.NewRecorder()
+		padding := strings.Repeat(" ", handlers.MAX_JSON_BODY_BYTES)
+		postRequest := newJSONRequest(http.MethodPost, handlers.CreateUserEndpoint, strings.NewReader(padding+validUserJson))
+
+		userHandler.CreateUserResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusRequestEntityTooLarge)
+	})
+
+	t.Run("ReturnUnknownFieldInErrors", func(t *testing.T) {
+		setup()
+		response := httptest.NewRecorder()
+		reader := strings.NewReader("{\"email\": \"EMAIL@TEST.com\", \"password\": \"correct horse\", \"role\": \"admin\"}")
+		postRequest := newJSONRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
+
+		userHandler.CreateUserResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+		assertFieldErrors(t, response, "role")
+	})
+
+	t.Run("ReturnHttpBadRequestIfBodyHoldsSeveralObjects", func(t *testing.T) {
+		setup()
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, handlers.CreateUserEndpoint, strings.NewReader(validUserJson+validUserJson))
+
+		userHandler.CreateUserResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("ReturnEveryInvalidFieldInErrors", func(t *testing.T) {
+		setup()
+		registerService = application.NewRegisterService(&userRepoEmptyMock, &hasherDummy, models.NewDefaultPasswordPolicy(), &eventPublisherDummy, verificationService)
+		userHandler = handlers.NewUserHandler(registerService, fetchUserService, createDiskService)
+		response := httptest.NewRecorder()
+		reader := strings.NewReader("{\"email\": \"not an email\", \"password\": \"12345\"}")
+		postRequest := newJSONRequest(http.MethodPost, handlers.CreateUserEndpoint, reader)
+
+		userHandler.CreateUserResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+		assertFieldErrors(t, response, "email", "password")
+	})
 }
 
 func TestGetUser(t *testing.T) {
@@ -221,7 +315,7 @@ func TestGetUser(t *testing.T) {
 }
 
 func TestCreateDiskResource(t *testing.T) {
-	t.Run("IfUserHasADiskReturnHttpStatusForbidden", func(t *testing.T) {
+	t.Run("IfUserHasADiskReturnHttpStatusConflict", func(t *testing.T) {
 		setup()
 		existingDisk := models.NewDisk(uint64(anySizeInMiB))
 		_ = userInRepository.AddDisk(existingDisk)
@@ -233,7 +327,7 @@ func TestCreateDiskResource(t *testing.T) {
 		userHandler.CreateDiskResource(response, postRequest)
 
 		got := response.Code
-		want := http.StatusForbidden
+		want := http.StatusConflict
 		assertStatus(t, got, want)
 	})
 
@@ -283,6 +377,40 @@ func TestCreateDiskResource(t *testing.T) {
 		assertStatus(t, got, want)
 	})
 
+	t.Run("IfEmailNotVerifiedReturnHttpForbidden", func(t *testing.T) {
+		setup()
+		userInRepository.SetEmailVerified(false)
+		response := httptest.NewRecorder()
+		reader := strings.NewReader("")
+		postRequest, _ := http.NewRequest(http.MethodPost, handlers.CreateDiskEndpoint, reader)
+		postRequest.SetPathValue("id", idOfUserInRepository.ToString())
+
+		userHandler.CreateDiskResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusForbidden)
+	})
+}
+
+func newJSONRequest(method string, target string, body io.Reader) *http.Request {
+	req, _ := http.NewRequest(method, target, body)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func assertFieldErrors(t *testing.T, response *httptest.ResponseRecorder, fields ...string) {
+	t.Helper()
+
+	var body handlers.Problem
+	_ = json.Unmarshal(response.Body.Bytes(), &body)
+
+	got := make([]string, 0, len(body.Errors))
+	for _, fieldError := range body.Errors {
+		got = append(got, fieldError.Field)
+	}
+
+	if !reflect.DeepEqual(got, fields) {
+		t.Fatalf("Expected errors for the fields %v, got %s", fields, response.Body.String())
+	}
 }
 
 func assertNoSave(t *testing.T, userRepoMock mocks.UserRepositoryMock) {
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+)
+
+const (
+	SendVerificationEndpoint = "POST /users/{id}/verification"
+	VerifyEmailEndpoint      = "POST /verifications"
+)
+
+type VerificationHandler struct {
+	verificationService *application.EmailVerificationService
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+func NewVerificationHandler(verificationService *application.EmailVerificationService) *VerificationHandler {
+	return &VerificationHandler{
+		verificationService: verificationService,
+	}
+}
+
+func (h *VerificationHandler) SendVerificationResource(writer http.ResponseWriter, req *http.Request) {
+	err := h.verificationService.SendVerification(req.PathValue("id"))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+func (h *VerificationHandler) VerifyEmailResource(writer http.ResponseWriter, req *http.Request) {
+	var verifyEmailRequest VerifyEmailRequest
+
+	err := decodeJSON(writer, req, &verifyEmailRequest)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	err = h.verificationService.Verify(verifyEmailRequest.Token)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,89 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestVerificationResources(t *testing.T) {
+	user := models.NewUser("John_doe@test.com", "hashed:correct horse")
+	userRepo := mocks.UserRepositoryMock{FnGetUserByID: func(id models.UserID) *models.User {
+		if id == user.GetID() {
+			return user
+		}
+		return nil
+	}}
+	mailerSpy := mocks.MailerMock{}
+	verificationHandler := handlers.NewVerificationHandler(application.NewEmailVerificationService(&userRepo, infrastructure.NewRamTokenRepository(), &mailerSpy))
+
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		router := http.NewServeMux()
+		router.HandleFunc(handlers.SendVerificationEndpoint, verificationHandler.SendVerificationResource)
+		router.HandleFunc(handlers.VerifyEmailEndpoint, verificationHandler.VerifyEmailResource)
+		response := httptest.NewRecorder()
+		request := newJSONRequest(method, target, strings.NewReader(body))
+
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("ReturnHttpAcceptedWhenVerificationIsSent", func(t *testing.T) {
+		response := serve(http.MethodPost, "/users/"+idOf(user)+"/verification", "")
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+		if !mailerSpy.SendCalled {
+			t.Errorf("Expected the verification to be mailed")
+		}
+	})
+
+	t.Run("ReturnHttpNotFoundIfUserDoesNotExist", func(t *testing.T) {
+		unknownUserID := models.NewUserID()
+
+		response := serve(http.MethodPost, "/users/"+unknownUserID.ToString()+"/verification", "")
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("ReturnHttpNoContentWhenEmailIsVerified", func(t *testing.T) {
+		_ = serve(http.MethodPost, "/users/"+idOf(user)+"/verification", "")
+
+		response := serve(http.MethodPost, "/verifications", "{\"token\": \""+mailedToken(mailerSpy.SendCalledWith)+"\"}")
+
+		assertStatus(t, response.Code, http.StatusNoContent)
+		if !user.IsEmailVerified() {
+			t.Errorf("Expected the email to be verified")
+		}
+	})
+
+	t.Run("ReturnHttpConflictIfEmailIsAlreadyVerified", func(t *testing.T) {
+		user.SetEmailVerified(true)
+
+		response := serve(http.MethodPost, "/users/"+idOf(user)+"/verification", "")
+
+		assertStatus(t, response.Code, http.StatusConflict)
+	})
+
+	t.Run("ReturnHttpUnauthorizedIfTokenIsInvalid", func(t *testing.T) {
+		response := serve(http.MethodPost, "/verifications", "{\"token\": \"unknown\"}")
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+	})
+}
+
+// mailedToken returns the token of a mail, which has a paragraph of its own.
+func mailedToken(mail models.Mail) string {
+	paragraphs := strings.Split(mail.Body, "\n\n")
+	if len(paragraphs) < 2 {
+		return ""
+	}
+
+	return paragraphs[1]
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+const (
+	CreateWebhookEndpoint         = "POST /webhooks"
+	ListWebhooksEndpoint          = "GET /webhooks"
+	GetWebhookEndpoint            = "GET /webhooks/{id}"
+	DeleteWebhookEndpoint         = "DELETE /webhooks/{id}"
+	ListWebhookDeliveriesEndpoint = "GET /webhooks/{id}/deliveries"
+)
+
+const (
+	DEFAULT_DELIVERIES_PAGE_SIZE = 100
+	MAX_DELIVERIES_PAGE_SIZE     = 1000
+)
+
+type WebhookHandler struct {
+	webhookService *application.WebhookService
+}
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	UserID string   `json:"userId"`
+	Events []string `json:"events"`
+}
+
+type WebhookResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	UserID    string    `json:"userId,omitempty"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID         string    `json:"id"`
+	EventID    uint64    `json:"eventId"`
+	EventType  string    `json:"eventType"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Succeeded  bool      `json:"succeeded"`
+	Time       time.Time `json:"time"`
+}
+
+func NewWebhookHandler(webhookService *application.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// CreateWebhookResource registers a webhook. The secret used to sign its
+// deliveries is only ever returned by this call.
+func (h *WebhookHandler) CreateWebhookResource(writer http.ResponseWriter, req *http.Request) {
+	var webhookRequest WebhookRequest
+
+	err := decodeJSON(writer, req, &webhookRequest)
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	webhook, err := h.webhookService.RegisterWebhook(webhookRequest.URL, webhookRequest.Secret, webhookRequest.UserID, webhookRequest.Events)
+	switch err.(type) {
+	case nil:
+
+	// The user is named in the body, so it is a rejected field rather than
+	// a missing resource.
+	case *models.ErrInvalidID, *application.ErrUserDoesNotExist:
+		writeError(writer, req, &application.ErrInvalidFields{Fields: []application.FieldError{{Field: "userId", Err: err}}})
+		return
+
+	default:
+		writeError(writer, req, err)
+		return
+	}
+
+	resp := toWebhookResponse(webhook)
+	resp.Secret = webhook.Secret
+
+	writer.Header().Set("Location", fmt.Sprintf("/webhooks/%s", webhook.ID))
+	writeJSON(writer, http.StatusCreated, resp)
+}
+
+func (h *WebhookHandler) ListWebhooksResource(writer http.ResponseWriter, req *http.Request) {
+	webhooks := h.webhookService.ListWebhooks()
+
+	resp := make([]WebhookResponse, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		resp = append(resp, toWebhookResponse(webhook))
+	}
+
+	writeJSON(writer, http.StatusOK, resp)
+}
+
+func (h *WebhookHandler) GetWebhookResource(writer http.ResponseWriter, req *http.Request) {
+	webhook, err := h.webhookService.GetWebhook(req.PathValue("id"))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writeJSON(writer, http.StatusOK, toWebhookResponse(webhook))
+}
+
+func (h *WebhookHandler) DeleteWebhookResource(writer http.ResponseWriter, req *http.Request) {
+	err := h.webhookService.DeleteWebhook(req.PathValue("id"))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) ListWebhookDeliveriesResource(writer http.ResponseWriter, req *http.Request) {
+	limit, err := parseUintQuery(req, "limit", DEFAULT_DELIVERIES_PAGE_SIZE)
+	if err != nil || limit == 0 || limit > MAX_DELIVERIES_PAGE_SIZE {
+		writeError(writer, req, &ErrInvalidParameter{Name: "limit", Reason: fmt.Sprintf("must be between 1 and %d", MAX_DELIVERIES_PAGE_SIZE)})
+		return
+	}
+
+	deliveries, err := h.webhookService.Deliveries(req.PathValue("id"), int(limit))
+	if err != nil {
+		writeError(writer, req, err)
+		return
+	}
+
+	resp := make([]WebhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		resp = append(resp, WebhookDeliveryResponse{
+			ID:         delivery.ID,
+			EventID:    delivery.EventID,
+			EventType:  string(delivery.EventType),
+			Attempt:    delivery.Attempt,
+			StatusCode: delivery.StatusCode,
+			Error:      delivery.Error,
+			Succeeded:  delivery.Succeeded,
+			Time:       delivery.Time,
+		})
+	}
+
+	writeJSON(writer, http.StatusOK, resp)
+}
+
+func toWebhookResponse(webhook *models.Webhook) WebhookResponse {
+	resp := WebhookResponse{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    make([]string, 0, len(webhook.Events)),
+		CreatedAt: webhook.CreatedAt,
+	}
+
+	if webhook.UserID != nil {
+		resp.UserID = webhook.UserID.ToString()
+	}
+
+	for _, event := range webhook.Events {
+		resp.Events = append(resp.Events, string(event))
+	}
+
+	return resp
+}
@@ -0,0 +1,88 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/application"
+	"github.com/Joey-Boivin/sdisk/internal/handlers"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestCreateWebhookResource(t *testing.T) {
+	t.Run("IfWebhookIsInvalidReturnHttpBadRequest", func(t *testing.T) {
+		setup()
+		webhookHandler := handlers.NewWebhookHandler(application.NewWebhookService(&userRepoWithUserMock, &mocks.WebhookRepositoryMock{}, &mocks.WebhookDeliveryLogMock{}))
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/webhooks", strings.NewReader("{\"url\": \"not an url\"}"))
+
+		webhookHandler.CreateWebhookResource(response, postRequest)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("IfUserDoesNotExistReturnRejectedUserID", func(t *testing.T) {
+		setup()
+		webhookHandler := handlers.NewWebhookHandler(application.NewWebhookService(&userRepoEmptyMock, &mocks.WebhookRepositoryMock{}, &mocks.WebhookDeliveryLogMock{}))
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/webhooks", strings.NewReader("{\"url\": \"https://example.com\", \"userId\": \""+idOfUserInRepository.ToString()+"\"}"))
+
+		webhookHandler.CreateWebhookResource(response, postRequest)
+
+		problem := assertProblem(t, response, http.StatusBadRequest, handlers.ProblemInvalidFields)
+		if len(problem.Errors) != 1 || problem.Errors[0].Field != "userId" {
+			t.Errorf("Expected the userId to be rejected, got %+v", problem.Errors)
+		}
+	})
+
+	t.Run("ReturnHttpCreatedWithSecret", func(t *testing.T) {
+		setup()
+		webhookHandler := handlers.NewWebhookHandler(application.NewWebhookService(&userRepoWithUserMock, &mocks.WebhookRepositoryMock{}, &mocks.WebhookDeliveryLogMock{}))
+		response := httptest.NewRecorder()
+		postRequest := newJSONRequest(http.MethodPost, "/webhooks", strings.NewReader("{\"url\": \"https://example.com\", \"secret\": \"s3cret\"}"))
+
+		webhookHandler.CreateWebhookResource(response, postRequest)
+
+		var body handlers.WebhookResponse
+		_ = json.Unmarshal(response.Body.Bytes(), &body)
+		assertStatus(t, response.Code, http.StatusCreated)
+		if body.Secret != "s3cret" || response.Header().Get("Location") != "/webhooks/"+body.ID {
+			t.Fatalf("Unexpected webhook %+v", body)
+		}
+	})
+}
+
+func TestListWebhooksResource(t *testing.T) {
+	t.Run("NeverReturnSecrets", func(t *testing.T) {
+		webhookRepoMock := mocks.WebhookRepositoryMock{FnListWebhooks: func() []*models.Webhook {
+			return []*models.Webhook{{ID: "hook", URL: "https://example.com", Secret: "s3cret"}}
+		}}
+		webhookHandler := handlers.NewWebhookHandler(application.NewWebhookService(&userRepoWithUserMock, &webhookRepoMock, &mocks.WebhookDeliveryLogMock{}))
+		response := httptest.NewRecorder()
+		getRequest, _ := http.NewRequest(http.MethodGet, "/webhooks", nil)
+
+		webhookHandler.ListWebhooksResource(response, getRequest)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if strings.Contains(response.Body.String(), "s3cret") {
+			t.Fatalf("Secret was returned in %s", response.Body.String())
+		}
+	})
+}
+
+func TestDeleteWebhookResource(t *testing.T) {
+	t.Run("IfWebhookDoesNotExistReturnHttpNotFound", func(t *testing.T) {
+		webhookHandler := handlers.NewWebhookHandler(application.NewWebhookService(&userRepoWithUserMock, &mocks.WebhookRepositoryMock{}, &mocks.WebhookDeliveryLogMock{}))
+		response := httptest.NewRecorder()
+		deleteRequest, _ := http.NewRequest(http.MethodDelete, "/webhooks/unknown", nil)
+		deleteRequest.SetPathValue("id", "unknown")
+
+		webhookHandler.DeleteWebhookResource(response, deleteRequest)
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+}
@@ -0,0 +1,209 @@
+package infrastructure
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const ARGON2ID_PREFIX = "$argon2id$"
+
+// Argon2idHasher hashes passwords with argon2id. Hashes are encoded in the PHC
+// string format, $argon2id$v=19$m=<KiB>,t=<iterations>,p=<threads>$<salt>$<key>,
+// so they can be verified after the parameters change. Only a few passwords
+// are hashed at once, since every hash takes its own memory.
+type Argon2idHasher struct {
+	memoryKiB   uint32
+	iterations  uint32
+	parallelism uint8
+	saltLength  uint32
+	keyLength   uint32
+	slots       chan struct{}
+	admitted    chan struct{}
+}
+
+type Argon2idConfig struct {
+	memoryKiB           uint32
+	iterations          uint32
+	parallelism         uint8
+	saltLength          uint32
+	keyLength           uint32
+	maxConcurrentHashes uint
+	maxQueuedHashes     uint
+}
+
+type argon2idHash struct {
+	memoryKiB   uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func NewDefaultArgon2idConfig() *Argon2idConfig {
+	return &Argon2idConfig{
+		memoryKiB:           DEFAULT_ARGON2ID_MEMORY_KIB,
+		iterations:          DEFAULT_ARGON2ID_ITERATIONS,
+		parallelism:         DEFAULT_ARGON2ID_PARALLELISM,
+		saltLength:          DEFAULT_ARGON2ID_SALT_SIZE_BYTES,
+		keyLength:           DEFAULT_ARGON2ID_KEY_SIZE_BYTES,
+		maxConcurrentHashes: uint(runtime.GOMAXPROCS(0)),
+		maxQueuedHashes:     DEFAULT_ARGON2ID_MAX_QUEUED_HASHES,
+	}
+}
+
+// SetParameters overrides the memory, in KiB, the number of passes and the
+// number of threads used to hash passwords. A zero value keeps the default.
+func (config *Argon2idConfig) SetParameters(memoryKiB uint32, iterations uint32, parallelism uint8) {
+	if memoryKiB != 0 {
+		config.memoryKiB = memoryKiB
+	}
+
+	if iterations != 0 {
+		config.iterations = iterations
+	}
+
+	if parallelism != 0 {
+		config.parallelism = parallelism
+	}
+}
+
+// SetConcurrency overrides how many passwords may be hashed at once and how
+// many more may wait for their turn. A zero maxConcurrent keeps the default,
+// which is one per CPU.
+func (config *Argon2idConfig) SetConcurrency(maxConcurrent uint, maxQueued uint) {
+	if maxConcurrent != 0 {
+		config.maxConcurrentHashes = maxConcurrent
+	}
+
+	config.maxQueuedHashes = maxQueued
+}
+
+func NewArgon2idHasher(config *Argon2idConfig) *Argon2idHasher {
+	return &Argon2idHasher{
+		memoryKiB:   config.memoryKiB,
+		iterations:  config.iterations,
+		parallelism: config.parallelism,
+		saltLength:  config.saltLength,
+		keyLength:   config.keyLength,
+		slots:       make(chan struct{}, config.maxConcurrentHashes),
+		admitted:    make(chan struct{}, config.maxConcurrentHashes+config.maxQueuedHashes),
+	}
+}
+
+// Hash returns the encoded hash of password. It returns an ErrHasherBusy
+// rather than wait when too many passwords are already waiting to be hashed.
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.saltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	err = h.acquire()
+	if err != nil {
+		return "", err
+	}
+	defer h.release()
+
+	key := argon2.IDKey([]byte(password), salt, h.iterations, h.memoryKiB, h.parallelism, h.keyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		ARGON2ID_PREFIX,
+		argon2.Version,
+		h.memoryKiB,
+		h.iterations,
+		h.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify hashes password with the salt and parameters of encodedHash and
+// compares the keys in constant time. Like Hash, it returns an ErrHasherBusy
+// when too many passwords are waiting to be hashed.
+func (h *Argon2idHasher) Verify(password string, encodedHash string) (bool, bool, error) {
+	hash, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return false, false, err
+	}
+
+	err = h.acquire()
+	if err != nil {
+		return false, false, err
+	}
+
+	key := argon2.IDKey([]byte(password), hash.salt, hash.iterations, hash.memoryKiB, hash.parallelism, uint32(len(hash.key)))
+	h.release()
+	if subtle.ConstantTimeCompare(key, hash.key) != 1 {
+		return false, false, nil
+	}
+
+	needsRehash := hash.memoryKiB != h.memoryKiB ||
+		hash.iterations != h.iterations ||
+		hash.parallelism != h.parallelism ||
+		uint32(len(hash.salt)) != h.saltLength ||
+		uint32(len(hash.key)) != h.keyLength
+
+	return true, needsRehash, nil
+}
+
+// acquire waits for a slot to hash a password in, unless too many hashes are
+// already waiting for one.
+func (h *Argon2idHasher) acquire() error {
+	select {
+	case h.admitted <- struct{}{}:
+	default:
+		return &ErrHasherBusy{}
+	}
+
+	h.slots <- struct{}{}
+	return nil
+}
+
+func (h *Argon2idHasher) release() {
+	<-h.slots
+	<-h.admitted
+}
+
+func decodeArgon2idHash(encodedHash string) (*argon2idHash, error) {
+	invalid := &ErrInvalidPasswordHash{}
+
+	if !strings.HasPrefix(encodedHash, ARGON2ID_PREFIX) {
+		return nil, invalid
+	}
+
+	parts := strings.Split(strings.TrimPrefix(encodedHash, ARGON2ID_PREFIX), "$")
+	if len(parts) != 4 {
+		return nil, invalid
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[0], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return nil, invalid
+	}
+
+	var hash argon2idHash
+	_, err = fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &hash.memoryKiB, &hash.iterations, &hash.parallelism)
+	if err != nil || hash.iterations == 0 || hash.parallelism == 0 {
+		return nil, invalid
+	}
+
+	hash.salt, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil || len(hash.salt) == 0 {
+		return nil, invalid
+	}
+
+	hash.key, err = base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(hash.key) == 0 {
+		return nil, invalid
+	}
+
+	return &hash, nil
+}
@@ -0,0 +1,103 @@
+package infrastructure_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+)
+
+func TestArgon2idHasher(t *testing.T) {
+	anyPassword := "correct horse"
+	newHasher := func(iterations uint32) *infrastructure.Argon2idHasher {
+		config := infrastructure.NewDefaultArgon2idConfig()
+		config.SetParameters(64, iterations, 1)
+		return infrastructure.NewArgon2idHasher(config)
+	}
+
+	t.Run("EncodeParametersInHash", func(t *testing.T) {
+		hash, err := newHasher(1).Hash(anyPassword)
+
+		assertNoError(t, err)
+		if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+			t.Errorf("Expected a PHC encoded argon2id hash, got %s", hash)
+		}
+	})
+
+	t.Run("SaltEveryHash", func(t *testing.T) {
+		hasher := newHasher(1)
+
+		first, _ := hasher.Hash(anyPassword)
+		second, _ := hasher.Hash(anyPassword)
+
+		assertFalse(t, first == second)
+	})
+
+	t.Run("VerifyMatchingPassword", func(t *testing.T) {
+		hasher := newHasher(1)
+		hash, _ := hasher.Hash(anyPassword)
+
+		ok, needsRehash, err := hasher.Verify(anyPassword, hash)
+
+		assertNoError(t, err)
+		assertTrue(t, ok)
+		assertFalse(t, needsRehash)
+	})
+
+	t.Run("RejectOtherPassword", func(t *testing.T) {
+		hasher := newHasher(1)
+		hash, _ := hasher.Hash(anyPassword)
+
+		ok, _, err := hasher.Verify("correct horsf", hash)
+
+		assertNoError(t, err)
+		assertFalse(t, ok)
+	})
+
+	t.Run("AskForRehashWhenParametersChanged", func(t *testing.T) {
+		hash, _ := newHasher(1).Hash(anyPassword)
+
+		ok, needsRehash, err := newHasher(2).Verify(anyPassword, hash)
+
+		assertNoError(t, err)
+		assertTrue(t, ok)
+		assertTrue(t, needsRehash)
+	})
+
+	t.Run("ReturnErrHasherBusyWhenQueueIsFull", func(t *testing.T) {
+		config := infrastructure.NewDefaultArgon2idConfig()
+		config.SetParameters(16*1024, 8, 1)
+		config.SetConcurrency(1, 0)
+		hasher := infrastructure.NewArgon2idHasher(config)
+		errs := make(chan error, 4)
+
+		for i := 0; i < cap(errs); i++ {
+			go func() {
+				_, err := hasher.Hash(anyPassword)
+				errs <- err
+			}()
+		}
+
+		busy := 0
+		for i := 0; i < cap(errs); i++ {
+			err := <-errs
+			if _, ok := err.(*infrastructure.ErrHasherBusy); ok {
+				busy++
+			} else {
+				assertNoError(t, err)
+			}
+		}
+
+		assertTrue(t, busy > 0 && busy < cap(errs))
+	})
+
+	t.Run("ReturnErrInvalidPasswordHash", func(t *testing.T) {
+		for _, hash := range []string{"12345", "$argon2id$v=19$m=64,t=1,p=1$salt", "$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5", "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5"} {
+			_, _, err := newHasher(1).Verify(anyPassword, hash)
+
+			if _, ok := err.(*infrastructure.ErrInvalidPasswordHash); !ok {
+				t.Errorf("Expected ErrInvalidPasswordHash for %s, got %v", hash, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package infrastructure
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+type trafficDirection int
+
+const (
+	upload trafficDirection = iota
+	download
+)
+
+// TokenBucket allows a number of bytes per second with bursts of up to one
+// second worth of bytes. A zero rate means unlimited.
+type TokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(bytesPerSecond uint64) *TokenBucket {
+	return &TokenBucket{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+	}
+}
+
+func (b *TokenBucket) SetRate(bytesPerSecond uint64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.rate = float64(bytesPerSecond)
+	b.tokens = min(b.tokens, b.rate)
+}
+
+// Reserve takes n bytes from the bucket and returns how long the caller must
+// wait before using them.
+func (b *TokenBucket) Reserve(n int) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+
+	if b.rate == 0 {
+		return 0
+	}
+
+	b.tokens = min(b.rate, b.tokens+elapsed*b.rate)
+	b.tokens -= float64(n)
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// BandwidthShaper throttles the traffic of the server overall and per user.
+type BandwidthShaper struct {
+	mutex         sync.Mutex
+	limits        models.BandwidthLimits
+	upload        *TokenBucket
+	download      *TokenBucket
+	userUploads   map[string]*TokenBucket
+	userDownloads map[string]*TokenBucket
+	stats         models.BandwidthStats
+}
+
+func NewBandwidthShaper(limits models.BandwidthLimits) *BandwidthShaper {
+	return &BandwidthShaper{
+		limits:        limits,
+		upload:        NewTokenBucket(limits.Upload),
+		download:      NewTokenBucket(limits.Download),
+		userUploads:   make(map[string]*TokenBucket),
+		userDownloads: make(map[string]*TokenBucket),
+		stats: models.BandwidthStats{
+			Users: make(map[string]models.UserBandwidthStats),
+		},
+	}
+}
+
+func (s *BandwidthShaper) SetLimits(limits models.BandwidthLimits) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.limits = limits
+	s.upload.SetRate(limits.Upload)
+	s.download.SetRate(limits.Download)
+
+	for _, bucket := range s.userUploads {
+		bucket.SetRate(limits.UserUpload)
+	}
+
+	for _, bucket := range s.userDownloads {
+		bucket.SetRate(limits.UserDownload)
+	}
+}
+
+// forget drops the buckets and the stats of userID, which has no connection
+// left. Its traffic stays in the totals.
+func (s *BandwidthShaper) forget(userID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.userUploads, userID)
+	delete(s.userDownloads, userID)
+	delete(s.stats.Users, userID)
+}
+
+func (s *BandwidthShaper) Stats() models.BandwidthStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := s.stats
+	stats.Limits = s.limits
+	stats.Users = make(map[string]models.UserBandwidthStats, len(s.stats.Users))
+	for userID, userStats := range s.stats.Users {
+		stats.Users[userID] = userStats
+	}
+
+	return stats
+}
+
+// throttle blocks until n bytes may go in direction for userID. An empty
+// userID is only limited by the overall limits.
+func (s *BandwidthShaper) throttle(direction trafficDirection, userID string, n int) {
+	wait := s.reserve(direction, userID, n)
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+func (s *BandwidthShaper) reserve(direction trafficDirection, userID string, n int) time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	global, userBuckets, userLimit := s.upload, s.userUploads, s.limits.UserUpload
+	if direction == download {
+		global, userBuckets, userLimit = s.download, s.userDownloads, s.limits.UserDownload
+	}
+
+	wait := global.Reserve(n)
+
+	if userID != "" {
+		bucket, ok := userBuckets[userID]
+		if !ok {
+			bucket = NewTokenBucket(userLimit)
+			userBuckets[userID] = bucket
+		}
+
+		wait = max(wait, bucket.Reserve(n))
+	}
+
+	userStats := s.stats.Users[userID]
+	total, perUser := &s.stats.Upload, &userStats.Upload
+	if direction == download {
+		total, perUser = &s.stats.Download, &userStats.Download
+	}
+
+	record(total, n, wait)
+	if userID != "" {
+		record(perUser, n, wait)
+		s.stats.Users[userID] = userStats
+	}
+
+	return wait
+}
+
+func record(stats *models.TrafficStats, n int, wait time.Duration) {
+	stats.Bytes += uint64(n)
+
+	if wait > 0 {
+		stats.Throttled++
+		stats.ThrottledFor += wait
+	}
+}
@@ -0,0 +1,76 @@
+package infrastructure_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestTokenBucket(t *testing.T) {
+	t.Run("NeverWaitIfUnlimited", func(t *testing.T) {
+		bucket := infrastructure.NewTokenBucket(0)
+
+		wait := bucket.Reserve(1 << 30)
+
+		assertDurationEquals(t, wait, 0)
+	})
+
+	t.Run("AllowBurstOfOneSecond", func(t *testing.T) {
+		bucket := infrastructure.NewTokenBucket(1024)
+
+		wait := bucket.Reserve(1024)
+
+		assertDurationEquals(t, wait, 0)
+	})
+
+	t.Run("WaitForMissingTokens", func(t *testing.T) {
+		bucket := infrastructure.NewTokenBucket(1024)
+		_ = bucket.Reserve(1024)
+
+		wait := bucket.Reserve(1024)
+
+		assertDurationAround(t, wait, time.Second)
+	})
+
+	t.Run("UseNewRateAfterChange", func(t *testing.T) {
+		bucket := infrastructure.NewTokenBucket(1024)
+		_ = bucket.Reserve(1024)
+		bucket.SetRate(2048)
+
+		wait := bucket.Reserve(1024)
+
+		assertDurationAround(t, wait, time.Second/2)
+	})
+}
+
+func TestBandwidthShaper(t *testing.T) {
+	t.Run("ReportLimits", func(t *testing.T) {
+		anyLimits := models.BandwidthLimits{Upload: 1024, UserDownload: 2048}
+		shaper := infrastructure.NewBandwidthShaper(models.BandwidthLimits{})
+
+		shaper.SetLimits(anyLimits)
+
+		if shaper.Stats().Limits != anyLimits {
+			t.Fatalf("Expected limits %v, got %v", anyLimits, shaper.Stats().Limits)
+		}
+	})
+}
+
+func assertDurationEquals(t *testing.T, got time.Duration, want time.Duration) {
+	t.Helper()
+
+	if got != want {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+}
+
+func assertDurationAround(t *testing.T, got time.Duration, want time.Duration) {
+	t.Helper()
+
+	tolerance := 50 * time.Millisecond
+	if got < want-tolerance || got > want+tolerance {
+		t.Fatalf("Expected around %s, got %s", want, got)
+	}
+}
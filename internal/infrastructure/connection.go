@@ -1,45 +1,77 @@
 package infrastructure
 
 import (
-	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/smallnest/ringbuffer"
 )
 
 type Connection struct {
+	id                 string
 	conn               net.Conn
 	transactionQueue   chan *Transaction
+	disconnectionQueue chan string
 	dataQueueSizeBytes uint
+	writeMutex         sync.Mutex
+	sendQueue          chan func() error
+	closed             chan struct{}
+	closeOnce          sync.Once
+	shaper             *BandwidthShaper
+	shapedAs           atomic.Value
+	metrics            *Metrics
+	logger             *slog.Logger
 }
 
 type ConnectionConfig struct {
 	conn               net.Conn
 	dataQueueSizeBytes uint
+	maxQueuedSends     uint
 	transactionQueue   chan *Transaction
+	disconnectionQueue chan string
+	shaper             *BandwidthShaper
+	metrics            *Metrics
 }
 
-func NewDefaultConnectionConfig(conn net.Conn, transactionQueue chan *Transaction) *ConnectionConfig {
+func NewDefaultConnectionConfig(conn net.Conn, transactionQueue chan *Transaction, disconnectionQueue chan string) *ConnectionConfig {
 	return &ConnectionConfig{
 		conn:               conn,
 		dataQueueSizeBytes: DEFAULT_QUEUE_SIZE_BYTES,
+		maxQueuedSends:     DEFAULT_MAX_QUEUED_SENDS,
 		transactionQueue:   transactionQueue,
+		disconnectionQueue: disconnectionQueue,
 	}
 }
 
 func NewConnection(config *ConnectionConfig) *Connection {
+	id := uuid.NewString()
+
 	return &Connection{
+		id:                 id,
 		conn:               config.conn,
 		transactionQueue:   config.transactionQueue,
+		disconnectionQueue: config.disconnectionQueue,
 		dataQueueSizeBytes: config.dataQueueSizeBytes,
+		sendQueue:          make(chan func() error, config.maxQueuedSends),
+		closed:             make(chan struct{}),
+		shaper:             config.shaper,
+		metrics:            config.metrics,
+		logger:             slog.Default().With("connection", id),
 	}
 }
 
+// Read queues every packet received until the connection is closed, then
+// queues the id of the connection on the disconnection queue. All the complete
+// packets buffered are queued before reading again, since a read may carry
+// several of them.
 func (connection *Connection) Read() {
+	defer connection.notifyDisconnection()
+
 	ring := ringbuffer.New(DEFAULT_QUEUE_SIZE_BYTES * 10)
 
 	for {
@@ -48,66 +80,175 @@ func (connection *Connection) Read() {
 		_ = connection.conn.SetDeadline(readDeadline)
 		read, err := connection.conn.Read(buff)
 
+		if err != nil && !os.IsTimeout(err) {
+			return
+		}
+
+		connection.throttle(upload, read)
+		connection.countBytes(upload, read)
+
+		wrote, err := ring.Write(buff[:read])
+
 		if err != nil {
-			if err == io.EOF {
+			connection.logger.Error("could not buffer received data", "bytes", read, "buffered", wrote, "error", err)
+			return
+		}
+
+		for ring.Length() >= HEADER_SIZE {
+			var h PacketHeader
+			peeked, err := ring.Peek(buff[:HEADER_SIZE])
+			if err != nil {
+				connection.logger.Error("could not peek packet header", "bytes", HEADER_SIZE, "peeked", peeked, "error", err)
+				return
+			}
+
+			err = h.fromBytes(buff[:HEADER_SIZE])
+			if err != nil {
+				connection.logger.Warn("received an invalid packet header", "error", err)
+				return
+			}
+
+			nextPacketLength := int(h.DataSize) + HEADER_SIZE
+
+			if ring.Length() < nextPacketLength {
 				break
 			}
 
-			if !os.IsTimeout(err) {
-				log.Fatal(err.Error())
+			raw := make([]byte, nextPacketLength)
+			read, err = ring.Read(raw)
+
+			if err != nil {
+				connection.logger.Error("could not read buffered packet", "bytes", nextPacketLength, "read", read, "error", err)
+				ring.Reset()
+				break
 			}
-		}
 
-		wrote, err := ring.Write(buff[:read])
+			var packet Packet
+			err = packet.FromBytes(raw)
+			if err != nil {
+				connection.logger.Warn("received an invalid packet", "opcode", h.Opcode.String(), "error", err)
+				return
+			}
 
-		if err != nil {
-			log.Fatalf("expected to write %d and wrote %d with the following error:\n%s", read, wrote, err)
-		}
+			connection.countFrame(upload, packet.Header.Opcode)
+			connection.logger.Debug("received packet", "opcode", packet.Header.Opcode.String(), "bytes", nextPacketLength)
 
-		if ring.Length() < HEADER_SIZE {
-			continue
-		}
+			transaction := Transaction{
+				packet: &packet,
+				from:   connection.id,
+			}
 
-		var h PacketHeader
-		peeked, err := ring.Peek(buff[:HEADER_SIZE])
-		if err != nil {
-			log.Fatalf("expected to peek %d and peeked %d with the following error:\n%s", HEADER_SIZE, peeked, err)
+			connection.transactionQueue <- &transaction
 		}
+	}
+}
 
-		err = h.fromBytes(buff[:HEADER_SIZE])
-		if err != nil {
-			log.Fatal(err.Error())
-		}
+// Write sends data as a whole, so packets written from several goroutines are
+// never interleaved.
+func (connection *Connection) Write(data []byte) (int, error) {
+	connection.writeMutex.Lock()
+	defer connection.writeMutex.Unlock()
+
+	connection.throttle(download, len(data))
+	wrote, err := connection.conn.Write(data)
+	connection.countBytes(download, wrote)
+	if err == nil && len(data) >= HEADER_SIZE {
+		connection.countFrame(download, PacketOpcode(data[1]))
+	}
 
-		nextPacketLength := int(h.DataSize) + HEADER_SIZE
+	return wrote, err
+}
 
-		if ring.Length() < nextPacketLength {
-			continue
-		}
+// Send queues data to be written by the writer of the connection, so the
+// caller waits neither on the peer nor on the bandwidth limits.
+func (connection *Connection) Send(data []byte) error {
+	return connection.Queue(func() error {
+		_, err := connection.Write(data)
+		return err
+	})
+}
 
-		read, err = ring.Read(buff[:nextPacketLength])
+// Queue runs send on the writer of the connection, after everything queued
+// before it. When the peer does not keep up and the queue is full, the
+// connection is closed rather than have the peer miss packets.
+func (connection *Connection) Queue(send func() error) error {
+	select {
+	case <-connection.closed:
+		return &ErrDisconnected{}
+	default:
+	}
 
-		if err != nil {
-			fmt.Printf("expected to read %d and read %d with the following error:\n%s", nextPacketLength, read, err)
-			ring.Reset()
-			continue
-		}
+	select {
+	case connection.sendQueue <- send:
+		return nil
+	default:
+		connection.logger.Warn("closing connection that does not keep up", "queued", len(connection.sendQueue))
+		_ = connection.Close()
+		return &ErrDataQueueFilled{}
+	}
+}
 
-		var packet Packet
-		err = packet.FromBytes(buff[:nextPacketLength])
-		if err != nil {
-			log.Fatal(err.Error())
+// WriteQueued runs what is queued with Send and Queue, one after the other,
+// until the connection is closed. A failed send closes the connection.
+func (connection *Connection) WriteQueued() {
+	for {
+		select {
+		case <-connection.closed:
+			return
+		case send := <-connection.sendQueue:
+			err := send()
+			if err != nil {
+				connection.logger.Warn("could not send queued data", "error", err)
+				_ = connection.Close()
+				return
+			}
 		}
+	}
+}
 
-		transaction := Transaction{
-			packet: &packet,
-			from:   connection.conn.LocalAddr().String(),
-		}
+func (connection *Connection) Close() error {
+	connection.closeOnce.Do(func() {
+		close(connection.closed)
+	})
+
+	return connection.conn.Close()
+}
+
+// shapeAs makes the traffic of the connection count against the limits of
+// userID.
+func (connection *Connection) shapeAs(userID string) {
+	connection.shapedAs.Store(userID)
+}
 
-		connection.transactionQueue <- &transaction
+func (connection *Connection) throttle(direction trafficDirection, n int) {
+	if connection.shaper == nil || n == 0 {
+		return
 	}
+
+	userID, _ := connection.shapedAs.Load().(string)
+	connection.shaper.throttle(direction, userID, n)
 }
 
-func (connection *Connection) Write(data []byte) (int, error) {
-	return connection.conn.Write(data)
+func (connection *Connection) countBytes(direction trafficDirection, n int) {
+	if connection.metrics == nil || n == 0 {
+		return
+	}
+
+	connection.metrics.Add(METRIC_BYTES, float64(n), "direction", metricDirection(direction))
+}
+
+func (connection *Connection) countFrame(direction trafficDirection, opcode PacketOpcode) {
+	if connection.metrics == nil {
+		return
+	}
+
+	connection.metrics.Add(METRIC_FRAMES, 1, "direction", metricDirection(direction), "opcode", opcode.String())
+}
+
+func (connection *Connection) notifyDisconnection() {
+	_ = connection.Close()
+
+	if connection.disconnectionQueue != nil {
+		connection.disconnectionQueue <- connection.id
+	}
 }
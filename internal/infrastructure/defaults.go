@@ -2,9 +2,43 @@ package infrastructure
 
 const (
 	DEFAULT_MAX_CONNECTIONS                = 8
+	DEFAULT_MAX_CONNECTIONS_PER_USER       = 4
+	DEFAULT_RETRY_AFTER_MS                 = 30 * 1000
+	DEFAULT_IDLE_TIMEOUT_MS                = 30 * 60 * 1000
+	DEFAULT_MAX_SESSION_AGE_MS             = 24 * 60 * 60 * 1000
+	DEFAULT_SESSION_CHECK_INTERVAL_MS      = 10 * 1000
 	DEFAULT_MAX_QUEUED_CONNECTIONS         = DEFAULT_MAX_CONNECTIONS
 	DEFAULT_MAX_QUEUED_SERVER_TRANSACTIONS = 128
 	DEFAULT_MAX_QUEUED_CLIENT_TRANSACTIONS = 32
 	DEFAULT_QUEUE_SIZE_BYTES               = (1024 * 64) - 1 // 64 KB
+	DEFAULT_MAX_QUEUED_SENDS               = 64
 	DEFAULT_READ_TIMEOUT_MS                = 100
+	DEFAULT_STAGING_MAX_AGE_MS             = 24 * 60 * 60 * 1000
+	DEFAULT_STAGING_CLEANUP_INTERVAL_MS    = 60 * 60 * 1000
+	DEFAULT_JOURNAL_DELETE_RETENTION_MS    = 30 * 24 * 60 * 60 * 1000
+	DEFAULT_EVENT_HISTORY_SIZE             = 256
+	DEFAULT_EVENT_SUBSCRIBER_BUFFER_SIZE   = 64
+	DEFAULT_WEBHOOK_TIMEOUT_MS             = 10 * 1000
+	DEFAULT_WEBHOOK_RETRY_BASE_MS          = 10 * 1000
+	DEFAULT_WEBHOOK_RETRY_MAX_MS           = 60 * 60 * 1000
+	DEFAULT_WEBHOOK_MAX_ATTEMPTS           = 10
+	DEFAULT_WEBHOOK_POLL_INTERVAL_MS       = 1000
+	DEFAULT_WEBHOOK_EVENT_QUEUE_SIZE       = 256
+	DEFAULT_WEBHOOK_LOG_SIZE_BYTES         = 4 * 1024 * 1024
+	DEFAULT_EXEC_HOOK_TIMEOUT_MS           = 5 * 60 * 1000
+	DEFAULT_MAX_CONCURRENT_EXEC_HOOKS      = 2
+	DEFAULT_EXEC_HOOK_QUEUE_SIZE           = 128
+	DEFAULT_EXEC_HOOK_OUTPUT_SIZE_BYTES    = 64 * 1024
+	DEFAULT_ACCEPT_RETRY_MS                = 100
+	DEFAULT_ARGON2ID_MEMORY_KIB            = 64 * 1024
+	DEFAULT_ARGON2ID_ITERATIONS            = 3
+	DEFAULT_ARGON2ID_PARALLELISM           = 2
+	DEFAULT_ARGON2ID_SALT_SIZE_BYTES       = 16
+	DEFAULT_ARGON2ID_KEY_SIZE_BYTES        = 32
+	DEFAULT_ARGON2ID_MAX_QUEUED_HASHES     = 64
+	DEFAULT_LOG_LEVEL                      = "info"
+	DEFAULT_LOG_FORMAT                     = LOG_FORMAT_TEXT
+	DEFAULT_MAIL_FROM                      = "sdisk@localhost"
+	DEFAULT_SMTP_PORT                      = 587
+	DEFAULT_SMTP_TIMEOUT_MS                = 10 * 1000
 )
@@ -1,6 +1,9 @@
 package infrastructure
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type ErrUnknownPacket struct {
 	Opcode uint8
@@ -68,3 +71,145 @@ type ErrDisconnected struct {
 func (e *ErrDisconnected) Error() string {
 	return "disconnected"
 }
+
+type ErrReportedByPeer struct {
+	Code    ErrorCode
+	Path    string
+	Message string
+}
+
+func (e *ErrReportedByPeer) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("peer reported error %d: %s", e.Code, e.Message)
+	}
+
+	return fmt.Sprintf("peer reported error %d for %s: %s", e.Code, e.Path, e.Message)
+}
+
+type ErrInvalidPath struct {
+	Path   string
+	Reason string
+}
+
+func (e *ErrInvalidPath) Error() string {
+	return fmt.Sprintf("the path %q %s", e.Path, e.Reason)
+}
+
+type ErrHashMismatch struct {
+	Path string
+}
+
+func (e *ErrHashMismatch) Error() string {
+	return fmt.Sprintf("the content received for %s does not match its hash", e.Path)
+}
+
+type ErrMaximumUserConnectionsReached struct {
+	MaxConnections uint
+}
+
+func (e *ErrMaximumUserConnectionsReached) Error() string {
+	return fmt.Sprintf("maximum number of %d connections for a single user reached", e.MaxConnections)
+}
+
+type ErrSessionUserMismatch struct {
+}
+
+func (e *ErrSessionUserMismatch) Error() string {
+	return "packet was sent for a different user than the one of its connection"
+}
+
+type ErrNotAuthenticated struct {
+	Reason string
+}
+
+func (e *ErrNotAuthenticated) Error() string {
+	return fmt.Sprintf("connection is not authenticated: %s", e.Reason)
+}
+
+type ErrDisconnectedByPeer struct {
+	Reason     DisconnectReason
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *ErrDisconnectedByPeer) Error() string {
+	return fmt.Sprintf("disconnected by peer with reason %d, retry after %s: %s", e.Reason, e.RetryAfter, e.Message)
+}
+
+type ErrSocketInUse struct {
+	Path string
+}
+
+func (e *ErrSocketInUse) Error() string {
+	return fmt.Sprintf("socket %s is already used by another process", e.Path)
+}
+
+type ErrNotASocket struct {
+	Path string
+}
+
+func (e *ErrNotASocket) Error() string {
+	return fmt.Sprintf("%s exists and is not a socket", e.Path)
+}
+
+type ErrInvalidLogLevel struct {
+	Level string
+}
+
+func (e *ErrInvalidLogLevel) Error() string {
+	return fmt.Sprintf("invalid log level %q, expected debug, info, warn or error", e.Level)
+}
+
+type ErrInvalidLogFormat struct {
+	Format string
+}
+
+func (e *ErrInvalidLogFormat) Error() string {
+	return fmt.Sprintf("invalid log format %q, expected text or json", e.Format)
+}
+
+type ErrInvalidPasswordHash struct {
+}
+
+func (e *ErrInvalidPasswordHash) Error() string {
+	return "password hash is not a valid argon2id hash"
+}
+
+type ErrHasherBusy struct {
+}
+
+func (e *ErrHasherBusy) Error() string {
+	return "too many passwords are being hashed, try again later"
+}
+
+type ErrInvalidMailHeader struct {
+	Header string
+}
+
+func (e *ErrInvalidMailHeader) Error() string {
+	return fmt.Sprintf("mail header %s must fit on a single line", e.Header)
+}
+
+type ErrPayloadTooLarge struct {
+	Size int
+}
+
+func (e *ErrPayloadTooLarge) Error() string {
+	return fmt.Sprintf("payload of %d bytes does not fit in a packet", e.Size)
+}
+
+type ErrSMTPAuthUnsupported struct {
+	Host string
+}
+
+func (e *ErrSMTPAuthUnsupported) Error() string {
+	return fmt.Sprintf("smtp server %s does not support authentication", e.Host)
+}
+
+type ErrSMTPTLSUnsupported struct {
+	Host string
+}
+
+func (e *ErrSMTPTLSUnsupported) Error() string {
+	return fmt.Sprintf("smtp server %s does not support STARTTLS, which is required", e.Host)
+}
@@ -0,0 +1,135 @@
+package infrastructure
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+// EventBus delivers the events published by the server to the subscribers of
+// their user and keeps the last ones of every user so subscribers can resume.
+// Event ids are at least the time they were published at in microseconds, so
+// they keep increasing across restarts and a subscriber resuming from an id of
+// a previous run is not mistaken for one that already saw the new events.
+type EventBus struct {
+	lastID      uint64
+	history     map[models.UserID][]models.Event
+	historySize int
+	subscribers map[models.UserID]map[*eventSubscriber]struct{}
+	bufferSize  int
+	forwards    []ports.EventPublisher
+	mutex       sync.Mutex
+}
+
+type eventSubscriber struct {
+	events chan models.Event
+	closed bool
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		history:     make(map[models.UserID][]models.Event),
+		historySize: DEFAULT_EVENT_HISTORY_SIZE,
+		subscribers: make(map[models.UserID]map[*eventSubscriber]struct{}),
+		bufferSize:  DEFAULT_EVENT_SUBSCRIBER_BUFFER_SIZE,
+	}
+}
+
+// Forward makes the bus publish every event to publisher once it has an id.
+func (bus *EventBus) Forward(publisher ports.EventPublisher) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	bus.forwards = append(bus.forwards, publisher)
+}
+
+// Publish never blocks on subscribers. A subscriber whose buffer is full is
+// dropped and has to resume from the last event it received.
+func (bus *EventBus) Publish(event models.Event) {
+	event, forwards := bus.deliver(event)
+
+	for _, publisher := range forwards {
+		publisher.Publish(event)
+	}
+}
+
+func (bus *EventBus) deliver(event models.Event) (models.Event, []ports.EventPublisher) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	bus.lastID = max(bus.lastID+1, uint64(time.Now().UnixMicro()))
+	event.ID = bus.lastID
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	history := append(bus.history[event.UserID], event)
+	if len(history) > bus.historySize {
+		history = history[len(history)-bus.historySize:]
+	}
+	bus.history[event.UserID] = history
+
+	for subscriber := range bus.subscribers[event.UserID] {
+		select {
+		case subscriber.events <- event:
+		default:
+			bus.unsubscribe(event.UserID, subscriber)
+		}
+	}
+
+	return event, bus.forwards
+}
+
+func (bus *EventBus) Subscribe(userID models.UserID, lastEventID uint64) ([]models.Event, <-chan models.Event, func()) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	var replay []models.Event
+	for _, event := range bus.history[userID] {
+		if event.ID > lastEventID {
+			replay = append(replay, event)
+		}
+	}
+
+	subscriber := &eventSubscriber{events: make(chan models.Event, bus.bufferSize)}
+	if bus.subscribers[userID] == nil {
+		bus.subscribers[userID] = make(map[*eventSubscriber]struct{})
+	}
+	bus.subscribers[userID][subscriber] = struct{}{}
+
+	cancel := func() {
+		bus.mutex.Lock()
+		defer bus.mutex.Unlock()
+
+		bus.unsubscribe(userID, subscriber)
+	}
+
+	return replay, subscriber.events, cancel
+}
+
+// Forget drops the events kept for a user and closes their subscriptions.
+func (bus *EventBus) Forget(userID models.UserID) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	delete(bus.history, userID)
+	for subscriber := range bus.subscribers[userID] {
+		bus.unsubscribe(userID, subscriber)
+	}
+}
+
+func (bus *EventBus) unsubscribe(userID models.UserID, subscriber *eventSubscriber) {
+	if subscriber.closed {
+		return
+	}
+
+	subscriber.closed = true
+	close(subscriber.events)
+	delete(bus.subscribers[userID], subscriber)
+
+	if len(bus.subscribers[userID]) == 0 {
+		delete(bus.subscribers, userID)
+	}
+}
@@ -0,0 +1,107 @@
+package infrastructure_test
+
+import (
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestEventBus(t *testing.T) {
+	anyUserID := models.NewUserID()
+	otherUserID := models.NewUserID()
+
+	t.Run("DeliverEventsOfUser", func(t *testing.T) {
+		bus := infrastructure.NewEventBus()
+		_, events, cancel := bus.Subscribe(anyUserID, 0)
+		defer cancel()
+
+		bus.Publish(models.Event{Type: models.EventFileUpdated, UserID: otherUserID})
+		bus.Publish(models.Event{Type: models.EventFileDeleted, UserID: anyUserID})
+
+		event := <-events
+		if event.Type != models.EventFileDeleted {
+			t.Fatalf("Expected an event of type %s, got %+v", models.EventFileDeleted, event)
+		}
+	})
+
+	t.Run("ReplayEventsAfterLastEventID", func(t *testing.T) {
+		bus := infrastructure.NewEventBus()
+		bus.Publish(models.Event{Type: models.EventDiskCreated, UserID: anyUserID})
+		bus.Publish(models.Event{Type: models.EventFileUpdated, UserID: anyUserID})
+		history, _, cancelHistory := bus.Subscribe(anyUserID, 0)
+		cancelHistory()
+
+		replay, _, cancel := bus.Subscribe(anyUserID, history[0].ID)
+		defer cancel()
+
+		if len(replay) != 1 || replay[0].ID != history[1].ID {
+			t.Fatalf("Expected to replay the second event, got %+v", replay)
+		}
+	})
+
+	t.Run("IssueIdsAboveThoseOfAPreviousRun", func(t *testing.T) {
+		previousRun := infrastructure.NewEventBus()
+		previousRun.Publish(models.Event{Type: models.EventFileUpdated, UserID: anyUserID})
+		previous, _, cancelPrevious := previousRun.Subscribe(anyUserID, 0)
+		cancelPrevious()
+		bus := infrastructure.NewEventBus()
+
+		bus.Publish(models.Event{Type: models.EventFileUpdated, UserID: anyUserID})
+
+		replay, _, cancel := bus.Subscribe(anyUserID, previous[0].ID)
+		defer cancel()
+		if len(replay) != 1 {
+			t.Fatalf("Expected the event of the new run to be newer than %d, got %+v", previous[0].ID, replay)
+		}
+	})
+
+	t.Run("ForgetEventsOfUser", func(t *testing.T) {
+		bus := infrastructure.NewEventBus()
+		bus.Publish(models.Event{Type: models.EventFileUpdated, UserID: anyUserID})
+		_, events, cancel := bus.Subscribe(anyUserID, 0)
+		defer cancel()
+
+		bus.Forget(anyUserID)
+
+		if _, ok := <-events; ok {
+			t.Fatalf("Expected the subscription to be closed")
+		}
+		replay, _, cancelReplay := bus.Subscribe(anyUserID, 0)
+		defer cancelReplay()
+		if len(replay) != 0 {
+			t.Fatalf("Expected no event kept, got %+v", replay)
+		}
+	})
+
+	t.Run("DropSubscriberFallingBehind", func(t *testing.T) {
+		bus := infrastructure.NewEventBus()
+		_, events, cancel := bus.Subscribe(anyUserID, 0)
+		defer cancel()
+
+		for i := 0; i <= infrastructure.DEFAULT_EVENT_SUBSCRIBER_BUFFER_SIZE; i++ {
+			bus.Publish(models.Event{Type: models.EventFileUpdated, UserID: anyUserID})
+		}
+
+		received := 0
+		for range events {
+			received++
+		}
+
+		if received != infrastructure.DEFAULT_EVENT_SUBSCRIBER_BUFFER_SIZE {
+			t.Fatalf("Expected %d events before being dropped, got %d", infrastructure.DEFAULT_EVENT_SUBSCRIBER_BUFFER_SIZE, received)
+		}
+	})
+
+	t.Run("StopDeliveringAfterCancel", func(t *testing.T) {
+		bus := infrastructure.NewEventBus()
+		_, events, cancel := bus.Subscribe(anyUserID, 0)
+
+		cancel()
+		bus.Publish(models.Event{Type: models.EventFileUpdated, UserID: anyUserID})
+
+		if _, ok := <-events; ok {
+			t.Fatalf("Expected no event after cancel")
+		}
+	})
+}
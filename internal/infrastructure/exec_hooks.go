@@ -0,0 +1,295 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+// ExecHook is a program run when an event of type Event happens on a path
+// matching PathPattern. An empty pattern matches every event, and ** in a
+// pattern matches any number of directories. The program only gets PATH and
+// HOME from the environment of the server unless InheritEnvironment is set,
+// so secrets such as SDISK_SMTP_PASSWORD are not handed to it.
+type ExecHook struct {
+	Name               string
+	Event              models.EventType
+	PathPattern        string
+	Command            []string
+	Timeout            time.Duration
+	InheritEnvironment bool
+}
+
+// ExecHookRunner runs the hooks of the events published to it, at most
+// maxConcurrent at a time. A hook gets the event in SDISK_* environment
+// variables and as JSON on its standard input, and its output is logged.
+type ExecHookRunner struct {
+	hooks         []ExecHook
+	diskRoot      string
+	maxConcurrent int
+	jobs          chan execHookJob
+	stop          chan struct{}
+	workers       sync.WaitGroup
+	logger        *slog.Logger
+}
+
+type execHookJob struct {
+	hook  ExecHook
+	event models.Event
+}
+
+type execHookInput struct {
+	Event     string    `json:"event"`
+	EventID   uint64    `json:"eventId"`
+	Time      time.Time `json:"time"`
+	UserID    string    `json:"userId"`
+	Email     string    `json:"email,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	File      string    `json:"file,omitempty"`
+	Size      uint64    `json:"size,omitempty"`
+	Hash      string    `json:"hash,omitempty"`
+	UsedBytes uint64    `json:"usedBytes,omitempty"`
+	DiskBytes uint64    `json:"diskBytes,omitempty"`
+}
+
+// limitedBuffer keeps the first bytes written to it and drops the rest.
+type limitedBuffer struct {
+	buffer    bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// NewExecHookRunner checks the hooks and returns a runner for them. Files are
+// given to hooks by their path under diskRoot.
+func NewExecHookRunner(hooks []ExecHook, diskRoot string, maxConcurrent int) (*ExecHookRunner, error) {
+	for i, hook := range hooks {
+		if !slices.Contains(models.EventTypes, hook.Event) {
+			return nil, fmt.Errorf("hook %s is run on unknown event %s", hook.Name, hook.Event)
+		}
+
+		if len(hook.Command) == 0 {
+			return nil, fmt.Errorf("hook %s has no command", hook.Name)
+		}
+
+		_, err := path.Match(hook.PathPattern, "")
+		if err != nil {
+			return nil, fmt.Errorf("hook %s has an invalid path pattern: %w", hook.Name, err)
+		}
+
+		if hook.Timeout <= 0 {
+			hooks[i].Timeout = DEFAULT_EXEC_HOOK_TIMEOUT_MS * time.Millisecond
+		}
+	}
+
+	if maxConcurrent <= 0 {
+		maxConcurrent = DEFAULT_MAX_CONCURRENT_EXEC_HOOKS
+	}
+
+	return &ExecHookRunner{
+		hooks:         hooks,
+		diskRoot:      diskRoot,
+		maxConcurrent: maxConcurrent,
+		jobs:          make(chan execHookJob, DEFAULT_EXEC_HOOK_QUEUE_SIZE),
+		stop:          make(chan struct{}),
+		logger:        slog.Default(),
+	}, nil
+}
+
+// Publish queues the hooks interested in event. Hooks are skipped when too
+// many are already waiting to run.
+func (r *ExecHookRunner) Publish(event models.Event) {
+	for _, hook := range r.hooks {
+		if hook.Event != event.Type || !MatchSyncPath(hook.PathPattern, event.Path) {
+			continue
+		}
+
+		select {
+		case r.jobs <- execHookJob{hook: hook, event: event}:
+		default:
+			r.logger.Warn("hook skipped, too many hooks waiting to run", "hook", hook.Name, "event", event.ID, "type", string(event.Type))
+		}
+	}
+}
+
+// Run runs the queued hooks until Stop is called.
+func (r *ExecHookRunner) Run() {
+	for i := 0; i < r.maxConcurrent; i++ {
+		r.workers.Add(1)
+		go r.work()
+	}
+
+	r.workers.Wait()
+}
+
+// Stop waits for the hooks running to exit and stops Run. Queued hooks are
+// dropped.
+func (r *ExecHookRunner) Stop() {
+	close(r.stop)
+	r.workers.Wait()
+}
+
+func (r *ExecHookRunner) work() {
+	defer r.workers.Done()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case job := <-r.jobs:
+			r.run(job.hook, job.event)
+		}
+	}
+}
+
+func (r *ExecHookRunner) run(hook ExecHook, event models.Event) {
+	input := r.inputOf(event)
+	stdin, err := json.Marshal(input)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hook.Timeout)
+	defer cancel()
+
+	output := limitedBuffer{limit: DEFAULT_EXEC_HOOK_OUTPUT_SIZE_BYTES}
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Env = append(baseEnvironment(hook), hookEnvironment(input)...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", hook.Timeout)
+	}
+
+	attrs := []any{"hook", hook.Name, "event", event.ID, "type", string(event.Type), "user", input.UserID, "path", event.Path, "duration", elapsed, "output", output.String()}
+	if err != nil {
+		r.logger.Error("hook failed", append(attrs, "error", err)...)
+		return
+	}
+
+	r.logger.Info("hook ran", attrs...)
+}
+
+func (r *ExecHookRunner) inputOf(event models.Event) execHookInput {
+	input := execHookInput{
+		Event:     string(event.Type),
+		EventID:   event.ID,
+		Time:      event.Time,
+		UserID:    event.UserID.ToString(),
+		Email:     event.Email,
+		Path:      event.Path,
+		Size:      event.Size,
+		Hash:      event.Hash,
+		UsedBytes: event.UsedBytes,
+		DiskBytes: event.DiskBytes,
+	}
+
+	if event.Path != "" {
+		input.File = filepath.Join(r.diskRoot, input.UserID, filepath.FromSlash(event.Path))
+	}
+
+	return input
+}
+
+// baseEnvironment returns the variables of the server given to hook, before
+// the ones describing the event.
+func baseEnvironment(hook ExecHook) []string {
+	if hook.InheritEnvironment {
+		return os.Environ()
+	}
+
+	var environment []string
+	for _, name := range []string{"PATH", "HOME"} {
+		value, ok := os.LookupEnv(name)
+		if ok {
+			environment = append(environment, name+"="+value)
+		}
+	}
+
+	return environment
+}
+
+func hookEnvironment(input execHookInput) []string {
+	return []string{
+		"SDISK_EVENT=" + input.Event,
+		"SDISK_EVENT_ID=" + strconv.FormatUint(input.EventID, 10),
+		"SDISK_USER_ID=" + input.UserID,
+		"SDISK_PATH=" + input.Path,
+		"SDISK_FILE=" + input.File,
+		"SDISK_SIZE=" + strconv.FormatUint(input.Size, 10),
+		"SDISK_HASH=" + input.Hash,
+	}
+}
+
+// MatchSyncPath reports whether the synchronized path p matches pattern. The
+// pattern is matched segment by segment like path.Match, and a ** segment
+// matches any number of segments.
+func MatchSyncPath(pattern string, p string) bool {
+	if pattern == "" {
+		return true
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(p, "/"))
+}
+
+func matchSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	matched, err := path.Match(pattern[0], segments[0])
+	return err == nil && matched && matchSegments(pattern[1:], segments[1:])
+}
+
+func (b *limitedBuffer) Write(data []byte) (int, error) {
+	written := len(data)
+	room := b.limit - b.buffer.Len()
+	if room < len(data) {
+		b.truncated = true
+		data = data[:max(room, 0)]
+	}
+
+	b.buffer.Write(data)
+	return written, nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buffer.String() + "\n[output truncated]"
+	}
+
+	return b.buffer.String()
+}
@@ -0,0 +1,142 @@
+package infrastructure_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestMatchSyncPath(t *testing.T) {
+	matches := map[string]string{
+		"":                 "anything/at/all.txt",
+		"*.mov":            "clip.mov",
+		"videos/*.mov":     "videos/clip.mov",
+		"videos/**/*.mov":  "videos/2024/summer/clip.mov",
+		"videos/**":        "videos/clip.mov",
+		"**/scans/*.pdf":   "scans/invoice.pdf",
+		"scans/[a-c]*.pdf": "scans/bill.pdf",
+	}
+
+	for pattern, path := range matches {
+		if !infrastructure.MatchSyncPath(pattern, path) {
+			t.Fatalf("Expected %s to match %s", path, pattern)
+		}
+	}
+
+	mismatches := map[string]string{
+		"*.mov":           "videos/clip.mov",
+		"videos/*.mov":    "videos/2024/clip.mov",
+		"videos/**/*.mov": "photos/clip.mov",
+		"scans/*.pdf":     "scans/invoice.png",
+	}
+
+	for pattern, path := range mismatches {
+		if infrastructure.MatchSyncPath(pattern, path) {
+			t.Fatalf("Expected %s not to match %s", path, pattern)
+		}
+	}
+}
+
+func TestExecHookRunner(t *testing.T) {
+	anyUserID := models.NewUserID()
+
+	t.Run("RejectUnknownEvent", func(t *testing.T) {
+		_, err := infrastructure.NewExecHookRunner([]infrastructure.ExecHook{{Event: "file.renamed", Command: []string{"true"}}}, t.TempDir(), 1)
+
+		assertError(t, err)
+	})
+
+	t.Run("RejectHookWithoutCommand", func(t *testing.T) {
+		_, err := infrastructure.NewExecHookRunner([]infrastructure.ExecHook{{Event: models.EventFileUpdated}}, t.TempDir(), 1)
+
+		assertError(t, err)
+	})
+
+	t.Run("GiveEventToMatchingHook", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "output")
+		script := "echo \"$SDISK_EVENT $SDISK_PATH $SDISK_FILE\" > " + output + "; cat >> " + output
+		hook := infrastructure.ExecHook{Name: "scan", Event: models.EventFileUpdated, PathPattern: "scans/*.pdf", Command: []string{"sh", "-c", script}}
+		runner := newTestHookRunner(t, "/srv/disk", hook)
+
+		runner.Publish(models.Event{ID: 1, Type: models.EventFileUpdated, UserID: anyUserID, Path: "photos/cat.png"})
+		runner.Publish(models.Event{ID: 2, Type: models.EventFileUpdated, UserID: anyUserID, Path: "scans/bill.pdf"})
+
+		content := waitForFile(t, output, "}")
+		file := filepath.Join("/srv/disk", anyUserID.ToString(), "scans", "bill.pdf")
+		if !strings.HasPrefix(content, "file.updated scans/bill.pdf "+file+"\n") || !strings.Contains(content, "\"eventId\":2") {
+			t.Fatalf("Unexpected hook input %q", content)
+		}
+	})
+
+	t.Run("KeepSecretsOfServerFromHook", func(t *testing.T) {
+		t.Setenv("SDISK_SMTP_PASSWORD", "hunter2")
+		output := filepath.Join(t.TempDir(), "output")
+		script := "echo \"secret=$SDISK_SMTP_PASSWORD path=$PATH\" > " + output
+		hook := infrastructure.ExecHook{Name: "env", Event: models.EventFileDeleted, Command: []string{"sh", "-c", script}}
+		runner := newTestHookRunner(t, t.TempDir(), hook)
+
+		runner.Publish(models.Event{ID: 1, Type: models.EventFileDeleted, UserID: anyUserID, Path: "a.txt"})
+
+		content := waitForFile(t, output, os.Getenv("PATH"))
+		if strings.Contains(content, "hunter2") {
+			t.Fatalf("Expected the hook not to get the environment of the server, got %q", content)
+		}
+	})
+
+	t.Run("GiveEnvironmentOfServerToHookInheritingIt", func(t *testing.T) {
+		t.Setenv("SDISK_SMTP_PASSWORD", "hunter2")
+		output := filepath.Join(t.TempDir(), "output")
+		script := "echo \"secret=$SDISK_SMTP_PASSWORD\" > " + output
+		hook := infrastructure.ExecHook{Name: "env", Event: models.EventFileDeleted, Command: []string{"sh", "-c", script}, InheritEnvironment: true}
+		runner := newTestHookRunner(t, t.TempDir(), hook)
+
+		runner.Publish(models.Event{ID: 1, Type: models.EventFileDeleted, UserID: anyUserID, Path: "a.txt"})
+
+		waitForFile(t, output, "secret=hunter2")
+	})
+
+	t.Run("KillHookAfterTimeout", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "output")
+		script := "sleep 5; echo late > " + output
+		hook := infrastructure.ExecHook{Name: "slow", Event: models.EventFileDeleted, Command: []string{"sh", "-c", script}, Timeout: 50 * time.Millisecond}
+		runner := newTestHookRunner(t, t.TempDir(), hook)
+
+		runner.Publish(models.Event{ID: 1, Type: models.EventFileDeleted, UserID: anyUserID, Path: "a.txt"})
+		time.Sleep(1500 * time.Millisecond)
+
+		assertFileDoesNotExist(t, output)
+	})
+}
+
+func newTestHookRunner(t *testing.T, diskRoot string, hooks ...infrastructure.ExecHook) *infrastructure.ExecHookRunner {
+	t.Helper()
+
+	runner, err := infrastructure.NewExecHookRunner(hooks, diskRoot, 1)
+	assertNoError(t, err)
+	go runner.Run()
+	t.Cleanup(runner.Stop)
+
+	return runner
+}
+
+func waitForFile(t *testing.T, path string, suffix string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		content, err := os.ReadFile(path)
+		if err == nil && strings.HasSuffix(strings.TrimSpace(string(content)), suffix) {
+			return string(content)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Expected %s to be written", path)
+	return ""
+}
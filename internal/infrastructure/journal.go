@@ -0,0 +1,381 @@
+package infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+const (
+	JOURNAL_DIRECTORY_NAME = ".sdisk-journal"
+	JOURNAL_FILE_EXTENSION = ".jsonl"
+)
+
+// compactedOperation marks the first record of a compacted journal. Its seq is
+// the last change that was dropped, so cursors before it cannot be resumed.
+const compactedOperation = "compacted"
+
+// FileJournal keeps the changes of every user in an append-only file of JSON
+// lines. Every record is synced before Append returns.
+type FileJournal struct {
+	root     string
+	journals map[string]*userJournal
+	mutex    sync.Mutex
+}
+
+type userJournal struct {
+	lastSeq uint64
+	horizon uint64
+	latest  map[string]models.Change
+}
+
+type journalRecord struct {
+	Seq    uint64    `json:"seq"`
+	Op     string    `json:"op"`
+	Path   string    `json:"path,omitempty"`
+	Size   uint64    `json:"size,omitempty"`
+	Hash   string    `json:"hash,omitempty"`
+	Device string    `json:"device,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+func NewFileJournal(root string) *FileJournal {
+	return &FileJournal{
+		root:     root,
+		journals: make(map[string]*userJournal),
+	}
+}
+
+func (j *FileJournal) Append(userID models.UserID, change models.Change) (models.Change, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	journal, err := j.load(userID)
+	if err != nil {
+		return change, err
+	}
+
+	err = os.MkdirAll(j.root, 0777)
+	if err != nil {
+		return change, err
+	}
+
+	change.Seq = journal.lastSeq + 1
+	if change.Time.IsZero() {
+		change.Time = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(toJournalRecord(change))
+	if err != nil {
+		return change, err
+	}
+
+	file, err := os.OpenFile(j.path(userID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return change, err
+	}
+
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		return change, err
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return change, err
+	}
+
+	journal.lastSeq = change.Seq
+	journal.latest[change.Path] = change
+	return change, nil
+}
+
+// Since returns the changes recorded after cursor in order and the cursor of
+// the last change of the journal.
+func (j *FileJournal) Since(userID models.UserID, cursor uint64) ([]models.Change, uint64, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	journal, err := j.load(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if cursor < journal.horizon || cursor > journal.lastSeq {
+		return nil, journal.lastSeq, &models.ErrCursorExpired{Cursor: cursor}
+	}
+
+	records, err := j.read(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var changes []models.Change
+	for _, record := range records {
+		if record.Op != compactedOperation && record.Seq > cursor {
+			changes = append(changes, record.toChange())
+		}
+	}
+
+	return changes, journal.lastSeq, nil
+}
+
+// Cursor returns the cursor of the last change of the journal.
+func (j *FileJournal) Cursor(userID models.UserID) (uint64, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	journal, err := j.load(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return journal.lastSeq, nil
+}
+
+// Latest returns the last change recorded for path, and false when there is
+// none.
+func (j *FileJournal) Latest(userID models.UserID, path string) (models.Change, bool, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	journal, err := j.load(userID)
+	if err != nil {
+		return models.Change{}, false, err
+	}
+
+	change, ok := journal.latest[path]
+	return change, ok, nil
+}
+
+// Compact keeps only the last change of every path and drops the deletions
+// older than deleteRetention.
+func (j *FileJournal) Compact(userID models.UserID, deleteRetention time.Duration) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	journal, err := j.load(userID)
+	if err != nil {
+		return err
+	}
+
+	records, err := j.read(userID)
+	if err != nil {
+		return err
+	}
+
+	latest := make(map[string]uint64)
+	for _, record := range records {
+		if record.Op != compactedOperation {
+			latest[record.Path] = record.Seq
+		}
+	}
+
+	horizon := journal.horizon
+	var kept []journalRecord
+	dropped := 0
+	now := time.Now()
+
+	for _, record := range records {
+		if record.Op == compactedOperation {
+			continue
+		}
+
+		if latest[record.Path] != record.Seq {
+			dropped++
+			continue
+		}
+
+		if record.Op == string(models.ChangeDelete) && now.Sub(record.Time) >= deleteRetention {
+			horizon = max(horizon, record.Seq)
+			dropped++
+			continue
+		}
+
+		kept = append(kept, record)
+	}
+
+	if dropped == 0 {
+		return nil
+	}
+
+	if horizon > 0 {
+		marker := journalRecord{Seq: horizon, Op: compactedOperation, Time: now.UTC()}
+		kept = append([]journalRecord{marker}, kept...)
+	}
+
+	err = j.rewrite(userID, kept)
+	if err != nil {
+		return err
+	}
+
+	journal.horizon = horizon
+	return nil
+}
+
+// Delete removes the journal of userID. A journal that does not exist is not
+// an error.
+func (j *FileJournal) Delete(userID models.UserID) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	err := os.Remove(j.path(userID))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	delete(j.journals, userID.ToString())
+	return nil
+}
+
+func (j *FileJournal) path(userID models.UserID) string {
+	return filepath.Join(j.root, userID.ToString()+JOURNAL_FILE_EXTENSION)
+}
+
+func (j *FileJournal) load(userID models.UserID) (*userJournal, error) {
+	id := userID.ToString()
+	journal, ok := j.journals[id]
+	if ok {
+		return journal, nil
+	}
+
+	err := j.repair(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := j.read(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	journal = &userJournal{latest: make(map[string]models.Change)}
+	for _, record := range records {
+		journal.lastSeq = max(journal.lastSeq, record.Seq)
+		if record.Op == compactedOperation {
+			journal.horizon = max(journal.horizon, record.Seq)
+			continue
+		}
+
+		journal.latest[record.Path] = record.toChange()
+	}
+
+	j.journals[id] = journal
+	return journal, nil
+}
+
+// repair truncates a line cut short by a crash while it was appended, so the
+// next record does not end up on the same line.
+func (j *FileJournal) repair(userID models.UserID) error {
+	path := j.path(userID)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil || len(data) == 0 || data[len(data)-1] == '\n' {
+		return err
+	}
+
+	return os.Truncate(path, int64(bytes.LastIndexByte(data, '\n')+1))
+}
+
+// read returns the records of the journal of userID, skipping the lines that
+// cannot be decoded.
+func (j *FileJournal) read(userID models.UserID) ([]journalRecord, error) {
+	file, err := os.Open(j.path(userID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var records []journalRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 2*MAX_SYNC_PATH_LENGTH+64*1024)
+
+	for scanner.Scan() {
+		var record journalRecord
+		if json.Unmarshal(scanner.Bytes(), &record) == nil {
+			records = append(records, record)
+		}
+	}
+
+	return records, scanner.Err()
+}
+
+func (j *FileJournal) rewrite(userID models.UserID, records []journalRecord) error {
+	path := j.path(userID)
+	temporaryPath := path + ".tmp"
+
+	file, err := os.Create(temporaryPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		_, _ = writer.Write(append(line, '\n'))
+	}
+
+	err = writer.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(temporaryPath, path)
+	if err != nil {
+		return err
+	}
+
+	return syncDirectory(j.root)
+}
+
+func toJournalRecord(change models.Change) journalRecord {
+	return journalRecord{
+		Seq:    change.Seq,
+		Op:     string(change.Op),
+		Path:   change.Path,
+		Size:   change.Size,
+		Hash:   change.Hash,
+		Device: change.Device,
+		Time:   change.Time,
+	}
+}
+
+func (record journalRecord) toChange() models.Change {
+	return models.Change{
+		Seq:    record.Seq,
+		Op:     models.ChangeOperation(record.Op),
+		Path:   record.Path,
+		Size:   record.Size,
+		Hash:   record.Hash,
+		Device: record.Device,
+		Time:   record.Time,
+	}
+}
@@ -0,0 +1,167 @@
+package infrastructure_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestFileJournal(t *testing.T) {
+	anyUserID := models.NewUserID()
+
+	t.Run("NumberChangesInOrder", func(t *testing.T) {
+		journal := infrastructure.NewFileJournal(t.TempDir())
+
+		first, _ := journal.Append(anyUserID, updateOf("a.txt"))
+		second, err := journal.Append(anyUserID, updateOf("b.txt"))
+
+		assertNoError(t, err)
+		assertUint64Equals(t, first.Seq, 1)
+		assertUint64Equals(t, second.Seq, 2)
+	})
+
+	t.Run("ListChangesAfterCursor", func(t *testing.T) {
+		journal := infrastructure.NewFileJournal(t.TempDir())
+		_, _ = journal.Append(anyUserID, updateOf("a.txt"))
+		_, _ = journal.Append(anyUserID, updateOf("b.txt"))
+
+		changes, cursor, err := journal.Since(anyUserID, 1)
+
+		assertNoError(t, err)
+		assertUint64Equals(t, cursor, 2)
+		assertChangePaths(t, changes, "b.txt")
+	})
+
+	t.Run("KeepChangesAcrossRestarts", func(t *testing.T) {
+		root := t.TempDir()
+		_, _ = infrastructure.NewFileJournal(root).Append(anyUserID, updateOf("a.txt"))
+
+		journal := infrastructure.NewFileJournal(root)
+		change, _ := journal.Append(anyUserID, updateOf("b.txt"))
+
+		assertUint64Equals(t, change.Seq, 2)
+	})
+
+	t.Run("IgnoreRecordCutShortByCrash", func(t *testing.T) {
+		root := t.TempDir()
+		_, _ = infrastructure.NewFileJournal(root).Append(anyUserID, updateOf("a.txt"))
+		path := filepath.Join(root, anyUserID.ToString()+infrastructure.JOURNAL_FILE_EXTENSION)
+		file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		_, _ = file.WriteString("{\"seq\":2,\"op\":\"upd")
+		file.Close()
+
+		journal := infrastructure.NewFileJournal(root)
+		_, _ = journal.Append(anyUserID, updateOf("b.txt"))
+		changes, _, err := journal.Since(anyUserID, 0)
+
+		assertNoError(t, err)
+		assertChangePaths(t, changes, "a.txt", "b.txt")
+	})
+
+	t.Run("RejectUnknownCursor", func(t *testing.T) {
+		journal := infrastructure.NewFileJournal(t.TempDir())
+
+		_, _, err := journal.Since(anyUserID, 3)
+
+		assertCursorExpired(t, err)
+	})
+
+	t.Run("CompactToLastChangeOfEveryPath", func(t *testing.T) {
+		journal := infrastructure.NewFileJournal(t.TempDir())
+		_, _ = journal.Append(anyUserID, updateOf("a.txt"))
+		_, _ = journal.Append(anyUserID, updateOf("b.txt"))
+		_, _ = journal.Append(anyUserID, updateOf("a.txt"))
+
+		err := journal.Compact(anyUserID, time.Hour)
+		changes, cursor, _ := journal.Since(anyUserID, 0)
+
+		assertNoError(t, err)
+		assertUint64Equals(t, cursor, 3)
+		assertChangePaths(t, changes, "b.txt", "a.txt")
+	})
+
+	t.Run("ExpireCursorsBeforeDroppedDeletions", func(t *testing.T) {
+		journal := infrastructure.NewFileJournal(t.TempDir())
+		_, _ = journal.Append(anyUserID, updateOf("a.txt"))
+		_, _ = journal.Append(anyUserID, models.Change{Op: models.ChangeDelete, Path: "a.txt"})
+		_, _ = journal.Append(anyUserID, updateOf("b.txt"))
+
+		_ = journal.Compact(anyUserID, 0)
+		_, _, expired := journal.Since(anyUserID, 1)
+		changes, _, err := journal.Since(anyUserID, 2)
+		next, _ := journal.Append(anyUserID, updateOf("c.txt"))
+
+		assertCursorExpired(t, expired)
+		assertNoError(t, err)
+		assertChangePaths(t, changes, "b.txt")
+		assertUint64Equals(t, next.Seq, 4)
+	})
+
+	t.Run("ReturnLatestChangeOfPath", func(t *testing.T) {
+		root := t.TempDir()
+		_, _ = infrastructure.NewFileJournal(root).Append(anyUserID, updateOf("a.txt"))
+		_, _ = infrastructure.NewFileJournal(root).Append(anyUserID, models.Change{Op: models.ChangeDelete, Path: "a.txt"})
+
+		journal := infrastructure.NewFileJournal(root)
+		latest, ok, err := journal.Latest(anyUserID, "a.txt")
+		_, missing, _ := journal.Latest(anyUserID, "b.txt")
+
+		assertNoError(t, err)
+		assertTrue(t, ok)
+		assertTrue(t, latest.Op == models.ChangeDelete)
+		assertUint64Equals(t, latest.Seq, 2)
+		assertFalse(t, missing)
+	})
+
+	t.Run("StartOverAfterDelete", func(t *testing.T) {
+		root := t.TempDir()
+		journal := infrastructure.NewFileJournal(root)
+		_, _ = journal.Append(anyUserID, updateOf("a.txt"))
+
+		err := journal.Delete(anyUserID)
+		cursor, _ := journal.Cursor(anyUserID)
+		_, statErr := os.Stat(filepath.Join(root, anyUserID.ToString()+infrastructure.JOURNAL_FILE_EXTENSION))
+
+		assertNoError(t, err)
+		assertUint64Equals(t, cursor, 0)
+		assertTrue(t, os.IsNotExist(statErr))
+	})
+
+	t.Run("DeleteMissingJournal", func(t *testing.T) {
+		journal := infrastructure.NewFileJournal(t.TempDir())
+
+		err := journal.Delete(anyUserID)
+
+		assertNoError(t, err)
+	})
+}
+
+func updateOf(path string) models.Change {
+	return models.Change{Op: models.ChangeUpdate, Path: path, Size: 5}
+}
+
+func assertChangePaths(t *testing.T, changes []models.Change, want ...string) {
+	t.Helper()
+
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %d", len(want), len(changes))
+	}
+
+	for i, change := range changes {
+		if change.Path != want[i] {
+			t.Fatalf("Expected change %d to be on %s, got %s", i, want[i], change.Path)
+		}
+	}
+}
+
+func assertCursorExpired(t *testing.T, err error) {
+	t.Helper()
+
+	if _, ok := err.(*models.ErrCursorExpired); !ok {
+		t.Fatalf("Expected ErrCursorExpired, got %v", err)
+	}
+}
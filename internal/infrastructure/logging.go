@@ -0,0 +1,42 @@
+package infrastructure
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	LOG_FORMAT_TEXT = "text"
+	LOG_FORMAT_JSON = "json"
+)
+
+// NewLogger returns a logger writing to w the records at level or above,
+// formatted as text or as one JSON object per line. An empty level or format
+// keeps the default.
+func NewLogger(w io.Writer, level string, format string) (*slog.Logger, error) {
+	if level == "" {
+		level = DEFAULT_LOG_LEVEL
+	}
+
+	if format == "" {
+		format = DEFAULT_LOG_FORMAT
+	}
+
+	var slogLevel slog.Level
+	err := slogLevel.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, &ErrInvalidLogLevel{Level: level}
+	}
+
+	options := &slog.HandlerOptions{Level: slogLevel}
+
+	switch strings.ToLower(format) {
+	case LOG_FORMAT_TEXT:
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case LOG_FORMAT_JSON:
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+
+	return nil, &ErrInvalidLogFormat{Format: format}
+}
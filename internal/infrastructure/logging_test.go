@@ -0,0 +1,71 @@
+package infrastructure_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Run("WriteOneJSONObjectPerRecord", func(t *testing.T) {
+		var output bytes.Buffer
+		logger, err := infrastructure.NewLogger(&output, "info", "json")
+		assertNoError(t, err)
+
+		logger.Info("connection opened", "connection", "abc")
+
+		var record map[string]any
+		assertNoError(t, json.Unmarshal(output.Bytes(), &record))
+		if record["msg"] != "connection opened" || record["connection"] != "abc" {
+			t.Errorf("Expected the message and its attributes but got %v", record)
+		}
+	})
+
+	t.Run("DropRecordsBelowLevel", func(t *testing.T) {
+		var output bytes.Buffer
+		logger, err := infrastructure.NewLogger(&output, "warn", "text")
+		assertNoError(t, err)
+
+		logger.Debug("sent chunk")
+		logger.Info("connection opened")
+		logger.Warn("could not handle packet")
+
+		if strings.Contains(output.String(), "sent chunk") || strings.Contains(output.String(), "connection opened") {
+			t.Errorf("Expected records below warn to be dropped but got %s", output.String())
+		}
+		assertContains(t, output.String(), "could not handle packet")
+	})
+
+	t.Run("DefaultToInfoAsText", func(t *testing.T) {
+		var output bytes.Buffer
+		logger, err := infrastructure.NewLogger(&output, "", "")
+		assertNoError(t, err)
+
+		logger.Debug("sent chunk")
+		logger.Info("connection opened")
+
+		assertContains(t, output.String(), "level=INFO msg=\"connection opened\"")
+		if strings.Contains(output.String(), "sent chunk") {
+			t.Errorf("Expected debug records to be dropped")
+		}
+	})
+
+	t.Run("ReturnErrInvalidLogLevel", func(t *testing.T) {
+		_, err := infrastructure.NewLogger(&bytes.Buffer{}, "verbose", "text")
+
+		if _, ok := err.(*infrastructure.ErrInvalidLogLevel); !ok {
+			t.Errorf("Expected ErrInvalidLogLevel but got %v", err)
+		}
+	})
+
+	t.Run("ReturnErrInvalidLogFormat", func(t *testing.T) {
+		_, err := infrastructure.NewLogger(&bytes.Buffer{}, "info", "xml")
+
+		if _, ok := err.(*infrastructure.ErrInvalidLogFormat); !ok {
+			t.Errorf("Expected ErrInvalidLogFormat but got %v", err)
+		}
+	})
+}
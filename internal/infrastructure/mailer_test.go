@@ -0,0 +1,168 @@
+package infrastructure_test
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestOutboxMailer(t *testing.T) {
+	anyMail := models.Mail{To: "john_doe@test.com", Subject: "Vérifiez", Body: "first line\nsecond line\n"}
+
+	t.Run("WriteMailToOutbox", func(t *testing.T) {
+		mailer := infrastructure.NewOutboxMailer(t.TempDir(), "sdisk@localhost")
+
+		assertNoError(t, mailer.Send(anyMail))
+
+		messages, err := mailer.Messages()
+		assertNoError(t, err)
+		assertTrue(t, len(messages) == 1)
+		content, _ := os.ReadFile(messages[0])
+		message := string(content)
+		assertTrue(t, strings.Contains(message, "From: sdisk@localhost\r\n"))
+		assertTrue(t, strings.Contains(message, "To: john_doe@test.com\r\n"))
+		assertTrue(t, strings.Contains(message, "Subject: =?utf-8?q?"))
+		assertTrue(t, strings.HasSuffix(message, "\r\n\r\nfirst line\r\nsecond line\r\n"))
+	})
+
+	t.Run("RefuseHeaderSpanningLines", func(t *testing.T) {
+		mailer := infrastructure.NewOutboxMailer(t.TempDir(), "sdisk@localhost")
+
+		err := mailer.Send(models.Mail{To: "john_doe@test.com\r\nBcc: eve@test.com", Subject: "subject"})
+
+		if _, ok := err.(*infrastructure.ErrInvalidMailHeader); !ok {
+			t.Fatalf("Expected ErrInvalidMailHeader, got %v", err)
+		}
+		messages, _ := mailer.Messages()
+		assertTrue(t, len(messages) == 0)
+	})
+}
+
+func TestSMTPMailer(t *testing.T) {
+	t.Run("DeliverMailToRelay", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assertNoError(t, err)
+		defer listener.Close()
+		received := make(chan []string, 1)
+		go serveSMTP(listener, received)
+
+		port := uint(listener.Addr().(*net.TCPAddr).Port)
+		mailer := infrastructure.NewSMTPMailer(infrastructure.NewDefaultSMTPMailerConfig("127.0.0.1", port, "sdisk@localhost"))
+
+		err = mailer.Send(models.Mail{To: "john_doe@test.com", Subject: "subject", Body: "body"})
+
+		assertNoError(t, err)
+		commands := strings.Join(<-received, "\n")
+		assertTrue(t, strings.Contains(commands, "MAIL FROM:<sdisk@localhost>"))
+		assertTrue(t, strings.Contains(commands, "RCPT TO:<john_doe@test.com>"))
+		assertTrue(t, strings.Contains(commands, "Subject: subject"))
+	})
+
+	t.Run("ReturnErrSMTPAuthUnsupportedIfRelayCannotAuthenticate", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assertNoError(t, err)
+		defer listener.Close()
+		go serveSMTP(listener, make(chan []string, 1))
+
+		port := uint(listener.Addr().(*net.TCPAddr).Port)
+		config := infrastructure.NewDefaultSMTPMailerConfig("127.0.0.1", port, "sdisk@localhost")
+		config.SetCredentials("sdisk", "secret")
+		config.SetRequireTLS(false)
+		mailer := infrastructure.NewSMTPMailer(config)
+
+		err = mailer.Send(models.Mail{To: "john_doe@test.com", Subject: "subject", Body: "body"})
+
+		if _, ok := err.(*infrastructure.ErrSMTPAuthUnsupported); !ok {
+			t.Fatalf("Expected ErrSMTPAuthUnsupported, got %v", err)
+		}
+	})
+
+	t.Run("RefuseToSendCredentialsWithoutSTARTTLS", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assertNoError(t, err)
+		defer listener.Close()
+		received := make(chan []string, 1)
+		go serveSMTP(listener, received)
+
+		port := uint(listener.Addr().(*net.TCPAddr).Port)
+		config := infrastructure.NewDefaultSMTPMailerConfig("127.0.0.1", port, "sdisk@localhost")
+		config.SetCredentials("sdisk", "secret")
+		mailer := infrastructure.NewSMTPMailer(config)
+
+		err = mailer.Send(models.Mail{To: "john_doe@test.com", Subject: "subject", Body: "body"})
+
+		if _, ok := err.(*infrastructure.ErrSMTPTLSUnsupported); !ok {
+			t.Fatalf("Expected ErrSMTPTLSUnsupported, got %v", err)
+		}
+		commands := strings.Join(<-received, "\n")
+		assertFalse(t, strings.Contains(commands, "MAIL FROM"))
+	})
+
+	t.Run("RefuseToSendWithoutSTARTTLSWhenRequired", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assertNoError(t, err)
+		defer listener.Close()
+		go serveSMTP(listener, make(chan []string, 1))
+
+		port := uint(listener.Addr().(*net.TCPAddr).Port)
+		config := infrastructure.NewDefaultSMTPMailerConfig("127.0.0.1", port, "sdisk@localhost")
+		config.SetRequireTLS(true)
+		mailer := infrastructure.NewSMTPMailer(config)
+
+		err = mailer.Send(models.Mail{To: "john_doe@test.com", Subject: "subject", Body: "body"})
+
+		if _, ok := err.(*infrastructure.ErrSMTPTLSUnsupported); !ok {
+			t.Fatalf("Expected ErrSMTPTLSUnsupported, got %v", err)
+		}
+	})
+}
+
+// serveSMTP answers one client as a relay without extensions, and reports
+// every line it received.
+func serveSMTP(listener net.Listener, received chan<- []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var lines []string
+	defer func() { received <- lines }()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+
+		switch {
+		case inData:
+			if line == "." {
+				inData = false
+				reply("250 queued")
+			}
+		case strings.HasPrefix(line, "EHLO"):
+			reply("250 localhost")
+		case strings.HasPrefix(line, "DATA"):
+			inData = true
+			reply("354 go ahead")
+		case strings.HasPrefix(line, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
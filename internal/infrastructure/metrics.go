@@ -0,0 +1,270 @@
+package infrastructure
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type metricKind string
+
+const (
+	counterMetric   metricKind = "counter"
+	gaugeMetric     metricKind = "gauge"
+	histogramMetric metricKind = "histogram"
+)
+
+// DEFAULT_LATENCY_BUCKETS are the upper bounds, in seconds, of the latency
+// histograms.
+var DEFAULT_LATENCY_BUCKETS = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics keeps counters, gauges and histograms and writes them in the text
+// format scraped by Prometheus. Labels are given as name and value pairs.
+type Metrics struct {
+	families   map[string]*metricFamily
+	collectors []func()
+	mutex      sync.Mutex
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	kind    metricKind
+	buckets []float64
+	series  map[string]*metricSeries
+}
+
+type metricSeries struct {
+	labels string
+	value  float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func NewMetrics() *Metrics {
+	metrics := &Metrics{
+		families: make(map[string]*metricFamily),
+	}
+
+	metrics.DescribeCounter(METRIC_HTTP_REQUESTS, "Requests answered by the API, by route and status.")
+	metrics.DescribeHistogram(METRIC_HTTP_REQUEST_DURATION, "Time taken to answer requests to the API, by route.", DEFAULT_LATENCY_BUCKETS)
+
+	return metrics
+}
+
+func (m *Metrics) DescribeCounter(name string, help string) {
+	m.describe(name, help, counterMetric, nil)
+}
+
+func (m *Metrics) DescribeGauge(name string, help string) {
+	m.describe(name, help, gaugeMetric, nil)
+}
+
+func (m *Metrics) DescribeHistogram(name string, help string, buckets []float64) {
+	m.describe(name, help, histogramMetric, buckets)
+}
+
+// OnCollect registers collect to be called before the metrics are written,
+// to set the gauges that are read rather than updated as things happen.
+func (m *Metrics) OnCollect(collect func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.collectors = append(m.collectors, collect)
+}
+
+func (m *Metrics) Add(name string, value float64, labels ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	series := m.series(name, labels)
+	if series != nil {
+		series.value += value
+	}
+}
+
+func (m *Metrics) Set(name string, value float64, labels ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	series := m.series(name, labels)
+	if series != nil {
+		series.value = value
+	}
+}
+
+func (m *Metrics) Observe(name string, value float64, labels ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	family := m.families[name]
+	series := m.series(name, labels)
+	if series == nil {
+		return
+	}
+
+	if series.counts == nil {
+		series.counts = make([]uint64, len(family.buckets))
+	}
+
+	for i, bound := range family.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+
+	series.count++
+	series.sum += value
+}
+
+// Reset forgets every series of the metric name, so series of things that
+// disappeared are not reported anymore.
+func (m *Metrics) Reset(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	family := m.families[name]
+	if family != nil {
+		family.series = make(map[string]*metricSeries)
+	}
+}
+
+// ObserveHTTPRequest records a request answered by the API.
+func (m *Metrics) ObserveHTTPRequest(route string, status int, duration time.Duration) {
+	m.Add(METRIC_HTTP_REQUESTS, 1, "route", route, "status", strconv.Itoa(status))
+	m.Observe(METRIC_HTTP_REQUEST_DURATION, duration.Seconds(), "route", route)
+}
+
+func (m *Metrics) WriteText(w io.Writer) error {
+	m.mutex.Lock()
+	collectors := m.collectors
+	m.mutex.Unlock()
+
+	for _, collect := range collectors {
+		collect()
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	writer := bufio.NewWriter(w)
+	for _, name := range names {
+		m.families[name].writeText(writer)
+	}
+
+	return writer.Flush()
+}
+
+func (m *Metrics) describe(name string, help string, kind metricKind, buckets []float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.families[name]; ok {
+		return
+	}
+
+	m.families[name] = &metricFamily{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		buckets: buckets,
+		series:  make(map[string]*metricSeries),
+	}
+}
+
+func (m *Metrics) series(name string, labels []string) *metricSeries {
+	family := m.families[name]
+	if family == nil {
+		return nil
+	}
+
+	key := formatLabels(labels)
+	series, ok := family.series[key]
+	if !ok {
+		series = &metricSeries{labels: key}
+		family.series[key] = series
+	}
+
+	return series
+}
+
+func (family *metricFamily) writeText(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", family.name, family.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", family.name, family.kind)
+
+	keys := make([]string, 0, len(family.series))
+	for key := range family.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		series := family.series[key]
+
+		if family.kind != histogramMetric {
+			fmt.Fprintf(w, "%s%s %s\n", family.name, withBraces(series.labels), formatFloat(series.value))
+			continue
+		}
+
+		for i, bound := range family.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", family.name, withBraces(joinLabels(series.labels, "le=\""+formatFloat(bound)+"\"")), series.counts[i])
+		}
+
+		fmt.Fprintf(w, "%s_bucket%s %d\n", family.name, withBraces(joinLabels(series.labels, "le=\"+Inf\"")), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", family.name, withBraces(series.labels), formatFloat(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", family.name, withBraces(series.labels), series.count)
+	}
+}
+
+func formatLabels(labels []string) string {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+"=\""+escapeLabelValue(labels[i+1])+"\"")
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(value)
+}
+
+func joinLabels(labels string, label string) string {
+	if labels == "" {
+		return label
+	}
+
+	return labels + "," + label
+}
+
+func withBraces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+
+	return "{" + labels + "}"
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
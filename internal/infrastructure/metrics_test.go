@@ -0,0 +1,124 @@
+package infrastructure_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("WriteCountersWithTheirLabels", func(t *testing.T) {
+		metrics := infrastructure.NewMetrics()
+		metrics.DescribeCounter("frames_total", "Frames.")
+
+		metrics.Add("frames_total", 1, "opcode", "UpdateData")
+		metrics.Add("frames_total", 2, "opcode", "UpdateData")
+		metrics.Add("frames_total", 1, "opcode", "PullData")
+
+		text := writeMetrics(t, metrics)
+		assertContains(t, text, "# TYPE frames_total counter\n")
+		assertContains(t, text, "frames_total{opcode=\"PullData\"} 1\n")
+		assertContains(t, text, "frames_total{opcode=\"UpdateData\"} 3\n")
+	})
+
+	t.Run("EscapeLabelValues", func(t *testing.T) {
+		metrics := infrastructure.NewMetrics()
+		metrics.DescribeGauge("used_bytes", "Used.")
+
+		metrics.Set("used_bytes", 10, "user", "a\"b\\c")
+
+		assertContains(t, writeMetrics(t, metrics), "used_bytes{user=\"a\\\"b\\\\c\"} 10\n")
+	})
+
+	t.Run("WriteCumulativeHistogramBuckets", func(t *testing.T) {
+		metrics := infrastructure.NewMetrics()
+		metrics.DescribeHistogram("latency_seconds", "Latency.", []float64{0.1, 1})
+
+		metrics.Observe("latency_seconds", 0.05, "route", "GET /ping")
+		metrics.Observe("latency_seconds", 0.5, "route", "GET /ping")
+		metrics.Observe("latency_seconds", 5, "route", "GET /ping")
+
+		text := writeMetrics(t, metrics)
+		assertContains(t, text, "latency_seconds_bucket{route=\"GET /ping\",le=\"0.1\"} 1\n")
+		assertContains(t, text, "latency_seconds_bucket{route=\"GET /ping\",le=\"1\"} 2\n")
+		assertContains(t, text, "latency_seconds_bucket{route=\"GET /ping\",le=\"+Inf\"} 3\n")
+		assertContains(t, text, "latency_seconds_sum{route=\"GET /ping\"} 5.55\n")
+		assertContains(t, text, "latency_seconds_count{route=\"GET /ping\"} 3\n")
+	})
+
+	t.Run("IgnoreUndescribedMetrics", func(t *testing.T) {
+		metrics := infrastructure.NewMetrics()
+
+		metrics.Add("unknown_total", 1)
+
+		if strings.Contains(writeMetrics(t, metrics), "unknown_total") {
+			t.Errorf("Expected undescribed metrics not to be written")
+		}
+	})
+
+	t.Run("CollectGaugesBeforeWriting", func(t *testing.T) {
+		metrics := infrastructure.NewMetrics()
+		metrics.DescribeGauge("queue_depth", "Depth.")
+		depth := 0.0
+		metrics.OnCollect(func() {
+			metrics.Set("queue_depth", depth)
+		})
+
+		depth = 7
+
+		assertContains(t, writeMetrics(t, metrics), "queue_depth 7\n")
+	})
+
+	t.Run("ForgetSeriesOnReset", func(t *testing.T) {
+		metrics := infrastructure.NewMetrics()
+		metrics.DescribeGauge("used_bytes", "Used.")
+		metrics.Set("used_bytes", 10, "user", "a")
+
+		metrics.Reset("used_bytes")
+
+		if strings.Contains(writeMetrics(t, metrics), "user=\"a\"") {
+			t.Errorf("Expected the series to be forgotten")
+		}
+	})
+
+	t.Run("ObserveHttpRequestsByRouteAndStatus", func(t *testing.T) {
+		metrics := infrastructure.NewMetrics()
+
+		metrics.ObserveHTTPRequest("GET /users/{id}", 404, 0)
+
+		text := writeMetrics(t, metrics)
+		assertContains(t, text, infrastructure.METRIC_HTTP_REQUESTS+"{route=\"GET /users/{id}\",status=\"404\"} 1\n")
+		assertContains(t, text, infrastructure.METRIC_HTTP_REQUEST_DURATION+"_count{route=\"GET /users/{id}\"} 1\n")
+	})
+}
+
+func TestServerMetrics(t *testing.T) {
+	t.Run("ReportQueueDepthsAndSessions", func(t *testing.T) {
+		metrics := infrastructure.NewMetrics()
+		config := infrastructure.NewDefaultTCPServerConfig("localhost", 0)
+		config.SetMetrics(metrics)
+		_ = infrastructure.NewTCPServer(config)
+
+		text := writeMetrics(t, metrics)
+		assertContains(t, text, infrastructure.METRIC_SESSIONS+" 0\n")
+		assertContains(t, text, infrastructure.METRIC_TRANSACTION_QUEUE+" 0\n")
+		assertContains(t, text, infrastructure.METRIC_CONNECTIONS_QUEUE+" 0\n")
+	})
+}
+
+func writeMetrics(t *testing.T, metrics *infrastructure.Metrics) string {
+	t.Helper()
+
+	var builder strings.Builder
+	assertNoError(t, metrics.WriteText(&builder))
+	return builder.String()
+}
+
+func assertContains(t *testing.T, text string, expected string) {
+	t.Helper()
+
+	if !strings.Contains(text, expected) {
+		t.Errorf("Expected %q in\n%s", expected, text)
+	}
+}
@@ -0,0 +1,46 @@
+package infrastructure
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/google/uuid"
+)
+
+const OUTBOX_DIRECTORY_NAME = ".sdisk-outbox"
+
+// OutboxMailer writes every mail to its own .eml file in a directory instead
+// of sending it, for development and tests.
+type OutboxMailer struct {
+	path string
+	from string
+}
+
+func NewOutboxMailer(path string, from string) *OutboxMailer {
+	if from == "" {
+		from = DEFAULT_MAIL_FROM
+	}
+
+	return &OutboxMailer{
+		path: path,
+		from: from,
+	}
+}
+
+func (m *OutboxMailer) Send(mail models.Mail) error {
+	now := time.Now()
+	message, err := formatMail(m.from, mail, now)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", now.UnixNano(), uuid.NewString())
+	return writeFileAtomically(filepath.Join(m.path, name), message, 0600)
+}
+
+// Messages returns the paths of the mail written so far, oldest first.
+func (m *OutboxMailer) Messages() ([]string, error) {
+	return filepath.Glob(filepath.Join(m.path, "*.eml"))
+}
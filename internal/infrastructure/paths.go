@@ -0,0 +1,105 @@
+package infrastructure
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	MAX_SYNC_PATH_LENGTH = 4096
+	MAX_SYNC_NAME_LENGTH = 255
+)
+
+// ResolveSyncPath validates a path received from a peer and returns where it
+// lives under root. Synchronized paths are relative, slash separated and
+// already canonical: anything that could resolve outside of root is rejected.
+func ResolveSyncPath(root string, syncPath string) (string, error) {
+	err := validateSyncPath(syncPath)
+	if err != nil {
+		return "", err
+	}
+
+	current := root
+	segments := strings.Split(syncPath, "/")
+
+	for i, segment := range segments {
+		current = filepath.Join(current, segment)
+		info, err := os.Lstat(current)
+
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return "", &ErrInvalidPath{Path: syncPath, Reason: "goes through a symbolic link"}
+		}
+
+		isLast := i == len(segments)-1
+		if !isLast && !info.IsDir() {
+			return "", &ErrInvalidPath{Path: syncPath, Reason: "goes through a file"}
+		}
+
+		if isLast && !info.Mode().IsRegular() {
+			return "", &ErrInvalidPath{Path: syncPath, Reason: "is not a regular file"}
+		}
+	}
+
+	return filepath.Join(root, filepath.FromSlash(syncPath)), nil
+}
+
+// RelativeSyncPath returns the path to send to a peer for a file under root.
+func RelativeSyncPath(root string, filePath string) (string, error) {
+	rel, err := filepath.Rel(root, filePath)
+	if err != nil {
+		return "", err
+	}
+
+	syncPath := filepath.ToSlash(rel)
+	return syncPath, validateSyncPath(syncPath)
+}
+
+func validateSyncPath(syncPath string) error {
+	if syncPath == "" {
+		return &ErrInvalidPath{Path: syncPath, Reason: "is empty"}
+	}
+
+	if len(syncPath) > MAX_SYNC_PATH_LENGTH {
+		return &ErrInvalidPath{Path: syncPath, Reason: "is too long"}
+	}
+
+	if strings.ContainsRune(syncPath, 0) {
+		return &ErrInvalidPath{Path: syncPath, Reason: "contains a NUL byte"}
+	}
+
+	if strings.ContainsRune(syncPath, '\\') {
+		return &ErrInvalidPath{Path: syncPath, Reason: "contains a backslash"}
+	}
+
+	if path.IsAbs(syncPath) || filepath.IsAbs(syncPath) || filepath.VolumeName(syncPath) != "" {
+		return &ErrInvalidPath{Path: syncPath, Reason: "is absolute"}
+	}
+
+	for _, segment := range strings.Split(syncPath, "/") {
+		if segment == ".." {
+			return &ErrInvalidPath{Path: syncPath, Reason: "contains a parent directory segment"}
+		}
+
+		if len(segment) > MAX_SYNC_NAME_LENGTH {
+			return &ErrInvalidPath{Path: syncPath, Reason: "has a name that is too long"}
+		}
+	}
+
+	if path.Clean(syncPath) != syncPath {
+		return &ErrInvalidPath{Path: syncPath, Reason: "is not canonical"}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package infrastructure_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+)
+
+func TestResolveSyncPath(t *testing.T) {
+	root := t.TempDir()
+
+	t.Run("ResolveValidPathUnderRoot", func(t *testing.T) {
+		resolved, err := infrastructure.ResolveSyncPath(root, "photos/2024/cat.png")
+
+		assertNoError(t, err)
+		assertPathEquals(t, resolved, filepath.Join(root, "photos", "2024", "cat.png"))
+	})
+
+	t.Run("AllowExistingRegularFile", func(t *testing.T) {
+		_ = os.WriteFile(filepath.Join(root, "existing.txt"), []byte("hello"), 0644)
+
+		_, err := infrastructure.ResolveSyncPath(root, "existing.txt")
+
+		assertNoError(t, err)
+	})
+
+	hostilePaths := map[string]string{
+		"Empty":                   "",
+		"Absolute":                "/etc/passwd",
+		"AbsoluteWithTraversal":   "/../../etc/cron.d/x",
+		"ParentSegment":           "../outside.txt",
+		"NestedParentSegment":     "photos/../../outside.txt",
+		"TrailingParentSegment":   "photos/..",
+		"NulByte":                 "photos/cat.png\x00.txt",
+		"Backslash":               "..\\..\\outside.txt",
+		"WindowsVolume":           "C:\\Windows\\win.ini",
+		"DotSegment":              "./photos/cat.png",
+		"DoubleSlash":             "photos//cat.png",
+		"TrailingSlash":           "photos/",
+		"OnlyDot":                 ".",
+		"OverlongName":            strings.Repeat("a", infrastructure.MAX_SYNC_NAME_LENGTH+1),
+		"OverlongPath":            strings.Repeat("a/", infrastructure.MAX_SYNC_PATH_LENGTH/2+1) + "a",
+		"ParentSegmentAfterValid": "a/b/c/../../../../x",
+	}
+
+	for name, hostilePath := range hostilePaths {
+		t.Run("Reject"+name, func(t *testing.T) {
+			_, err := infrastructure.ResolveSyncPath(root, hostilePath)
+
+			assertInvalidPath(t, err)
+		})
+	}
+
+	t.Run("RejectPathThroughSymlinkedDirectory", func(t *testing.T) {
+		outside := t.TempDir()
+		_ = os.Symlink(outside, filepath.Join(root, "escape"))
+
+		_, err := infrastructure.ResolveSyncPath(root, "escape/cron.d/x")
+
+		assertInvalidPath(t, err)
+	})
+
+	t.Run("RejectSymlinkedFile", func(t *testing.T) {
+		outside := filepath.Join(t.TempDir(), "target.txt")
+		_ = os.WriteFile(outside, []byte("secret"), 0644)
+		_ = os.Symlink(outside, filepath.Join(root, "link.txt"))
+
+		_, err := infrastructure.ResolveSyncPath(root, "link.txt")
+
+		assertInvalidPath(t, err)
+	})
+
+	t.Run("RejectPathThroughFile", func(t *testing.T) {
+		_ = os.WriteFile(filepath.Join(root, "file.txt"), []byte("hello"), 0644)
+
+		_, err := infrastructure.ResolveSyncPath(root, "file.txt/child.txt")
+
+		assertInvalidPath(t, err)
+	})
+
+	t.Run("RejectDirectory", func(t *testing.T) {
+		_ = os.Mkdir(filepath.Join(root, "directory"), 0777)
+
+		_, err := infrastructure.ResolveSyncPath(root, "directory")
+
+		assertInvalidPath(t, err)
+	})
+}
+
+func TestRelativeSyncPath(t *testing.T) {
+	root := t.TempDir()
+
+	t.Run("ReturnSlashSeparatedRelativePath", func(t *testing.T) {
+		syncPath, err := infrastructure.RelativeSyncPath(root, filepath.Join(root, "photos", "cat.png"))
+
+		assertNoError(t, err)
+		assertPathEquals(t, syncPath, "photos/cat.png")
+	})
+
+	t.Run("RejectFileOutsideOfRoot", func(t *testing.T) {
+		_, err := infrastructure.RelativeSyncPath(root, filepath.Join(filepath.Dir(root), "outside.txt"))
+
+		assertInvalidPath(t, err)
+	})
+}
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("Expected no error but got %s", err.Error())
+	}
+}
+
+func assertInvalidPath(t *testing.T, err error) {
+	t.Helper()
+
+	if _, ok := err.(*infrastructure.ErrInvalidPath); !ok {
+		t.Fatalf("Expected ErrInvalidPath, got %v", err)
+	}
+}
+
+func assertPathEquals(t *testing.T, got string, want string) {
+	t.Helper()
+
+	if got != want {
+		t.Fatalf("Expected path %s, got %s", want, got)
+	}
+}
@@ -2,11 +2,14 @@ package infrastructure
 
 import (
 	"encoding/binary"
+	"math"
 )
 
 const VERSION = 0
 const HEADER_SIZE = 21
 const ID_SIZE = 16
+const UPDATE_DATA_HEADER_SIZE = 32
+const REPORT_ERROR_HEADER_SIZE = 10
 
 type PacketOpcode byte
 type PacketEncoding byte
@@ -15,6 +18,61 @@ const (
 	PrepareDisk PacketOpcode = iota
 	UpdateData
 	PullData
+	DeleteData
+	ReportError
+	Disconnect
+	SyncCursor
+	Authenticate
+	AuthenticateDevice
+)
+
+func (opcode PacketOpcode) String() string {
+	switch opcode {
+	case PrepareDisk:
+		return "PrepareDisk"
+	case UpdateData:
+		return "UpdateData"
+	case PullData:
+		return "PullData"
+	case DeleteData:
+		return "DeleteData"
+	case ReportError:
+		return "ReportError"
+	case Disconnect:
+		return "Disconnect"
+	case SyncCursor:
+		return "SyncCursor"
+	case Authenticate:
+		return "Authenticate"
+	case AuthenticateDevice:
+		return "AuthenticateDevice"
+	}
+
+	return "Unknown"
+}
+
+type DisconnectReason uint16
+
+const (
+	DisconnectReasonLeaving DisconnectReason = iota
+	DisconnectReasonServerBusy
+	DisconnectReasonTooManyConnections
+	DisconnectReasonKicked
+	DisconnectReasonIdleTimeout
+	DisconnectReasonSessionExpired
+	DisconnectReasonUnauthorized
+	DisconnectReasonRevoked
+)
+
+type ErrorCode uint16
+
+const (
+	ErrorCodeInternal ErrorCode = iota
+	ErrorCodeUnknownPacket
+	ErrorCodeNoDisk
+	ErrorCodeQuotaExceeded
+	ErrorCodeInvalidPath
+	ErrorCodeHashMismatch
 )
 
 const (
@@ -41,14 +99,87 @@ type PrepareDiskPayload struct {
 	DiskSize uint64
 }
 
+// PullDataPayload asks for the changes made after Cursor. A PullData packet
+// without payload asks for the whole disk.
+type PullDataPayload struct {
+	Cursor uint64
+}
+
+// SyncCursorPayload is sent once a pull is over with the cursor to resume
+// from on the next pull.
+type SyncCursorPayload struct {
+	Cursor uint64
+}
+
+// AuthenticatePayload carries the access token of an API session. When the
+// server verifies tokens, it must be the first packet of a connection, which
+// then lasts as long as the session.
+type AuthenticatePayload struct {
+	Token string
+}
+
+// AuthenticateDevicePayload carries the credential of a registered device and
+// the version of the client running on it. The server answers with an
+// AuthenticateDevice packet for the user of the device, carrying a
+// DeviceAuthenticatedPayload.
+type AuthenticateDevicePayload struct {
+	VersionLen uint16
+	Version    string
+	Credential string
+}
+
+type DeviceAuthenticatedPayload struct {
+	DeviceID string
+}
+
+type DeleteDataPayload struct {
+	Path string
+}
+
+type ReportErrorPayload struct {
+	Code    ErrorCode
+	PathLen uint64
+	Path    string
+	Message string
+}
+
+type DisconnectPayload struct {
+	Reason     DisconnectReason
+	RetryAfter uint32
+	Message    string
+}
+
 type UpdateDataPayload struct {
 	Total    uint64
 	Offset   uint64
 	PathLen  uint64
+	HashLen  uint64
 	Path     string
+	Hash     []byte
 	FileData []byte
 }
 
+// newPacket returns the packet carrying payload, which must fit in the
+// DataSize of its header.
+func newPacket(opcode PacketOpcode, id []byte, payload []byte) (*Packet, error) {
+	if len(payload) > math.MaxUint16 {
+		return nil, &ErrPayloadTooLarge{Size: len(payload)}
+	}
+
+	header := PacketHeader{
+		Version:  VERSION,
+		Opcode:   opcode,
+		Encoding: EncodingNone,
+		DataSize: uint16(len(payload)),
+	}
+	copy(header.id[:], id)
+
+	return &Packet{
+		Header:  header,
+		Payload: payload,
+	}, nil
+}
+
 func (header *PacketHeader) fromBytes(data []byte) error {
 	if data[0] != VERSION {
 		return &ErrUnsuportedProtocolVersion{ReceivedVersion: data[0]}
@@ -78,7 +209,7 @@ func (packet *Packet) FromBytes(data []byte) error {
 	}
 
 	packet.Header = header
-	packet.Payload = data[HEADER_SIZE : HEADER_SIZE+packet.Header.DataSize]
+	packet.Payload = data[HEADER_SIZE : HEADER_SIZE+int(packet.Header.DataSize)]
 
 	return nil
 }
@@ -113,26 +244,161 @@ func (p *PrepareDiskPayload) FromBytes(data []byte) error {
 }
 
 func (u *UpdateDataPayload) Bytes() ([]byte, error) {
-	buff := make([]byte, 0, 24+int(u.PathLen)+len(u.FileData))
-	buffTotal := make([]byte, 8)
-	buffOffset := make([]byte, 8)
-	buffPathLen := make([]byte, 8)
-	binary.BigEndian.PutUint64(buffTotal, u.Total)
-	binary.BigEndian.PutUint64(buffOffset, u.Offset)
-	binary.BigEndian.PutUint64(buffPathLen, u.PathLen)
-
-	buff = append(buff, buffTotal...)
-	buff = append(buff, buffOffset...)
-	buff = append(buff, buffPathLen...)
+	buff := make([]byte, 0, UPDATE_DATA_HEADER_SIZE+int(u.PathLen)+int(u.HashLen)+len(u.FileData))
+	buff = binary.BigEndian.AppendUint64(buff, u.Total)
+	buff = binary.BigEndian.AppendUint64(buff, u.Offset)
+	buff = binary.BigEndian.AppendUint64(buff, u.PathLen)
+	buff = binary.BigEndian.AppendUint64(buff, u.HashLen)
 	buff = append(buff, []byte(u.Path)...)
+	buff = append(buff, u.Hash...)
 	return append(buff, u.FileData...), nil
 }
 
 func (u *UpdateDataPayload) FromBytes(data []byte) error {
+	if len(data) < UPDATE_DATA_HEADER_SIZE {
+		return &ErrIncompletePacket{}
+	}
+
+	pathLen := binary.BigEndian.Uint64(data[16:24])
+	hashLen := binary.BigEndian.Uint64(data[24:32])
+	available := uint64(len(data[UPDATE_DATA_HEADER_SIZE:]))
+	if pathLen > available || hashLen > available-pathLen {
+		return &ErrIncompletePacket{}
+	}
+
 	u.Total = binary.BigEndian.Uint64(data[0:8])
 	u.Offset = binary.BigEndian.Uint64(data[8:16])
-	u.PathLen = binary.BigEndian.Uint64(data[16:24])
-	u.Path = string(data[24 : u.PathLen+24])
-	u.FileData = data[24+u.PathLen:]
+	u.PathLen = pathLen
+	u.HashLen = hashLen
+	pathEnd := UPDATE_DATA_HEADER_SIZE + pathLen
+	hashEnd := pathEnd + hashLen
+	u.Path = string(data[UPDATE_DATA_HEADER_SIZE:pathEnd])
+	u.Hash = data[pathEnd:hashEnd]
+	u.FileData = data[hashEnd:]
+	return nil
+}
+
+func (d *DeleteDataPayload) Bytes() []byte {
+	return []byte(d.Path)
+}
+
+func (d *DeleteDataPayload) FromBytes(data []byte) error {
+	d.Path = string(data)
+	return nil
+}
+
+func (r *ReportErrorPayload) Bytes() []byte {
+	buff := make([]byte, 0, REPORT_ERROR_HEADER_SIZE+len(r.Path)+len(r.Message))
+	buff = binary.BigEndian.AppendUint16(buff, uint16(r.Code))
+	buff = binary.BigEndian.AppendUint64(buff, uint64(len(r.Path)))
+	buff = append(buff, []byte(r.Path)...)
+	return append(buff, []byte(r.Message)...)
+}
+
+func (r *ReportErrorPayload) FromBytes(data []byte) error {
+	if len(data) < REPORT_ERROR_HEADER_SIZE {
+		return &ErrIncompletePacket{}
+	}
+
+	r.Code = ErrorCode(binary.BigEndian.Uint16(data[0:2]))
+	r.PathLen = binary.BigEndian.Uint64(data[2:10])
+	if r.PathLen > uint64(len(data[10:])) {
+		return &ErrIncompletePacket{}
+	}
+
+	r.Path = string(data[10 : 10+r.PathLen])
+	r.Message = string(data[10+r.PathLen:])
+	return nil
+}
+
+func (d *DisconnectPayload) Bytes() []byte {
+	buff := make([]byte, 0, 6+len(d.Message))
+	buff = binary.BigEndian.AppendUint16(buff, uint16(d.Reason))
+	buff = binary.BigEndian.AppendUint32(buff, d.RetryAfter)
+	return append(buff, []byte(d.Message)...)
+}
+
+func (d *DisconnectPayload) FromBytes(data []byte) error {
+	if len(data) < 6 {
+		return &ErrIncompletePacket{}
+	}
+
+	d.Reason = DisconnectReason(binary.BigEndian.Uint16(data[0:2]))
+	d.RetryAfter = binary.BigEndian.Uint32(data[2:6])
+	d.Message = string(data[6:])
+	return nil
+}
+
+func (a *AuthenticatePayload) Bytes() []byte {
+	return []byte(a.Token)
+}
+
+func (a *AuthenticatePayload) FromBytes(data []byte) error {
+	if len(data) == 0 {
+		return &ErrIncompletePacket{}
+	}
+
+	a.Token = string(data)
+	return nil
+}
+
+func (a *AuthenticateDevicePayload) Bytes() []byte {
+	buff := make([]byte, 0, 2+len(a.Version)+len(a.Credential))
+	buff = binary.BigEndian.AppendUint16(buff, uint16(len(a.Version)))
+	buff = append(buff, []byte(a.Version)...)
+	return append(buff, []byte(a.Credential)...)
+}
+
+func (a *AuthenticateDevicePayload) FromBytes(data []byte) error {
+	if len(data) < 2 {
+		return &ErrIncompletePacket{}
+	}
+
+	a.VersionLen = binary.BigEndian.Uint16(data[0:2])
+	if int(a.VersionLen) >= len(data[2:]) {
+		return &ErrIncompletePacket{}
+	}
+
+	a.Version = string(data[2 : 2+a.VersionLen])
+	a.Credential = string(data[2+a.VersionLen:])
+	return nil
+}
+
+func (d *DeviceAuthenticatedPayload) Bytes() []byte {
+	return []byte(d.DeviceID)
+}
+
+func (d *DeviceAuthenticatedPayload) FromBytes(data []byte) error {
+	if len(data) == 0 {
+		return &ErrIncompletePacket{}
+	}
+
+	d.DeviceID = string(data)
+	return nil
+}
+
+func (p *PullDataPayload) Bytes() []byte {
+	return binary.BigEndian.AppendUint64(nil, p.Cursor)
+}
+
+func (p *PullDataPayload) FromBytes(data []byte) error {
+	if len(data) < 8 {
+		return &ErrIncompletePacket{}
+	}
+
+	p.Cursor = binary.BigEndian.Uint64(data)
+	return nil
+}
+
+func (s *SyncCursorPayload) Bytes() []byte {
+	return binary.BigEndian.AppendUint64(nil, s.Cursor)
+}
+
+func (s *SyncCursorPayload) FromBytes(data []byte) error {
+	if len(data) < 8 {
+		return &ErrIncompletePacket{}
+	}
+
+	s.Cursor = binary.BigEndian.Uint64(data)
 	return nil
 }
@@ -0,0 +1,120 @@
+package infrastructure_test
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+)
+
+func TestUpdateDataPayloadFromBytes(t *testing.T) {
+	t.Run("ReturnErrorIfPayloadIsTooShort", func(t *testing.T) {
+		var payload infrastructure.UpdateDataPayload
+
+		err := payload.FromBytes(make([]byte, 8))
+
+		assertIncompletePacket(t, err)
+	})
+
+	t.Run("ReturnErrorIfPathLenExceedsPayload", func(t *testing.T) {
+		var payload infrastructure.UpdateDataPayload
+		raw := make([]byte, infrastructure.UPDATE_DATA_HEADER_SIZE, infrastructure.UPDATE_DATA_HEADER_SIZE+4)
+		binary.BigEndian.PutUint64(raw[16:24], 1<<40)
+		raw = append(raw, []byte("a.tx")...)
+
+		err := payload.FromBytes(raw)
+
+		assertIncompletePacket(t, err)
+	})
+
+	t.Run("ReturnErrorIfHashLenExceedsPayload", func(t *testing.T) {
+		var payload infrastructure.UpdateDataPayload
+		raw := make([]byte, infrastructure.UPDATE_DATA_HEADER_SIZE, infrastructure.UPDATE_DATA_HEADER_SIZE+4)
+		binary.BigEndian.PutUint64(raw[16:24], 2)
+		binary.BigEndian.PutUint64(raw[24:32], 1<<63)
+		raw = append(raw, []byte("a.tx")...)
+
+		err := payload.FromBytes(raw)
+
+		assertIncompletePacket(t, err)
+	})
+
+	t.Run("DecodeWhatWasEncoded", func(t *testing.T) {
+		sent := infrastructure.UpdateDataPayload{Total: 5, Offset: 0, PathLen: 5, Path: "a.txt", FileData: []byte("hello")}
+		raw, _ := sent.Bytes()
+		var received infrastructure.UpdateDataPayload
+
+		err := received.FromBytes(raw)
+
+		assertNoError(t, err)
+		assertPathEquals(t, received.Path, sent.Path)
+	})
+}
+
+func TestAuthenticatePayloadFromBytes(t *testing.T) {
+	t.Run("ReturnErrorIfTokenIsMissing", func(t *testing.T) {
+		var payload infrastructure.AuthenticatePayload
+
+		err := payload.FromBytes(nil)
+
+		assertIncompletePacket(t, err)
+	})
+
+	t.Run("DecodeWhatWasEncoded", func(t *testing.T) {
+		sent := infrastructure.AuthenticatePayload{Token: "token"}
+		var received infrastructure.AuthenticatePayload
+
+		err := received.FromBytes(sent.Bytes())
+
+		assertNoError(t, err)
+		assertTrue(t, received.Token == sent.Token)
+	})
+}
+
+func TestAuthenticateDevicePayloadFromBytes(t *testing.T) {
+	t.Run("ReturnErrorIfVersionLenExceedsPayload", func(t *testing.T) {
+		var payload infrastructure.AuthenticateDevicePayload
+		raw := binary.BigEndian.AppendUint16(nil, 10)
+		raw = append(raw, []byte("1.2")...)
+
+		err := payload.FromBytes(raw)
+
+		assertIncompletePacket(t, err)
+	})
+
+	t.Run("DecodeWhatWasEncoded", func(t *testing.T) {
+		sent := infrastructure.AuthenticateDevicePayload{Version: "1.2.3", Credential: "credential"}
+		var received infrastructure.AuthenticateDevicePayload
+
+		err := received.FromBytes(sent.Bytes())
+
+		assertNoError(t, err)
+		assertTrue(t, received.Version == sent.Version && received.Credential == sent.Credential)
+	})
+}
+
+func assertIncompletePacket(t *testing.T, err error) {
+	t.Helper()
+
+	if _, ok := err.(*infrastructure.ErrIncompletePacket); !ok {
+		t.Fatalf("Expected ErrIncompletePacket, got %v", err)
+	}
+}
+
+func TestPacketFromBytes(t *testing.T) {
+	t.Run("DecodePacketWithLargestPayload", func(t *testing.T) {
+		raw := make([]byte, infrastructure.HEADER_SIZE+math.MaxUint16)
+		binary.BigEndian.PutUint16(raw[19:infrastructure.HEADER_SIZE], math.MaxUint16)
+		var packet infrastructure.Packet
+
+		err := packet.FromBytes(raw)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+		if len(packet.Payload) != math.MaxUint16 {
+			t.Fatalf("Expected a payload of %d bytes, got %d", math.MaxUint16, len(packet.Payload))
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package infrastructure
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+// RamDeviceRepository keeps devices in memory. Devices are copied in and out,
+// so a device changed by a caller only changes once saved again.
+type RamDeviceRepository struct {
+	devices          map[string]*models.Device
+	credentialHashes map[string]string
+	mutex            sync.Mutex
+}
+
+func NewRamDeviceRepository() *RamDeviceRepository {
+	return &RamDeviceRepository{
+		devices:          make(map[string]*models.Device),
+		credentialHashes: make(map[string]string),
+	}
+}
+
+func (r *RamDeviceRepository) SaveDevice(d *models.Device) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	old := r.devices[d.ID]
+	if old != nil {
+		delete(r.credentialHashes, old.CredentialHash)
+	}
+
+	saved := *d
+	r.devices[saved.ID] = &saved
+	r.credentialHashes[saved.CredentialHash] = saved.ID
+	return nil
+}
+
+func (r *RamDeviceRepository) GetDevice(id string) *models.Device {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.get(id)
+}
+
+func (r *RamDeviceRepository) GetDeviceByCredentialHash(credentialHash string) *models.Device {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.get(r.credentialHashes[credentialHash])
+}
+
+// ListDevices returns the devices of a user, oldest first.
+func (r *RamDeviceRepository) ListDevices(userID models.UserID) []*models.Device {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	devices := make([]*models.Device, 0)
+	for id, d := range r.devices {
+		if d.UserID == userID {
+			devices = append(devices, r.get(id))
+		}
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].CreatedAt.Before(devices[j].CreatedAt)
+	})
+
+	return devices
+}
+
+func (r *RamDeviceRepository) DeleteDevice(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	d := r.devices[id]
+	if d != nil {
+		delete(r.devices, id)
+		delete(r.credentialHashes, d.CredentialHash)
+	}
+
+	return nil
+}
+
+func (r *RamDeviceRepository) get(id string) *models.Device {
+	d := r.devices[id]
+	if d == nil {
+		return nil
+	}
+
+	device := *d
+	return &device
+}
@@ -0,0 +1,54 @@
+package infrastructure_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestRamDeviceRepository(t *testing.T) {
+	anyUserID := models.NewUserID()
+	newDevice := func() *models.Device {
+		return &models.Device{ID: "device", UserID: anyUserID, Name: "laptop", CredentialHash: "hash", CreatedAt: time.Now()}
+	}
+
+	t.Run("GetSavedDeviceByCredentialHash", func(t *testing.T) {
+		repo := infrastructure.NewRamDeviceRepository()
+
+		assertNoError(t, repo.SaveDevice(newDevice()))
+
+		found := repo.GetDeviceByCredentialHash("hash")
+		assertTrue(t, found != nil && found.ID == "device")
+	})
+
+	t.Run("KeepDeviceUnchangedUntilSaved", func(t *testing.T) {
+		repo := infrastructure.NewRamDeviceRepository()
+		_ = repo.SaveDevice(newDevice())
+
+		repo.GetDevice("device").Version = "1.2.3"
+
+		assertTrue(t, repo.GetDevice("device").Version == "")
+	})
+
+	t.Run("ListDevicesOfUser", func(t *testing.T) {
+		repo := infrastructure.NewRamDeviceRepository()
+		_ = repo.SaveDevice(newDevice())
+		_ = repo.SaveDevice(&models.Device{ID: "other", UserID: models.NewUserID(), CredentialHash: "other"})
+
+		devices := repo.ListDevices(anyUserID)
+
+		assertTrue(t, len(devices) == 1 && devices[0].ID == "device")
+	})
+
+	t.Run("ForgetDeletedDevice", func(t *testing.T) {
+		repo := infrastructure.NewRamDeviceRepository()
+		_ = repo.SaveDevice(newDevice())
+
+		assertNoError(t, repo.DeleteDevice("device"))
+
+		assertTrue(t, repo.GetDevice("device") == nil)
+		assertTrue(t, repo.GetDeviceByCredentialHash("hash") == nil)
+	})
+}
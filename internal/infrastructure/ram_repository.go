@@ -1,11 +1,17 @@
 package infrastructure
 
 import (
+	"sort"
+	"sync"
+
 	"github.com/Joey-Boivin/sdisk/internal/models"
 )
 
+// RamRepository keeps users in memory. Users are copied in and out, so a user
+// changed by a caller only changes once saved again.
 type RamRepository struct {
 	users map[string]*models.User
+	mutex sync.RWMutex
 }
 
 func NewRamRepository() *RamRepository {
@@ -14,25 +20,70 @@ func NewRamRepository() *RamRepository {
 	return &repo
 }
 
-func (r *RamRepository) SaveUser(u *models.User) {
-	id := u.GetID()
-	r.users[id.ToString()] = u
+func (r *RamRepository) SaveUser(u *models.User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	saved := *u
+	id := saved.GetID()
+	for key, val := range r.users {
+		if key != id.ToString() && val.GetEmail() == saved.GetEmail() {
+			return &models.ErrEmailTaken{Email: saved.GetEmail()}
+		}
+	}
+
+	r.users[id.ToString()] = &saved
+	return nil
 }
 
 func (r *RamRepository) GetByID(id models.UserID) *models.User {
-	if val, ok := r.users[id.ToString()]; ok {
-		return val
-	} else {
-		return nil
-	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return copyUser(r.users[id.ToString()])
 }
 
 func (r *RamRepository) GetByEmail(email string) *models.User {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
 	for _, val := range r.users {
 		if val.GetEmail() == email {
-			return val
+			return copyUser(val)
 		}
 	}
 
 	return nil
 }
+
+func (r *RamRepository) DeleteUser(id models.UserID) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.users, id.ToString())
+}
+
+func (r *RamRepository) ListUsers() []*models.User {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	users := make([]*models.User, 0, len(r.users))
+	for _, val := range r.users {
+		users = append(users, copyUser(val))
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].GetEmail() < users[j].GetEmail()
+	})
+
+	return users
+}
+
+func copyUser(u *models.User) *models.User {
+	if u == nil {
+		return nil
+	}
+
+	user := *u
+	return &user
+}
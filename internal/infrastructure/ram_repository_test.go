@@ -1,7 +1,11 @@
 package infrastructure_test
 
 import (
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
 	"github.com/Joey-Boivin/sdisk/internal/models"
@@ -32,6 +36,39 @@ func TestSaveUser(t *testing.T) {
 		userSaved := ramRepository.GetByEmail(anyUserEmail)
 		assertSameUsers(t, userToSave, userSaved)
 	})
+
+	t.Run("ReturnErrEmailTakenForAnotherUserWithSameEmail", func(t *testing.T) {
+		ramRepository := infrastructure.NewRamRepository()
+		first := models.NewUser("john_doe@test.com", "12345")
+		_ = ramRepository.SaveUser(first)
+
+		err := ramRepository.SaveUser(models.NewUser("john_doe@test.com", "67890"))
+
+		if _, ok := err.(*models.ErrEmailTaken); !ok {
+			t.Fatalf("Expected ErrEmailTaken, got %v", err)
+		}
+		assertSameUsers(t, first, ramRepository.GetByEmail("john_doe@test.com"))
+	})
+
+	t.Run("SaveOnlyOneOfConcurrentUsersWithSameEmail", func(t *testing.T) {
+		ramRepository := infrastructure.NewRamRepository()
+		var saved atomic.Int32
+		var wg sync.WaitGroup
+
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if ramRepository.SaveUser(models.NewUser("john_doe@test.com", "12345")) == nil {
+					saved.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assertTrue(t, saved.Load() == 1)
+		assertTrue(t, len(ramRepository.ListUsers()) == 1)
+	})
 }
 
 func TestGetUser(t *testing.T) {
@@ -46,6 +83,76 @@ func TestGetUser(t *testing.T) {
 	})
 }
 
+func TestDeleteUser(t *testing.T) {
+	ramRepository := infrastructure.NewRamRepository()
+
+	t.Run("ForgetUser", func(t *testing.T) {
+		user := models.NewUser("email@test.com", "12345")
+		ramRepository.SaveUser(user)
+
+		ramRepository.DeleteUser(user.GetID())
+
+		assertUserIsNil(t, ramRepository.GetByID(user.GetID()))
+		assertUserIsNil(t, ramRepository.GetByEmail(user.GetEmail()))
+	})
+}
+
+func TestRamRepositoryConcurrency(t *testing.T) {
+	t.Run("KeepUserUnchangedUntilSaved", func(t *testing.T) {
+		ramRepository := infrastructure.NewRamRepository()
+		user := models.NewUser("email@test.com", "12345")
+		ramRepository.SaveUser(user)
+
+		ramRepository.GetByID(user.GetID()).MarkDeleted(time.Now())
+		user.SetRole(models.RoleAdmin)
+
+		saved := ramRepository.GetByID(user.GetID())
+		assertTrue(t, !saved.IsDeleted() && !saved.IsAdmin())
+	})
+
+	// Run with -race: purging deleted users while others are read and saved
+	// must not touch the same map or user values without a lock.
+	t.Run("PurgeWhileReading", func(t *testing.T) {
+		ramRepository := infrastructure.NewRamRepository()
+		users := make([]*models.User, 0, 50)
+		for i := 0; i < cap(users); i++ {
+			user := models.NewUser(fmt.Sprintf("user%d@test.com", i), "12345")
+			ramRepository.SaveUser(user)
+			users = append(users, user)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for _, user := range ramRepository.ListUsers() {
+				user.MarkDeleted(time.Now())
+				ramRepository.SaveUser(user)
+				ramRepository.DeleteUser(user.GetID())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for _, user := range users {
+				if found := ramRepository.GetByEmail(user.GetEmail()); found != nil {
+					found.SetEmailVerified(true)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for _, user := range users {
+				if found := ramRepository.GetByID(user.GetID()); found != nil {
+					_ = found.IsDeleted()
+				}
+			}
+		}()
+		wg.Wait()
+
+		assertTrue(t, len(ramRepository.ListUsers()) == 0)
+	})
+}
+
 func assertSameUsers(t *testing.T, got *models.User, want *models.User) {
 	if got.GetEmail() != want.GetEmail() {
 		t.Fatalf("Expected %p, got %p.", got, want)
@@ -0,0 +1,131 @@
+package infrastructure
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+// RamSessionRepository keeps sessions in memory. Expired sessions are
+// forgotten when they are looked up. Sessions are copied in and out, so a
+// session changed by a caller only changes once saved again.
+type RamSessionRepository struct {
+	sessions      map[string]*models.Session
+	tokenHashes   map[string]string
+	refreshHashes map[string]string
+	mutex         sync.Mutex
+}
+
+func NewRamSessionRepository() *RamSessionRepository {
+	return &RamSessionRepository{
+		sessions:      make(map[string]*models.Session),
+		tokenHashes:   make(map[string]string),
+		refreshHashes: make(map[string]string),
+	}
+}
+
+func (r *RamSessionRepository) SaveSession(s *models.Session) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	old := r.sessions[s.ID]
+	if old != nil {
+		r.delete(old)
+	}
+
+	saved := *s
+	r.sessions[saved.ID] = &saved
+	r.tokenHashes[saved.TokenHash] = saved.ID
+	if saved.RefreshTokenHash != "" {
+		r.refreshHashes[saved.RefreshTokenHash] = saved.ID
+	}
+	if saved.PreviousRefreshTokenHash != "" {
+		r.refreshHashes[saved.PreviousRefreshTokenHash] = saved.ID
+	}
+
+	return nil
+}
+
+func (r *RamSessionRepository) GetSession(id string) *models.Session {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.get(id)
+}
+
+func (r *RamSessionRepository) GetSessionByTokenHash(tokenHash string) *models.Session {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.get(r.tokenHashes[tokenHash])
+}
+
+// GetSessionByRefreshTokenHash returns the session whose current or previous
+// refresh token hashes to refreshTokenHash.
+func (r *RamSessionRepository) GetSessionByRefreshTokenHash(refreshTokenHash string) *models.Session {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.get(r.refreshHashes[refreshTokenHash])
+}
+
+// ListSessions returns the sessions of a user that have not expired, oldest
+// first.
+func (r *RamSessionRepository) ListSessions(userID models.UserID) []*models.Session {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	sessions := make([]*models.Session, 0)
+	for id, s := range r.sessions {
+		if s.UserID != userID {
+			continue
+		}
+
+		session := r.get(id)
+		if session != nil {
+			sessions = append(sessions, session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+
+	return sessions
+}
+
+func (r *RamSessionRepository) DeleteSession(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s := r.sessions[id]
+	if s != nil {
+		r.delete(s)
+	}
+
+	return nil
+}
+
+func (r *RamSessionRepository) get(id string) *models.Session {
+	s := r.sessions[id]
+	if s == nil {
+		return nil
+	}
+
+	if s.Expired(time.Now()) {
+		r.delete(s)
+		return nil
+	}
+
+	session := *s
+	return &session
+}
+
+func (r *RamSessionRepository) delete(s *models.Session) {
+	delete(r.sessions, s.ID)
+	delete(r.tokenHashes, s.TokenHash)
+	delete(r.refreshHashes, s.RefreshTokenHash)
+	delete(r.refreshHashes, s.PreviousRefreshTokenHash)
+}
@@ -0,0 +1,79 @@
+package infrastructure_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestRamSessionRepository(t *testing.T) {
+	anyUserID := models.NewUserID()
+	newSession := func(expiresAt time.Time) *models.Session {
+		return &models.Session{ID: "session", UserID: anyUserID, TokenHash: "hash", RefreshTokenHash: "refresh", ExpiresAt: expiresAt}
+	}
+
+	t.Run("GetSavedSessionByTokenHash", func(t *testing.T) {
+		repo := infrastructure.NewRamSessionRepository()
+		session := newSession(time.Now().Add(time.Hour))
+
+		assertNoError(t, repo.SaveSession(session))
+
+		found := repo.GetSessionByTokenHash("hash")
+		assertTrue(t, found != nil && found.ID == session.ID)
+	})
+
+	t.Run("GetSavedSessionByRefreshTokenHash", func(t *testing.T) {
+		repo := infrastructure.NewRamSessionRepository()
+		_ = repo.SaveSession(newSession(time.Now().Add(time.Hour)))
+
+		found := repo.GetSessionByRefreshTokenHash("refresh")
+
+		assertTrue(t, found != nil && found.ID == "session")
+	})
+
+	t.Run("ForgetReplacedTokenHashes", func(t *testing.T) {
+		repo := infrastructure.NewRamSessionRepository()
+		session := newSession(time.Now().Add(time.Hour))
+		_ = repo.SaveSession(session)
+		session.TokenHash = "new hash"
+		session.PreviousRefreshTokenHash = session.RefreshTokenHash
+		session.RefreshTokenHash = "new refresh"
+
+		_ = repo.SaveSession(session)
+
+		assertTrue(t, repo.GetSessionByTokenHash("hash") == nil)
+		assertTrue(t, repo.GetSessionByTokenHash("new hash") != nil)
+		assertTrue(t, repo.GetSessionByRefreshTokenHash("refresh") != nil)
+		assertTrue(t, repo.GetSessionByRefreshTokenHash("new refresh") != nil)
+	})
+
+	t.Run("ListSessionsOfUser", func(t *testing.T) {
+		repo := infrastructure.NewRamSessionRepository()
+		_ = repo.SaveSession(newSession(time.Now().Add(time.Hour)))
+		_ = repo.SaveSession(&models.Session{ID: "other", UserID: models.NewUserID(), TokenHash: "other", ExpiresAt: time.Now().Add(time.Hour)})
+
+		sessions := repo.ListSessions(anyUserID)
+
+		assertTrue(t, len(sessions) == 1 && sessions[0].ID == "session")
+	})
+
+	t.Run("ForgetDeletedSession", func(t *testing.T) {
+		repo := infrastructure.NewRamSessionRepository()
+		_ = repo.SaveSession(newSession(time.Now().Add(time.Hour)))
+
+		assertNoError(t, repo.DeleteSession("session"))
+
+		assertTrue(t, repo.GetSessionByTokenHash("hash") == nil)
+		assertTrue(t, repo.GetSessionByRefreshTokenHash("refresh") == nil)
+	})
+
+	t.Run("ForgetExpiredSession", func(t *testing.T) {
+		repo := infrastructure.NewRamSessionRepository()
+		_ = repo.SaveSession(newSession(time.Now().Add(-time.Second)))
+
+		assertTrue(t, repo.GetSessionByTokenHash("hash") == nil)
+		assertTrue(t, len(repo.ListSessions(anyUserID)) == 0)
+	})
+}
@@ -0,0 +1,59 @@
+package infrastructure
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+// RamTokenRepository keeps one-time tokens in memory. Expired tokens are
+// forgotten when they are looked up.
+type RamTokenRepository struct {
+	tokens map[string]models.OneTimeToken
+	mutex  sync.Mutex
+}
+
+func NewRamTokenRepository() *RamTokenRepository {
+	return &RamTokenRepository{
+		tokens: make(map[string]models.OneTimeToken),
+	}
+}
+
+func (r *RamTokenRepository) SaveToken(t *models.OneTimeToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.tokens[t.Hash] = *t
+	return nil
+}
+
+func (r *RamTokenRepository) GetToken(hash string) *models.OneTimeToken {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	t, ok := r.tokens[hash]
+	if !ok {
+		return nil
+	}
+
+	if t.Expired(time.Now()) {
+		delete(r.tokens, hash)
+		return nil
+	}
+
+	return &t
+}
+
+func (r *RamTokenRepository) DeleteTokens(userID models.UserID, purpose models.TokenPurpose) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for hash, t := range r.tokens {
+		if t.UserID == userID && t.Purpose == purpose {
+			delete(r.tokens, hash)
+		}
+	}
+
+	return nil
+}
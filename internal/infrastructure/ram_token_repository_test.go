@@ -0,0 +1,43 @@
+package infrastructure_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestRamTokenRepository(t *testing.T) {
+	anyUserID := models.NewUserID()
+	newToken := func(hash string, purpose models.TokenPurpose, lifetime time.Duration) *models.OneTimeToken {
+		return &models.OneTimeToken{Hash: hash, UserID: anyUserID, Purpose: purpose, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(lifetime)}
+	}
+
+	t.Run("GetSavedToken", func(t *testing.T) {
+		repo := infrastructure.NewRamTokenRepository()
+
+		assertNoError(t, repo.SaveToken(newToken("hash", models.TokenPurposeVerifyEmail, time.Hour)))
+
+		found := repo.GetToken("hash")
+		assertTrue(t, found != nil && found.UserID == anyUserID)
+	})
+
+	t.Run("ForgetExpiredToken", func(t *testing.T) {
+		repo := infrastructure.NewRamTokenRepository()
+		_ = repo.SaveToken(newToken("hash", models.TokenPurposeVerifyEmail, -time.Second))
+
+		assertTrue(t, repo.GetToken("hash") == nil)
+	})
+
+	t.Run("DeleteTokensOfPurposeOnly", func(t *testing.T) {
+		repo := infrastructure.NewRamTokenRepository()
+		_ = repo.SaveToken(newToken("verify", models.TokenPurposeVerifyEmail, time.Hour))
+		_ = repo.SaveToken(newToken("reset", models.TokenPurposeResetPassword, time.Hour))
+
+		assertNoError(t, repo.DeleteTokens(anyUserID, models.TokenPurposeVerifyEmail))
+
+		assertTrue(t, repo.GetToken("verify") == nil)
+		assertTrue(t, repo.GetToken("reset") != nil)
+	})
+}
@@ -0,0 +1,58 @@
+package infrastructure
+
+import "sort"
+
+type byteRange struct {
+	start uint64
+	end   uint64
+}
+
+// byteRanges keeps the sorted, non overlapping [start, end) ranges of a file
+// that were received so far.
+type byteRanges struct {
+	ranges []byteRange
+}
+
+// add records [start, end) and returns how many of those bytes were not
+// already recorded.
+func (b *byteRanges) add(start uint64, end uint64) uint64 {
+	if start >= end {
+		return 0
+	}
+
+	added := end - start
+	merged := byteRange{start: start, end: end}
+	kept := make([]byteRange, 0, len(b.ranges)+1)
+
+	for _, r := range b.ranges {
+		if r.end < merged.start || r.start > merged.end {
+			kept = append(kept, r)
+			continue
+		}
+
+		overlapStart := max(r.start, start)
+		overlapEnd := min(r.end, end)
+		if overlapEnd > overlapStart {
+			added -= overlapEnd - overlapStart
+		}
+
+		merged.start = min(merged.start, r.start)
+		merged.end = max(merged.end, r.end)
+	}
+
+	kept = append(kept, merged)
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].start < kept[j].start
+	})
+
+	b.ranges = kept
+	return added
+}
+
+func (b *byteRanges) covers(total uint64) bool {
+	if total == 0 {
+		return true
+	}
+
+	return len(b.ranges) == 1 && b.ranges[0].start == 0 && b.ranges[0].end >= total
+}
@@ -0,0 +1,83 @@
+package infrastructure
+
+import (
+	"reflect"
+)
+
+const (
+	METRIC_SESSIONS              = "sdisk_sessions_active"
+	METRIC_FRAMES                = "sdisk_frames_total"
+	METRIC_BYTES                 = "sdisk_bytes_total"
+	METRIC_TRANSACTION_QUEUE     = "sdisk_transaction_queue_depth"
+	METRIC_CONNECTIONS_QUEUE     = "sdisk_connections_queue_depth"
+	METRIC_HANDLER_ERRORS        = "sdisk_handler_errors_total"
+	METRIC_DISK_USED_BYTES       = "sdisk_disk_used_bytes"
+	METRIC_DISK_SIZE_BYTES       = "sdisk_disk_size_bytes"
+	METRIC_HTTP_REQUESTS         = "sdisk_http_requests_total"
+	METRIC_HTTP_REQUEST_DURATION = "sdisk_http_request_duration_seconds"
+)
+
+// registerMetrics describes the metrics of the server and reads the gauges
+// when they are collected. It must be called before Run.
+func (server *TCPServer) registerMetrics() {
+	metrics := server.metrics
+
+	metrics.DescribeGauge(METRIC_SESSIONS, "Number of connections currently open on the real-time server.")
+	metrics.DescribeCounter(METRIC_FRAMES, "Frames received and sent by the real-time server, by opcode.")
+	metrics.DescribeCounter(METRIC_BYTES, "Bytes received and sent by the real-time server.")
+	metrics.DescribeGauge(METRIC_TRANSACTION_QUEUE, "Transactions waiting to be handled by the real-time server.")
+	metrics.DescribeGauge(METRIC_CONNECTIONS_QUEUE, "Connections waiting to be accepted by the real-time server.")
+	metrics.DescribeCounter(METRIC_HANDLER_ERRORS, "Errors returned while handling frames, by type.")
+	metrics.DescribeGauge(METRIC_DISK_USED_BYTES, "Bytes used on the disk of a user.")
+	metrics.DescribeGauge(METRIC_DISK_SIZE_BYTES, "Size in bytes of the disk of a user.")
+
+	metrics.OnCollect(server.collectMetrics)
+}
+
+func (server *TCPServer) collectMetrics() {
+	metrics := server.metrics
+
+	metrics.Set(METRIC_SESSIONS, float64(server.activeSessions.Load()))
+	metrics.Set(METRIC_TRANSACTION_QUEUE, float64(len(server.transactionQueue)))
+	metrics.Set(METRIC_CONNECTIONS_QUEUE, float64(len(server.connectionsQueue)))
+
+	metrics.Reset(METRIC_DISK_USED_BYTES)
+	metrics.Reset(METRIC_DISK_SIZE_BYTES)
+
+	server.disksMutex.RLock()
+	defer server.disksMutex.RUnlock()
+
+	for userID, disk := range server.disks {
+		metrics.Set(METRIC_DISK_USED_BYTES, float64(disk.GetUsedBytes()), "user", userID)
+		metrics.Set(METRIC_DISK_SIZE_BYTES, float64(disk.GetTotalBytes()), "user", userID)
+	}
+}
+
+func (server *TCPServer) countError(err error) {
+	if server.metrics == nil {
+		return
+	}
+
+	server.metrics.Add(METRIC_HANDLER_ERRORS, 1, "type", errorTypeName(err))
+}
+
+func errorTypeName(err error) string {
+	errorType := reflect.TypeOf(err)
+	for errorType.Kind() == reflect.Pointer {
+		errorType = errorType.Elem()
+	}
+
+	if errorType.Name() == "" {
+		return errorType.String()
+	}
+
+	return errorType.Name()
+}
+
+func metricDirection(direction trafficDirection) string {
+	if direction == upload {
+		return "in"
+	}
+
+	return "out"
+}
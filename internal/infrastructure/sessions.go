@@ -0,0 +1,421 @@
+package infrastructure
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+)
+
+// LOCAL_TRANSACTION is the origin of transactions queued by the server itself
+// rather than received from a connection.
+const LOCAL_TRANSACTION = "localhost"
+
+// session is a connection accepted by the server. It is bound to the user of
+// the first packet it sends and may only send packets for that user after.
+// When the server verifies credentials, it is bound instead to the API session
+// whose access token it sent first, and lasts as long as it, or to the device
+// whose credential it sent first.
+type session struct {
+	connection    *Connection
+	userID        *models.UserID
+	authSessionID string
+	authExpiresAt time.Time
+	deviceID      string
+	startedAt     time.Time
+	lastFrameAt   time.Time
+}
+
+// SetTokenVerifier makes every connection authenticate with the access token
+// of an API session before sending anything else. It must be called before
+// Run.
+func (server *TCPServer) SetTokenVerifier(verifier ports.AccessTokenVerifier) {
+	server.tokenVerifier = verifier
+}
+
+func (server *TCPServer) DisconnectUser(user *models.User) error {
+	userID := user.GetID()
+	packet, err := newPacket(Disconnect, userID.Bytes(), nil)
+	if err != nil {
+		return err
+	}
+
+	server.transactionQueue <- &Transaction{
+		packet: packet,
+		from:   LOCAL_TRANSACTION,
+	}
+
+	return nil
+}
+
+// SetDeviceAuthenticator makes every connection authenticate, with the
+// credential of a registered device or like SetTokenVerifier, before sending
+// anything else. It must be called before Run.
+func (server *TCPServer) SetDeviceAuthenticator(authenticator ports.DeviceAuthenticator) {
+	server.deviceAuthenticator = authenticator
+}
+
+// DropSession disconnects the connections authenticated with the API session
+// sessionID, which was revoked.
+func (server *TCPServer) DropSession(sessionID string) error {
+	server.revocationQueue <- sessionID
+	return nil
+}
+
+// DropDevice disconnects the connections of the device deviceID, which was
+// revoked.
+func (server *TCPServer) DropDevice(deviceID string) error {
+	server.revocationQueue <- deviceID
+	return nil
+}
+
+func (server *TCPServer) addConnection(conn net.Conn) error {
+	if len(server.sessions) >= int(server.maxConnections) {
+		server.rejectConnection(conn)
+		return &ErrMaximumClientsReached{maxClients: server.maxConnections}
+	}
+
+	conf := NewDefaultConnectionConfig(conn, server.transactionQueue, server.disconnectionQueue)
+	conf.shaper = server.shaper
+	conf.metrics = server.metrics
+	connection := NewConnection(conf)
+	now := time.Now()
+	server.sessions[connection.id] = &session{
+		connection:  connection,
+		startedAt:   now,
+		lastFrameAt: now,
+	}
+	server.activeSessions.Store(int64(len(server.sessions)))
+	server.logger.Info("connection opened", "connection", connection.id, "remote", conn.RemoteAddr().String())
+	go connection.Read()
+	go connection.WriteQueued()
+
+	return nil
+}
+
+func (server *TCPServer) rejectConnection(conn net.Conn) {
+	disconnectPayload := DisconnectPayload{
+		Reason:     DisconnectReasonServerBusy,
+		RetryAfter: uint32(server.retryAfter.Seconds()),
+		Message:    "server busy",
+	}
+
+	packet, err := newPacket(Disconnect, nil, disconnectPayload.Bytes())
+	if err == nil {
+		_, _ = conn.Write(packet.Bytes())
+	}
+
+	_ = conn.Close()
+}
+
+// removeConnection forgets a closed connection, and the bandwidth used by its
+// user once it was their last one.
+func (server *TCPServer) removeConnection(id string) {
+	s, ok := server.sessions[id]
+	if !ok {
+		return
+	}
+
+	server.logger.Info("connection closed", "connection", id)
+	server.deviceSeen(s)
+	delete(server.sessions, id)
+	server.activeSessions.Store(int64(len(server.sessions)))
+
+	if s.userID != nil && server.countSessions(*s.userID) == 0 {
+		server.shaper.forget(s.userID.ToString())
+	}
+}
+
+func (server *TCPServer) getConnection(id string) *Connection {
+	s := server.sessions[id]
+	if s == nil {
+		return nil
+	}
+
+	return s.connection
+}
+
+// bindSession checks that a packet received from a connection may be handled
+// and ties the connection to its user on its first packet.
+func (server *TCPServer) bindSession(transaction *Transaction) error {
+	s := server.sessions[transaction.from]
+	if s == nil {
+		return &ErrDisconnected{}
+	}
+
+	s.lastFrameAt = time.Now()
+	userID, err := models.FromBytes(transaction.packet.Header.id[:])
+	if err != nil {
+		return err
+	}
+
+	if s.userID != nil {
+		if *s.userID != userID {
+			return &ErrSessionUserMismatch{}
+		}
+
+		return nil
+	}
+
+	if server.tokenVerifier != nil || server.deviceAuthenticator != nil {
+		err := &ErrNotAuthenticated{Reason: "the first packet must authenticate the connection"}
+		server.disconnect(s, DisconnectReasonUnauthorized, 0, err.Error())
+		return err
+	}
+
+	return server.bindUser(s, userID)
+}
+
+// authenticate ties a connection to the API session of the access token it
+// sent, and to the user of that session. Without a token verifier, the
+// connection is bound to the user of the packet like any other.
+func (server *TCPServer) authenticate(transaction *Transaction) error {
+	if server.tokenVerifier == nil {
+		return server.bindSession(transaction)
+	}
+
+	s := server.sessions[transaction.from]
+	if s == nil {
+		return &ErrDisconnected{}
+	}
+
+	s.lastFrameAt = time.Now()
+	if s.authenticated() {
+		return &ErrNotAuthenticated{Reason: "the connection is already authenticated"}
+	}
+
+	var authenticatePayload AuthenticatePayload
+	err := authenticatePayload.FromBytes(transaction.packet.Payload)
+	if err != nil {
+		return err
+	}
+
+	authSession, err := server.tokenVerifier.VerifyAccessToken(authenticatePayload.Token)
+	if err != nil {
+		notAuthenticated := &ErrNotAuthenticated{Reason: err.Error()}
+		server.disconnect(s, DisconnectReasonUnauthorized, 0, notAuthenticated.Error())
+		return notAuthenticated
+	}
+
+	err = server.bindUser(s, authSession.UserID)
+	if err != nil {
+		return err
+	}
+
+	s.authSessionID = authSession.ID
+	s.authExpiresAt = authSession.ExpiresAt
+	server.logger.Info("session authenticated", "connection", s.connection.id, "session", authSession.ID)
+	return nil
+}
+
+// authenticateDevice ties a connection to the registered device whose
+// credential it sent, and to the user of that device. The client is told who
+// this user is, so it does not need to know it beforehand.
+func (server *TCPServer) authenticateDevice(transaction *Transaction) error {
+	s := server.sessions[transaction.from]
+	if s == nil {
+		return &ErrDisconnected{}
+	}
+
+	s.lastFrameAt = time.Now()
+	if s.authenticated() {
+		return &ErrNotAuthenticated{Reason: "the connection is already authenticated"}
+	}
+
+	var authenticateDevicePayload AuthenticateDevicePayload
+	err := authenticateDevicePayload.FromBytes(transaction.packet.Payload)
+	if err != nil {
+		return err
+	}
+
+	device, err := server.deviceAuthenticator.AuthenticateDevice(authenticateDevicePayload.Credential, authenticateDevicePayload.Version)
+	if err != nil {
+		notAuthenticated := &ErrNotAuthenticated{Reason: err.Error()}
+		server.disconnect(s, DisconnectReasonUnauthorized, 0, notAuthenticated.Error())
+		return notAuthenticated
+	}
+
+	err = server.bindUser(s, device.UserID)
+	if err != nil {
+		return err
+	}
+
+	s.deviceID = device.ID
+	server.logger.Info("device authenticated", "connection", s.connection.id, "device", device.ID, "version", device.Version)
+
+	deviceAuthenticatedPayload := DeviceAuthenticatedPayload{DeviceID: device.ID}
+	packet, err := newPacket(AuthenticateDevice, device.UserID.Bytes(), deviceAuthenticatedPayload.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return s.connection.Send(packet.Bytes())
+}
+
+func (s *session) authenticated() bool {
+	return s.authSessionID != "" || s.deviceID != ""
+}
+
+// deviceSeen records that the device of a session was seen, as it goes away.
+func (server *TCPServer) deviceSeen(s *session) {
+	if s.deviceID == "" || server.deviceAuthenticator == nil {
+		return
+	}
+
+	err := server.deviceAuthenticator.DeviceSeen(s.deviceID)
+	if err != nil {
+		server.logger.Debug("could not record when device was seen", "device", s.deviceID, "error", err)
+	}
+}
+
+// originOf returns what changes made through a connection are recorded as
+// coming from: its device, or the connection itself.
+func (server *TCPServer) originOf(connectionID string) string {
+	s := server.sessions[connectionID]
+	if s != nil && s.deviceID != "" {
+		return s.deviceID
+	}
+
+	return connectionID
+}
+
+func (server *TCPServer) bindUser(s *session, userID models.UserID) error {
+	if server.countSessions(userID) >= server.maxConnectionsPerUser {
+		err := &ErrMaximumUserConnectionsReached{MaxConnections: server.maxConnectionsPerUser}
+		server.disconnect(s, DisconnectReasonTooManyConnections, server.retryAfter, err.Error())
+		return err
+	}
+
+	s.userID = &userID
+	s.connection.shapeAs(userID.ToString())
+	server.logger.Info("session bound to user", "connection", s.connection.id, "user", userID.ToString())
+	return nil
+}
+
+// dropCredential disconnects the connections authenticated with the API
+// session or the device credentialID.
+func (server *TCPServer) dropCredential(credentialID string) {
+	for _, s := range server.sessions {
+		if s.authSessionID == credentialID || s.deviceID == credentialID {
+			server.disconnect(s, DisconnectReasonRevoked, 0, "the credential was revoked")
+		}
+	}
+}
+
+func (server *TCPServer) countSessions(userID models.UserID) uint {
+	count := uint(0)
+	for _, s := range server.sessions {
+		if s.userID != nil && *s.userID == userID {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (server *TCPServer) disconnect(s *session, reason DisconnectReason, retryAfter time.Duration, message string) {
+	disconnectPayload := DisconnectPayload{
+		Reason:     reason,
+		RetryAfter: uint32(retryAfter.Seconds()),
+		Message:    message,
+	}
+
+	var id []byte
+	attrs := []any{"connection", s.connection.id, "reason", uint16(reason), "message", message}
+	if s.userID != nil {
+		id = s.userID.Bytes()
+		attrs = append(attrs, "user", s.userID.ToString())
+	}
+	server.logger.Info("disconnecting session", attrs...)
+
+	packet, packetErr := newPacket(Disconnect, id, disconnectPayload.Bytes())
+	connection := s.connection
+	err := connection.Queue(func() error {
+		if packetErr == nil {
+			_, _ = connection.Write(packet.Bytes())
+		}
+
+		return connection.Close()
+	})
+	if err != nil {
+		_ = connection.Close()
+	}
+	server.removeConnection(connection.id)
+}
+
+// disconnectData handles a client leaving or, when queued by the server
+// itself, kicks every connection of a user.
+func (server *TCPServer) disconnectData(transaction *Transaction) error {
+	if transaction.from != LOCAL_TRANSACTION {
+		s := server.sessions[transaction.from]
+		if s != nil {
+			_ = s.connection.Close()
+		}
+
+		server.removeConnection(transaction.from)
+		return nil
+	}
+
+	userID, err := models.FromBytes(transaction.packet.Header.id[:])
+	if err != nil {
+		return err
+	}
+
+	for _, s := range server.sessions {
+		if s.userID != nil && *s.userID == userID {
+			server.disconnect(s, DisconnectReasonKicked, server.retryAfter, fmt.Sprintf("sessions of user %s were closed by an administrator", userID.ToString()))
+		}
+	}
+
+	return nil
+}
+
+// expireSessions closes the sessions that stayed idle or open for too long. The
+// client is told to reconnect right away when its session got too old, so it
+// authenticates again.
+func (server *TCPServer) expireSessions() {
+	now := time.Now()
+
+	for _, s := range server.sessions {
+		if server.idleTimeout > 0 && now.Sub(s.lastFrameAt) >= server.idleTimeout {
+			server.disconnect(s, DisconnectReasonIdleTimeout, server.retryAfter, fmt.Sprintf("no frames received for %s", server.idleTimeout))
+			continue
+		}
+
+		if server.maxSessionAge > 0 && now.Sub(s.startedAt) >= server.maxSessionAge {
+			server.disconnect(s, DisconnectReasonSessionExpired, 0, fmt.Sprintf("session is older than %s", server.maxSessionAge))
+			continue
+		}
+
+		if !s.authExpiresAt.IsZero() && !now.Before(s.authExpiresAt) {
+			server.disconnect(s, DisconnectReasonSessionExpired, 0, "the API session expired")
+		}
+	}
+}
+
+// ServeConn makes conn a session of the server, like a connection accepted by
+// one of its listeners, and blocks until the session is over. It lets other
+// transports, such as WebSocket, carry the protocol.
+func (server *TCPServer) ServeConn(conn net.Conn) {
+	closable := &closeNotifyingConn{Conn: conn, closed: make(chan struct{})}
+	server.connectionsQueue <- closable
+	<-closable.closed
+}
+
+type closeNotifyingConn struct {
+	net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (c *closeNotifyingConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		close(c.closed)
+	})
+
+	return err
+}
@@ -0,0 +1,195 @@
+package infrastructure
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/google/uuid"
+)
+
+// SMTPMailer sends mail through an SMTP relay, upgrading the connection with
+// STARTTLS whenever the relay offers it. When TLS is required, mail is not
+// sent to a relay that does not offer STARTTLS.
+type SMTPMailer struct {
+	host       string
+	port       uint
+	from       string
+	username   string
+	password   string
+	timeout    time.Duration
+	requireTLS bool
+}
+
+type SMTPMailerConfig struct {
+	host       string
+	port       uint
+	from       string
+	username   string
+	password   string
+	timeout    time.Duration
+	requireTLS *bool
+}
+
+// NewDefaultSMTPMailerConfig returns the configuration of a mailer sending
+// mail from the address from through the relay at host. A zero port keeps the
+// submission port.
+func NewDefaultSMTPMailerConfig(host string, port uint, from string) *SMTPMailerConfig {
+	if port == 0 {
+		port = DEFAULT_SMTP_PORT
+	}
+
+	if from == "" {
+		from = DEFAULT_MAIL_FROM
+	}
+
+	return &SMTPMailerConfig{
+		host:    host,
+		port:    port,
+		from:    from,
+		timeout: DEFAULT_SMTP_TIMEOUT_MS * time.Millisecond,
+	}
+}
+
+// SetCredentials makes the mailer authenticate with PLAIN, which is only done
+// over TLS or to localhost.
+func (config *SMTPMailerConfig) SetCredentials(username string, password string) {
+	config.username = username
+	config.password = password
+}
+
+// SetRequireTLS overrides whether mail is only sent over STARTTLS. By default,
+// it is required when the mailer authenticates or the relay is not on
+// localhost.
+func (config *SMTPMailerConfig) SetRequireTLS(require bool) {
+	config.requireTLS = &require
+}
+
+func NewSMTPMailer(config *SMTPMailerConfig) *SMTPMailer {
+	requireTLS := config.username != "" || !isLocalhost(config.host)
+	if config.requireTLS != nil {
+		requireTLS = *config.requireTLS
+	}
+
+	return &SMTPMailer{
+		host:       config.host,
+		port:       config.port,
+		from:       config.from,
+		username:   config.username,
+		password:   config.password,
+		timeout:    config.timeout,
+		requireTLS: requireTLS,
+	}
+}
+
+func (m *SMTPMailer) Send(mail models.Mail) error {
+	message, err := formatMail(m.from, mail, time.Now())
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", m.host, m.port), m.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(m.timeout))
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: m.host})
+		if err != nil {
+			return err
+		}
+	} else if m.requireTLS {
+		return &ErrSMTPTLSUnsupported{Host: m.host}
+	}
+
+	if m.username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return &ErrSMTPAuthUnsupported{Host: m.host}
+		}
+
+		err = client.Auth(smtp.PlainAuth("", m.username, m.password, m.host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(m.from)
+	if err != nil {
+		return err
+	}
+
+	err = client.Rcpt(mail.To)
+	if err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(message)
+	if err != nil {
+		return err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func isLocalhost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// formatMail returns mail as an RFC 5322 message in UTF-8 plain text. Headers
+// spanning several lines are refused, so they cannot be used to add others.
+func formatMail(from string, mail models.Mail, date time.Time) ([]byte, error) {
+	headers := [][2]string{{"From", from}, {"To", mail.To}, {"Subject", mail.Subject}}
+	for _, header := range headers {
+		if strings.ContainsAny(header[1], "\r\n") {
+			return nil, &ErrInvalidMailHeader{Header: header[0]}
+		}
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", mail.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Message-ID: <%s@sdisk>\r\n", uuid.NewString())
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	message.WriteString("\r\n")
+
+	body := strings.ReplaceAll(mail.Body, "\r\n", "\n")
+	message.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return message.Bytes(), nil
+}
@@ -0,0 +1,246 @@
+package infrastructure
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	STAGING_DIRECTORY_NAME = ".sdisk-staging"
+	STAGED_FILE_EXTENSION  = ".part"
+)
+
+// StagingArea holds incoming files until they are complete. Chunks are written
+// at their offset in any order and a staged file is only renamed to its
+// destination once every byte has been received and synced, so a dropped
+// connection never leaves a truncated file behind.
+type StagingArea struct {
+	root    string
+	uploads map[string]*stagedUpload
+	mutex   sync.Mutex
+}
+
+type stagedUpload struct {
+	total    uint64
+	received byteRanges
+}
+
+type StageResult struct {
+	Completed bool
+	Written   uint64
+	Released  uint64
+}
+
+func NewStagingArea(root string) *StagingArea {
+	return &StagingArea{
+		root:    root,
+		uploads: make(map[string]*stagedUpload),
+	}
+}
+
+// Stage writes a chunk of the file destined to destination. Written is the
+// number of bytes of the chunk that were not received before and Released the
+// number of bytes freed by restarting an upload, discarding a corrupted one or
+// replacing an existing file.
+func (s *StagingArea) Stage(destination string, payload *UpdateDataPayload) (StageResult, error) {
+	var result StageResult
+	chunkEnd := payload.Offset + uint64(len(payload.FileData))
+
+	if chunkEnd < payload.Offset || chunkEnd > payload.Total {
+		return result, &ErrUnexpectedFileState{}
+	}
+
+	err := os.MkdirAll(s.root, 0777)
+	if err != nil {
+		return result, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stagedPath := s.stagedPath(destination)
+	upload, ok := s.uploads[stagedPath]
+
+	if !ok || upload.total != payload.Total {
+		info, err := os.Stat(stagedPath)
+		if err == nil {
+			result.Released += uint64(info.Size())
+		}
+
+		err = os.Remove(stagedPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return result, err
+		}
+
+		upload = &stagedUpload{total: payload.Total}
+		s.uploads[stagedPath] = upload
+	}
+
+	file, err := os.OpenFile(stagedPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return result, err
+	}
+
+	defer file.Close()
+
+	_, err = file.WriteAt(payload.FileData, int64(payload.Offset))
+	if err != nil {
+		return result, err
+	}
+
+	result.Written = upload.received.add(payload.Offset, chunkEnd)
+
+	if !upload.received.covers(payload.Total) {
+		return result, nil
+	}
+
+	delete(s.uploads, stagedPath)
+	released, err := s.commit(stagedPath, destination, payload)
+	result.Released += released
+
+	if err != nil {
+		return result, err
+	}
+
+	result.Completed = true
+	return result, nil
+}
+
+// Clean removes staged files that were not written to for longer than maxAge
+// and returns the number of bytes freed.
+func (s *StagingArea) Clean(maxAge time.Duration) (uint64, error) {
+	var released uint64
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries, err := os.ReadDir(s.root)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), STAGED_FILE_EXTENSION) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		if time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+
+		stagedPath := filepath.Join(s.root, entry.Name())
+		if os.Remove(stagedPath) == nil {
+			released += uint64(info.Size())
+		}
+
+		delete(s.uploads, stagedPath)
+	}
+
+	return released, nil
+}
+
+func (s *StagingArea) Size() (uint64, error) {
+	size, err := directorySize(s.root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	return size, err
+}
+
+func (s *StagingArea) stagedPath(destination string) string {
+	sum := sha256.Sum256([]byte(destination))
+	return filepath.Join(s.root, hex.EncodeToString(sum[:])+STAGED_FILE_EXTENSION)
+}
+
+func (s *StagingArea) commit(stagedPath string, destination string, payload *UpdateDataPayload) (uint64, error) {
+	if len(payload.Hash) > 0 {
+		sum, err := hashFile(stagedPath)
+		if err != nil {
+			return 0, err
+		}
+
+		if !bytes.Equal(sum, payload.Hash) {
+			_ = os.Remove(stagedPath)
+			return payload.Total, &ErrHashMismatch{Path: payload.Path}
+		}
+	}
+
+	var released uint64
+	info, err := os.Stat(destination)
+	if err == nil {
+		released = uint64(info.Size())
+	}
+
+	err = os.MkdirAll(filepath.Dir(destination), 0777)
+	if err != nil {
+		return 0, err
+	}
+
+	err = syncFile(stagedPath)
+	if err != nil {
+		return 0, err
+	}
+
+	err = os.Rename(stagedPath, destination)
+	if err != nil {
+		return 0, err
+	}
+
+	return released, syncDirectory(filepath.Dir(destination))
+}
+
+func hashFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, file)
+	if err != nil {
+		return nil, err
+	}
+
+	return hasher.Sum(nil), nil
+}
+
+func syncFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	return file.Sync()
+}
+
+func syncDirectory(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,225 @@
+package infrastructure_test
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+)
+
+func TestStage(t *testing.T) {
+	content := []byte("hello world")
+	sum := sha256.Sum256(content)
+
+	t.Run("DoNotCreateDestinationBeforeLastChunk", func(t *testing.T) {
+		stagingArea := infrastructure.NewStagingArea(t.TempDir())
+		destination := filepath.Join(t.TempDir(), "hello.txt")
+
+		result, err := stagingArea.Stage(destination, chunkOf(content, 0, 5, nil))
+
+		assertNoError(t, err)
+		assertFalse(t, result.Completed)
+		assertFileDoesNotExist(t, destination)
+	})
+
+	t.Run("MoveFileToDestinationAfterLastChunk", func(t *testing.T) {
+		stagingArea := infrastructure.NewStagingArea(t.TempDir())
+		destination := filepath.Join(t.TempDir(), "photos", "hello.txt")
+
+		_, _ = stagingArea.Stage(destination, chunkOf(content, 0, 5, sum[:]))
+		result, err := stagingArea.Stage(destination, chunkOf(content, 5, len(content), sum[:]))
+
+		assertNoError(t, err)
+		assertTrue(t, result.Completed)
+		assertFileContent(t, destination, content)
+	})
+
+	t.Run("ReleaseSizeOfReplacedFile", func(t *testing.T) {
+		stagingArea := infrastructure.NewStagingArea(t.TempDir())
+		destination := filepath.Join(t.TempDir(), "hello.txt")
+		_ = os.WriteFile(destination, []byte("old"), 0644)
+
+		result, _ := stagingArea.Stage(destination, chunkOf(content, 0, len(content), nil))
+
+		assertUint64Equals(t, result.Released, 3)
+	})
+
+	t.Run("RejectFileIfHashDoesNotMatch", func(t *testing.T) {
+		stagingArea := infrastructure.NewStagingArea(t.TempDir())
+		destination := filepath.Join(t.TempDir(), "hello.txt")
+		otherSum := sha256.Sum256([]byte("something else"))
+
+		result, err := stagingArea.Stage(destination, chunkOf(content, 0, len(content), otherSum[:]))
+
+		assertHashMismatch(t, err)
+		assertUint64Equals(t, result.Released, uint64(len(content)))
+		assertFileDoesNotExist(t, destination)
+	})
+
+	t.Run("AssembleChunksReceivedOutOfOrder", func(t *testing.T) {
+		stagingArea := infrastructure.NewStagingArea(t.TempDir())
+		destination := filepath.Join(t.TempDir(), "hello.txt")
+
+		first, _ := stagingArea.Stage(destination, chunkOf(content, 8, len(content), sum[:]))
+		second, _ := stagingArea.Stage(destination, chunkOf(content, 0, 3, sum[:]))
+		last, err := stagingArea.Stage(destination, chunkOf(content, 3, 8, sum[:]))
+
+		assertNoError(t, err)
+		assertFalse(t, first.Completed || second.Completed)
+		assertTrue(t, last.Completed)
+		assertFileContent(t, destination, content)
+	})
+
+	t.Run("OnlyCountBytesNotReceivedBefore", func(t *testing.T) {
+		stagingArea := infrastructure.NewStagingArea(t.TempDir())
+		destination := filepath.Join(t.TempDir(), "hello.txt")
+		_, _ = stagingArea.Stage(destination, chunkOf(content, 0, 5, nil))
+
+		result, _ := stagingArea.Stage(destination, chunkOf(content, 3, 8, nil))
+
+		assertUint64Equals(t, result.Written, 3)
+	})
+
+	t.Run("RejectChunkPastTotal", func(t *testing.T) {
+		stagingArea := infrastructure.NewStagingArea(t.TempDir())
+		destination := filepath.Join(t.TempDir(), "hello.txt")
+		chunk := chunkOf(content, 5, len(content), nil)
+		chunk.Total = 6
+
+		result, err := stagingArea.Stage(destination, chunk)
+
+		assertError(t, err)
+		assertUint64Equals(t, result.Written, 0)
+	})
+
+	t.Run("RestartUploadWhenTotalChanges", func(t *testing.T) {
+		stagingArea := infrastructure.NewStagingArea(t.TempDir())
+		destination := filepath.Join(t.TempDir(), "hello.txt")
+		_, _ = stagingArea.Stage(destination, chunkOf(content, 0, 5, nil))
+		chunk := chunkOf(content, 0, 5, nil)
+		chunk.Total = 20
+
+		result, _ := stagingArea.Stage(destination, chunk)
+
+		assertUint64Equals(t, result.Released, 5)
+		assertUint64Equals(t, result.Written, 5)
+	})
+}
+
+func TestClean(t *testing.T) {
+	content := []byte("hello world")
+
+	t.Run("RemoveAbandonedStagedFiles", func(t *testing.T) {
+		root := t.TempDir()
+		stagingArea := infrastructure.NewStagingArea(root)
+		_, _ = stagingArea.Stage(filepath.Join(t.TempDir(), "hello.txt"), chunkOf(content, 0, 5, nil))
+
+		released, err := stagingArea.Clean(0)
+
+		assertNoError(t, err)
+		assertUint64Equals(t, released, 5)
+		assertDirectoryIsEmpty(t, root)
+	})
+
+	t.Run("KeepRecentStagedFiles", func(t *testing.T) {
+		stagingArea := infrastructure.NewStagingArea(t.TempDir())
+		_, _ = stagingArea.Stage(filepath.Join(t.TempDir(), "hello.txt"), chunkOf(content, 0, 5, nil))
+
+		released, _ := stagingArea.Clean(time.Hour)
+
+		assertUint64Equals(t, released, 0)
+	})
+
+	t.Run("IgnoreMissingStagingArea", func(t *testing.T) {
+		stagingArea := infrastructure.NewStagingArea(filepath.Join(t.TempDir(), "missing"))
+
+		_, err := stagingArea.Clean(0)
+
+		assertNoError(t, err)
+	})
+}
+
+func chunkOf(content []byte, from int, to int, hash []byte) *infrastructure.UpdateDataPayload {
+	return &infrastructure.UpdateDataPayload{
+		Total:    uint64(len(content)),
+		Offset:   uint64(from),
+		PathLen:  uint64(len("hello.txt")),
+		HashLen:  uint64(len(hash)),
+		Path:     "hello.txt",
+		Hash:     hash,
+		FileData: content[from:to],
+	}
+}
+
+func assertError(t *testing.T, err error) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("Expected an error but there was none")
+	}
+}
+
+func assertHashMismatch(t *testing.T, err error) {
+	t.Helper()
+
+	if _, ok := err.(*infrastructure.ErrHashMismatch); !ok {
+		t.Fatalf("Expected ErrHashMismatch, got %v", err)
+	}
+}
+
+func assertTrue(t *testing.T, statement bool) {
+	t.Helper()
+
+	if !statement {
+		t.Fatalf("Expected true, got false.")
+	}
+}
+
+func assertFalse(t *testing.T, statement bool) {
+	t.Helper()
+
+	if statement {
+		t.Fatalf("Expected false, got true.")
+	}
+}
+
+func assertUint64Equals(t *testing.T, got uint64, want uint64) {
+	t.Helper()
+
+	if got != want {
+		t.Fatalf("Expected %d, got %d", want, got)
+	}
+}
+
+func assertFileDoesNotExist(t *testing.T, path string) {
+	t.Helper()
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected %s to not exist", path)
+	}
+}
+
+func assertFileContent(t *testing.T, path string, want []byte) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read %s: %s", path, err.Error())
+	}
+
+	if string(got) != string(want) {
+		t.Fatalf("Expected content %s, got %s", string(want), string(got))
+	}
+}
+
+func assertDirectoryIsEmpty(t *testing.T, path string) {
+	t.Helper()
+
+	entries, _ := os.ReadDir(path)
+	if len(entries) != 0 {
+		t.Fatalf("Expected %s to be empty, found %d entries", path, len(entries))
+	}
+}
@@ -1,112 +1,333 @@
 package infrastructure
 
 import (
-	"fmt"
-	"log"
+	"bytes"
+	"errors"
+	"io/fs"
+	"log/slog"
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/Joey-Boivin/sdisk/internal/models"
 )
 
+const (
+	STATE_DIRECTORY_NAME = ".sdisk-state"
+	CURSOR_FILE_NAME     = "cursor"
+)
+
 type TCPClient struct {
-	transactionQueue chan *Transaction
-	address          string
-	port             uint
-	connection       *Connection
-	syncPath         string
-	userID           models.UserID
+	transactionQueue   chan *Transaction
+	disconnectionQueue chan string
+	address            string
+	port               uint
+	socketPath         string
+	connection         *Connection
+	syncPath           string
+	stagingArea        *StagingArea
+	stagingMaxAge      time.Duration
+	cursorPath         string
+	scannedAt          time.Time
+	identical          map[string]bool
+	userID             models.UserID
+	deviceCredential   string
+	version            string
+	logger             *slog.Logger
 }
 
 type TCPClientConfig struct {
 	maxQueuedTransactions uint
 	address               string
 	port                  uint
+	socketPath            string
 	syncPath              string
-	userID                models.UserID
+	stagingPath           string
+	stagingMaxAge         time.Duration
+	cursorPath            string
+	deviceCredential      string
+	version               string
 }
 
-func NewDefaultTCPClientConfig(userID models.UserID, host string, port uint, clientRootFolder string) *TCPClientConfig {
+// NewDefaultTCPClientConfig returns the configuration of a client connecting
+// with the credential of a registered device.
+func NewDefaultTCPClientConfig(deviceCredential string, host string, port uint, clientRootFolder string) *TCPClientConfig {
 	syncPath := os.Getenv("SDISK_HOME") + "/" + clientRootFolder
+	stagingPath := filepath.Join(os.Getenv("SDISK_HOME"), STAGING_DIRECTORY_NAME, clientRootFolder)
+	cursorPath := filepath.Join(os.Getenv("SDISK_HOME"), STATE_DIRECTORY_NAME, clientRootFolder, CURSOR_FILE_NAME)
 
 	defaultClientConfig := TCPClientConfig{
 		maxQueuedTransactions: DEFAULT_MAX_QUEUED_CLIENT_TRANSACTIONS,
 		address:               host,
 		port:                  port,
 		syncPath:              syncPath,
-		userID:                userID,
+		stagingPath:           stagingPath,
+		stagingMaxAge:         DEFAULT_STAGING_MAX_AGE_MS * time.Millisecond,
+		cursorPath:            cursorPath,
+		deviceCredential:      deviceCredential,
 	}
 
 	return &defaultClientConfig
 }
 
+// SetUnixSocket makes the client connect to the Unix socket at path instead of
+// the host and port of the server.
+func (config *TCPClientConfig) SetUnixSocket(path string) {
+	config.socketPath = path
+}
+
+// SetVersion sets the version the client reports to the server when it
+// connects.
+func (config *TCPClientConfig) SetVersion(version string) {
+	config.version = version
+}
+
 func NewTCPClient(config *TCPClientConfig) *TCPClient {
 	if config == nil || config.maxQueuedTransactions == 0 {
 		return nil
 	}
 
-	net, err := net.Dial("tcp", fmt.Sprintf("%s:%d", config.address, config.port))
-
-	if err != nil {
-		panic(err)
+	client := TCPClient{
+		transactionQueue:   make(chan *Transaction, config.maxQueuedTransactions),
+		disconnectionQueue: make(chan string, 1),
+		address:            config.address,
+		port:               config.port,
+		socketPath:         config.socketPath,
+		syncPath:           config.syncPath,
+		stagingArea:        NewStagingArea(config.stagingPath),
+		stagingMaxAge:      config.stagingMaxAge,
+		cursorPath:         config.cursorPath,
+		identical:          make(map[string]bool),
+		deviceCredential:   config.deviceCredential,
+		version:            config.version,
+		logger:             slog.Default(),
 	}
 
-	client := TCPClient{
-		transactionQueue: make(chan *Transaction, config.maxQueuedTransactions),
-		address:          config.address,
-		port:             config.port,
-		syncPath:         config.syncPath,
-		userID:           config.userID,
+	return &client
+}
+
+// Run connects to the server and synchronizes until the connection is lost.
+// The returned error is an ErrDisconnectedByPeer when the server closed the
+// connection itself.
+func (client *TCPClient) Run() error {
+	conn, err := client.dial()
+
+	if err != nil {
+		return err
 	}
 
-	connectionConfig := NewDefaultConnectionConfig(net, client.transactionQueue)
+	connectionConfig := NewDefaultConnectionConfig(conn, client.transactionQueue, client.disconnectionQueue)
 	client.connection = NewConnection(connectionConfig)
 
-	return &client
-}
+	client.logger.Info("connected to server", "connection", client.connection.id, "remote", conn.RemoteAddr().String())
+
+	_, err = client.stagingArea.Clean(client.stagingMaxAge)
+	if err != nil {
+		client.logger.Error("could not clean staging area", "error", err)
+	}
 
-func (client *TCPClient) Run() {
 	go client.connection.Read()
+
+	err = client.authenticate()
+	if err != nil {
+		return err
+	}
+
+	// Only the files modified since the files were scanned for the last pull
+	// are sent, so reconnecting does not upload the whole folder again.
+	scannedAt := time.Now()
+	_, lastScan, _ := client.savedCursor()
 	files := walkDirectory(client.syncPath)
 
 	for _, file := range files {
-		sendFile(&file, client.syncPath, client.connection, client.userID)
+		if !file.modifiedAfter(lastScan) {
+			continue
+		}
+
+		err := sendFile(&file, client.syncPath, client.connection, client.userID)
+		if err != nil {
+			return client.disconnected()
+		}
 	}
 
-	header := PacketHeader{
-		Version:  VERSION,
-		Opcode:   PullData,
-		Encoding: EncodingNone,
-		DataSize: 0,
+	client.scannedAt = scannedAt
+	packet, err := client.pullDataPacket()
+	if err != nil {
+		return err
 	}
 
-	copy(header.id[:], client.userID.Bytes())
-	data := []byte{}
+	_, err = client.connection.Write(packet.Bytes())
 
-	packet := Packet{
-		Header:  header,
-		Payload: data,
+	if err != nil {
+		return client.disconnected()
 	}
-	_, err := client.connection.Write(packet.Bytes())
 
+	for {
+		select {
+		case transaction := <-client.transactionQueue:
+			err := client.handlePacket(transaction)
+			if err != nil {
+				_ = client.connection.Close()
+				<-client.disconnectionQueue
+				return err
+			}
+
+		case <-client.disconnectionQueue:
+			return client.pendingDisconnection()
+		}
+	}
+}
+
+// authenticate presents the credential of the device and waits for the server
+// to tell which user the device syncs for.
+func (client *TCPClient) authenticate() error {
+	authenticateDevicePayload := AuthenticateDevicePayload{Version: client.version, Credential: client.deviceCredential}
+	packet, err := newPacket(AuthenticateDevice, nil, authenticateDevicePayload.Bytes())
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	for transaction := range client.transactionQueue {
-		if transaction.packet.Header.Opcode != UpdateData {
-			log.Fatalf("unknown opcode")
+	_, err = client.connection.Write(packet.Bytes())
+	if err != nil {
+		return client.disconnected()
+	}
+
+	select {
+	case transaction := <-client.transactionQueue:
+		switch transaction.packet.Header.Opcode {
+		case AuthenticateDevice:
+			err = client.deviceAuthenticated(transaction)
+			if err == nil {
+				return nil
+			}
+		case ReportError:
+			err = client.reportedError(transaction)
+		case Disconnect:
+			err = client.disconnectedByPeer(transaction)
+		default:
+			err = &ErrUnknownPacket{Opcode: uint8(transaction.packet.Header.Opcode)}
 		}
 
+		_ = client.connection.Close()
+		<-client.disconnectionQueue
+		return err
+
+	case <-client.disconnectionQueue:
+		return client.pendingDisconnection()
+	}
+}
+
+func (client *TCPClient) deviceAuthenticated(transaction *Transaction) error {
+	var deviceAuthenticatedPayload DeviceAuthenticatedPayload
+	err := deviceAuthenticatedPayload.FromBytes(transaction.packet.Payload)
+	if err != nil {
+		return err
+	}
+
+	client.userID, err = models.FromBytes(transaction.packet.Header.id[:])
+	if err != nil {
+		return err
+	}
+
+	client.logger = slog.Default().With("user", client.userID.ToString(), "device", deviceAuthenticatedPayload.DeviceID)
+	client.logger.Info("device authenticated")
+	return nil
+}
+
+func (client *TCPClient) handlePacket(transaction *Transaction) error {
+	switch transaction.packet.Header.Opcode {
+	case UpdateData:
 		err := client.updateData(transaction)
 
-		if err != nil {
-			panic(err)
+		switch err.(type) {
+		case nil:
+		case *ErrInvalidPath, *ErrHashMismatch, *ErrUnexpectedFileState:
+			client.logger.Warn("could not apply update from server", "opcode", UpdateData.String(), "error", err)
+		default:
+			return err
+		}
+
+	case DeleteData:
+		err := client.deleteData(transaction)
+
+		switch err.(type) {
+		case nil:
+		case *ErrInvalidPath:
+			client.logger.Warn("could not apply deletion from server", "opcode", DeleteData.String(), "error", err)
+		default:
+			return err
+		}
+
+	case SyncCursor:
+		return client.syncCursor(transaction)
+
+	case ReportError:
+		client.logger.Warn("server reported an error", "error", client.reportedError(transaction))
+
+	case Disconnect:
+		return client.disconnectedByPeer(transaction)
+
+	default:
+		return &ErrUnknownPacket{Opcode: uint8(transaction.packet.Header.Opcode)}
+	}
+
+	return nil
+}
+
+// disconnected closes the connection after a failed write and waits for the
+// reader to stop, so a disconnection frame sent by the server is not missed.
+func (client *TCPClient) disconnected() error {
+	_ = client.connection.Close()
+	<-client.disconnectionQueue
+	return client.pendingDisconnection()
+}
+
+func (client *TCPClient) pendingDisconnection() error {
+	for {
+		select {
+		case transaction := <-client.transactionQueue:
+			if transaction.packet.Header.Opcode == Disconnect {
+				return client.disconnectedByPeer(transaction)
+			}
+
+		default:
+			return &ErrDisconnected{}
 		}
 	}
 }
 
+func (client *TCPClient) disconnectedByPeer(transaction *Transaction) error {
+	var disconnectPayload DisconnectPayload
+	err := disconnectPayload.FromBytes(transaction.packet.Payload)
+
+	if err != nil {
+		return err
+	}
+
+	return &ErrDisconnectedByPeer{
+		Reason:     disconnectPayload.Reason,
+		RetryAfter: time.Duration(disconnectPayload.RetryAfter) * time.Second,
+		Message:    disconnectPayload.Message,
+	}
+}
+
+func (client *TCPClient) reportedError(transaction *Transaction) error {
+	var reportErrorPayload ReportErrorPayload
+	err := reportErrorPayload.FromBytes(transaction.packet.Payload)
+
+	if err != nil {
+		return err
+	}
+
+	return &ErrReportedByPeer{
+		Code:    reportErrorPayload.Code,
+		Path:    reportErrorPayload.Path,
+		Message: reportErrorPayload.Message,
+	}
+}
+
 func (client *TCPClient) updateData(transaction *Transaction) error {
 	var updateDataPayload UpdateDataPayload
 	err := updateDataPayload.FromBytes(transaction.packet.Payload)
@@ -115,36 +336,138 @@ func (client *TCPClient) updateData(transaction *Transaction) error {
 		return err
 	}
 
-	filePath := client.syncPath + updateDataPayload.Path
-	dirPath := filepath.Dir(filePath)
+	filePath, err := ResolveSyncPath(client.syncPath, updateDataPayload.Path)
+	if err != nil {
+		return err
+	}
+
+	if client.alreadyHas(filePath, &updateDataPayload) {
+		return nil
+	}
+
+	_, err = client.stagingArea.Stage(filePath, &updateDataPayload)
+	return err
+}
+
+// alreadyHas reports whether the file at filePath already has the content
+// sent, so pulling a file the client sent itself does not rewrite it and make
+// it look modified to the next scan. The answer is kept until the end of the
+// pull, so the file is hashed once rather than for every chunk.
+func (client *TCPClient) alreadyHas(filePath string, updateDataPayload *UpdateDataPayload) bool {
+	if len(updateDataPayload.Hash) == 0 {
+		return false
+	}
+
+	key := updateDataPayload.Path + "\x00" + string(updateDataPayload.Hash)
+	identical, ok := client.identical[key]
+	if ok {
+		return identical
+	}
+
+	info, err := os.Stat(filePath)
+	identical = err == nil && uint64(info.Size()) == updateDataPayload.Total
+	if identical {
+		hash, err := hashFile(filePath)
+		identical = err == nil && bytes.Equal(hash, updateDataPayload.Hash)
+	}
+
+	client.identical[key] = identical
+	return identical
+}
+
+func (client *TCPClient) deleteData(transaction *Transaction) error {
+	var deleteDataPayload DeleteDataPayload
+	err := deleteDataPayload.FromBytes(transaction.packet.Payload)
 
-	err = os.MkdirAll(dirPath, 0777)
 	if err != nil {
 		return err
 	}
 
-	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	filePath, err := ResolveSyncPath(client.syncPath, deleteDataPayload.Path)
 	if err != nil {
 		return err
 	}
 
-	defer file.Close()
+	err = os.Remove(filePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
 
-	if updateDataPayload.Offset != 0 {
-		seeked, err := file.Seek(int64(updateDataPayload.Offset), 0)
-		if err != nil {
-			return err
-		}
-		if seeked != int64(updateDataPayload.Offset) {
-			return &ErrUnexpectedFileState{}
-		}
+	return nil
+}
+
+// pullDataPacket asks for the changes since the last pull, or for the whole
+// disk when the client never pulled.
+func (client *TCPClient) pullDataPacket() (*Packet, error) {
+	cursor, _, err := client.savedCursor()
+	if err != nil {
+		return newPacket(PullData, client.userID.Bytes(), nil)
 	}
 
-	_, err = file.Write(updateDataPayload.FileData)
+	pullDataPayload := PullDataPayload{Cursor: cursor}
+	return newPacket(PullData, client.userID.Bytes(), pullDataPayload.Bytes())
+}
+
+// savedCursor returns the cursor saved at the end of the last pull, and when
+// the files were scanned before that pull. The scan time is zero when the
+// client never pulled.
+func (client *TCPClient) savedCursor() (uint64, time.Time, error) {
+	data, err := os.ReadFile(client.cursorPath)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	var syncCursorPayload SyncCursorPayload
+	err = syncCursorPayload.FromBytes(data)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	info, err := os.Stat(client.cursorPath)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return syncCursorPayload.Cursor, info.ModTime(), nil
+}
+
+// syncCursor saves the cursor sent at the end of a pull, once every change
+// before it was applied. The cursor file is dated from when the files were
+// scanned before the pull, which is when the next scan starts looking for
+// modified files.
+func (client *TCPClient) syncCursor(transaction *Transaction) error {
+	var syncCursorPayload SyncCursorPayload
+	err := syncCursorPayload.FromBytes(transaction.packet.Payload)
 
 	if err != nil {
 		return err
 	}
 
-	return nil
+	clear(client.identical)
+
+	err = os.MkdirAll(filepath.Dir(client.cursorPath), 0777)
+	if err != nil {
+		return err
+	}
+
+	temporaryPath := client.cursorPath + ".tmp"
+	err = os.WriteFile(temporaryPath, syncCursorPayload.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+
+	err = os.Chtimes(temporaryPath, client.scannedAt, client.scannedAt)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temporaryPath, client.cursorPath)
+}
+
+func (client *TCPClient) dial() (net.Conn, error) {
+	if client.socketPath != "" {
+		return net.Dial("unix", client.socketPath)
+	}
+
+	return net.Dial("tcp", net.JoinHostPort(client.address, strconv.FormatUint(uint64(client.port), 10)))
 }
@@ -1,12 +1,21 @@
 package infrastructure
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
 	"net"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
 )
 
 type Transaction struct {
@@ -14,63 +23,253 @@ type Transaction struct {
 	from   string
 }
 
+// purgeRequest asks the event loop to delete the disk of a user, and carries
+// back whether it could.
+type purgeRequest struct {
+	userID string
+	done   chan error
+}
+
 type TCPServer struct {
-	transactionQueue  chan *Transaction
-	connectionsQueue  chan net.Conn
-	maxConnections    uint
-	activeConnections map[string]*Connection
-	address           string
-	port              uint
+	transactionQueue      chan *Transaction
+	connectionsQueue      chan net.Conn
+	disconnectionQueue    chan string
+	revocationQueue       chan string
+	purgeQueue            chan purgeRequest
+	maxConnections        uint
+	maxConnectionsPerUser uint
+	retryAfter            time.Duration
+	idleTimeout           time.Duration
+	maxSessionAge         time.Duration
+	sessionCheckInterval  time.Duration
+	sessions              map[string]*session
+	tokenVerifier         ports.AccessTokenVerifier
+	deviceAuthenticator   ports.DeviceAuthenticator
+	shaper                *BandwidthShaper
+	disks                 map[string]*models.Disk
+	disksMutex            sync.RWMutex
+	stagingAreas          map[string]*StagingArea
+	stagingMaxAge         time.Duration
+	cleanupInterval       time.Duration
+	journal               ports.ChangeJournal
+	journalRetention      time.Duration
+	events                ports.EventPublisher
+	metrics               *Metrics
+	activeSessions        atomic.Int64
+	logger                *slog.Logger
+	quotaLevels           map[string]models.EventType
+	address               string
+	port                  uint
+	socketPath            string
+	socketMode            os.FileMode
 }
 
 type TCPServerConfig struct {
 	maxConnections        uint
+	maxConnectionsPerUser uint
+	retryAfter            time.Duration
+	idleTimeout           time.Duration
+	maxSessionAge         time.Duration
+	sessionCheckInterval  time.Duration
+	bandwidthLimits       models.BandwidthLimits
 	maxQueuedTransactions uint
 	maxQueuedConnections  uint
+	stagingMaxAge         time.Duration
+	cleanupInterval       time.Duration
+	journal               ports.ChangeJournal
+	journalRetention      time.Duration
+	events                ports.EventPublisher
+	metrics               *Metrics
 	address               string
 	port                  uint
+	socketPath            string
+	socketMode            os.FileMode
 }
 
 func NewDefaultTCPServerConfig(host string, port uint) *TCPServerConfig {
 	return &TCPServerConfig{
 		maxConnections:        DEFAULT_MAX_CONNECTIONS,
+		maxConnectionsPerUser: DEFAULT_MAX_CONNECTIONS_PER_USER,
+		retryAfter:            DEFAULT_RETRY_AFTER_MS * time.Millisecond,
+		idleTimeout:           DEFAULT_IDLE_TIMEOUT_MS * time.Millisecond,
+		maxSessionAge:         DEFAULT_MAX_SESSION_AGE_MS * time.Millisecond,
+		sessionCheckInterval:  DEFAULT_SESSION_CHECK_INTERVAL_MS * time.Millisecond,
 		maxQueuedConnections:  DEFAULT_MAX_QUEUED_CONNECTIONS,
 		maxQueuedTransactions: DEFAULT_MAX_QUEUED_SERVER_TRANSACTIONS,
+		stagingMaxAge:         DEFAULT_STAGING_MAX_AGE_MS * time.Millisecond,
+		cleanupInterval:       DEFAULT_STAGING_CLEANUP_INTERVAL_MS * time.Millisecond,
+		journalRetention:      DEFAULT_JOURNAL_DELETE_RETENTION_MS * time.Millisecond,
 		address:               host,
 		port:                  port,
 	}
 }
 
+// SetSessionLimits overrides how long a session may stay without receiving
+// frames and how long it may last overall. A zero value keeps the default and a
+// negative value disables the limit.
+func (config *TCPServerConfig) SetSessionLimits(idleTimeout time.Duration, maxSessionAge time.Duration) {
+	if idleTimeout != 0 {
+		config.idleTimeout = idleTimeout
+	}
+
+	if maxSessionAge != 0 {
+		config.maxSessionAge = maxSessionAge
+	}
+}
+
+// SetSessionCheckInterval overrides how often the sessions are checked against
+// their limits. A zero value keeps the default.
+func (config *TCPServerConfig) SetSessionCheckInterval(interval time.Duration) {
+	if interval != 0 {
+		config.sessionCheckInterval = interval
+	}
+}
+
+// SetUnixSocket makes the server also accept connections on the Unix socket at
+// path. Only the users allowed by mode may connect to it.
+func (config *TCPServerConfig) SetUnixSocket(path string, mode os.FileMode) {
+	config.socketPath = path
+	config.socketMode = mode
+}
+
+// SetChangeJournal makes the server record the changes applied to the disks
+// in journal instead of files under SDISK_ROOT.
+func (config *TCPServerConfig) SetChangeJournal(journal ports.ChangeJournal) {
+	config.journal = journal
+}
+
+// SetEventPublisher makes the server publish the file, disk and quota events
+// of every user to events.
+func (config *TCPServerConfig) SetEventPublisher(events ports.EventPublisher) {
+	config.events = events
+}
+
+// SetMetrics makes the server report its sessions, traffic, queues, errors
+// and disk usage in metrics.
+func (config *TCPServerConfig) SetMetrics(metrics *Metrics) {
+	config.metrics = metrics
+}
+
+func (config *TCPServerConfig) SetBandwidthLimits(limits models.BandwidthLimits) {
+	config.bandwidthLimits = limits
+}
+
 func NewTCPServer(config *TCPServerConfig) *TCPServer {
 	if config == nil || config.maxQueuedConnections == 0 || config.maxQueuedTransactions == 0 {
 		return nil
 	}
 
-	return &TCPServer{
-		transactionQueue:  make(chan *Transaction, config.maxQueuedTransactions),
-		connectionsQueue:  make(chan net.Conn, config.maxQueuedConnections),
-		activeConnections: make(map[string]*Connection),
-		maxConnections:    config.maxConnections,
-		address:           config.address,
-		port:              config.port,
+	journal := config.journal
+	if journal == nil {
+		journal = NewFileJournal(filepath.Join(os.Getenv("SDISK_ROOT"), JOURNAL_DIRECTORY_NAME))
+	}
+
+	server := &TCPServer{
+		transactionQueue:      make(chan *Transaction, config.maxQueuedTransactions),
+		connectionsQueue:      make(chan net.Conn, config.maxQueuedConnections),
+		disconnectionQueue:    make(chan string, config.maxQueuedConnections),
+		revocationQueue:       make(chan string, config.maxQueuedConnections),
+		purgeQueue:            make(chan purgeRequest, config.maxQueuedConnections),
+		sessions:              make(map[string]*session),
+		shaper:                NewBandwidthShaper(config.bandwidthLimits),
+		disks:                 make(map[string]*models.Disk),
+		stagingAreas:          make(map[string]*StagingArea),
+		stagingMaxAge:         config.stagingMaxAge,
+		cleanupInterval:       config.cleanupInterval,
+		journal:               journal,
+		journalRetention:      config.journalRetention,
+		events:                config.events,
+		metrics:               config.metrics,
+		logger:                slog.Default(),
+		quotaLevels:           make(map[string]models.EventType),
+		maxConnections:        config.maxConnections,
+		maxConnectionsPerUser: config.maxConnectionsPerUser,
+		retryAfter:            config.retryAfter,
+		idleTimeout:           config.idleTimeout,
+		maxSessionAge:         config.maxSessionAge,
+		sessionCheckInterval:  config.sessionCheckInterval,
+		address:               config.address,
+		port:                  config.port,
+		socketPath:            config.socketPath,
+		socketMode:            config.socketMode,
+	}
+
+	if server.metrics != nil {
+		server.registerMetrics()
 	}
+
+	return server
 }
 
-func (server *TCPServer) Run() {
-	go server.connectionWorker()
+// SetConnectionLimits overrides the maximum number of connections overall and
+// for a single user. A zero value keeps the default.
+func (config *TCPServerConfig) SetConnectionLimits(maxConnections uint, maxConnectionsPerUser uint) {
+	if maxConnections != 0 {
+		config.maxConnections = maxConnections
+	}
+
+	if maxConnectionsPerUser != 0 {
+		config.maxConnectionsPerUser = maxConnectionsPerUser
+	}
+}
+
+// Run listens for connections, then handles them until the process exits. It
+// only returns if a listener cannot be opened.
+func (server *TCPServer) Run() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", server.address, server.port))
+	if err != nil {
+		return err
+	}
+	go server.acceptConnections(listener)
+
+	if server.socketPath != "" {
+		socketListener, err := ListenUnixSocket(server.socketPath, server.socketMode)
+		if err != nil {
+			_ = listener.Close()
+			return err
+		}
+		go server.acceptConnections(socketListener)
+	}
+
+	cleanupTicker := time.NewTicker(server.cleanupInterval)
+	defer cleanupTicker.Stop()
+	sessionTicker := time.NewTicker(server.sessionCheckInterval)
+	defer sessionTicker.Stop()
 
 	for {
 		select {
+		case <-cleanupTicker.C:
+			server.cleanStagingAreas()
+			server.compactJournals()
+
+		case <-sessionTicker.C:
+			server.expireSessions()
+
 		case conn := <-server.connectionsQueue:
 			err := server.addConnection(conn)
 			if err != nil {
-				fmt.Println(err)
+				server.logger.Warn("rejected connection", "remote", conn.RemoteAddr().String(), "error", err)
 			}
 
+		case id := <-server.disconnectionQueue:
+			server.removeConnection(id)
+
+		case credentialID := <-server.revocationQueue:
+			server.dropCredential(credentialID)
+
+		case request := <-server.purgeQueue:
+			err := server.purgeDisk(request.userID)
+			if err != nil {
+				server.logger.Error("could not delete disk", "user", request.userID, "error", err)
+			}
+			request.done <- err
+
 		case transaction := <-server.transactionQueue:
 			err := server.handlePacket(transaction)
 			if err != nil {
-				fmt.Println(err)
+				server.logger.Warn("could not handle packet", server.transactionAttrs(transaction, "error", err)...)
+				server.countError(err)
+				server.reportError(transaction, err)
 			}
 		}
 	}
@@ -94,14 +293,17 @@ func (server *TCPServer) PrepareDisk(disk *models.Disk, user *models.User) error
 	idAsBytes := userID.Bytes()
 	copy(header.id[:], idAsBytes)
 
+	server.disksMutex.Lock()
+	server.disks[userID.ToString()] = disk
+	server.disksMutex.Unlock()
+
 	packet := new(Packet)
 	packet.Header = header
 	packet.Payload = raw
 
 	transaction := Transaction{
 		packet: packet,
-		from:   "localhost",
-		//withId: userID.ToString(), TODO?
+		from:   LOCAL_TRANSACTION,
 	}
 
 	server.transactionQueue <- &transaction
@@ -109,44 +311,77 @@ func (server *TCPServer) PrepareDisk(disk *models.Disk, user *models.User) error
 	return nil
 }
 
-func (server *TCPServer) connectionWorker() {
-	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", server.address, server.port))
+// DeleteDisk disconnects a user, then removes the files, staged uploads and
+// journal of their disk for good. It returns once they are removed, or with
+// the error that stopped it, so the deletion can be tried again.
+func (server *TCPServer) DeleteDisk(user *models.User) error {
+	userID := user.GetID()
+	request := purgeRequest{userID: userID.ToString(), done: make(chan error, 1)}
+	server.purgeQueue <- request
+	return <-request.done
+}
 
-	if err != nil {
-		panic(err)
-	}
+func (server *TCPServer) SetBandwidthLimits(limits models.BandwidthLimits) error {
+	server.shaper.SetLimits(limits)
+	return nil
+}
+
+func (server *TCPServer) GetBandwidthStats() models.BandwidthStats {
+	return server.shaper.Stats()
+}
 
+// acceptConnections hands every connection accepted by listener to the event
+// loop, so all listeners share the same sessions and limits.
+func (server *TCPServer) acceptConnections(listener net.Listener) {
 	for {
 		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
 		if err != nil {
-			panic(err)
+			server.logger.Error("could not accept connection", "listener", listener.Addr().String(), "error", err)
+			time.Sleep(DEFAULT_ACCEPT_RETRY_MS * time.Millisecond)
+			continue
 		}
 
 		server.connectionsQueue <- conn
 	}
 }
 
-func (server *TCPServer) addConnection(conn net.Conn) error {
-	if len(server.activeConnections) >= int(server.maxConnections) {
-		return &ErrMaximumClientsReached{}
+func (server *TCPServer) handlePacket(transaction *Transaction) error {
+	if transaction.from != LOCAL_TRANSACTION {
+		switch transaction.packet.Header.Opcode {
+		case Authenticate:
+			return server.authenticate(transaction)
+		case AuthenticateDevice:
+			if server.deviceAuthenticator != nil {
+				return server.authenticateDevice(transaction)
+			}
+		}
 	}
 
-	conf := NewDefaultConnectionConfig(conn, server.transactionQueue)
-	connection := NewConnection(conf)
-	server.activeConnections[conn.LocalAddr().String()] = connection
-	go connection.Read()
-
-	return nil
-}
+	if transaction.from != LOCAL_TRANSACTION {
+		err := server.bindSession(transaction)
+		if err != nil {
+			return err
+		}
+	}
 
-func (server *TCPServer) handlePacket(transaction *Transaction) error {
 	switch transaction.packet.Header.Opcode {
 	case PrepareDisk:
+		if transaction.from != LOCAL_TRANSACTION {
+			break
+		}
 		return server.prepareDisk(transaction)
 	case UpdateData:
 		return server.updateData(transaction)
 	case PullData:
 		return server.pullData(transaction)
+	case DeleteData:
+		return server.deleteData(transaction)
+	case Disconnect:
+		return server.disconnectData(transaction)
 	}
 
 	return &ErrUnknownPacket{Opcode: uint8(transaction.packet.Header.Opcode)}
@@ -167,11 +402,24 @@ func (server *TCPServer) prepareDisk(transaction *Transaction) error {
 
 	userDiskPath := os.Getenv("SDISK_ROOT") + "/" + userID.ToString()
 
-	err = os.Mkdir(userDiskPath, 0777)
+	err = os.MkdirAll(userDiskPath, 0777)
 	if err != nil {
 		return err
 	}
 
+	disk := server.getDisk(userID)
+	if disk == nil {
+		return &ErrUserHasNoDisk{}
+	}
+
+	server.publish(models.Event{
+		Type:      models.EventDiskCreated,
+		UserID:    userID,
+		UsedBytes: disk.GetUsedBytes(),
+		DiskBytes: disk.GetTotalBytes(),
+	})
+	server.checkQuota(userID, disk, "")
+
 	return nil
 }
 
@@ -194,40 +442,141 @@ func (server *TCPServer) updateData(transaction *Transaction) error {
 		return &ErrUserHasNoDisk{}
 	}
 
-	filePath := userDiskPath + updateDataPayload.Path
-	dirPath := filepath.Dir(filePath)
+	disk := server.getDisk(userID)
+	if disk == nil {
+		return &ErrUserHasNoDisk{}
+	}
 
-	err = os.MkdirAll(dirPath, 0777)
+	filePath, err := ResolveSyncPath(userDiskPath, updateDataPayload.Path)
 	if err != nil {
 		return err
 	}
 
-	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	chunkSize := uint64(len(updateDataPayload.FileData))
+	err = disk.Allocate(chunkSize)
 	if err != nil {
+		server.quotaExceeded(userID, disk, updateDataPayload.Path)
 		return err
 	}
 
-	defer file.Close()
+	result, err := server.getStagingArea(userID).Stage(filePath, &updateDataPayload)
+	disk.Release(chunkSize - result.Written + result.Released)
+
+	if err != nil || !result.Completed {
+		return err
+	}
 
-	if updateDataPayload.Offset != 0 {
-		seeked, err := file.Seek(int64(updateDataPayload.Offset), 0)
+	hash := updateDataPayload.Hash
+	if len(hash) == 0 {
+		hash, err = hashFile(filePath)
 		if err != nil {
 			return err
 		}
-		if seeked != int64(updateDataPayload.Offset) {
-			return &ErrUnexpectedFileState{}
-		}
 	}
 
-	_, err = file.Write(updateDataPayload.FileData)
+	change := models.Change{
+		Op:     models.ChangeUpdate,
+		Path:   updateDataPayload.Path,
+		Size:   updateDataPayload.Total,
+		Hash:   hex.EncodeToString(hash),
+		Device: server.originOf(transaction.from),
+	}
 
+	// A client sending a file the disk already has, after reconnecting for
+	// instance, changes nothing that other clients need to know about.
+	latest, ok, err := server.journal.Latest(userID, change.Path)
 	if err != nil {
 		return err
 	}
 
+	if ok && latest.Op == models.ChangeUpdate && latest.Hash == change.Hash {
+		return nil
+	}
+
+	err = server.recordChange(userID, change)
+	if err != nil {
+		return err
+	}
+
+	server.publish(models.Event{
+		Type:      models.EventFileUpdated,
+		UserID:    userID,
+		Path:      change.Path,
+		Size:      change.Size,
+		Hash:      change.Hash,
+		UsedBytes: disk.GetUsedBytes(),
+		DiskBytes: disk.GetTotalBytes(),
+	})
+	server.checkQuota(userID, disk, change.Path)
+
 	return nil
 }
 
+func (server *TCPServer) deleteData(transaction *Transaction) error {
+	var deleteDataPayload DeleteDataPayload
+	err := deleteDataPayload.FromBytes(transaction.packet.Payload)
+
+	if err != nil {
+		return err
+	}
+
+	userID, err := models.FromBytes(transaction.packet.Header.id[:])
+	if err != nil {
+		return err
+	}
+
+	disk := server.getDisk(userID)
+	if disk == nil {
+		return &ErrUserHasNoDisk{}
+	}
+
+	userDiskPath := os.Getenv("SDISK_ROOT") + "/" + userID.ToString()
+	filePath, err := ResolveSyncPath(userDiskPath, deleteDataPayload.Path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return &ErrUnexpectedFileState{}
+	}
+
+	err = os.Remove(filePath)
+	if err != nil {
+		return err
+	}
+
+	disk.Release(uint64(info.Size()))
+
+	err = server.recordChange(userID, models.Change{
+		Op:     models.ChangeDelete,
+		Path:   deleteDataPayload.Path,
+		Device: server.originOf(transaction.from),
+	})
+	if err != nil {
+		return err
+	}
+
+	server.publish(models.Event{
+		Type:      models.EventFileDeleted,
+		UserID:    userID,
+		Path:      deleteDataPayload.Path,
+		Size:      uint64(info.Size()),
+		UsedBytes: disk.GetUsedBytes(),
+		DiskBytes: disk.GetTotalBytes(),
+	})
+	server.checkQuota(userID, disk, deleteDataPayload.Path)
+
+	return nil
+}
+
+// pullData sends the changes made after the cursor of the packet, or the whole
+// disk when it has none or the cursor cannot be resumed from, then the cursor
+// to resume from next time.
 func (server *TCPServer) pullData(transaction *Transaction) error {
 	userID, err := models.FromBytes(transaction.packet.Header.id[:])
 	if err != nil {
@@ -240,16 +589,382 @@ func (server *TCPServer) pullData(transaction *Transaction) error {
 		return &ErrUserHasNoDisk{}
 	}
 
-	files := walkDirectory(userDiskPath)
-
-	conn := server.activeConnections[transaction.from]
+	conn := server.getConnection(transaction.from)
 	if conn == nil {
 		return &ErrDisconnected{}
 	}
 
+	from := server.originOf(transaction.from)
+	cursor, err := server.journal.Cursor(userID)
+	if err != nil {
+		return err
+	}
+
+	var changes []models.Change
+	resumed := false
+
+	if len(transaction.packet.Payload) > 0 {
+		var pullDataPayload PullDataPayload
+		err := pullDataPayload.FromBytes(transaction.packet.Payload)
+		if err != nil {
+			return err
+		}
+
+		changes, cursor, err = server.journal.Since(userID, pullDataPayload.Cursor)
+		switch err.(type) {
+		case nil:
+			resumed = true
+		case *models.ErrCursorExpired:
+		default:
+			return err
+		}
+	}
+
+	// Files are sent by the writer of the connection, so the other sessions are
+	// not held up while a large disk is sent at the bandwidth of this one.
+	return conn.Queue(func() error {
+		var err error
+		if resumed {
+			err = sendChanges(userID, userDiskPath, changes, from, conn)
+		} else {
+			err = sendDisk(userID, userDiskPath, conn)
+		}
+
+		if err != nil {
+			server.logger.Warn("could not send pulled data", server.transactionAttrs(transaction, "error", err)...)
+			server.countError(err)
+			packet, err := errorReportOf(transaction, err)
+			if err != nil {
+				return err
+			}
+
+			_, err = conn.Write(packet.Bytes())
+			return err
+		}
+
+		syncCursorPayload := SyncCursorPayload{Cursor: cursor}
+		packet, err := newPacket(SyncCursor, userID.Bytes(), syncCursorPayload.Bytes())
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.Write(packet.Bytes())
+		return err
+	})
+}
+
+func sendDisk(userID models.UserID, userDiskPath string, conn *Connection) error {
+	files := walkDirectory(userDiskPath)
+
 	for _, file := range files {
-		sendFile(&file, userDiskPath, conn, userID)
+		err := sendFile(&file, userDiskPath, conn, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendChanges sends the current state of every path changed by another
+// origin than the one pulling, which already has its own changes.
+func sendChanges(userID models.UserID, userDiskPath string, changes []models.Change, from string, conn *Connection) error {
+	latest := make(map[string]models.Change)
+	var paths []string
+
+	for _, change := range changes {
+		if _, ok := latest[change.Path]; !ok {
+			paths = append(paths, change.Path)
+		}
+		latest[change.Path] = change
+	}
+
+	for _, path := range paths {
+		change := latest[path]
+		if change.Device == from {
+			continue
+		}
+
+		filePath, err := ResolveSyncPath(userDiskPath, path)
+		if err != nil {
+			continue
+		}
+
+		info, err := os.Lstat(filePath)
+		if err != nil {
+			deleteDataPayload := DeleteDataPayload{Path: path}
+			packet, err := newPacket(DeleteData, userID.Bytes(), deleteDataPayload.Bytes())
+			if err != nil {
+				return err
+			}
+
+			_, err = conn.Write(packet.Bytes())
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		file := FileToSend{path: filePath, entry: fs.FileInfoToDirEntry(info)}
+		err = sendFile(&file, userDiskPath, conn, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (server *TCPServer) purgeDisk(id string) error {
+	userID, err := models.FromString(id)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range server.sessions {
+		if s.userID != nil && *s.userID == userID {
+			server.disconnect(s, DisconnectReasonRevoked, 0, "the account was deleted")
+		}
+	}
+
+	server.disksMutex.Lock()
+	delete(server.disks, id)
+	server.disksMutex.Unlock()
+	delete(server.stagingAreas, id)
+	delete(server.quotaLevels, id)
+
+	root := os.Getenv("SDISK_ROOT")
+	err = os.RemoveAll(filepath.Join(root, STAGING_DIRECTORY_NAME, id))
+	if err != nil {
+		return err
+	}
+
+	err = os.RemoveAll(filepath.Join(root, id))
+	if err != nil {
+		return err
+	}
+
+	err = server.journal.Delete(userID)
+	if err != nil {
+		return err
 	}
 
+	server.logger.Info("disk deleted", "user", id)
 	return nil
 }
+
+// getDisk returns the disk of the user, with its usage measured on the file
+// system the first time it is needed, since the files of the user may have
+// been written before the server started.
+func (server *TCPServer) getDisk(userID models.UserID) *models.Disk {
+	server.disksMutex.RLock()
+	disk := server.disks[userID.ToString()]
+	server.disksMutex.RUnlock()
+
+	if disk == nil || disk.IsMeasured() {
+		return disk
+	}
+
+	err := server.measureDisk(userID, disk)
+	if err != nil {
+		server.logger.Error("could not measure disk usage", "user", userID.ToString(), "error", err)
+	}
+
+	return disk
+}
+
+// measureDisk sets the usage of disk to the size of the files and staged
+// uploads of the user.
+func (server *TCPServer) measureDisk(userID models.UserID, disk *models.Disk) error {
+	used, err := directorySize(filepath.Join(os.Getenv("SDISK_ROOT"), userID.ToString()))
+	if err != nil {
+		return err
+	}
+
+	staged, err := server.getStagingArea(userID).Size()
+	if err != nil {
+		return err
+	}
+
+	disk.SetUsedBytes(used + staged)
+	return nil
+}
+
+func (server *TCPServer) getStagingArea(userID models.UserID) *StagingArea {
+	id := userID.ToString()
+	stagingArea, ok := server.stagingAreas[id]
+
+	if !ok {
+		stagingArea = NewStagingArea(filepath.Join(os.Getenv("SDISK_ROOT"), STAGING_DIRECTORY_NAME, id))
+		server.stagingAreas[id] = stagingArea
+	}
+
+	return stagingArea
+}
+
+func (server *TCPServer) cleanStagingAreas() {
+	server.disksMutex.RLock()
+	defer server.disksMutex.RUnlock()
+
+	for id, disk := range server.disks {
+		userID, err := models.FromString(id)
+		if err != nil {
+			continue
+		}
+
+		released, err := server.getStagingArea(userID).Clean(server.stagingMaxAge)
+		if err != nil {
+			server.logger.Error("could not clean staging area", "user", id, "error", err)
+		}
+
+		disk.Release(released)
+	}
+}
+
+func (server *TCPServer) recordChange(userID models.UserID, change models.Change) error {
+	change.Time = time.Now().UTC()
+	_, err := server.journal.Append(userID, change)
+	return err
+}
+
+func (server *TCPServer) publish(event models.Event) {
+	if server.events != nil {
+		server.events.Publish(event)
+	}
+}
+
+// checkQuota publishes a warning once the disk of the user gets close to full
+// and forgets about past warnings once enough space was freed.
+func (server *TCPServer) checkQuota(userID models.UserID, disk *models.Disk, path string) {
+	id := userID.ToString()
+	used := disk.GetUsedBytes()
+	total := disk.GetTotalBytes()
+
+	if float64(used) < float64(total)*models.QuotaWarningRatio {
+		delete(server.quotaLevels, id)
+		return
+	}
+
+	if _, ok := server.quotaLevels[id]; ok {
+		return
+	}
+
+	server.quotaLevels[id] = models.EventQuotaWarning
+	server.publish(models.Event{
+		Type:      models.EventQuotaWarning,
+		UserID:    userID,
+		Path:      path,
+		UsedBytes: used,
+		DiskBytes: total,
+	})
+}
+
+// quotaExceeded publishes that a write was refused because the disk of the
+// user is full, once until space is freed again.
+func (server *TCPServer) quotaExceeded(userID models.UserID, disk *models.Disk, path string) {
+	id := userID.ToString()
+	if server.quotaLevels[id] == models.EventQuotaExceeded {
+		return
+	}
+
+	server.quotaLevels[id] = models.EventQuotaExceeded
+	server.publish(models.Event{
+		Type:      models.EventQuotaExceeded,
+		UserID:    userID,
+		Path:      path,
+		UsedBytes: disk.GetUsedBytes(),
+		DiskBytes: disk.GetTotalBytes(),
+	})
+}
+
+func (server *TCPServer) compactJournals() {
+	server.disksMutex.RLock()
+	defer server.disksMutex.RUnlock()
+
+	for id := range server.disks {
+		userID, err := models.FromString(id)
+		if err != nil {
+			continue
+		}
+
+		err = server.journal.Compact(userID, server.journalRetention)
+		if err != nil {
+			server.logger.Error("could not compact journal", "user", id, "error", err)
+		}
+	}
+}
+
+func (server *TCPServer) reportError(transaction *Transaction, err error) {
+	conn := server.getConnection(transaction.from)
+	if conn == nil {
+		return
+	}
+
+	packet, err := errorReportOf(transaction, err)
+	if err != nil {
+		server.logger.Warn("could not report error", server.transactionAttrs(transaction, "error", err)...)
+		return
+	}
+
+	_ = conn.Send(packet.Bytes())
+}
+
+// errorReportOf returns the packet telling the sender of transaction that it
+// failed with err. The path and the message, which often repeats the path, are
+// truncated so the report always fits in a packet.
+func errorReportOf(transaction *Transaction, err error) (*Packet, error) {
+	reportErrorPayload := ReportErrorPayload{
+		Code: errorCodeOf(err),
+	}
+
+	switch transaction.packet.Header.Opcode {
+	case UpdateData:
+		var updateDataPayload UpdateDataPayload
+		if updateDataPayload.FromBytes(transaction.packet.Payload) == nil {
+			reportErrorPayload.Path = updateDataPayload.Path
+		}
+	case DeleteData:
+		var deleteDataPayload DeleteDataPayload
+		if deleteDataPayload.FromBytes(transaction.packet.Payload) == nil {
+			reportErrorPayload.Path = deleteDataPayload.Path
+		}
+	}
+
+	available := math.MaxUint16 - REPORT_ERROR_HEADER_SIZE
+	reportErrorPayload.Path = truncate(reportErrorPayload.Path, available)
+	reportErrorPayload.Message = truncate(err.Error(), available-len(reportErrorPayload.Path))
+
+	return newPacket(ReportError, transaction.packet.Header.id[:], reportErrorPayload.Bytes())
+}
+
+// transactionAttrs returns the attributes identifying transaction in logs,
+// followed by attrs.
+func (server *TCPServer) transactionAttrs(transaction *Transaction, attrs ...any) []any {
+	header := transaction.packet.Header
+	base := []any{"connection", transaction.from, "opcode", header.Opcode.String()}
+
+	userID, err := models.FromBytes(header.id[:])
+	if err == nil {
+		base = append(base, "user", userID.ToString())
+	}
+
+	return append(base, attrs...)
+}
+
+func errorCodeOf(err error) ErrorCode {
+	switch err.(type) {
+	case *models.ErrDiskQuotaExceeded:
+		return ErrorCodeQuotaExceeded
+	case *ErrUserHasNoDisk:
+		return ErrorCodeNoDisk
+	case *ErrUnknownPacket:
+		return ErrorCodeUnknownPacket
+	case *ErrInvalidPath:
+		return ErrorCodeInvalidPath
+	case *ErrHashMismatch:
+		return ErrorCodeHashMismatch
+	}
+
+	return ErrorCodeInternal
+}
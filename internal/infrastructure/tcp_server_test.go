@@ -0,0 +1,489 @@
+package infrastructure_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/mocks"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestDeleteDisk(t *testing.T) {
+	t.Run("RemoveFilesOfDisk", func(t *testing.T) {
+		root := t.TempDir()
+		t.Setenv("SDISK_ROOT", root)
+		server := runServer(t, infrastructure.NewDefaultTCPServerConfig("127.0.0.1", 0))
+		user := models.NewUser("john_doe@test.com", "hash")
+		prepareDisk(t, server, user, 1024)
+		userID := user.GetID()
+		diskPath := filepath.Join(root, userID.ToString())
+		_ = os.WriteFile(filepath.Join(diskPath, "hello.txt"), []byte("hello world"), 0644)
+
+		err := server.DeleteDisk(user)
+
+		assertNoError(t, err)
+		assertFileDoesNotExist(t, diskPath)
+	})
+
+	t.Run("ReturnErrorWhenDiskCannotBeDeleted", func(t *testing.T) {
+		t.Setenv("SDISK_ROOT", t.TempDir())
+		config := infrastructure.NewDefaultTCPServerConfig("127.0.0.1", 0)
+		config.SetChangeJournal(&mocks.ChangeJournalMock{FnDelete: func(userID models.UserID) error {
+			return errors.New("read-only file system")
+		}})
+		server := runServer(t, config)
+
+		err := server.DeleteDisk(models.NewUser("john_doe@test.com", "hash"))
+
+		assertError(t, err)
+	})
+}
+
+func TestUpdateData(t *testing.T) {
+	t.Run("RecordUnchangedFileOnlyOnce", func(t *testing.T) {
+		root := t.TempDir()
+		t.Setenv("SDISK_ROOT", root)
+		server := runServer(t, infrastructure.NewDefaultTCPServerConfig("127.0.0.1", 0))
+		user := models.NewUser("john_doe@test.com", "hash")
+		prepareDisk(t, server, user, 1024)
+		client := connect(t, server, user.GetID())
+
+		client.send(t, infrastructure.UpdateData, updateDataOf("a.txt", []byte("hello")))
+		client.send(t, infrastructure.UpdateData, updateDataOf("a.txt", []byte("hello")))
+		client.send(t, infrastructure.PullData, nil)
+
+		var syncCursorPayload infrastructure.SyncCursorPayload
+		_ = syncCursorPayload.FromBytes(client.next(t, infrastructure.SyncCursor).Payload)
+		assertUint64Equals(t, syncCursorPayload.Cursor, 1)
+	})
+
+	t.Run("RejectUpdateOverQuotaWithoutKeepingIt", func(t *testing.T) {
+		root := t.TempDir()
+		t.Setenv("SDISK_ROOT", root)
+		server := runServer(t, infrastructure.NewDefaultTCPServerConfig("127.0.0.1", 0))
+		user := models.NewUser("john_doe@test.com", "hash")
+		userID := user.GetID()
+		diskPath := filepath.Join(root, userID.ToString())
+		_ = os.MkdirAll(diskPath, 0777)
+		_ = os.WriteFile(filepath.Join(diskPath, "full.bin"), make([]byte, models.BytesPerMiB), 0644)
+		prepareDisk(t, server, user, 1)
+		client := connect(t, server, userID)
+
+		client.send(t, infrastructure.UpdateData, updateDataOf("a.txt", []byte("hello")))
+
+		var reportErrorPayload infrastructure.ReportErrorPayload
+		_ = reportErrorPayload.FromBytes(client.next(t, infrastructure.ReportError).Payload)
+		if reportErrorPayload.Code != infrastructure.ErrorCodeQuotaExceeded {
+			t.Fatalf("Expected error code %d, got %d (%s)", infrastructure.ErrorCodeQuotaExceeded, reportErrorPayload.Code, reportErrorPayload.Message)
+		}
+		assertFileDoesNotExist(t, filepath.Join(diskPath, "a.txt"))
+		assertDirectoryIsEmpty(t, filepath.Join(root, infrastructure.STAGING_DIRECTORY_NAME, userID.ToString()))
+	})
+
+	t.Run("TruncateReportOfLongPathToFitInOnePacket", func(t *testing.T) {
+		t.Setenv("SDISK_ROOT", t.TempDir())
+		server := runServer(t, infrastructure.NewDefaultTCPServerConfig("127.0.0.1", 0))
+		user := models.NewUser("john_doe@test.com", "hash")
+		prepareDisk(t, server, user, 1)
+		client := connect(t, server, user.GetID())
+		path := strings.Repeat("a", 40000)
+
+		client.send(t, infrastructure.UpdateData, updateDataOf(path, []byte("hello")))
+
+		var reportErrorPayload infrastructure.ReportErrorPayload
+		err := reportErrorPayload.FromBytes(client.next(t, infrastructure.ReportError).Payload)
+		if err != nil {
+			t.Fatalf("Expected a complete report, got %s", err.Error())
+		}
+		if reportErrorPayload.Code != infrastructure.ErrorCodeInvalidPath {
+			t.Fatalf("Expected error code %d, got %d", infrastructure.ErrorCodeInvalidPath, reportErrorPayload.Code)
+		}
+		if reportErrorPayload.Path != path {
+			t.Fatalf("Expected the reported path to be the one sent")
+		}
+	})
+}
+
+func TestReceivePackets(t *testing.T) {
+	t.Run("HandleEveryPacketOfAFrame", func(t *testing.T) {
+		t.Setenv("SDISK_ROOT", t.TempDir())
+		server := runServer(t, infrastructure.NewDefaultTCPServerConfig("127.0.0.1", 0))
+		user := models.NewUser("john_doe@test.com", "hash")
+		prepareDisk(t, server, user, 1024)
+		client := connectWithoutDeadlines(t, server, user.GetID())
+
+		client.write(t, append(client.packetOf(infrastructure.UpdateData, updateDataOf("a.txt", []byte("hello"))), client.packetOf(infrastructure.PullData, nil)...))
+
+		var syncCursorPayload infrastructure.SyncCursorPayload
+		_ = syncCursorPayload.FromBytes(client.next(t, infrastructure.SyncCursor).Payload)
+		assertUint64Equals(t, syncCursorPayload.Cursor, 1)
+	})
+}
+
+func TestBandwidthLimits(t *testing.T) {
+	t.Run("KeepHandlingOtherUsersWhileDownloadIsThrottled", func(t *testing.T) {
+		root := t.TempDir()
+		t.Setenv("SDISK_ROOT", root)
+		config := infrastructure.NewDefaultTCPServerConfig("127.0.0.1", 0)
+		config.SetBandwidthLimits(models.BandwidthLimits{UserDownload: 1024})
+		server := runServer(t, config)
+		user := models.NewUser("john_doe@test.com", "hash")
+		prepareDisk(t, server, user, 1<<20)
+		userID := user.GetID()
+		_ = os.WriteFile(filepath.Join(root, userID.ToString(), "large.bin"), make([]byte, 16*1024), 0644)
+		client := connect(t, server, userID)
+		client.send(t, infrastructure.PullData, nil)
+		time.Sleep(50 * time.Millisecond)
+
+		started := time.Now()
+		err := server.DeleteDisk(models.NewUser("jane_doe@test.com", "hash"))
+
+		assertNoError(t, err)
+		if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+			t.Fatalf("Expected other users to be served while the download is throttled, waited %s", elapsed)
+		}
+	})
+
+	t.Run("ForgetTrafficOfUserOnceDisconnected", func(t *testing.T) {
+		t.Setenv("SDISK_ROOT", t.TempDir())
+		server := runServer(t, infrastructure.NewDefaultTCPServerConfig("127.0.0.1", 0))
+		user := models.NewUser("john_doe@test.com", "hash")
+		prepareDisk(t, server, user, 1024)
+		userID := user.GetID()
+		client := connect(t, server, userID)
+		client.send(t, infrastructure.UpdateData, updateDataOf("a.txt", []byte("hello")))
+		client.send(t, infrastructure.PullData, nil)
+		_ = client.next(t, infrastructure.SyncCursor)
+		waitFor(t, func() bool {
+			_, ok := server.GetBandwidthStats().Users[userID.ToString()]
+			return ok
+		})
+
+		_ = client.conn.Close()
+
+		waitFor(t, func() bool {
+			_, ok := server.GetBandwidthStats().Users[userID.ToString()]
+			return !ok
+		})
+	})
+}
+
+func TestConnectionLimits(t *testing.T) {
+	anyRetryAfter := uint32(infrastructure.DEFAULT_RETRY_AFTER_MS / 1000)
+
+	t.Run("TellClientOverMaxConnectionsServerIsBusy", func(t *testing.T) {
+		t.Setenv("SDISK_ROOT", t.TempDir())
+		config := infrastructure.NewDefaultTCPServerConfig("127.0.0.1", 0)
+		config.SetConnectionLimits(1, 1)
+		server := runServer(t, config)
+		user := models.NewUser("john_doe@test.com", "hash")
+		prepareDisk(t, server, user, 1024)
+		first := connect(t, server, user.GetID())
+		first.send(t, infrastructure.PullData, nil)
+		first.next(t, infrastructure.SyncCursor)
+
+		second := connect(t, server, models.NewUser("jane_doe@test.com", "hash").GetID())
+
+		disconnect := second.disconnection(t)
+		assertDisconnectReason(t, disconnect, infrastructure.DisconnectReasonServerBusy)
+		assertTrue(t, disconnect.RetryAfter == anyRetryAfter)
+		first.send(t, infrastructure.PullData, nil)
+		first.next(t, infrastructure.SyncCursor)
+	})
+
+	t.Run("TellClientOverMaxConnectionsOfUserThereAreTooMany", func(t *testing.T) {
+		t.Setenv("SDISK_ROOT", t.TempDir())
+		config := infrastructure.NewDefaultTCPServerConfig("127.0.0.1", 0)
+		config.SetConnectionLimits(8, 1)
+		server := runServer(t, config)
+		user := models.NewUser("john_doe@test.com", "hash")
+		prepareDisk(t, server, user, 1024)
+		first := connect(t, server, user.GetID())
+		first.send(t, infrastructure.PullData, nil)
+		first.next(t, infrastructure.SyncCursor)
+
+		second := connect(t, server, user.GetID())
+		second.send(t, infrastructure.PullData, nil)
+
+		disconnect := second.disconnection(t)
+		assertDisconnectReason(t, disconnect, infrastructure.DisconnectReasonTooManyConnections)
+		assertTrue(t, disconnect.RetryAfter == anyRetryAfter)
+		first.send(t, infrastructure.PullData, nil)
+		first.next(t, infrastructure.SyncCursor)
+	})
+
+	t.Run("AcceptConnectionsOfOtherUsersWhenOneIsAtItsMax", func(t *testing.T) {
+		t.Setenv("SDISK_ROOT", t.TempDir())
+		config := infrastructure.NewDefaultTCPServerConfig("127.0.0.1", 0)
+		config.SetConnectionLimits(8, 1)
+		server := runServer(t, config)
+		user := models.NewUser("john_doe@test.com", "hash")
+		other := models.NewUser("jane_doe@test.com", "hash")
+		prepareDisk(t, server, user, 1024)
+		prepareDisk(t, server, other, 1024)
+		first := connect(t, server, user.GetID())
+		first.send(t, infrastructure.PullData, nil)
+		first.next(t, infrastructure.SyncCursor)
+
+		second := connect(t, server, other.GetID())
+		second.send(t, infrastructure.PullData, nil)
+
+		second.next(t, infrastructure.SyncCursor)
+	})
+}
+
+func TestSessionLimits(t *testing.T) {
+	newConfig := func(idleTimeout time.Duration, maxSessionAge time.Duration) *infrastructure.TCPServerConfig {
+		config := infrastructure.NewDefaultTCPServerConfig("127.0.0.1", 0)
+		config.SetSessionLimits(idleTimeout, maxSessionAge)
+		config.SetSessionCheckInterval(10 * time.Millisecond)
+		return config
+	}
+
+	t.Run("DisconnectIdleSession", func(t *testing.T) {
+		t.Setenv("SDISK_ROOT", t.TempDir())
+		server := runServer(t, newConfig(50*time.Millisecond, -1))
+		client := connect(t, server, models.NewUser("john_doe@test.com", "hash").GetID())
+		client.send(t, infrastructure.PullData, nil)
+
+		disconnect := client.disconnection(t)
+
+		assertDisconnectReason(t, disconnect, infrastructure.DisconnectReasonIdleTimeout)
+		assertTrue(t, disconnect.RetryAfter > 0)
+	})
+
+	t.Run("DisconnectSessionOlderThanMaxAge", func(t *testing.T) {
+		t.Setenv("SDISK_ROOT", t.TempDir())
+		server := runServer(t, newConfig(-1, 50*time.Millisecond))
+		client := connect(t, server, models.NewUser("john_doe@test.com", "hash").GetID())
+		client.send(t, infrastructure.PullData, nil)
+
+		disconnect := client.disconnection(t)
+
+		assertDisconnectReason(t, disconnect, infrastructure.DisconnectReasonSessionExpired)
+		assertTrue(t, disconnect.RetryAfter == 0)
+	})
+
+	t.Run("KeepSessionOpenWhenLimitsAreDisabled", func(t *testing.T) {
+		t.Setenv("SDISK_ROOT", t.TempDir())
+		server := runServer(t, newConfig(-1, -1))
+		user := models.NewUser("john_doe@test.com", "hash")
+		prepareDisk(t, server, user, 1024)
+		client := connect(t, server, user.GetID())
+		time.Sleep(100 * time.Millisecond)
+
+		client.send(t, infrastructure.PullData, nil)
+
+		client.next(t, infrastructure.SyncCursor)
+	})
+}
+
+// runServer starts server on a free port and returns it once it handles
+// requests.
+func runServer(t *testing.T, config *infrastructure.TCPServerConfig) *infrastructure.TCPServer {
+	t.Helper()
+
+	server := infrastructure.NewTCPServer(config)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Run()
+	}()
+
+	select {
+	case err := <-errs:
+		t.Fatalf("Could not run server: %s", err.Error())
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	return server
+}
+
+func updateDataOf(path string, content []byte) []byte {
+	sum := sha256.Sum256(content)
+	updateDataPayload := infrastructure.UpdateDataPayload{
+		Total:    uint64(len(content)),
+		PathLen:  uint64(len(path)),
+		HashLen:  uint64(len(sum)),
+		Path:     path,
+		Hash:     sum[:],
+		FileData: content,
+	}
+
+	raw, _ := updateDataPayload.Bytes()
+	return raw
+}
+
+func prepareDisk(t *testing.T, server *infrastructure.TCPServer, user *models.User, size uint64) *models.Disk {
+	t.Helper()
+
+	disk := models.NewDisk(size)
+	userID := user.GetID()
+	diskPath := filepath.Join(os.Getenv("SDISK_ROOT"), userID.ToString())
+	_ = server.PrepareDisk(disk, user)
+	waitFor(t, func() bool {
+		_, err := os.Stat(diskPath)
+		return err == nil
+	})
+
+	return disk
+}
+
+// testClient is a connection served by a server. It sends packets of its user
+// as raw bytes and collects the packets sent back.
+type testClient struct {
+	conn     net.Conn
+	userID   models.UserID
+	received chan *infrastructure.Packet
+}
+
+func connect(t *testing.T, server *infrastructure.TCPServer, userID models.UserID) *testClient {
+	t.Helper()
+
+	conn, served := net.Pipe()
+	return serve(t, server, served, conn, userID)
+}
+
+// connectWithoutDeadlines connects like connect, through a transport that
+// ignores read deadlines, like WebSocket.
+func connectWithoutDeadlines(t *testing.T, server *infrastructure.TCPServer, userID models.UserID) *testClient {
+	t.Helper()
+
+	conn, served := net.Pipe()
+	return serve(t, server, &noDeadlineConn{Conn: served}, conn, userID)
+}
+
+func serve(t *testing.T, server *infrastructure.TCPServer, served net.Conn, conn net.Conn, userID models.UserID) *testClient {
+	t.Helper()
+
+	go server.ServeConn(served)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	client := &testClient{conn: conn, userID: userID, received: make(chan *infrastructure.Packet, 64)}
+	go client.receive()
+	return client
+}
+
+func (c *testClient) send(t *testing.T, opcode infrastructure.PacketOpcode, payload []byte) {
+	t.Helper()
+
+	c.write(t, c.packetOf(opcode, payload))
+}
+
+// write sends raw as a single frame.
+func (c *testClient) write(t *testing.T, raw []byte) {
+	t.Helper()
+
+	_, err := c.conn.Write(raw)
+	if err != nil {
+		t.Fatalf("Could not send packet: %s", err.Error())
+	}
+}
+
+func (c *testClient) packetOf(opcode infrastructure.PacketOpcode, payload []byte) []byte {
+	raw := make([]byte, infrastructure.HEADER_SIZE, infrastructure.HEADER_SIZE+len(payload))
+	raw[0] = infrastructure.VERSION
+	raw[1] = byte(opcode)
+	raw[2] = byte(infrastructure.EncodingNone)
+	copy(raw[3:19], c.userID.Bytes())
+	binary.BigEndian.PutUint16(raw[19:infrastructure.HEADER_SIZE], uint16(len(payload)))
+
+	return append(raw, payload...)
+}
+
+func (c *testClient) receive() {
+	defer close(c.received)
+
+	for {
+		raw := make([]byte, infrastructure.HEADER_SIZE)
+		_, err := io.ReadFull(c.conn, raw)
+		if err != nil {
+			return
+		}
+
+		payload := make([]byte, binary.BigEndian.Uint16(raw[19:infrastructure.HEADER_SIZE]))
+		_, err = io.ReadFull(c.conn, payload)
+		if err != nil {
+			return
+		}
+
+		var packet infrastructure.Packet
+		if packet.FromBytes(append(raw, payload...)) == nil {
+			c.received <- &packet
+		}
+	}
+}
+
+// next returns the next packet with opcode sent to the client, skipping the
+// others.
+func (c *testClient) next(t *testing.T, opcode infrastructure.PacketOpcode) *infrastructure.Packet {
+	t.Helper()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case packet, ok := <-c.received:
+			if !ok {
+				t.Fatalf("Expected a %s packet, the connection was closed", opcode.String())
+			}
+
+			if packet.Header.Opcode == opcode {
+				return packet
+			}
+		case <-timeout:
+			t.Fatalf("Expected a %s packet within a second", opcode.String())
+		}
+	}
+}
+
+type noDeadlineConn struct {
+	net.Conn
+}
+
+func (c *noDeadlineConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+// disconnection returns the payload of the next Disconnect packet sent to the
+// client.
+func (c *testClient) disconnection(t *testing.T) *infrastructure.DisconnectPayload {
+	t.Helper()
+
+	var disconnectPayload infrastructure.DisconnectPayload
+	err := disconnectPayload.FromBytes(c.next(t, infrastructure.Disconnect).Payload)
+	if err != nil {
+		t.Fatalf("Could not read Disconnect packet: %s", err.Error())
+	}
+
+	return &disconnectPayload
+}
+
+func assertDisconnectReason(t *testing.T, disconnect *infrastructure.DisconnectPayload, expected infrastructure.DisconnectReason) {
+	t.Helper()
+
+	if disconnect.Reason != expected {
+		t.Fatalf("Expected disconnection reason %d, got %d (%s)", expected, disconnect.Reason, disconnect.Message)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected condition to be met within a second")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
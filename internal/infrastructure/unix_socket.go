@@ -0,0 +1,77 @@
+package infrastructure
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// ListenUnixSocket listens on the Unix socket at path and restricts who may
+// connect to it with mode. A socket left behind by a previous run is replaced,
+// but a socket still in use or any other kind of file is never removed.
+func ListenUnixSocket(path string, mode os.FileMode) (net.Listener, error) {
+	info, err := os.Lstat(path)
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	case info.Mode().Type() != fs.ModeSocket:
+		return nil, &ErrNotASocket{Path: path}
+	default:
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			_ = conn.Close()
+			return nil, &ErrSocketInUse{Path: path}
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The socket is created in a directory only the server may enter, so
+	// nobody can connect to it before it gets its mode, then moved in place.
+	private, err := os.MkdirTemp(filepath.Dir(path), ".sdisk-")
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.RemoveAll(private)
+
+	privatePath := filepath.Join(private, filepath.Base(path))
+	listener, err := net.Listen("unix", privatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	err = os.Chmod(privatePath, mode)
+	if err == nil {
+		err = os.Rename(privatePath, path)
+	}
+
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	return &unixSocketListener{Listener: listener, path: path}, nil
+}
+
+// unixSocketListener removes its socket once closed, since the socket was
+// moved away from the path it was created at.
+type unixSocketListener struct {
+	net.Listener
+	path string
+}
+
+func (l *unixSocketListener) Close() error {
+	err := l.Listener.Close()
+	_ = os.Remove(l.path)
+	return err
+}
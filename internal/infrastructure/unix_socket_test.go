@@ -0,0 +1,79 @@
+package infrastructure_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	t.Run("RestrictSocketToMode", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sdisk.sock")
+
+		listener, err := infrastructure.ListenUnixSocket(path, 0600)
+
+		assertNoError(t, err)
+		defer listener.Close()
+		info, _ := os.Stat(path)
+		if info.Mode().Perm() != 0600 {
+			t.Fatalf("Expected mode %o, got %o", 0600, info.Mode().Perm())
+		}
+	})
+
+	t.Run("LeaveOnlySocketInDirectory", func(t *testing.T) {
+		directory := t.TempDir()
+		path := filepath.Join(directory, "sdisk.sock")
+
+		listener, err := infrastructure.ListenUnixSocket(path, 0600)
+
+		assertNoError(t, err)
+		entries, _ := os.ReadDir(directory)
+		if len(entries) != 1 || entries[0].Name() != "sdisk.sock" {
+			t.Fatalf("Expected only the socket in %s, found %v", directory, entries)
+		}
+		conn, err := net.Dial("unix", path)
+		assertNoError(t, err)
+		_ = conn.Close()
+		_ = listener.Close()
+		assertFileDoesNotExist(t, path)
+	})
+
+	t.Run("ReplaceStaleSocket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sdisk.sock")
+		stale, _ := net.Listen("unix", path)
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		_ = stale.Close()
+
+		listener, err := infrastructure.ListenUnixSocket(path, 0600)
+
+		assertNoError(t, err)
+		_ = listener.Close()
+	})
+
+	t.Run("RefuseSocketInUse", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sdisk.sock")
+		running, _ := net.Listen("unix", path)
+		defer running.Close()
+
+		_, err := infrastructure.ListenUnixSocket(path, 0600)
+
+		if _, ok := err.(*infrastructure.ErrSocketInUse); !ok {
+			t.Fatalf("Expected ErrSocketInUse, got %v", err)
+		}
+	})
+
+	t.Run("RefuseToReplaceRegularFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sdisk.sock")
+		_ = os.WriteFile(path, []byte("hello"), 0644)
+
+		_, err := infrastructure.ListenUnixSocket(path, 0600)
+
+		if _, ok := err.(*infrastructure.ErrNotASocket); !ok {
+			t.Fatalf("Expected ErrNotASocket, got %v", err)
+		}
+		assertFileContent(t, path, []byte("hello"))
+	})
+}
@@ -1,13 +1,13 @@
 package infrastructure
 
 import (
-	"fmt"
 	"io"
 	"io/fs"
 	"math"
 	"os"
 	"path/filepath"
-	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/Joey-Boivin/sdisk/internal/models"
 )
@@ -20,7 +20,7 @@ type FileToSend struct {
 func walkDirectory(dirPath string) []FileToSend {
 	var files []FileToSend
 	_ = filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
-		if d != nil && !d.IsDir() {
+		if d != nil && d.Type().IsRegular() {
 			files = append(files, FileToSend{path, d})
 		}
 		return nil
@@ -29,86 +29,127 @@ func walkDirectory(dirPath string) []FileToSend {
 	return files
 }
 
-func sendFile(file *FileToSend, syncPath string, connection *Connection, userID models.UserID) {
+// modifiedAfter reports whether the file was modified after t. A file whose
+// modification time cannot be read is reported as modified.
+func (file *FileToSend) modifiedAfter(t time.Time) bool {
+	info, err := file.entry.Info()
+	if err != nil {
+		return true
+	}
+
+	return info.ModTime().After(t)
+}
+
+func directorySize(dirPath string) (uint64, error) {
+	var size uint64
+	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+
+	return size, err
+}
+
+func sendFile(file *FileToSend, syncPath string, connection *Connection, userID models.UserID) error {
 
 	entry := file.entry
 
 	info, err := entry.Info()
 
-	path := strings.TrimPrefix(file.path, syncPath)
+	if err != nil {
+		return err
+	}
+
+	path, err := RelativeSyncPath(syncPath, file.path)
 
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	hash, err := hashFile(file.path)
+	if err != nil {
+		return err
 	}
 
 	f, err := os.Open(file.path)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	chunksSize := DEFAULT_QUEUE_SIZE_BYTES - HEADER_SIZE - 24 - len(info.Name())
-	chunks := float64(info.Size()) / float64(chunksSize)
-	chunksCeil := int(math.Ceil(chunks))
+	defer f.Close()
 
-	sent := 0
-	read := 0
-	total := int(info.Size())
+	chunksSize := DEFAULT_QUEUE_SIZE_BYTES - HEADER_SIZE - UPDATE_DATA_HEADER_SIZE - len(path) - len(hash)
+	chunks := float64(info.Size()) / float64(chunksSize)
+	chunksCeil := int(math.Max(1, math.Ceil(chunks)))
 
+	// Chunks share the connection, so they are sent one after the other. The
+	// other side writes them at their offset all the same.
 	for i := 0; i < chunksCeil; i++ {
-		fileContentBuffer := make([]byte, int(math.Min(float64(total), float64(chunksSize))))
-
-		if err != nil {
-			panic(err)
+		chunk := UpdateDataPayload{
+			Total:   uint64(info.Size()),
+			Offset:  uint64(i * chunksSize),
+			PathLen: uint64(len(path)),
+			HashLen: uint64(len(hash)),
+			Path:    path,
+			Hash:    hash,
 		}
 
-		read, err = f.Read(fileContentBuffer)
-
+		err := sendChunk(f, &chunk, chunksSize, connection, userID)
 		if err != nil {
-			if err != io.EOF {
-				panic(err)
-			}
+			return err
 		}
+	}
 
-		updatePacket := UpdateDataPayload{
-			Total:    uint64(info.Size()),
-			Offset:   uint64(read),
-			PathLen:  uint64(len(path)),
-			Path:     path,
-			FileData: fileContentBuffer,
-		}
+	return nil
+}
 
-		raw, err := updatePacket.Bytes()
+func sendChunk(f *os.File, chunk *UpdateDataPayload, chunksSize int, connection *Connection, userID models.UserID) error {
+	fileContentBuffer := make([]byte, int(math.Min(float64(chunk.Total-chunk.Offset), float64(chunksSize))))
 
-		if err != nil {
-			panic(err)
-		}
+	read, err := f.ReadAt(fileContentBuffer, int64(chunk.Offset))
 
-		header := PacketHeader{
-			DataSize: uint16(len(raw)),
-			Version:  VERSION,
-			Encoding: EncodingNone,
-			Opcode:   UpdateData,
-		}
+	if err != nil && err != io.EOF {
+		return err
+	}
 
-		idAsBytes := userID.Bytes()
-		copy(header.id[:], idAsBytes)
+	chunk.FileData = fileContentBuffer[:read]
+	raw, err := chunk.Bytes()
 
-		packet := Packet{
-			Header:  header,
-			Payload: raw,
-		}
+	if err != nil {
+		return err
+	}
 
-		toSend := packet.Bytes()
-		wrote, err := connection.Write(toSend)
-		fmt.Printf("wrote %d\n", wrote)
-		sent += wrote
+	packet, err := newPacket(UpdateData, userID.Bytes(), raw)
+	if err != nil {
+		return err
+	}
 
-		updatePacket.Offset += uint64(wrote)
+	wrote, err := connection.Write(packet.Bytes())
+	connection.logger.Debug("sent chunk", "user", userID.ToString(), "path", chunk.Path, "offset", chunk.Offset, "bytes", wrote)
 
-		if err != nil {
-			panic(err)
-		}
+	return err
+}
 
-		total -= read
+// truncate returns the longest prefix of s that fits in size bytes without
+// splitting a UTF-8 sequence.
+func truncate(s string, size int) string {
+	if len(s) <= size {
+		return s
 	}
+
+	for size > 0 && !utf8.RuneStart(s[size]) {
+		size--
+	}
+
+	return s[:size]
 }
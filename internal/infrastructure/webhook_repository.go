@@ -0,0 +1,220 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+const (
+	WEBHOOKS_DIRECTORY_NAME = ".sdisk-webhooks"
+	WEBHOOKS_FILE_NAME      = "webhooks.json"
+)
+
+// FileWebhookRepository keeps the registered webhooks in memory and saves all
+// of them to a single file on every change.
+type FileWebhookRepository struct {
+	path     string
+	webhooks map[string]*models.Webhook
+	mutex    sync.RWMutex
+}
+
+type webhookRecord struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	UserID    string    `json:"userId,omitempty"`
+	Events    []string  `json:"events,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func NewFileWebhookRepository(path string) (*FileWebhookRepository, error) {
+	repo := FileWebhookRepository{
+		path:     path,
+		webhooks: make(map[string]*models.Webhook),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &repo, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var records []webhookRecord
+	err = json.Unmarshal(data, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		webhook, err := record.toWebhook()
+		if err != nil {
+			return nil, err
+		}
+
+		repo.webhooks[webhook.ID] = webhook
+	}
+
+	return &repo, nil
+}
+
+func (r *FileWebhookRepository) SaveWebhook(w *models.Webhook) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	previous := r.webhooks[w.ID]
+	r.webhooks[w.ID] = w
+
+	err := r.save()
+	if err != nil {
+		r.restore(w.ID, previous)
+	}
+
+	return err
+}
+
+func (r *FileWebhookRepository) DeleteWebhook(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	previous := r.webhooks[id]
+	delete(r.webhooks, id)
+
+	err := r.save()
+	if err != nil {
+		r.restore(id, previous)
+	}
+
+	return err
+}
+
+func (r *FileWebhookRepository) GetWebhook(id string) *models.Webhook {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.webhooks[id]
+}
+
+func (r *FileWebhookRepository) ListWebhooks() []*models.Webhook {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	webhooks := make([]*models.Webhook, 0, len(r.webhooks))
+	for _, webhook := range r.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+
+	return webhooks
+}
+
+func (r *FileWebhookRepository) restore(id string, previous *models.Webhook) {
+	if previous == nil {
+		delete(r.webhooks, id)
+		return
+	}
+
+	r.webhooks[id] = previous
+}
+
+func (r *FileWebhookRepository) save() error {
+	records := make([]webhookRecord, 0, len(r.webhooks))
+	for _, webhook := range r.webhooks {
+		records = append(records, toWebhookRecord(webhook))
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomically(r.path, data, 0600)
+}
+
+func toWebhookRecord(w *models.Webhook) webhookRecord {
+	record := webhookRecord{
+		ID:        w.ID,
+		URL:       w.URL,
+		Secret:    w.Secret,
+		CreatedAt: w.CreatedAt,
+	}
+
+	if w.UserID != nil {
+		record.UserID = w.UserID.ToString()
+	}
+
+	for _, event := range w.Events {
+		record.Events = append(record.Events, string(event))
+	}
+
+	return record
+}
+
+func (record webhookRecord) toWebhook() (*models.Webhook, error) {
+	webhook := models.Webhook{
+		ID:        record.ID,
+		URL:       record.URL,
+		Secret:    record.Secret,
+		CreatedAt: record.CreatedAt,
+	}
+
+	if record.UserID != "" {
+		userID, err := models.FromString(record.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		webhook.UserID = &userID
+	}
+
+	for _, event := range record.Events {
+		webhook.Events = append(webhook.Events, models.EventType(event))
+	}
+
+	return &webhook, nil
+}
+
+// writeFileAtomically replaces the file at path with data, so a crash leaves
+// either the old or the new content but never a mix of both.
+func writeFileAtomically(path string, data []byte, perm os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(path), 0777)
+	if err != nil {
+		return err
+	}
+
+	temporaryPath := path + ".tmp"
+	file, err := os.OpenFile(temporaryPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(temporaryPath, path)
+	if err != nil {
+		return err
+	}
+
+	return syncDirectory(filepath.Dir(path))
+}
@@ -0,0 +1,538 @@
+package infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+	"github.com/Joey-Boivin/sdisk/internal/ports"
+	"github.com/google/uuid"
+)
+
+const (
+	WEBHOOK_QUEUE_DIRECTORY_NAME = "queue"
+	WEBHOOK_DELIVERIES_FILE_NAME = "deliveries.jsonl"
+	WEBHOOK_SIGNATURE_HEADER     = "X-Sdisk-Signature"
+	WEBHOOK_TIMESTAMP_HEADER     = "X-Sdisk-Timestamp"
+	WEBHOOK_EVENT_HEADER         = "X-Sdisk-Event"
+	WEBHOOK_DELIVERY_HEADER      = "X-Sdisk-Delivery"
+)
+
+// WebhookDispatcher notifies the registered webhooks of the events published
+// to it. Deliveries are queued on disk first, so they survive a restart, and
+// retried with an exponential backoff until they succeed or run out of
+// attempts. Every attempt is appended to the delivery log, which is rotated
+// once it reaches maxLogSize so only the last two logs are kept.
+type WebhookDispatcher struct {
+	webhooks     ports.WebhookRepository
+	queuePath    string
+	logPath      string
+	client       *http.Client
+	retryBase    time.Duration
+	retryMax     time.Duration
+	maxAttempts  int
+	pollInterval time.Duration
+	maxLogSize   int64
+	events       chan models.Event
+	wake         chan struct{}
+	stop         chan struct{}
+	workers      sync.WaitGroup
+	queueMutex   sync.Mutex
+	logMutex     sync.Mutex
+	logger       *slog.Logger
+}
+
+type WebhookDispatcherConfig struct {
+	webhooks     ports.WebhookRepository
+	root         string
+	timeout      time.Duration
+	retryBase    time.Duration
+	retryMax     time.Duration
+	maxAttempts  int
+	pollInterval time.Duration
+	maxLogSize   int64
+	eventBuffer  int
+}
+
+type queuedDelivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhookId"`
+	EventID       uint64          `json:"eventId"`
+	EventType     string          `json:"eventType"`
+	Body          json.RawMessage `json:"body"`
+	Attempt       int             `json:"attempt"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+}
+
+type deliveryRecord struct {
+	ID         string    `json:"id"`
+	WebhookID  string    `json:"webhookId"`
+	EventID    uint64    `json:"eventId"`
+	EventType  string    `json:"eventType"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Succeeded  bool      `json:"succeeded"`
+	Time       time.Time `json:"time"`
+}
+
+// webhookPayload is the body posted to webhooks.
+type webhookPayload struct {
+	Delivery  string    `json:"delivery"`
+	Event     string    `json:"event"`
+	EventID   uint64    `json:"eventId"`
+	Time      time.Time `json:"time"`
+	UserID    string    `json:"userId"`
+	Email     string    `json:"email,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	Size      uint64    `json:"size,omitempty"`
+	Hash      string    `json:"hash,omitempty"`
+	UsedBytes uint64    `json:"usedBytes,omitempty"`
+	DiskBytes uint64    `json:"diskBytes,omitempty"`
+}
+
+func NewDefaultWebhookDispatcherConfig(webhooks ports.WebhookRepository, root string) *WebhookDispatcherConfig {
+	return &WebhookDispatcherConfig{
+		webhooks:     webhooks,
+		root:         root,
+		timeout:      DEFAULT_WEBHOOK_TIMEOUT_MS * time.Millisecond,
+		retryBase:    DEFAULT_WEBHOOK_RETRY_BASE_MS * time.Millisecond,
+		retryMax:     DEFAULT_WEBHOOK_RETRY_MAX_MS * time.Millisecond,
+		maxAttempts:  DEFAULT_WEBHOOK_MAX_ATTEMPTS,
+		pollInterval: DEFAULT_WEBHOOK_POLL_INTERVAL_MS * time.Millisecond,
+		maxLogSize:   DEFAULT_WEBHOOK_LOG_SIZE_BYTES,
+		eventBuffer:  DEFAULT_WEBHOOK_EVENT_QUEUE_SIZE,
+	}
+}
+
+// SetRetryPolicy overrides the delay before the first retry, the longest delay
+// between two retries and how many attempts are made overall.
+func (config *WebhookDispatcherConfig) SetRetryPolicy(base time.Duration, max time.Duration, maxAttempts int) {
+	config.retryBase = base
+	config.retryMax = max
+	config.maxAttempts = maxAttempts
+}
+
+// SetPollInterval overrides how often the queue is checked for deliveries to
+// retry.
+func (config *WebhookDispatcherConfig) SetPollInterval(interval time.Duration) {
+	config.pollInterval = interval
+}
+
+// SetDeliveryLogSize overrides the size the delivery log is rotated at. A zero
+// value keeps the default.
+func (config *WebhookDispatcherConfig) SetDeliveryLogSize(maxBytes int64) {
+	if maxBytes != 0 {
+		config.maxLogSize = maxBytes
+	}
+}
+
+func NewWebhookDispatcher(config *WebhookDispatcherConfig) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhooks:     config.webhooks,
+		queuePath:    filepath.Join(config.root, WEBHOOK_QUEUE_DIRECTORY_NAME),
+		logPath:      filepath.Join(config.root, WEBHOOK_DELIVERIES_FILE_NAME),
+		client:       &http.Client{Timeout: config.timeout},
+		retryBase:    config.retryBase,
+		retryMax:     config.retryMax,
+		maxAttempts:  config.maxAttempts,
+		pollInterval: config.pollInterval,
+		maxLogSize:   config.maxLogSize,
+		events:       make(chan models.Event, config.eventBuffer),
+		wake:         make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		logger:       slog.Default(),
+	}
+}
+
+// Publish hands event to the dispatcher, which queues a delivery of it for
+// every webhook interested in it. It never blocks: the event is dropped when
+// too many events are waiting to be queued.
+func (d *WebhookDispatcher) Publish(event models.Event) {
+	select {
+	case d.events <- event:
+	default:
+		d.logger.Warn("webhook event dropped, too many events waiting to be queued", "event", event.ID, "type", string(event.Type))
+	}
+}
+
+// queue queues a delivery of event for every webhook interested in it.
+func (d *WebhookDispatcher) queue(event models.Event) {
+	queued := false
+
+	for _, webhook := range d.webhooks.ListWebhooks() {
+		if !webhook.Matches(event) {
+			continue
+		}
+
+		err := d.enqueue(webhook, event)
+		if err != nil {
+			d.logger.Error("could not queue webhook delivery", "webhook", webhook.ID, "event", event.ID, "error", err)
+			continue
+		}
+
+		queued = true
+	}
+
+	if queued {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run queues the events published and delivers the queued deliveries as they
+// come due until Stop is called.
+func (d *WebhookDispatcher) Run() {
+	d.workers.Add(2)
+	go d.receive()
+	go d.dispatch()
+	d.workers.Wait()
+}
+
+// Stop waits for the delivery in progress, if any, and stops Run. The events
+// published but not queued yet are queued, so they are delivered with the
+// other queued deliveries the next time the dispatcher runs.
+func (d *WebhookDispatcher) Stop() {
+	close(d.stop)
+	d.workers.Wait()
+
+	for {
+		select {
+		case event := <-d.events:
+			d.queue(event)
+		default:
+			return
+		}
+	}
+}
+
+func (d *WebhookDispatcher) receive() {
+	defer d.workers.Done()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case event := <-d.events:
+			d.queue(event)
+		}
+	}
+}
+
+func (d *WebhookDispatcher) dispatch() {
+	defer d.workers.Done()
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue()
+
+		select {
+		case <-ticker.C:
+		case <-d.wake:
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// Deliveries returns the attempts to deliver events to the webhook still in
+// the delivery logs, the most recent first.
+func (d *WebhookDispatcher) Deliveries(webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	d.logMutex.Lock()
+	defer d.logMutex.Unlock()
+
+	var deliveries []models.WebhookDelivery
+	for _, path := range []string{d.rotatedLogPath(), d.logPath} {
+		records, err := readDeliveryLog(path, webhookID)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, records...)
+	}
+
+	// The most recent attempts come first.
+	for i, j := 0, len(deliveries)-1; i < j; i, j = i+1, j-1 {
+		deliveries[i], deliveries[j] = deliveries[j], deliveries[i]
+	}
+
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	return deliveries, nil
+}
+
+func readDeliveryLog(path string, webhookID string) ([]models.WebhookDelivery, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var deliveries []models.WebhookDelivery
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		var record deliveryRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil || record.WebhookID != webhookID {
+			continue
+		}
+
+		deliveries = append(deliveries, record.toDelivery())
+	}
+
+	return deliveries, scanner.Err()
+}
+
+func (d *WebhookDispatcher) enqueue(webhook *models.Webhook, event models.Event) error {
+	id := uuid.NewString()
+	body, err := json.Marshal(webhookPayload{
+		Delivery:  id,
+		Event:     string(event.Type),
+		EventID:   event.ID,
+		Time:      event.Time,
+		UserID:    event.UserID.ToString(),
+		Email:     event.Email,
+		Path:      event.Path,
+		Size:      event.Size,
+		Hash:      event.Hash,
+		UsedBytes: event.UsedBytes,
+		DiskBytes: event.DiskBytes,
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.save(&queuedDelivery{
+		ID:            id,
+		WebhookID:     webhook.ID,
+		EventID:       event.ID,
+		EventType:     string(event.Type),
+		Body:          body,
+		NextAttemptAt: time.Now(),
+	})
+}
+
+func (d *WebhookDispatcher) deliverDue() {
+	entries, err := os.ReadDir(d.queuePath)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		delivery, err := d.load(entry.Name())
+		if err != nil || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+
+		d.deliver(delivery)
+	}
+}
+
+func (d *WebhookDispatcher) deliver(delivery *queuedDelivery) {
+	webhook := d.webhooks.GetWebhook(delivery.WebhookID)
+	if webhook == nil {
+		d.remove(delivery)
+		return
+	}
+
+	delivery.Attempt++
+	record := deliveryRecord{
+		ID:        delivery.ID,
+		WebhookID: delivery.WebhookID,
+		EventID:   delivery.EventID,
+		EventType: delivery.EventType,
+		Attempt:   delivery.Attempt,
+		Time:      time.Now().UTC(),
+	}
+
+	statusCode, err := d.post(webhook, delivery)
+	record.StatusCode = statusCode
+	record.Succeeded = err == nil
+
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	d.log(record)
+
+	if err != nil {
+		d.logger.Warn("webhook delivery failed", "webhook", webhook.ID, "delivery", delivery.ID, "event", delivery.EventID, "attempt", delivery.Attempt, "status", statusCode, "error", err)
+	} else {
+		d.logger.Debug("webhook delivered", "webhook", webhook.ID, "delivery", delivery.ID, "event", delivery.EventID, "attempt", delivery.Attempt, "status", statusCode)
+	}
+
+	if record.Succeeded || delivery.Attempt >= d.maxAttempts {
+		d.remove(delivery)
+		return
+	}
+
+	delivery.NextAttemptAt = time.Now().Add(d.backoff(delivery.Attempt))
+	err = d.save(delivery)
+	if err != nil {
+		d.logger.Error("could not reschedule webhook delivery", "webhook", webhook.ID, "delivery", delivery.ID, "error", err)
+	}
+}
+
+func (d *WebhookDispatcher) post(webhook *models.Webhook, delivery *queuedDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WEBHOOK_EVENT_HEADER, delivery.EventType)
+	req.Header.Set(WEBHOOK_DELIVERY_HEADER, delivery.ID)
+	req.Header.Set(WEBHOOK_TIMESTAMP_HEADER, timestamp)
+	req.Header.Set(WEBHOOK_SIGNATURE_HEADER, SignWebhookPayload(webhook.Secret, timestamp, delivery.Body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook answered with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload returns the signature sent along a delivery. Receivers
+// compute the HMAC-SHA256 of the timestamp header, a dot and the body with the
+// secret of the webhook and compare it to the signature header.
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *WebhookDispatcher) backoff(attempt int) time.Duration {
+	delay := d.retryBase
+	for i := 1; i < attempt && delay < d.retryMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, d.retryMax)
+}
+
+func (d *WebhookDispatcher) save(delivery *queuedDelivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	d.queueMutex.Lock()
+	defer d.queueMutex.Unlock()
+
+	return writeFileAtomically(filepath.Join(d.queuePath, delivery.ID+".json"), data, 0600)
+}
+
+func (d *WebhookDispatcher) load(name string) (*queuedDelivery, error) {
+	d.queueMutex.Lock()
+	defer d.queueMutex.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(d.queuePath, name))
+	if err != nil {
+		return nil, err
+	}
+
+	var delivery queuedDelivery
+	err = json.Unmarshal(data, &delivery)
+	return &delivery, err
+}
+
+func (d *WebhookDispatcher) remove(delivery *queuedDelivery) {
+	d.queueMutex.Lock()
+	defer d.queueMutex.Unlock()
+
+	_ = os.Remove(filepath.Join(d.queuePath, delivery.ID+".json"))
+}
+
+func (d *WebhookDispatcher) log(record deliveryRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+
+	d.logMutex.Lock()
+	defer d.logMutex.Unlock()
+
+	err = os.MkdirAll(filepath.Dir(d.logPath), 0777)
+	if err != nil {
+		return
+	}
+
+	info, err := os.Stat(d.logPath)
+	if err == nil && info.Size()+int64(len(line)) >= d.maxLogSize {
+		err = os.Rename(d.logPath, d.rotatedLogPath())
+		if err != nil {
+			d.logger.Error("could not rotate webhook delivery log", "path", d.logPath, "error", err)
+		}
+	}
+
+	file, err := os.OpenFile(d.logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		d.logger.Error("could not log webhook delivery", "webhook", record.WebhookID, "delivery", record.ID, "error", err)
+		return
+	}
+
+	defer file.Close()
+	_, _ = file.Write(append(line, '\n'))
+}
+
+// rotatedLogPath is where the previous delivery log is kept once rotated.
+func (d *WebhookDispatcher) rotatedLogPath() string {
+	return d.logPath + ".1"
+}
+
+func (record deliveryRecord) toDelivery() models.WebhookDelivery {
+	return models.WebhookDelivery{
+		ID:         record.ID,
+		WebhookID:  record.WebhookID,
+		EventID:    record.EventID,
+		EventType:  models.EventType(record.EventType),
+		Attempt:    record.Attempt,
+		StatusCode: record.StatusCode,
+		Error:      record.Error,
+		Succeeded:  record.Succeeded,
+		Time:       record.Time,
+	}
+}
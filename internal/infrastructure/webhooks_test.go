@@ -0,0 +1,205 @@
+package infrastructure_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/infrastructure"
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+type receivedDelivery struct {
+	body      []byte
+	signature string
+	timestamp string
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	anyUserID := models.NewUserID()
+	anySecret := "secret"
+
+	t.Run("PostSignedEvent", func(t *testing.T) {
+		received := make(chan receivedDelivery, 1)
+		receiver := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			received <- receivedDelivery{body, req.Header.Get(infrastructure.WEBHOOK_SIGNATURE_HEADER), req.Header.Get(infrastructure.WEBHOOK_TIMESTAMP_HEADER)}
+		}))
+		defer receiver.Close()
+		dispatcher, _ := newTestDispatcher(t, t.TempDir(), &models.Webhook{ID: "hook", URL: receiver.URL, Secret: anySecret})
+
+		dispatcher.Publish(models.Event{ID: 4, Type: models.EventFileUpdated, UserID: anyUserID, Path: "a.txt"})
+
+		delivery := waitForDelivery(t, received)
+		var payload map[string]any
+		_ = json.Unmarshal(delivery.body, &payload)
+		if payload["event"] != string(models.EventFileUpdated) || payload["path"] != "a.txt" {
+			t.Fatalf("Unexpected payload %s", delivery.body)
+		}
+		if delivery.signature != infrastructure.SignWebhookPayload(anySecret, delivery.timestamp, delivery.body) {
+			t.Fatalf("Signature %s does not match the payload", delivery.signature)
+		}
+	})
+
+	t.Run("SkipEventsOfOtherUsers", func(t *testing.T) {
+		var calls atomic.Int32
+		receiver := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+			calls.Add(1)
+		}))
+		defer receiver.Close()
+		otherUserID := models.NewUserID()
+		dispatcher, _ := newTestDispatcher(t, t.TempDir(), &models.Webhook{ID: "hook", URL: receiver.URL, UserID: &otherUserID})
+
+		dispatcher.Publish(models.Event{ID: 1, Type: models.EventFileUpdated, UserID: anyUserID})
+		time.Sleep(50 * time.Millisecond)
+
+		if calls.Load() != 0 {
+			t.Fatalf("Expected no delivery, got %d", calls.Load())
+		}
+	})
+
+	t.Run("RetryFailedDeliveryAndLogAttempts", func(t *testing.T) {
+		var calls atomic.Int32
+		received := make(chan receivedDelivery, 1)
+		receiver := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+			if calls.Add(1) == 1 {
+				writer.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			received <- receivedDelivery{}
+		}))
+		defer receiver.Close()
+		dispatcher, _ := newTestDispatcher(t, t.TempDir(), &models.Webhook{ID: "hook", URL: receiver.URL})
+
+		dispatcher.Publish(models.Event{ID: 1, Type: models.EventFileDeleted, UserID: anyUserID})
+		waitForDelivery(t, received)
+		time.Sleep(20 * time.Millisecond)
+		deliveries, err := dispatcher.Deliveries("hook", 10)
+
+		assertNoError(t, err)
+		if len(deliveries) != 2 || !deliveries[0].Succeeded || deliveries[1].StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("Unexpected deliveries %+v", deliveries)
+		}
+	})
+
+	t.Run("KeepOnlyLastTwoDeliveryLogs", func(t *testing.T) {
+		var calls atomic.Int32
+		receiver := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+			calls.Add(1)
+			writer.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer receiver.Close()
+		config, _ := newTestDispatcherConfig(t, t.TempDir(), &models.Webhook{ID: "hook", URL: receiver.URL})
+		config.SetDeliveryLogSize(1)
+		dispatcher := runDispatcher(t, config)
+
+		dispatcher.Publish(models.Event{ID: 1, Type: models.EventFileDeleted, UserID: anyUserID})
+		deadline := time.Now().Add(2 * time.Second)
+		for calls.Load() < 3 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+		deliveries, err := dispatcher.Deliveries("hook", 10)
+
+		assertNoError(t, err)
+		if len(deliveries) != 2 || deliveries[0].Attempt != 3 || deliveries[1].Attempt != 2 {
+			t.Fatalf("Expected the last two attempts, got %+v", deliveries)
+		}
+	})
+
+	t.Run("DeliverQueuedEventsAfterRestart", func(t *testing.T) {
+		root := t.TempDir()
+		received := make(chan receivedDelivery, 1)
+		receiver := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+			received <- receivedDelivery{}
+		}))
+		defer receiver.Close()
+		webhook := &models.Webhook{ID: "hook", URL: receiver.URL}
+		repository, _ := infrastructure.NewFileWebhookRepository(filepath.Join(root, infrastructure.WEBHOOKS_FILE_NAME))
+		_ = repository.SaveWebhook(webhook)
+		stopped := infrastructure.NewWebhookDispatcher(infrastructure.NewDefaultWebhookDispatcherConfig(repository, root))
+
+		stopped.Publish(models.Event{ID: 1, Type: models.EventDiskCreated, UserID: anyUserID})
+		stopped.Stop()
+		newTestDispatcher(t, root, webhook)
+
+		waitForDelivery(t, received)
+	})
+}
+
+func TestFileWebhookRepository(t *testing.T) {
+	t.Run("KeepWebhooksAcrossRestarts", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), infrastructure.WEBHOOKS_FILE_NAME)
+		userID := models.NewUserID()
+		repository, _ := infrastructure.NewFileWebhookRepository(path)
+		_ = repository.SaveWebhook(&models.Webhook{ID: "hook", URL: "http://example.com", UserID: &userID, Events: []models.EventType{models.EventFileUpdated}})
+
+		reloaded, err := infrastructure.NewFileWebhookRepository(path)
+
+		assertNoError(t, err)
+		webhook := reloaded.GetWebhook("hook")
+		if webhook == nil || *webhook.UserID != userID || webhook.Events[0] != models.EventFileUpdated {
+			t.Fatalf("Unexpected webhook %+v", webhook)
+		}
+	})
+
+	t.Run("ForgetDeletedWebhooks", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), infrastructure.WEBHOOKS_FILE_NAME)
+		repository, _ := infrastructure.NewFileWebhookRepository(path)
+		_ = repository.SaveWebhook(&models.Webhook{ID: "hook", URL: "http://example.com"})
+
+		_ = repository.DeleteWebhook("hook")
+		reloaded, _ := infrastructure.NewFileWebhookRepository(path)
+
+		if reloaded.GetWebhook("hook") != nil {
+			t.Fatalf("Expected webhook to be deleted")
+		}
+	})
+}
+
+func newTestDispatcher(t *testing.T, root string, webhook *models.Webhook) (*infrastructure.WebhookDispatcher, *infrastructure.FileWebhookRepository) {
+	t.Helper()
+
+	config, repository := newTestDispatcherConfig(t, root, webhook)
+	return runDispatcher(t, config), repository
+}
+
+func newTestDispatcherConfig(t *testing.T, root string, webhook *models.Webhook) (*infrastructure.WebhookDispatcherConfig, *infrastructure.FileWebhookRepository) {
+	t.Helper()
+
+	repository, _ := infrastructure.NewFileWebhookRepository(filepath.Join(root, infrastructure.WEBHOOKS_FILE_NAME))
+	_ = repository.SaveWebhook(webhook)
+	config := infrastructure.NewDefaultWebhookDispatcherConfig(repository, root)
+	config.SetRetryPolicy(time.Millisecond, time.Millisecond, 3)
+	config.SetPollInterval(5 * time.Millisecond)
+
+	return config, repository
+}
+
+func runDispatcher(t *testing.T, config *infrastructure.WebhookDispatcherConfig) *infrastructure.WebhookDispatcher {
+	t.Helper()
+
+	dispatcher := infrastructure.NewWebhookDispatcher(config)
+	go dispatcher.Run()
+	t.Cleanup(dispatcher.Stop)
+
+	return dispatcher
+}
+
+func waitForDelivery(t *testing.T, received chan receivedDelivery) receivedDelivery {
+	t.Helper()
+
+	select {
+	case delivery := <-received:
+		return delivery
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected a delivery")
+	}
+
+	return receivedDelivery{}
+}
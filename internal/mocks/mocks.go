@@ -1,9 +1,15 @@
 package mocks
 
-import "github.com/Joey-Boivin/sdisk/internal/models"
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
 
 type UserRepositoryMock struct {
-	FnSaveUser         func(u *models.User)
+	FnSaveUser         func(u *models.User) error
 	SaveUserCalled     bool
 	SaveUserCalledWith *models.User
 
@@ -14,15 +20,24 @@ type UserRepositoryMock struct {
 	FnGetUserByEmail         func(email string) *models.User
 	GetUserByEmailCalled     bool
 	GetUserByEmailCalledWith string
+
+	FnListUsers     func() []*models.User
+	ListUsersCalled bool
+
+	FnDeleteUser         func(id models.UserID)
+	DeleteUserCalled     bool
+	DeleteUserCalledWith models.UserID
 }
 
-func (r *UserRepositoryMock) SaveUser(u *models.User) {
+func (r *UserRepositoryMock) SaveUser(u *models.User) error {
 	r.SaveUserCalled = true
 	r.SaveUserCalledWith = u
 
 	if r.FnSaveUser != nil {
-		r.FnSaveUser(u)
+		return r.FnSaveUser(u)
 	}
+
+	return nil
 }
 
 func (r *UserRepositoryMock) GetByID(id models.UserID) *models.User {
@@ -46,14 +61,59 @@ func (r *UserRepositoryMock) GetByEmail(email string) *models.User {
 	return nil
 }
 
+func (r *UserRepositoryMock) ListUsers() []*models.User {
+	r.ListUsersCalled = true
+
+	if r.FnListUsers != nil {
+		return r.FnListUsers()
+	}
+
+	return nil
+}
+
+func (r *UserRepositoryMock) DeleteUser(id models.UserID) {
+	r.DeleteUserCalled = true
+	r.DeleteUserCalledWith = id
+
+	if r.FnDeleteUser != nil {
+		r.FnDeleteUser(id)
+	}
+}
+
 type ServerMock struct {
 	FnPrepareDisk             func(d *models.Disk) error
 	PrepareDiskCalled         bool
 	PrepareDiskCalledWithDisk *models.Disk
 	PrepareDiskCalledWithUser *models.User
 
-	FnRun     func()
+	FnRun     func() error
 	RunCalled bool
+
+	FnDisconnectUser         func(u *models.User) error
+	DisconnectUserCalled     bool
+	DisconnectUserCalledWith *models.User
+
+	FnDropSession         func(sessionID string) error
+	DropSessionCalled     bool
+	DropSessionCalledWith []string
+
+	FnDropDevice         func(deviceID string) error
+	DropDeviceCalled     bool
+	DropDeviceCalledWith string
+
+	FnDeleteDisk         func(u *models.User) error
+	DeleteDiskCalled     bool
+	DeleteDiskCalledWith *models.User
+
+	FnSetBandwidthLimits         func(limits models.BandwidthLimits) error
+	SetBandwidthLimitsCalled     bool
+	SetBandwidthLimitsCalledWith models.BandwidthLimits
+
+	FnGetBandwidthStats     func() models.BandwidthStats
+	GetBandwidthStatsCalled bool
+
+	FnServeConn     func(conn net.Conn)
+	ServeConnCalled bool
 }
 
 func (s *ServerMock) PrepareDisk(d *models.Disk, u *models.User) error {
@@ -68,10 +128,550 @@ func (s *ServerMock) PrepareDisk(d *models.Disk, u *models.User) error {
 	return nil
 }
 
-func (s *ServerMock) Run() {
+func (s *ServerMock) Run() error {
 	s.RunCalled = true
 
 	if s.FnRun != nil {
-		s.FnRun()
+		return s.FnRun()
+	}
+
+	return nil
+}
+
+func (s *ServerMock) DisconnectUser(u *models.User) error {
+	s.DisconnectUserCalled = true
+	s.DisconnectUserCalledWith = u
+
+	if s.FnDisconnectUser != nil {
+		return s.FnDisconnectUser(u)
 	}
+
+	return nil
+}
+
+func (s *ServerMock) DropSession(sessionID string) error {
+	s.DropSessionCalled = true
+	s.DropSessionCalledWith = append(s.DropSessionCalledWith, sessionID)
+
+	if s.FnDropSession != nil {
+		return s.FnDropSession(sessionID)
+	}
+
+	return nil
+}
+
+func (s *ServerMock) DropDevice(deviceID string) error {
+	s.DropDeviceCalled = true
+	s.DropDeviceCalledWith = deviceID
+
+	if s.FnDropDevice != nil {
+		return s.FnDropDevice(deviceID)
+	}
+
+	return nil
+}
+
+func (s *ServerMock) DeleteDisk(u *models.User) error {
+	s.DeleteDiskCalled = true
+	s.DeleteDiskCalledWith = u
+
+	if s.FnDeleteDisk != nil {
+		return s.FnDeleteDisk(u)
+	}
+
+	return nil
+}
+
+func (s *ServerMock) SetBandwidthLimits(limits models.BandwidthLimits) error {
+	s.SetBandwidthLimitsCalled = true
+	s.SetBandwidthLimitsCalledWith = limits
+
+	if s.FnSetBandwidthLimits != nil {
+		return s.FnSetBandwidthLimits(limits)
+	}
+
+	return nil
+}
+
+func (s *ServerMock) GetBandwidthStats() models.BandwidthStats {
+	s.GetBandwidthStatsCalled = true
+
+	if s.FnGetBandwidthStats != nil {
+		return s.FnGetBandwidthStats()
+	}
+
+	return models.BandwidthStats{}
+}
+
+func (s *ServerMock) ServeConn(conn net.Conn) {
+	s.ServeConnCalled = true
+
+	if s.FnServeConn != nil {
+		s.FnServeConn(conn)
+	}
+}
+
+type ChangeJournalMock struct {
+	FnAppend         func(userID models.UserID, change models.Change) (models.Change, error)
+	AppendCalled     bool
+	AppendCalledWith models.Change
+
+	FnSince         func(userID models.UserID, cursor uint64) ([]models.Change, uint64, error)
+	SinceCalled     bool
+	SinceCalledWith uint64
+
+	FnCursor     func(userID models.UserID) (uint64, error)
+	CursorCalled bool
+
+	FnLatest     func(userID models.UserID, path string) (models.Change, bool, error)
+	LatestCalled bool
+
+	FnCompact     func(userID models.UserID, deleteRetention time.Duration) error
+	CompactCalled bool
+
+	FnDelete     func(userID models.UserID) error
+	DeleteCalled bool
+}
+
+func (j *ChangeJournalMock) Append(userID models.UserID, change models.Change) (models.Change, error) {
+	j.AppendCalled = true
+	j.AppendCalledWith = change
+
+	if j.FnAppend != nil {
+		return j.FnAppend(userID, change)
+	}
+
+	return change, nil
+}
+
+func (j *ChangeJournalMock) Since(userID models.UserID, cursor uint64) ([]models.Change, uint64, error) {
+	j.SinceCalled = true
+	j.SinceCalledWith = cursor
+
+	if j.FnSince != nil {
+		return j.FnSince(userID, cursor)
+	}
+
+	return nil, cursor, nil
+}
+
+func (j *ChangeJournalMock) Cursor(userID models.UserID) (uint64, error) {
+	j.CursorCalled = true
+
+	if j.FnCursor != nil {
+		return j.FnCursor(userID)
+	}
+
+	return 0, nil
+}
+
+func (j *ChangeJournalMock) Latest(userID models.UserID, path string) (models.Change, bool, error) {
+	j.LatestCalled = true
+
+	if j.FnLatest != nil {
+		return j.FnLatest(userID, path)
+	}
+
+	return models.Change{}, false, nil
+}
+
+func (j *ChangeJournalMock) Compact(userID models.UserID, deleteRetention time.Duration) error {
+	j.CompactCalled = true
+
+	if j.FnCompact != nil {
+		return j.FnCompact(userID, deleteRetention)
+	}
+
+	return nil
+}
+
+func (j *ChangeJournalMock) Delete(userID models.UserID) error {
+	j.DeleteCalled = true
+
+	if j.FnDelete != nil {
+		return j.FnDelete(userID)
+	}
+
+	return nil
+}
+
+type EventStreamMock struct {
+	FnSubscribe         func(userID models.UserID, lastEventID uint64) ([]models.Event, <-chan models.Event, func())
+	SubscribeCalled     bool
+	SubscribeCalledWith uint64
+	ForgetCalled        bool
+	ForgetCalledWith    models.UserID
+}
+
+func (e *EventStreamMock) Subscribe(userID models.UserID, lastEventID uint64) ([]models.Event, <-chan models.Event, func()) {
+	e.SubscribeCalled = true
+	e.SubscribeCalledWith = lastEventID
+
+	if e.FnSubscribe != nil {
+		return e.FnSubscribe(userID, lastEventID)
+	}
+
+	events := make(chan models.Event)
+	close(events)
+	return nil, events, func() {}
+}
+
+func (e *EventStreamMock) Forget(userID models.UserID) {
+	e.ForgetCalled = true
+	e.ForgetCalledWith = userID
+}
+
+type EventPublisherMock struct {
+	FnPublish         func(event models.Event)
+	PublishCalled     bool
+	PublishCalledWith models.Event
+}
+
+func (e *EventPublisherMock) Publish(event models.Event) {
+	e.PublishCalled = true
+	e.PublishCalledWith = event
+
+	if e.FnPublish != nil {
+		e.FnPublish(event)
+	}
+}
+
+type WebhookRepositoryMock struct {
+	FnSaveWebhook         func(w *models.Webhook) error
+	SaveWebhookCalled     bool
+	SaveWebhookCalledWith *models.Webhook
+
+	FnDeleteWebhook         func(id string) error
+	DeleteWebhookCalled     bool
+	DeleteWebhookCalledWith string
+
+	FnGetWebhook func(id string) *models.Webhook
+
+	FnListWebhooks func() []*models.Webhook
+}
+
+func (r *WebhookRepositoryMock) SaveWebhook(w *models.Webhook) error {
+	r.SaveWebhookCalled = true
+	r.SaveWebhookCalledWith = w
+
+	if r.FnSaveWebhook != nil {
+		return r.FnSaveWebhook(w)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepositoryMock) DeleteWebhook(id string) error {
+	r.DeleteWebhookCalled = true
+	r.DeleteWebhookCalledWith = id
+
+	if r.FnDeleteWebhook != nil {
+		return r.FnDeleteWebhook(id)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepositoryMock) GetWebhook(id string) *models.Webhook {
+	if r.FnGetWebhook != nil {
+		return r.FnGetWebhook(id)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepositoryMock) ListWebhooks() []*models.Webhook {
+	if r.FnListWebhooks != nil {
+		return r.FnListWebhooks()
+	}
+
+	return nil
+}
+
+type WebhookDeliveryLogMock struct {
+	FnDeliveries func(webhookID string, limit int) ([]models.WebhookDelivery, error)
+}
+
+func (l *WebhookDeliveryLogMock) Deliveries(webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	if l.FnDeliveries != nil {
+		return l.FnDeliveries(webhookID, limit)
+	}
+
+	return nil, nil
+}
+
+type MetricsMock struct {
+	ObserveHTTPRequestCalled   bool
+	ObserveHTTPRequestRoute    string
+	ObserveHTTPRequestStatus   int
+	ObserveHTTPRequestDuration time.Duration
+	FnWriteText                func(w io.Writer) error
+	WriteTextCalled            bool
+}
+
+func (m *MetricsMock) ObserveHTTPRequest(route string, status int, duration time.Duration) {
+	m.ObserveHTTPRequestCalled = true
+	m.ObserveHTTPRequestRoute = route
+	m.ObserveHTTPRequestStatus = status
+	m.ObserveHTTPRequestDuration = duration
+}
+
+func (m *MetricsMock) WriteText(w io.Writer) error {
+	m.WriteTextCalled = true
+	if m.FnWriteText != nil {
+		return m.FnWriteText(w)
+	}
+
+	return nil
+}
+
+// PasswordHasherMock hashes passwords by prefixing them with "hashed:" unless
+// told otherwise.
+type PasswordHasherMock struct {
+	FnHash         func(password string) (string, error)
+	HashCalled     bool
+	HashCalledWith string
+
+	FnVerify         func(password string, encodedHash string) (bool, bool, error)
+	VerifyCalled     bool
+	VerifyCalledWith string
+}
+
+func (h *PasswordHasherMock) Hash(password string) (string, error) {
+	h.HashCalled = true
+	h.HashCalledWith = password
+
+	if h.FnHash != nil {
+		return h.FnHash(password)
+	}
+
+	return "hashed:" + password, nil
+}
+
+func (h *PasswordHasherMock) Verify(password string, encodedHash string) (bool, bool, error) {
+	h.VerifyCalled = true
+	h.VerifyCalledWith = password
+
+	if h.FnVerify != nil {
+		return h.FnVerify(password, encodedHash)
+	}
+
+	return encodedHash == "hashed:"+password, false, nil
+}
+
+type SessionRepositoryMock struct {
+	FnSaveSession         func(s *models.Session) error
+	SaveSessionCalled     bool
+	SaveSessionCalledWith *models.Session
+
+	FnGetSession         func(id string) *models.Session
+	GetSessionCalledWith string
+
+	FnGetSessionByTokenHash         func(tokenHash string) *models.Session
+	GetSessionByTokenHashCalledWith string
+
+	FnGetSessionByRefreshTokenHash         func(refreshTokenHash string) *models.Session
+	GetSessionByRefreshTokenHashCalledWith string
+
+	FnListSessions         func(userID models.UserID) []*models.Session
+	ListSessionsCalledWith models.UserID
+
+	FnDeleteSession         func(id string) error
+	DeleteSessionCalled     bool
+	DeleteSessionCalledWith []string
+}
+
+func (r *SessionRepositoryMock) SaveSession(s *models.Session) error {
+	r.SaveSessionCalled = true
+	r.SaveSessionCalledWith = s
+
+	if r.FnSaveSession != nil {
+		return r.FnSaveSession(s)
+	}
+
+	return nil
+}
+
+func (r *SessionRepositoryMock) GetSession(id string) *models.Session {
+	r.GetSessionCalledWith = id
+
+	if r.FnGetSession != nil {
+		return r.FnGetSession(id)
+	}
+
+	return nil
+}
+
+func (r *SessionRepositoryMock) GetSessionByTokenHash(tokenHash string) *models.Session {
+	r.GetSessionByTokenHashCalledWith = tokenHash
+
+	if r.FnGetSessionByTokenHash != nil {
+		return r.FnGetSessionByTokenHash(tokenHash)
+	}
+
+	return nil
+}
+
+func (r *SessionRepositoryMock) GetSessionByRefreshTokenHash(refreshTokenHash string) *models.Session {
+	r.GetSessionByRefreshTokenHashCalledWith = refreshTokenHash
+
+	if r.FnGetSessionByRefreshTokenHash != nil {
+		return r.FnGetSessionByRefreshTokenHash(refreshTokenHash)
+	}
+
+	return nil
+}
+
+func (r *SessionRepositoryMock) ListSessions(userID models.UserID) []*models.Session {
+	r.ListSessionsCalledWith = userID
+
+	if r.FnListSessions != nil {
+		return r.FnListSessions(userID)
+	}
+
+	return nil
+}
+
+func (r *SessionRepositoryMock) DeleteSession(id string) error {
+	r.DeleteSessionCalled = true
+	r.DeleteSessionCalledWith = append(r.DeleteSessionCalledWith, id)
+
+	if r.FnDeleteSession != nil {
+		return r.FnDeleteSession(id)
+	}
+
+	return nil
+}
+
+type DeviceRepositoryMock struct {
+	FnSaveDevice         func(d *models.Device) error
+	SaveDeviceCalled     bool
+	SaveDeviceCalledWith *models.Device
+
+	FnGetDevice         func(id string) *models.Device
+	GetDeviceCalledWith string
+
+	FnGetDeviceByCredentialHash         func(credentialHash string) *models.Device
+	GetDeviceByCredentialHashCalledWith string
+
+	FnListDevices         func(userID models.UserID) []*models.Device
+	ListDevicesCalledWith models.UserID
+
+	FnDeleteDevice         func(id string) error
+	DeleteDeviceCalled     bool
+	DeleteDeviceCalledWith string
+}
+
+func (r *DeviceRepositoryMock) SaveDevice(d *models.Device) error {
+	r.SaveDeviceCalled = true
+	r.SaveDeviceCalledWith = d
+
+	if r.FnSaveDevice != nil {
+		return r.FnSaveDevice(d)
+	}
+
+	return nil
+}
+
+func (r *DeviceRepositoryMock) GetDevice(id string) *models.Device {
+	r.GetDeviceCalledWith = id
+
+	if r.FnGetDevice != nil {
+		return r.FnGetDevice(id)
+	}
+
+	return nil
+}
+
+func (r *DeviceRepositoryMock) GetDeviceByCredentialHash(credentialHash string) *models.Device {
+	r.GetDeviceByCredentialHashCalledWith = credentialHash
+
+	if r.FnGetDeviceByCredentialHash != nil {
+		return r.FnGetDeviceByCredentialHash(credentialHash)
+	}
+
+	return nil
+}
+
+func (r *DeviceRepositoryMock) ListDevices(userID models.UserID) []*models.Device {
+	r.ListDevicesCalledWith = userID
+
+	if r.FnListDevices != nil {
+		return r.FnListDevices(userID)
+	}
+
+	return nil
+}
+
+func (r *DeviceRepositoryMock) DeleteDevice(id string) error {
+	r.DeleteDeviceCalled = true
+	r.DeleteDeviceCalledWith = id
+
+	if r.FnDeleteDevice != nil {
+		return r.FnDeleteDevice(id)
+	}
+
+	return nil
+}
+
+type OneTimeTokenRepositoryMock struct {
+	FnSaveToken         func(t *models.OneTimeToken) error
+	SaveTokenCalled     bool
+	SaveTokenCalledWith *models.OneTimeToken
+
+	FnGetToken         func(hash string) *models.OneTimeToken
+	GetTokenCalledWith string
+
+	FnDeleteTokens         func(userID models.UserID, purpose models.TokenPurpose) error
+	DeleteTokensCalled     bool
+	DeleteTokensCalledWith models.TokenPurpose
+}
+
+func (r *OneTimeTokenRepositoryMock) SaveToken(t *models.OneTimeToken) error {
+	r.SaveTokenCalled = true
+	r.SaveTokenCalledWith = t
+
+	if r.FnSaveToken != nil {
+		return r.FnSaveToken(t)
+	}
+
+	return nil
+}
+
+func (r *OneTimeTokenRepositoryMock) GetToken(hash string) *models.OneTimeToken {
+	r.GetTokenCalledWith = hash
+
+	if r.FnGetToken != nil {
+		return r.FnGetToken(hash)
+	}
+
+	return nil
+}
+
+func (r *OneTimeTokenRepositoryMock) DeleteTokens(userID models.UserID, purpose models.TokenPurpose) error {
+	r.DeleteTokensCalled = true
+	r.DeleteTokensCalledWith = purpose
+
+	if r.FnDeleteTokens != nil {
+		return r.FnDeleteTokens(userID, purpose)
+	}
+
+	return nil
+}
+
+type MailerMock struct {
+	FnSend         func(mail models.Mail) error
+	SendCalled     bool
+	SendCalledWith models.Mail
+}
+
+func (m *MailerMock) Send(mail models.Mail) error {
+	m.SendCalled = true
+	m.SendCalledWith = mail
+
+	if m.FnSend != nil {
+		return m.FnSend(mail)
+	}
+
+	return nil
 }
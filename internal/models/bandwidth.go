@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// BandwidthLimits are in bytes per second. Uploads go from the clients to the
+// server and downloads from the server to the clients. A zero limit means
+// unlimited.
+type BandwidthLimits struct {
+	Upload       uint64
+	Download     uint64
+	UserUpload   uint64
+	UserDownload uint64
+}
+
+type TrafficStats struct {
+	Bytes        uint64
+	Throttled    uint64
+	ThrottledFor time.Duration
+}
+
+type BandwidthStats struct {
+	Limits   BandwidthLimits
+	Upload   TrafficStats
+	Download TrafficStats
+	Users    map[string]UserBandwidthStats
+}
+
+type UserBandwidthStats struct {
+	Upload   TrafficStats
+	Download TrafficStats
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type ChangeOperation string
+
+const (
+	ChangeUpdate ChangeOperation = "update"
+	ChangeDelete ChangeOperation = "delete"
+)
+
+// Change is an entry of the journal of a user. Seq increases with every change
+// applied to the disk of the user and is the cursor clients resume from.
+type Change struct {
+	Seq    uint64
+	Op     ChangeOperation
+	Path   string
+	Size   uint64
+	Hash   string
+	Device string
+	Time   time.Time
+}
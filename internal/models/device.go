@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Device is a sync client registered by a user. It connects with a credential
+// of which only the SHA-256 is kept, until it is revoked. LastSeenAt and
+// Version are updated whenever it connects or disconnects.
+type Device struct {
+	ID             string
+	UserID         UserID
+	Name           string
+	CredentialHash string
+	Version        string
+	CreatedAt      time.Time
+	LastSeenAt     time.Time
+}
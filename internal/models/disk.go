@@ -1,7 +1,14 @@
 package models
 
+import "sync"
+
+const BytesPerMiB = 1024 * 1024
+
 type Disk struct {
 	totalSize uint64
+	usedBytes uint64
+	measured  bool
+	mutex     sync.Mutex
 }
 
 func NewDisk(size uint64) *Disk {
@@ -10,6 +17,84 @@ func NewDisk(size uint64) *Disk {
 	}
 }
 
+// GetSpaceLeft returns the free space in MiB, rounded down.
 func (d *Disk) GetSpaceLeft() uint64 {
+	return d.GetBytesLeft() / BytesPerMiB
+}
+
+func (d *Disk) GetTotalSize() uint64 {
 	return d.totalSize
 }
+
+func (d *Disk) GetTotalBytes() uint64 {
+	return d.totalSize * BytesPerMiB
+}
+
+func (d *Disk) GetUsedBytes() uint64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.usedBytes
+}
+
+func (d *Disk) GetBytesLeft() uint64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.bytesLeft()
+}
+
+// Allocate accounts for bytes about to be written on the disk. Nothing is
+// accounted for if the disk does not have enough space left.
+func (d *Disk) Allocate(bytes uint64) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if bytes > d.bytesLeft() {
+		return &ErrDiskQuotaExceeded{Requested: bytes, Available: d.bytesLeft()}
+	}
+
+	d.usedBytes += bytes
+	return nil
+}
+
+// Release accounts for bytes removed from the disk.
+func (d *Disk) Release(bytes uint64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if bytes > d.usedBytes {
+		d.usedBytes = 0
+		return
+	}
+
+	d.usedBytes -= bytes
+}
+
+// SetUsedBytes replaces the accounted usage, typically with what was measured
+// on the file system.
+func (d *Disk) SetUsedBytes(bytes uint64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.usedBytes = bytes
+	d.measured = true
+}
+
+// IsMeasured reports whether the usage was set with SetUsedBytes. Until then,
+// only what was allocated since the disk was created is accounted for.
+func (d *Disk) IsMeasured() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.measured
+}
+
+func (d *Disk) bytesLeft() uint64 {
+	total := d.totalSize * BytesPerMiB
+	if d.usedBytes >= total {
+		return 0
+	}
+
+	return total - d.usedBytes
+}
@@ -0,0 +1,80 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestAllocate(t *testing.T) {
+	anyDiskSizeInMiB := uint64(2)
+
+	t.Run("ReturnNoErrorIfEnoughSpaceLeft", func(t *testing.T) {
+		disk := models.NewDisk(anyDiskSizeInMiB)
+
+		err := disk.Allocate(models.BytesPerMiB)
+
+		assertNoError(t, err)
+	})
+
+	t.Run("ReduceSpaceLeftAfterAllocation", func(t *testing.T) {
+		disk := models.NewDisk(anyDiskSizeInMiB)
+
+		_ = disk.Allocate(models.BytesPerMiB)
+
+		assertDiskSpaceEquals(t, disk.GetSpaceLeft(), 1)
+		assertDiskSpaceEquals(t, disk.GetUsedBytes(), models.BytesPerMiB)
+	})
+
+	t.Run("ReturnErrDiskQuotaExceededIfNotEnoughSpaceLeft", func(t *testing.T) {
+		disk := models.NewDisk(anyDiskSizeInMiB)
+
+		err := disk.Allocate(disk.GetTotalBytes() + 1)
+
+		assertError(t, err)
+		assertDiskSpaceEquals(t, disk.GetUsedBytes(), 0)
+	})
+}
+
+func TestRelease(t *testing.T) {
+	anyDiskSizeInMiB := uint64(2)
+
+	t.Run("IncreaseSpaceLeftAfterRelease", func(t *testing.T) {
+		disk := models.NewDisk(anyDiskSizeInMiB)
+		_ = disk.Allocate(models.BytesPerMiB)
+
+		disk.Release(models.BytesPerMiB)
+
+		assertDiskSpaceEquals(t, disk.GetSpaceLeft(), anyDiskSizeInMiB)
+	})
+
+	t.Run("NeverGoBelowZeroUsedBytes", func(t *testing.T) {
+		disk := models.NewDisk(anyDiskSizeInMiB)
+
+		disk.Release(models.BytesPerMiB)
+
+		assertDiskSpaceEquals(t, disk.GetUsedBytes(), 0)
+	})
+}
+
+func TestSetUsedBytes(t *testing.T) {
+	t.Run("ReportNoSpaceLeftIfUsageIsOverQuota", func(t *testing.T) {
+		disk := models.NewDisk(1)
+
+		disk.SetUsedBytes(2 * models.BytesPerMiB)
+
+		assertDiskSpaceEquals(t, disk.GetBytesLeft(), 0)
+	})
+
+	t.Run("MarkDiskAsMeasured", func(t *testing.T) {
+		disk := models.NewDisk(1)
+		_ = disk.Allocate(1)
+		measuredBefore := disk.IsMeasured()
+
+		disk.SetUsedBytes(0)
+
+		if measuredBefore || !disk.IsMeasured() {
+			t.Fatalf("Expected the disk to be measured only once its usage is set")
+		}
+	})
+}
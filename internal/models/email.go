@@ -0,0 +1,34 @@
+package models
+
+import (
+	"net/mail"
+	"strings"
+)
+
+const MAX_EMAIL_LENGTH = 254
+
+// NormalizeEmail returns email without surrounding spaces and in lower case,
+// so the same address cannot be registered twice with different cases.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CheckEmail returns an ErrInvalidEmail unless email is a bare address, such
+// as john@example.com, that mail can be sent to.
+func CheckEmail(email string) error {
+	if len(email) > MAX_EMAIL_LENGTH {
+		return &ErrInvalidEmail{Email: email}
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != email {
+		return &ErrInvalidEmail{Email: email}
+	}
+
+	_, domain, _ := strings.Cut(email, "@")
+	if domain == "" || strings.HasPrefix(domain, "[") {
+		return &ErrInvalidEmail{Email: email}
+	}
+
+	return nil
+}
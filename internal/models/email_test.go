@@ -0,0 +1,35 @@
+package models_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestCheckEmail(t *testing.T) {
+	t.Run("AcceptBareAddress", func(t *testing.T) {
+		for _, email := range []string{"John_doe@test.com", "admin@localhost", "a.b+c@mail.example.org"} {
+			assertNoError(t, models.CheckEmail(email))
+		}
+	})
+
+	t.Run("RejectWhatIsNotABareAddress", func(t *testing.T) {
+		invalid := []string{"", "john", "john@", "@test.com", "John <john@test.com>", " john@test.com", "john@[127.0.0.1]", strings.Repeat("a", 250) + "@test.com"}
+		for _, email := range invalid {
+			if _, ok := models.CheckEmail(email).(*models.ErrInvalidEmail); !ok {
+				t.Errorf("Expected ErrInvalidEmail for %q", email)
+			}
+		}
+	})
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	t.Run("TrimSpacesAndLowerCase", func(t *testing.T) {
+		normalized := models.NormalizeEmail("  John_Doe@Test.COM\n")
+
+		if normalized != "john_doe@test.com" {
+			t.Fatalf("Expected john_doe@test.com, got %q", normalized)
+		}
+	})
+}
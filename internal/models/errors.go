@@ -26,3 +26,56 @@ type ErrInvalidID struct {
 func (e *ErrInvalidID) Error() string {
 	return fmt.Sprintf("the id %s is not valid", e.invalidID)
 }
+
+type ErrDiskQuotaExceeded struct {
+	Requested uint64
+	Available uint64
+}
+
+func (e *ErrDiskQuotaExceeded) Error() string {
+	return fmt.Sprintf("disk quota exceeded: %d bytes requested but only %d bytes available", e.Requested, e.Available)
+}
+
+// ErrCursorExpired means the changes since Cursor cannot be listed, because
+// some of them were compacted away or the cursor is unknown. The client has to
+// synchronize the whole disk again.
+type ErrCursorExpired struct {
+	Cursor uint64
+}
+
+func (e *ErrCursorExpired) Error() string {
+	return fmt.Sprintf("cannot resume from cursor %d", e.Cursor)
+}
+
+type ErrWeakPassword struct {
+	Reason string
+}
+
+func (e *ErrWeakPassword) Error() string {
+	return fmt.Sprintf("password is too weak: %s", e.Reason)
+}
+
+type ErrInvalidRole struct {
+	Role string
+}
+
+func (e *ErrInvalidRole) Error() string {
+	return fmt.Sprintf("unknown role %q", e.Role)
+}
+
+// ErrEmailTaken means another user already has the email address.
+type ErrEmailTaken struct {
+	Email string
+}
+
+func (e *ErrEmailTaken) Error() string {
+	return fmt.Sprintf("%s is already used by another user", e.Email)
+}
+
+type ErrInvalidEmail struct {
+	Email string
+}
+
+func (e *ErrInvalidEmail) Error() string {
+	return fmt.Sprintf("%q is not a valid email address", e.Email)
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+type EventType string
+
+const (
+	EventUserRegistered EventType = "user.registered"
+	EventFileUpdated    EventType = "file.updated"
+	EventFileDeleted    EventType = "file.deleted"
+	EventDiskCreated    EventType = "disk.created"
+	EventQuotaWarning   EventType = "quota.warning"
+	EventQuotaExceeded  EventType = "quota.exceeded"
+)
+
+// EventTypes lists every type of event.
+var EventTypes = []EventType{
+	EventUserRegistered,
+	EventFileUpdated,
+	EventFileDeleted,
+	EventDiskCreated,
+	EventQuotaWarning,
+	EventQuotaExceeded,
+}
+
+// QuotaWarningRatio is the share of a disk that has to be used for a quota
+// warning to be raised.
+const QuotaWarningRatio = 0.9
+
+// Event is something that happened to the disk of a user. ID increases with
+// every event published, across restarts as well, and is the id clients resume
+// a feed from.
+type Event struct {
+	ID        uint64
+	Type      EventType
+	UserID    UserID
+	Email     string
+	Time      time.Time
+	Path      string
+	Size      uint64
+	Hash      string
+	UsedBytes uint64
+	DiskBytes uint64
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+type TokenPurpose string
+
+const (
+	TokenPurposeVerifyEmail   TokenPurpose = "verify-email"
+	TokenPurposeResetPassword TokenPurpose = "reset-password"
+)
+
+// OneTimeToken is mailed to a user to prove they own their email address. It
+// only works for its purpose, once, until ExpiresAt. Only the SHA-256 of the
+// token is kept.
+type OneTimeToken struct {
+	Hash      string
+	UserID    UserID
+	Purpose   TokenPurpose
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func (t *OneTimeToken) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DEFAULT_MIN_PASSWORD_LENGTH = 8
+	DEFAULT_MAX_PASSWORD_LENGTH = 128
+)
+
+// PasswordPolicy decides which passwords users may choose. Lengths are
+// counted in characters.
+type PasswordPolicy struct {
+	minLength int
+	maxLength int
+}
+
+func NewDefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		minLength: DEFAULT_MIN_PASSWORD_LENGTH,
+		maxLength: DEFAULT_MAX_PASSWORD_LENGTH,
+	}
+}
+
+// SetLengths overrides the shortest and longest passwords accepted. A zero
+// value keeps the default.
+func (p *PasswordPolicy) SetLengths(minLength int, maxLength int) {
+	if minLength != 0 {
+		p.minLength = minLength
+	}
+
+	if maxLength != 0 {
+		p.maxLength = maxLength
+	}
+}
+
+// Check returns an ErrWeakPassword when password may not be chosen by the
+// user signing in with email.
+func (p *PasswordPolicy) Check(email string, password string) error {
+	length := utf8.RuneCountInString(password)
+
+	if length < p.minLength {
+		return &ErrWeakPassword{Reason: fmt.Sprintf("it must be at least %d characters long", p.minLength)}
+	}
+
+	if length > p.maxLength {
+		return &ErrWeakPassword{Reason: fmt.Sprintf("it must be at most %d characters long", p.maxLength)}
+	}
+
+	if strings.TrimSpace(password) == "" {
+		return &ErrWeakPassword{Reason: "it must not be blank"}
+	}
+
+	if strings.EqualFold(password, email) {
+		return &ErrWeakPassword{Reason: "it must not be the email address"}
+	}
+
+	return nil
+}
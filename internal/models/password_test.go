@@ -0,0 +1,66 @@
+package models_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	anyUserEmail := "EMAIL@TEST.com"
+	policy := models.NewDefaultPasswordPolicy()
+
+	t.Run("AcceptLongEnoughPassword", func(t *testing.T) {
+		err := policy.Check(anyUserEmail, "correct horse")
+
+		assertNoError(t, err)
+	})
+
+	t.Run("RejectShortPassword", func(t *testing.T) {
+		err := policy.Check(anyUserEmail, "12345")
+
+		assertWeakPassword(t, err)
+	})
+
+	t.Run("CountCharactersRatherThanBytes", func(t *testing.T) {
+		err := policy.Check(anyUserEmail, "ééééééé")
+
+		assertWeakPassword(t, err)
+	})
+
+	t.Run("RejectLongPassword", func(t *testing.T) {
+		err := policy.Check(anyUserEmail, strings.Repeat("a", models.DEFAULT_MAX_PASSWORD_LENGTH+1))
+
+		assertWeakPassword(t, err)
+	})
+
+	t.Run("RejectBlankPassword", func(t *testing.T) {
+		err := policy.Check(anyUserEmail, strings.Repeat(" ", 10))
+
+		assertWeakPassword(t, err)
+	})
+
+	t.Run("RejectEmailAsPassword", func(t *testing.T) {
+		err := policy.Check(anyUserEmail, strings.ToLower(anyUserEmail))
+
+		assertWeakPassword(t, err)
+	})
+
+	t.Run("UseConfiguredMinimumLength", func(t *testing.T) {
+		policy := models.NewDefaultPasswordPolicy()
+		policy.SetLengths(16, 0)
+
+		err := policy.Check(anyUserEmail, "correct horse")
+
+		assertWeakPassword(t, err)
+	})
+}
+
+func assertWeakPassword(t *testing.T, err error) {
+	t.Helper()
+
+	if _, ok := err.(*models.ErrWeakPassword); !ok {
+		t.Fatalf("Expected ErrWeakPassword, got %v", err)
+	}
+}
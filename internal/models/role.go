@@ -0,0 +1,23 @@
+package models
+
+import "slices"
+
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+var Roles = []Role{
+	RoleUser,
+	RoleAdmin,
+}
+
+func ParseRole(role string) (Role, error) {
+	if !slices.Contains(Roles, Role(role)) {
+		return "", &ErrInvalidRole{Role: role}
+	}
+
+	return Role(role), nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Session lets a user call the API with a short-lived access token, and trade
+// its refresh token for new tokens until ExpiresAt. Only the SHA-256 of the
+// tokens is kept, so a leaked store does not leak tokens. The refresh token
+// replaced by the last refresh is remembered to detect its reuse.
+type Session struct {
+	ID                       string
+	UserID                   UserID
+	TokenHash                string
+	RefreshTokenHash         string
+	PreviousRefreshTokenHash string
+	CreatedAt                time.Time
+	RefreshedAt              time.Time
+	AccessExpiresAt          time.Time
+	ExpiresAt                time.Time
+}
+
+// Expired reports whether the session is over and can no longer be
+// refreshed.
+func (s *Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// AccessExpired reports whether the current access token must be refreshed.
+func (s *Session) AccessExpired(now time.Time) bool {
+	return s.Expired(now) || !now.Before(s.AccessExpiresAt)
+}
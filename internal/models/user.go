@@ -1,6 +1,10 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type UserID struct {
 	id uuid.UUID
@@ -42,18 +46,27 @@ func FromBytes(id []byte) (UserID, error) {
 }
 
 type User struct {
-	id       UserID
-	email    string
-	password string
-	disk     *Disk
+	id            UserID
+	email         string
+	emailVerified bool
+	passwordHash  string
+	role          Role
+	disk          *Disk
+	deletedAt     time.Time
 }
 
-func NewUser(email string, password string) *User {
+// NewUser returns a user signing in with the password encoded in
+// passwordHash. The password itself is never kept. Users are created with
+// RoleUser and an email address that is not verified yet.
+func NewUser(email string, passwordHash string) *User {
 	return &User{
 		NewUserID(),
 		email,
-		password,
+		false,
+		passwordHash,
+		RoleUser,
 		nil,
+		time.Time{},
 	}
 }
 
@@ -65,8 +78,68 @@ func (u *User) GetEmail() string {
 	return u.email
 }
 
-func (u *User) GetPassword() string {
-	return u.password
+// ChangeEmail replaces the email address of the user, which must then be
+// verified again. email must already be normalized.
+func (u *User) ChangeEmail(email string) error {
+	err := CheckEmail(email)
+	if err != nil {
+		return err
+	}
+
+	if email == u.email {
+		return nil
+	}
+
+	u.email = email
+	u.emailVerified = false
+	return nil
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.emailVerified
+}
+
+func (u *User) SetEmailVerified(verified bool) {
+	u.emailVerified = verified
+}
+
+func (u *User) GetPasswordHash() string {
+	return u.passwordHash
+}
+
+func (u *User) SetPasswordHash(passwordHash string) {
+	u.passwordHash = passwordHash
+}
+
+func (u *User) GetRole() Role {
+	return u.role
+}
+
+func (u *User) SetRole(role Role) {
+	u.role = role
+}
+
+func (u *User) IsAdmin() bool {
+	return u.role == RoleAdmin
+}
+
+// IsDeleted reports whether the account was deleted and waits to be purged.
+func (u *User) IsDeleted() bool {
+	return !u.deletedAt.IsZero()
+}
+
+func (u *User) GetDeletedAt() time.Time {
+	return u.deletedAt
+}
+
+// MarkDeleted deletes the account at the given time. It is kept until it is
+// purged so the deletion can be cancelled with Restore.
+func (u *User) MarkDeleted(at time.Time) {
+	u.deletedAt = at
+}
+
+func (u *User) Restore() {
+	u.deletedAt = time.Time{}
 }
 
 func (u *User) GetDiskSpaceLeft() (uint64, error) {
@@ -2,6 +2,7 @@ package models_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/Joey-Boivin/sdisk/internal/models"
 )
@@ -51,6 +52,80 @@ func TestGetDiskSpaceLeft(t *testing.T) {
 	})
 }
 
+func TestChangeEmail(t *testing.T) {
+	anyUserPassword := "12345"
+
+	t.Run("ChangeEmailAndUnverifyIt", func(t *testing.T) {
+		user := models.NewUser("email@test.com", anyUserPassword)
+		user.SetEmailVerified(true)
+
+		err := user.ChangeEmail("other@test.com")
+
+		assertNoError(t, err)
+		if user.GetEmail() != "other@test.com" || user.IsEmailVerified() {
+			t.Fatalf("Expected the new unverified email, got %s", user.GetEmail())
+		}
+	})
+
+	t.Run("KeepVerificationOfSameEmail", func(t *testing.T) {
+		user := models.NewUser("email@test.com", anyUserPassword)
+		user.SetEmailVerified(true)
+
+		err := user.ChangeEmail("email@test.com")
+
+		assertNoError(t, err)
+		if !user.IsEmailVerified() {
+			t.Fatalf("Expected the email to stay verified")
+		}
+	})
+
+	t.Run("ReturnErrorForInvalidEmail", func(t *testing.T) {
+		user := models.NewUser("email@test.com", anyUserPassword)
+
+		err := user.ChangeEmail("not an email")
+
+		assertError(t, err)
+		if user.GetEmail() != "email@test.com" {
+			t.Fatalf("Expected the email to stay the same, got %s", user.GetEmail())
+		}
+	})
+}
+
+func TestMarkDeleted(t *testing.T) {
+	anyUserEmail := "email@test.com"
+	anyUserPassword := "12345"
+	anyTime := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("NewUserIsNotDeleted", func(t *testing.T) {
+		user := models.NewUser(anyUserEmail, anyUserPassword)
+
+		if user.IsDeleted() {
+			t.Fatalf("Expected a new user not to be deleted")
+		}
+	})
+
+	t.Run("UserIsDeletedAtTheGivenTime", func(t *testing.T) {
+		user := models.NewUser(anyUserEmail, anyUserPassword)
+
+		user.MarkDeleted(anyTime)
+
+		if !user.IsDeleted() || !user.GetDeletedAt().Equal(anyTime) {
+			t.Fatalf("Expected the user to be deleted at %s, got %s", anyTime, user.GetDeletedAt())
+		}
+	})
+
+	t.Run("RestoredUserIsNotDeleted", func(t *testing.T) {
+		user := models.NewUser(anyUserEmail, anyUserPassword)
+		user.MarkDeleted(anyTime)
+
+		user.Restore()
+
+		if user.IsDeleted() {
+			t.Fatalf("Expected a restored user not to be deleted")
+		}
+	})
+}
+
 func assertNoError(t *testing.T, err error) {
 	if err != nil {
 		t.Fatalf("Expected no error but there is one")
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Webhook is an URL notified of the events of a user, or of every user when
+// UserID is nil. A webhook without event types is notified of all of them.
+type Webhook struct {
+	ID        string
+	URL       string
+	Secret    string
+	UserID    *UserID
+	Events    []EventType
+	CreatedAt time.Time
+}
+
+// WebhookDelivery is an attempt at notifying a webhook of an event.
+type WebhookDelivery struct {
+	ID         string
+	WebhookID  string
+	EventID    uint64
+	EventType  EventType
+	Attempt    int
+	StatusCode int
+	Error      string
+	Succeeded  bool
+	Time       time.Time
+}
+
+func (w *Webhook) Matches(event Event) bool {
+	if w.UserID != nil && *w.UserID != event.UserID {
+		return false
+	}
+
+	return len(w.Events) == 0 || slices.Contains(w.Events, event.Type)
+}
@@ -1,14 +1,125 @@
 package ports
 
-import "github.com/Joey-Boivin/sdisk/internal/models"
+import (
+	"io"
+	"net"
+	"time"
 
+	"github.com/Joey-Boivin/sdisk/internal/models"
+)
+
+// UserRepository stores the users. SaveUser fails with models.ErrEmailTaken
+// when another user has the email of u, so an address is never shared.
 type UserRepository interface {
-	SaveUser(u *models.User)
+	SaveUser(u *models.User) error
 	GetByID(id models.UserID) *models.User
 	GetByEmail(email string) *models.User
+	ListUsers() []*models.User
+	DeleteUser(id models.UserID)
 }
 
 type RealTimeServer interface {
-	Run()
+	Run() error
 	PrepareDisk(d *models.Disk, user *models.User) error
+	DisconnectUser(user *models.User) error
+	DropSession(sessionID string) error
+	DropDevice(deviceID string) error
+	DeleteDisk(user *models.User) error
+	SetBandwidthLimits(limits models.BandwidthLimits) error
+	GetBandwidthStats() models.BandwidthStats
+	ServeConn(conn net.Conn)
+}
+
+// ChangeJournal records the changes applied to the disk of every user.
+type ChangeJournal interface {
+	Append(userID models.UserID, change models.Change) (models.Change, error)
+	Since(userID models.UserID, cursor uint64) ([]models.Change, uint64, error)
+	Cursor(userID models.UserID) (uint64, error)
+	Latest(userID models.UserID, path string) (models.Change, bool, error)
+	Compact(userID models.UserID, deleteRetention time.Duration) error
+	Delete(userID models.UserID) error
+}
+
+type EventPublisher interface {
+	Publish(event models.Event)
+}
+
+// EventStream lets clients follow the events of a user. Subscribe returns the
+// events kept since lastEventID, then the following ones on the channel until
+// cancel is called or the subscriber falls too far behind. Forget drops the
+// events kept for a user, once they are purged.
+type EventStream interface {
+	Subscribe(userID models.UserID, lastEventID uint64) (replay []models.Event, events <-chan models.Event, cancel func())
+	Forget(userID models.UserID)
+}
+
+type WebhookRepository interface {
+	SaveWebhook(w *models.Webhook) error
+	DeleteWebhook(id string) error
+	GetWebhook(id string) *models.Webhook
+	ListWebhooks() []*models.Webhook
+}
+
+// WebhookDeliveryLog keeps every attempt at delivering an event to a webhook.
+type WebhookDeliveryLog interface {
+	Deliveries(webhookID string, limit int) ([]models.WebhookDelivery, error)
+}
+
+// Metrics records what the server does and writes it in the text format
+// scraped by Prometheus.
+type Metrics interface {
+	ObserveHTTPRequest(route string, status int, duration time.Duration)
+	WriteText(w io.Writer) error
+}
+
+type SessionRepository interface {
+	SaveSession(s *models.Session) error
+	GetSession(id string) *models.Session
+	GetSessionByTokenHash(tokenHash string) *models.Session
+	GetSessionByRefreshTokenHash(refreshTokenHash string) *models.Session
+	ListSessions(userID models.UserID) []*models.Session
+	DeleteSession(id string) error
+}
+
+// AccessTokenVerifier returns the session an access token was issued for, so
+// real-time connections can be tied to the sessions of the API.
+type AccessTokenVerifier interface {
+	VerifyAccessToken(token string) (*models.Session, error)
+}
+
+type DeviceRepository interface {
+	SaveDevice(d *models.Device) error
+	GetDevice(id string) *models.Device
+	GetDeviceByCredentialHash(credentialHash string) *models.Device
+	ListDevices(userID models.UserID) []*models.Device
+	DeleteDevice(id string) error
+}
+
+// DeviceAuthenticator returns the device a credential was issued for, so
+// real-time connections can be tied to it, and records when devices are seen.
+type DeviceAuthenticator interface {
+	AuthenticateDevice(credential string, version string) (*models.Device, error)
+	DeviceSeen(deviceID string) error
+}
+
+// OneTimeTokenRepository keeps the tokens mailed to users, by hash.
+type OneTimeTokenRepository interface {
+	SaveToken(t *models.OneTimeToken) error
+	GetToken(hash string) *models.OneTimeToken
+	DeleteTokens(userID models.UserID, purpose models.TokenPurpose) error
+}
+
+// Mailer sends mail to users, or keeps it for someone to read it in
+// development.
+type Mailer interface {
+	Send(mail models.Mail) error
+}
+
+// PasswordHasher turns passwords into encoded hashes, carrying their salt and
+// parameters, and checks passwords against them. Verify reports when the hash
+// was made with other parameters than the current ones and should be
+// replaced.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password string, encodedHash string) (ok bool, needsRehash bool, err error)
 }